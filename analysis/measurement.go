@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/hscells/cqr"
 	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/metrics"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/groove/stats"
 	"github.com/hscells/transmute/fields"
@@ -82,11 +83,13 @@ func (m MeasurementExecutor) Execute(query pipeline.Query, ss stats.StatisticsSo
 	for i, measurement := range measurements {
 		qHash := hash(query.Query, measurement)
 		if v, err := m.cache.Read(qHash); err == nil && len(v) > 0 {
+			metrics.Cache("measurement", true)
 			bits := binary.BigEndian.Uint64(v)
 			f := math.Float64frombits(bits)
 			results[i] = f
 			continue
 		}
+		metrics.Cache("measurement", false)
 
 		var v float64
 		v, err := measurement.Execute(query, ss)
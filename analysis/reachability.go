@@ -117,6 +117,9 @@ func StringMatchReachability(queries []pipeline.Query, protocols guru.Protocols)
 	return reach
 }
 
+// ConceptMatchReachability finds the concepts of the keywords of queries that are reachable from the sections of
+// their protocols. Concepts are always found with MetaMap, as the protocols are matched by guru; a local concept
+// dictionary (see formulation.ConceptDictionary) is not supported.
 func ConceptMatchReachability(queries []pipeline.Query, protocols guru.Protocols, conceptsBinFile string, client metawrap.HTTPClient) (conceptReachabilityMapping map[string][]ConceptReachability, conceptsNotInTitle map[string]map[string]int, err error) {
 	// Load or create the concept mapping file.
 	var cm conceptMapping
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/alexflint/go-arg"
+	"github.com/blevesearch/bleve"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/guru"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	name    = "bleve_index"
+	version = "19.Oct.2026"
+	author  = "Harry Scells"
+)
+
+type args struct {
+	Index     string   `help:"Path to the bleve index to create or add to" arg:"-i,required"`
+	Format    string   `help:"Format of the input files (medline or xml)" arg:"-f"`
+	Analyser  string   `help:"Analyser to use for titles and abstracts" arg:"-a"`
+	BatchSize int      `help:"Number of documents to index at a time" arg:"-b"`
+	Files     []string `help:"Medline or PubMed XML files to index" arg:"required,positional"`
+}
+
+func (args) Version() string {
+	return version
+}
+
+func (args) Description() string {
+	return fmt.Sprintf(`%s
+@ %s
+# %s`, name, author, version)
+}
+
+// pubmedArticle is the subset of the PubMed XML format needed to construct a Medline document.
+type pubmedArticle struct {
+	MedlineCitation struct {
+		PMID          string `xml:"PMID"`
+		DateCompleted struct {
+			Year  string `xml:"Year"`
+			Month string `xml:"Month"`
+			Day   string `xml:"Day"`
+		} `xml:"DateCompleted"`
+		Article struct {
+			Journal struct {
+				JournalIssue struct {
+					PubDate struct {
+						Year        string `xml:"Year"`
+						Month       string `xml:"Month"`
+						Day         string `xml:"Day"`
+						MedlineDate string `xml:"MedlineDate"`
+					} `xml:"PubDate"`
+				} `xml:"JournalIssue"`
+			} `xml:"Journal"`
+			ArticleTitle string `xml:"ArticleTitle"`
+			Abstract     struct {
+				AbstractText []string `xml:"AbstractText"`
+			} `xml:"Abstract"`
+			PublicationTypeList struct {
+				PublicationType []string `xml:"PublicationType"`
+			} `xml:"PublicationTypeList"`
+		} `xml:"Article"`
+		MeshHeadingList struct {
+			MeshHeading []struct {
+				DescriptorName string `xml:"DescriptorName"`
+			} `xml:"MeshHeading"`
+		} `xml:"MeshHeadingList"`
+	} `xml:"MedlineCitation"`
+	PubmedData struct {
+		History struct {
+			PubMedPubDate []struct {
+				PubStatus string `xml:"PubStatus,attr"`
+				Year      string `xml:"Year"`
+				Month     string `xml:"Month"`
+				Day       string `xml:"Day"`
+			} `xml:"PubMedPubDate"`
+		} `xml:"History"`
+	} `xml:"PubmedData"`
+}
+
+// medlineDocument converts a PubMed XML article into a Medline document.
+func (a pubmedArticle) medlineDocument() guru.MedlineDocument {
+	c := a.MedlineCitation
+	doc := guru.MedlineDocument{
+		PMID: c.PMID,
+		TI:   c.Article.ArticleTitle,
+		AB:   strings.Join(c.Article.Abstract.AbstractText, " "),
+		PT:   c.Article.PublicationTypeList.PublicationType,
+	}
+	pd := c.Article.Journal.JournalIssue.PubDate
+	if len(pd.MedlineDate) > 0 {
+		doc.DP = pd.MedlineDate
+	} else if len(pd.Year) > 0 {
+		doc.DP = strings.TrimSpace(strings.Join([]string{pd.Year, pd.Month, pd.Day}, " "))
+	}
+	for _, d := range a.PubmedData.History.PubMedPubDate {
+		if d.PubStatus != "entrez" {
+			continue
+		}
+		month, err1 := strconv.Atoi(d.Month)
+		day, err2 := strconv.Atoi(d.Day)
+		if len(d.Year) == 4 && err1 == nil && err2 == nil {
+			doc.EDAT = fmt.Sprintf("%s/%02d/%02d", d.Year, month, day)
+		}
+	}
+	if len(c.DateCompleted.Year) > 0 {
+		doc.DCOM = c.DateCompleted.Year + c.DateCompleted.Month + c.DateCompleted.Day
+	}
+	for _, mh := range c.MeshHeadingList.MeshHeading {
+		doc.MH = append(doc.MH, mh.DescriptorName)
+	}
+	return doc
+}
+
+// readXML streams the articles of a PubMed XML file in batches.
+func readXML(r io.Reader, batchSize int, fn func(docs guru.MedlineDocuments) error) error {
+	dec := xml.NewDecoder(r)
+	var docs guru.MedlineDocuments
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "PubmedArticle" {
+			var a pubmedArticle
+			err := dec.DecodeElement(&a, &se)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, a.medlineDocument())
+			if len(docs) >= batchSize {
+				err := fn(docs)
+				if err != nil {
+					return err
+				}
+				docs = guru.MedlineDocuments{}
+			}
+		}
+	}
+	if len(docs) > 0 {
+		return fn(docs)
+	}
+	return nil
+}
+
+// readMedline reads the documents of a Medline text file in batches.
+func readMedline(r io.Reader, batchSize int, fn func(docs guru.MedlineDocuments) error) error {
+	docs := guru.UnmarshalMedline(r)
+	for i := 0; i < len(docs); i += batchSize {
+		j := i + batchSize
+		if j > len(docs) {
+			j = len(docs)
+		}
+		err := fn(docs[i:j])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	var args args
+	args.Format = "medline"
+	args.BatchSize = 1000
+	arg.MustParse(&args)
+
+	var (
+		idx bleve.Index
+		err error
+	)
+	if _, err = os.Stat(args.Index); os.IsNotExist(err) {
+		idx, err = bleve.New(args.Index, stats.NewBleveIndexMapping(args.Analyser))
+	} else {
+		idx, err = bleve.Open(args.Index)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer idx.Close()
+
+	var read func(r io.Reader, batchSize int, fn func(docs guru.MedlineDocuments) error) error
+	switch args.Format {
+	case "medline":
+		read = readMedline
+	case "xml":
+		read = readXML
+	default:
+		log.Fatalf("unknown format %s\n", args.Format)
+	}
+
+	n := 0
+	for _, file := range args.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = read(f, args.BatchSize, func(docs guru.MedlineDocuments) error {
+			n += len(docs)
+			log.Printf("indexing %d documents (%d total)\n", len(docs), n)
+			return stats.IndexMedlineDocuments(idx, docs)
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = f.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	log.Printf("indexed %d documents into %s\n", n, args.Index)
+}
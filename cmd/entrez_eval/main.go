@@ -14,7 +14,6 @@ import (
 	"log"
 	"os"
 	"path"
-	"strings"
 )
 
 var (
@@ -26,7 +25,7 @@ var (
 type args struct {
 	RelevanceGrade   int64    `help:"Minimum level of relevance to consider" arg:"-l"`
 	Evaluation       []string `help:"Which evaluation measures to use" arg:"-e,separate"`
-	ResultHandlers   []string `help:"Which run handlers to use" arg:"-r,separate"`
+	ResultHandlers   []string `help:"Which run handlers to use (e.g. deduplicate, cutoff:100, dates:19900101-20101231, pubtype:!Letter, rrf:other.run)" arg:"-r,separate"`
 	RunOutput        string   `help:"Name of processed run file" arg:"-o"`
 	EvaluationOutput string   `help:"Name of results file" arg:"-q"`
 	Summary          bool     `help:"Only output summary information" arg:"-s"`
@@ -94,10 +93,12 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	resultsHandlers := make(map[string]retrieval.ResultsHandler)
-	evaluationMeasures := make(map[string]eval.Evaluator)
+	resultsHandlers, err := retrieval.ParseResultsHandlers(args.ResultHandlers, e)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	resultsHandlers["deduplicate"] = retrieval.NewDeduplicator(e)
+	evaluationMeasures := make(map[string]eval.Evaluator)
 
 	evaluationMeasures["precision"] = eval.Precision
 	evaluationMeasures["recall"] = eval.Recall
@@ -121,7 +122,7 @@ func main() {
 
 	eval.RelevanceGrade = args.RelevanceGrade
 
-	r, err := os.OpenFile(args.RunFile, os.O_RDONLY, 0664)
+	r, err := output.OpenRun(args.RunFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -143,15 +144,13 @@ func main() {
 	size := 0
 	for k, v := range results.Results {
 		// Process all the results handlers first.
-		for _, h := range args.ResultHandlers {
-			size += v.Len()
-			if handler, ok := resultsHandlers[h]; ok {
-				err := handler.Handle(&v)
-				if err != nil {
-					log.Fatalln(err)
-				}
-				results.Results[k] = v
+		if len(resultsHandlers) > 0 {
+			err := resultsHandlers.Handle(&v)
+			if err != nil {
+				log.Fatalln(err)
 			}
+			results.Results[k] = v
+			size += v.Len()
 		}
 		// Then move on to perform the evaluation.
 		evaluation[k] = make(map[string]float64)
@@ -164,20 +163,7 @@ func main() {
 	}
 
 	if size > 0 {
-		t, err := os.OpenFile(args.RunOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
-		if err != nil {
-			log.Fatalln(err)
-		}
-
-		l := make([]string, size)
-		i := 0
-		for _, list := range results.Results {
-			for _, line := range list {
-				l[i] = line.String()
-			}
-		}
-
-		_, err = t.Write([]byte(strings.Join(l, "\n") + "\n"))
+		err := output.WriteRun(args.RunOutput, results.Results)
 		if err != nil {
 			log.Fatalln(err)
 		}
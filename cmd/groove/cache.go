@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+type cacheCmd struct {
+	Clear  bool     `help:"Remove the contents of the caches"`
+	Caches []string `help:"Which caches to inspect or clear (default: all)" arg:"positional"`
+}
+
+// caches are the directories groove caches to, relative to the user cache directory.
+var caches = map[string]string{
+	"statistics": path.Join("groove", "statistics_cache"),
+	"file":       path.Join("groove", "file_cache"),
+	"rank":       "groove_rank",
+}
+
+func (cmd cacheCmd) run(c config) error {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return err
+	}
+
+	names := cmd.Caches
+	if len(names) == 0 {
+		for name := range caches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	err = lookup("caches", names, func(name string) bool {
+		_, ok := caches[name]
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		p := path.Join(dir, caches[name])
+		if cmd.Clear {
+			err := os.RemoveAll(p)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("cleared %s cache (%s)\n", name, p)
+			continue
+		}
+
+		var files, size int64
+		err := filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files++
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Printf("%s\t%s\t%d files\t%d bytes\n", name, p, files, size)
+	}
+	return nil
+}
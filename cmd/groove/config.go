@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/hscells/groove/analysis"
+	"github.com/hscells/groove/analysis/postqpp"
+	"github.com/hscells/groove/analysis/preqpp"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// config is the configuration shared by every subcommand. The credentials are read from a TOML file in the format:
+//
+//	[entrez]
+//	email = "..."
+//	tool = "..."
+//	key = "..."
+//
+//	[elasticsearch]
+//	hosts = ["http://localhost:9200"]
+//	index = "medline"
+//
+//	[bleve]
+//	index = "medline.bleve"
+type config struct {
+	Entrez struct {
+		Email string `toml:"email"`
+		Tool  string `toml:"tool"`
+		Key   string `toml:"key"`
+	} `toml:"entrez"`
+	Elasticsearch struct {
+		Hosts []string `toml:"hosts"`
+		Index string   `toml:"index"`
+	} `toml:"elasticsearch"`
+	Bleve struct {
+		Index string `toml:"index"`
+	} `toml:"bleve"`
+
+	source  string
+	size    int
+	runName string
+}
+
+// loadConfig reads the configuration file, creating it if it does not exist.
+func loadConfig(file string) (config, error) {
+	var c config
+	if len(file) == 0 {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return c, err
+		}
+		file = path.Join(dir, ".entrez_eval")
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0664)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	_, err = toml.DecodeReader(f, &c)
+	return c, err
+}
+
+// applyArgs overrides the configuration with the global command-line arguments.
+func (c *config) applyArgs(args args) {
+	c.source = "entrez"
+	if len(args.Source) > 0 {
+		c.source = args.Source
+	}
+	c.size = 100000
+	if args.Size > 0 {
+		c.size = args.Size
+	}
+	c.runName = name
+	if len(args.RunName) > 0 {
+		c.runName = args.RunName
+	}
+	eval.RelevanceGrade = args.RelevanceGrade
+}
+
+// searchOptions are the search options for statistics sources.
+func (c config) searchOptions() stats.SearchOptions {
+	return stats.SearchOptions{
+		Size:    c.size,
+		RunName: c.runName,
+	}
+}
+
+// entrez creates an Entrez statistics source using the credentials in the configuration.
+func (c config) entrez() (stats.EntrezStatisticsSource, error) {
+	return stats.NewEntrezStatisticsSource(
+		stats.EntrezTool(c.Entrez.Tool),
+		stats.EntrezAPIKey(c.Entrez.Key),
+		stats.EntrezEmail(c.Entrez.Email),
+		stats.EntrezOptions(c.searchOptions()))
+}
+
+// statisticsSource creates the statistics source that has been configured.
+func (c config) statisticsSource() (stats.StatisticsSource, error) {
+	switch c.source {
+	case "entrez":
+		return c.entrez()
+	case "elasticsearch":
+		return stats.NewElasticsearchStatisticsSource(
+			stats.ElasticsearchHosts(c.Elasticsearch.Hosts...),
+			stats.ElasticsearchIndex(c.Elasticsearch.Index),
+			stats.ElasticsearchSearchOptions(c.searchOptions()))
+	case "bleve":
+		return stats.NewBleveStatisticsSource(
+			stats.BleveIndexPath(c.Bleve.Index),
+			stats.BleveSearchOptions(c.searchOptions()))
+	}
+	return nil, fmt.Errorf("unknown statistics source %s", c.source)
+}
+
+// queriesSource creates a query source for a query format.
+func queriesSource(format string) (query.QueriesSource, error) {
+	switch format {
+	case "medline":
+		return query.NewTransmuteQuerySource(query.MedlineTransmutePipeline), nil
+	case "pubmed":
+		return query.NewTransmuteQuerySource(query.PubMedTransmutePipeline), nil
+	case "cqr":
+		return query.NewTransmuteQuerySource(query.CQRTransmutePipeline), nil
+	case "keyword":
+		return query.NewKeywordQuerySource(fields.TitleAbstract), nil
+	case "tar":
+		return query.TARTask2QueriesSource{}, nil
+	case "tar2017":
+		return query.NewCLEFTARQuerySource(2017, fields.TitleAbstract), nil
+	case "tar2018":
+		return query.NewCLEFTARQuerySource(2018, fields.TitleAbstract), nil
+	case "tar2019":
+		return query.NewCLEFTARQuerySource(2019, fields.TitleAbstract), nil
+	case "trec":
+		return query.NewTRECQuerySource(nil, fields.TitleAbstract), nil
+	case "jsonl":
+		return query.NewJSONLQuerySource(fields.TitleAbstract), nil
+	case "protocol":
+		return query.NewProtocolQuerySource(), nil
+	}
+	return nil, fmt.Errorf("unknown query format %s", format)
+}
+
+// evaluators are the evaluation measures that can be used, keyed by the name used on the command line.
+func evaluators(N float64) map[string]eval.Evaluator {
+	return map[string]eval.Evaluator{
+		"precision":       eval.Precision,
+		"recall":          eval.Recall,
+		"f1":              eval.F1Measure,
+		"f0.5":            eval.F05Measure,
+		"f3":              eval.F3Measure,
+		"nnr":             eval.NNR,
+		"wss":             eval.NewWSSEvaluator(N),
+		"num_ret":         eval.NumRet,
+		"num_rel":         eval.NumRel,
+		"num_rel_ret":     eval.NumRelRet,
+		"ap":              eval.AP,
+		"p@10":            eval.PrecisionAtK{K: 10},
+		"p@1000":          eval.PrecisionAtK{K: 1000},
+		"ndcg":            eval.NDCG{},
+		"ndcg@5":          eval.NDCG{K: 5},
+		"ndcg@10":         eval.NDCG{K: 10},
+		"ndcg@100":        eval.NDCG{K: 100},
+		"ndcg@200":        eval.NDCG{K: 200},
+		"ndcg@500":        eval.NDCG{K: 500},
+		"relative_recall": eval.RelativeRecall,
+		"screening_hours": eval.ScreeningHours,
+	}
+}
+
+// measurements are the query performance predictors that can be used, keyed by their name.
+func measurements() map[string]analysis.Measurement {
+	m := make(map[string]analysis.Measurement)
+	for _, measurement := range []analysis.Measurement{
+		preqpp.AvgICTF,
+		preqpp.AvgIDF, preqpp.SumIDF, preqpp.MaxIDF, preqpp.StdDevIDF,
+		preqpp.QueryScope,
+		preqpp.RetrievalSize,
+		preqpp.SimplifiedClarityScore,
+		preqpp.SummedCollectionQuerySimilarity, preqpp.MaxCollectionQuerySimilarity, preqpp.AverageCollectionQuerySimilarity,
+		postqpp.ClarityScore,
+		postqpp.NormalisedQueryCommitment,
+		postqpp.WeightedInformationGain, postqpp.WeightedExpansionGain,
+	} {
+		m[measurement.Name()] = measurement
+	}
+	return m
+}
+
+// queryProcessors are the query processors that can be used, keyed by the name used on the command line.
+var queryProcessors = map[string]preprocess.QueryProcessor{
+	"alphanum":         preprocess.AlphaNum,
+	"strip_numbers":    preprocess.StripNumbers,
+	"lowercase":        preprocess.Lowercase,
+	"fold_unicode":     preprocess.FoldUnicode,
+	"porter":           preprocess.PorterStem,
+	"snowball":         preprocess.SnowballStem,
+	"stopwords":        preprocess.RemoveGeneralStopwords,
+	"pubmed_stopwords": preprocess.RemovePubMedStopwords,
+	"american":         preprocess.AmericanSpelling,
+	"british":          preprocess.BritishSpelling,
+}
+
+// booleanTransformations are the Boolean transformations that can be used, keyed by the name used on the command
+// line.
+var booleanTransformations = map[string]preprocess.BooleanTransformation{
+	"simplify":         preprocess.Simplify,
+	"and_simplify":     preprocess.AndSimplify,
+	"or_simplify":      preprocess.OrSimplify,
+	"relax_phrases":    preprocess.RelaxPhrases,
+	"remove_explosion": preprocess.RemoveExplosionMeSH,
+	"rct_filter":       preprocess.RCTFilter,
+	"normalise":        preprocess.Normalise,
+}
+
+// metadataTransformations are the metadata transformations that can be used, keyed by the name used on the command
+// line.
+var metadataTransformations = map[string]preprocess.MetadataTransformation{
+	"date_restrictions": preprocess.MetadataDateRestrictions,
+}
+
+// lookup calls fn for each of the names, returning an error listing the names that fn could not find.
+func lookup(kind string, names []string, fn func(name string) bool) error {
+	var unknown []string
+	for _, n := range names {
+		if !fn(n) {
+			unknown = append(unknown, n)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown %s: %s", kind, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// openOutput opens the file to write output to, or stdout if no file is specified.
+func openOutput(file string) (io.WriteCloser, error) {
+	if len(file) == 0 {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+}
+
+// nopCloser prevents stdout from being closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
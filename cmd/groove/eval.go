@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/output"
+	"github.com/hscells/groove/retrieval"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"gonum.org/v1/gonum/stat"
+	"log"
+	"os"
+)
+
+type evalCmd struct {
+	Evaluation       []string `help:"Which evaluation measures to use" arg:"-e,separate"`
+	ResultHandlers   []string `help:"Which run handlers to use (e.g. deduplicate, cutoff:100, dates:19900101-20101231, pubtype:!Letter, rrf:other.run)" arg:"-r,separate"`
+	RunOutput        string   `help:"Name of processed run file" arg:"-o"`
+	EvaluationOutput string   `help:"Name of results file" arg:"-q"`
+	Summary          bool     `help:"Only output summary information" arg:"-s"`
+	Strict           bool     `help:"Do not evaluate runs that are not valid for the topics in the qrels"`
+	OutputFormat     string   `help:"Format of the results (json, trec_eval, markdown, latex, arrow)" arg:"--output-format" default:"json"`
+	QrelsFile        string   `help:"Path to qrels file" arg:"required,positional"`
+	RunFile          string   `help:"Path to run file" arg:"required,positional"`
+}
+
+func (cmd evalCmd) run(c config) error {
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	N, err := ss.CollectionSize()
+	if err != nil {
+		return err
+	}
+
+	var fetcher retrieval.DocumentFetcher
+	if e, ok := ss.(stats.EntrezStatisticsSource); ok {
+		fetcher = e
+	}
+	resultsHandlers, err := retrieval.ParseResultsHandlers(cmd.ResultHandlers, fetcher)
+	if err != nil {
+		return err
+	}
+
+	writer, ok := evaluationWriters[cmd.OutputFormat]
+	if !ok {
+		return fmt.Errorf("unknown evaluation format %s", cmd.OutputFormat)
+	}
+
+	evaluationMeasures := evaluators(N)
+	err = lookup("evaluation measures", cmd.Evaluation, func(name string) bool {
+		_, ok := evaluationMeasures[name]
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	r, err := output.OpenRun(cmd.RunFile)
+	if err != nil {
+		return err
+	}
+	results, err := trecresults.ResultsFromReader(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	q, err := os.Open(cmd.QrelsFile)
+	if err != nil {
+		return err
+	}
+	qrels, err := trecresults.QrelsFromReader(q)
+	q.Close()
+	if err != nil {
+		return err
+	}
+
+	// Check the run is valid before it is evaluated, as trec_eval would.
+	errs := output.ValidateRun(results.Results, output.QrelsTopics(qrels)...)
+	for _, err := range errs {
+		log.Println(err)
+	}
+	if cmd.Strict && len(errs) > 0 {
+		return fmt.Errorf("%s is not a valid run (%d errors)", cmd.RunFile, len(errs))
+	}
+
+	evaluation := make(map[string]map[string]float64)
+	for k, v := range results.Results {
+		// Process all the results handlers first.
+		if len(resultsHandlers) > 0 {
+			err := resultsHandlers.Handle(&v)
+			if err != nil {
+				return err
+			}
+			results.Results[k] = v
+		}
+		// Then move on to perform the evaluation.
+		evaluation[k] = make(map[string]float64)
+		for _, ev := range cmd.Evaluation {
+			m := evaluationMeasures[ev]
+			evaluation[k][m.Name()] = m.Score(&v, qrels.Qrels[k])
+		}
+	}
+
+	if len(cmd.ResultHandlers) > 0 && len(cmd.RunOutput) > 0 {
+		err := output.WriteRun(cmd.RunOutput, results.Results)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := openOutput(cmd.EvaluationOutput)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if cmd.Summary {
+		summary := make(map[string][]float64)
+		for _, evals := range evaluation {
+			for measure, value := range evals {
+				summary[measure] = append(summary[measure], value)
+			}
+		}
+		avgs := make(map[string]float64)
+		for measure, values := range summary {
+			avgs[measure] = stat.Mean(values, nil)
+		}
+		avgs["NumQ"] = float64(len(evaluation))
+		return json.NewEncoder(w).Encode(avgs)
+	}
+
+	return writer(w, evaluation)
+}
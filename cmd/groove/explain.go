@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/trecresults"
+	"log"
+	"os"
+)
+
+type explainCmd struct {
+	Format       string `help:"Format of the queries" arg:"-f" default:"medline"`
+	OutputFormat string `help:"Format of the report (json, markdown)" arg:"--output-format" default:"json"`
+	Output       string `help:"Name of report file" arg:"-o"`
+	QrelsFile    string `help:"Path to qrels file" arg:"required,positional"`
+	Queries      string `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd explainCmd) run(c config) error {
+	if cmd.OutputFormat != "json" && cmd.OutputFormat != "markdown" {
+		return fmt.Errorf("unknown report format %s", cmd.OutputFormat)
+	}
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	queries, err := qs.Load(cmd.Queries)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(cmd.QrelsFile)
+	if err != nil {
+		return err
+	}
+	qrels, err := trecresults.QrelsFromReader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	w, err := openOutput(cmd.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	enc := json.NewEncoder(w)
+
+	cache := combinator.NewMapQueryCache()
+	for _, q := range queries {
+		topicQrels, ok := qrels.Qrels[q.Topic]
+		if !ok {
+			log.Printf("topic %s has no qrels\n", q.Topic)
+			continue
+		}
+		tree, _, err := combinator.NewLogicalTree(q, ss, cache)
+		if err != nil {
+			return fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		e := eval.Explain(q, tree, cache, topicQrels)
+		if cmd.OutputFormat == "markdown" {
+			err = e.WriteMarkdown(w)
+		} else {
+			err = enc.Encode(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
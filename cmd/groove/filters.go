@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/trecresults"
+	"os"
+)
+
+type filtersCmd struct {
+	Format    string   `help:"Format of the queries" arg:"-f" default:"medline"`
+	Filters   string   `help:"Path to directory of search filters" arg:"required"`
+	Names     []string `help:"Which search filters to evaluate (default: every filter)" arg:"-n,separate"`
+	Output    string   `help:"Name of report file (JSON lines)" arg:"-o"`
+	QrelsFile string   `help:"Path to qrels file" arg:"required,positional"`
+	Queries   string   `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd filtersCmd) run(c config) error {
+	library, err := preprocess.LoadFilterLibrary(cmd.Filters)
+	if err != nil {
+		return err
+	}
+	names := cmd.Names
+	if len(names) == 0 {
+		names = library.Names()
+	}
+	var filters []preprocess.SearchFilter
+	err = lookup("search filters", names, func(name string) bool {
+		f, ok := library[name]
+		filters = append(filters, f)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	queries, err := qs.Load(cmd.Queries)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(cmd.QrelsFile)
+	if err != nil {
+		return err
+	}
+	qrels, err := trecresults.QrelsFromReader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	evaluations, err := preprocess.EvaluateFilters(ss, queries, qrels, filters...)
+	if err != nil {
+		return fmt.Errorf("unable to evaluate search filters: %v", err)
+	}
+
+	w, err := openOutput(cmd.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	enc := json.NewEncoder(w)
+	for _, e := range evaluations {
+		err = enc.Encode(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
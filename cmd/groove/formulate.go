@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hscells/groove"
+	"github.com/hscells/groove/formulation"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"log"
+	"os"
+	"strconv"
+)
+
+type formulateCmd struct {
+	Format       string  `help:"Format of the topics" arg:"-f" default:"tar"`
+	Method       string  `help:"Query formulation method" arg:"-m" default:"objective"`
+	Qrels        string  `help:"Path to qrels file of the relevant studies of each topic" arg:"required"`
+	Optimisation string  `help:"Evaluation measure to optimise" default:"recall"`
+	Seed         int64   `help:"Seed used to split the relevant studies of each topic" default:"1000"`
+	Development  float64 `help:"Proportion of relevant studies used for development" default:"0.5"`
+	Validation   float64 `help:"Proportion of relevant studies used for validation" default:"0.25"`
+	PubDates     string  `help:"Path to file of publication date restrictions of topics"`
+	SemTypes     string  `help:"Path to file mapping semantic types to categories"`
+	MetaMap      string  `help:"URL of MetaMap service" default:"http://ielab-metamap.uqcloud.net"`
+	Cost         float64 `help:"Cost of missing a relevant study relative to retrieving a non-relevant study (dt)" default:"4"`
+	Depth        int     `help:"Maximum number of conditions of a learned rule (dt)" default:"4"`
+	Support      int     `help:"Minimum number of relevant studies a learned rule must identify (dt)" default:"2"`
+	Topics       string  `help:"Path to directory of topics" arg:"required,positional"`
+}
+
+// formulatorFactory creates the formulator of the query of a topic, given the relevance assessments of the topic.
+type formulatorFactory func(q pipeline.Query, qrels trecresults.Qrels) (formulation.Formulator, error)
+
+// formulators are the query formulation methods. Each method prepares what is shared between topics, and returns
+// the factory of the formulator for each topic.
+var formulators = map[string]func(cmd formulateCmd, e stats.EntrezStatisticsSource) (formulatorFactory, error){
+	"objective": objectiveFormulators,
+	"dt":        decisionTreeFormulators,
+}
+
+func objectiveFormulators(cmd formulateCmd, e stats.EntrezStatisticsSource) (formulatorFactory, error) {
+	optimisation, ok := evaluators(0)[cmd.Optimisation]
+	if !ok {
+		return nil, fmt.Errorf("unknown evaluation measure %s", cmd.Optimisation)
+	}
+	classifier, err := formulation.NewMetaMapTermClassifier(cmd.MetaMap, cmd.SemTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []formulation.ObjectiveOption{
+		formulation.ObjectiveSplitter(formulation.NewRandomSplitter(cmd.Seed, cmd.Development, cmd.Validation)),
+	}
+	if len(cmd.PubDates) > 0 {
+		dates, err := preprocess.LoadTopicDates(cmd.PubDates)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, formulation.ObjectiveDateRestrictions(dates.Restrict))
+	}
+
+	population := formulation.NewPubMedSet(e)
+	return func(q pipeline.Query, qrels trecresults.Qrels) (formulation.Formulator, error) {
+		return formulation.NewObjectiveFormulator(q, e, qrels, population, classifier, optimisation, options...), nil
+	}, nil
+}
+
+// decisionTreeFormulators learn rules from the relevant and non-relevant studies of each topic in the qrels.
+func decisionTreeFormulators(cmd formulateCmd, e stats.EntrezStatisticsSource) (formulatorFactory, error) {
+	options := []formulation.DecisionTreeOption{
+		formulation.DecisionTreeCost(cmd.Cost),
+		formulation.DecisionTreeMaxDepth(cmd.Depth),
+		formulation.DecisionTreeMinSupport(cmd.Support),
+	}
+	return func(q pipeline.Query, qrels trecresults.Qrels) (formulation.Formulator, error) {
+		var pos, neg []int
+		for _, rel := range qrels {
+			pmid, err := strconv.Atoi(rel.DocId)
+			if err != nil {
+				return nil, err
+			}
+			if rel.Score > 0 {
+				pos = append(pos, pmid)
+			} else {
+				neg = append(neg, pmid)
+			}
+		}
+		positive, err := e.Fetch(pos)
+		if err != nil {
+			return nil, err
+		}
+		negative, err := e.Fetch(neg)
+		if err != nil {
+			return nil, err
+		}
+		dt, err := formulation.NewDecisionTreeFormulator(q.Topic, positive, negative, options...)
+		if err != nil {
+			return nil, err
+		}
+		return dt, nil
+	}, nil
+}
+
+func (cmd formulateCmd) run(c config) error {
+	method, ok := formulators[cmd.Method]
+	if !ok {
+		return fmt.Errorf("unknown formulation method %s", cmd.Method)
+	}
+
+	e, err := c.entrez()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(cmd.Qrels)
+	if err != nil {
+		return err
+	}
+	qrels, err := trecresults.QrelsFromReader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	formulator, err := method(cmd, e)
+	if err != nil {
+		return err
+	}
+
+	it, err := query.NewQueryIterator(qs, cmd.Topics)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := it.Err(); err != nil {
+			log.Println(err)
+			continue
+		}
+		q := it.Query()
+
+		p := groove.NewGroovePipeline(qs, e)
+		p.QueryFormulator, err = formulator(q, qrels.Qrels[q.Topic])
+		if err != nil {
+			return err
+		}
+		err = execute(p, pipelineOutputs{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
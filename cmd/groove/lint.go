@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hscells/groove/lint"
+	"github.com/hscells/groove/stats"
+)
+
+type lintCmd struct {
+	Format  string   `help:"Format of the queries" arg:"-f" default:"medline"`
+	Strict  bool     `help:"Fail on warnings as well as errors"`
+	Fields  []string `help:"Fields supported by the statistics source (default: those known for the source)" arg:"separate"`
+	Queries string   `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+// lintFields are the fields supported by each statistics source, keyed by the name used on the command line. Sources
+// that are not listed support all fields.
+var lintFields = map[string][]string{
+	"entrez": lint.SupportedFields(stats.EntrezStatisticsSource{}),
+	"bleve":  lint.SupportedFields(&stats.BleveStatisticsSource{}),
+}
+
+func (cmd lintCmd) run(c config) error {
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	queries, err := qs.Load(cmd.Queries)
+	if err != nil {
+		return err
+	}
+
+	supported := cmd.Fields
+	if len(supported) == 0 {
+		supported = lintFields[c.source]
+	}
+	linter := lint.NewLinter(lint.LinterFields(supported...))
+	fail := lint.Error
+	if cmd.Strict {
+		fail = lint.Warning
+	}
+
+	failed := 0
+	for _, q := range queries {
+		findings := linter.Lint(q.Query)
+		for _, finding := range findings {
+			fmt.Printf("%s\t%s\n", q.Topic, finding)
+		}
+		if len(findings) > 0 && findings.Max() >= fail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queries failed linting", failed, len(queries))
+	}
+	return nil
+}
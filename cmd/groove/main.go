@@ -0,0 +1,139 @@
+// Command groove is a command-line interface to the groove library. Each workflow of groove (executing pipelines,
+// evaluating runs, evaluating queries with seed studies, explaining, measuring, transforming, linting and interactively
+// refining queries, evaluating search filters, checking the translation of queries, formulating queries, ranking, and
+// cache maintenance) is a subcommand of groove. Credentials for statistics sources are shared across subcommands and
+// are read from the `~/.entrez_eval` configuration file.
+package main
+
+import (
+	"fmt"
+	"github.com/alexflint/go-arg"
+	"github.com/hscells/groove/metrics"
+	"log"
+	"os"
+)
+
+var (
+	name    = "groove"
+	version = "19.Oct.2026"
+	author  = "Harry Scells"
+)
+
+type args struct {
+	Config         string        `help:"Path to credentials configuration file (default: ~/.entrez_eval)" arg:"--config"`
+	Source         string        `help:"Statistics source to use (entrez, elasticsearch, bleve)" arg:"--source"`
+	Size           int           `help:"Maximum number of results to retrieve" arg:"--size"`
+	RunName        string        `help:"Name of the run in trec results" arg:"--run-name"`
+	RelevanceGrade int64         `help:"Minimum level of relevance to consider" arg:"-l,--grade"`
+	Progress       bool          `help:"Show a progress bar" arg:"--progress"`
+	MetricsLog     string        `help:"Path to a JSON-lines log of progress and metrics" arg:"--metrics-log"`
+	Prometheus     string        `help:"Address to serve Prometheus metrics on (e.g. localhost:9090)" arg:"--prometheus"`
+	Run            *runCmd       `arg:"subcommand:run" help:"execute a pipeline configuration"`
+	Eval           *evalCmd      `arg:"subcommand:eval" help:"evaluate a run using qrels"`
+	Measure        *measureCmd   `arg:"subcommand:measure" help:"compute query performance predictors for a directory of queries"`
+	Transform      *transformCmd `arg:"subcommand:transform" help:"apply transformations to a directory of queries"`
+	Formulate      *formulateCmd `arg:"subcommand:formulate" help:"formulate queries for a directory of topics"`
+	Rank           *rankCmd      `arg:"subcommand:rank" help:"rank the results of queries using coordination level fusion or re-ranking scorers"`
+	Cache          *cacheCmd     `arg:"subcommand:cache" help:"inspect and clear groove caches"`
+	Lint           *lintCmd      `arg:"subcommand:lint" help:"check a directory of queries for mistakes"`
+	Serve          *serveCmd     `arg:"subcommand:serve" help:"serve an HTTP/JSON API for interactively refining queries"`
+	Seeds          *seedsCmd     `arg:"subcommand:seeds" help:"evaluate queries using known relevant (seed) studies"`
+	Explain        *explainCmd   `arg:"subcommand:explain" help:"explain which clauses of queries miss relevant and retrieve non-relevant documents"`
+	Filters        *filtersCmd   `arg:"subcommand:filters" help:"evaluate the sensitivity of search filters applied to queries"`
+	Translate      *translateCmd `arg:"subcommand:translate" help:"check that queries are unchanged when translated between syntaxes"`
+}
+
+func (args) Version() string {
+	return version
+}
+
+func (args) Description() string {
+	return fmt.Sprintf(`%s
+@ %s
+# %s`, name, author, version)
+}
+
+func main() {
+	var args args
+	p := arg.MustParse(&args)
+
+	if p.Subcommand() == nil {
+		p.Fail("missing subcommand")
+	}
+
+	c, err := loadConfig(args.Config)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	c.applyArgs(args)
+
+	finish, err := reportMetrics(args)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch {
+	case args.Run != nil:
+		err = args.Run.run(c)
+	case args.Eval != nil:
+		err = args.Eval.run(c)
+	case args.Measure != nil:
+		err = args.Measure.run(c)
+	case args.Transform != nil:
+		err = args.Transform.run(c)
+	case args.Formulate != nil:
+		err = args.Formulate.run(c)
+	case args.Rank != nil:
+		err = args.Rank.run(c)
+	case args.Cache != nil:
+		err = args.Cache.run(c)
+	case args.Lint != nil:
+		err = args.Lint.run(c)
+	case args.Serve != nil:
+		err = args.Serve.run(c)
+	case args.Seeds != nil:
+		err = args.Seeds.run(c)
+	case args.Explain != nil:
+		err = args.Explain.run(c)
+	case args.Filters != nil:
+		err = args.Filters.run(c)
+	case args.Translate != nil:
+		err = args.Translate.run(c)
+	}
+	finish()
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// reportMetrics registers the metrics reporters requested by the arguments. The returned function finishes
+// reporting once the subcommand has completed.
+func reportMetrics(args args) (func(), error) {
+	var finish []func()
+	if args.Progress {
+		bar := metrics.NewProgressBar(os.Stderr)
+		metrics.Register(bar)
+		finish = append(finish, bar.Finish)
+	}
+	if len(args.MetricsLog) > 0 {
+		f, err := os.OpenFile(args.MetricsLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Register(metrics.NewJSONLines(f))
+		finish = append(finish, func() { f.Close() })
+	}
+	if len(args.Prometheus) > 0 {
+		p, err := metrics.ServePrometheus(args.Prometheus)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Register(p)
+		log.Printf("serving metrics at http://%s/metrics\n", args.Prometheus)
+	}
+	return func() {
+		for _, f := range finish {
+			f()
+		}
+	}, nil
+}
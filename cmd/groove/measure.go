@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hscells/groove"
+	"github.com/hscells/groove/output"
+	"sort"
+)
+
+type measureCmd struct {
+	Format       string   `help:"Format of the queries" arg:"-f" default:"medline"`
+	Measurements []string `help:"Which measurements to compute (default: all)" arg:"-m,separate"`
+	Output       string   `help:"Name of measurements file" arg:"-o"`
+	OutputFormat string   `help:"Format of the measurements (json, csv, trec_eval, markdown, latex, arrow)" arg:"--output-format" default:"json"`
+	Queries      string   `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd measureCmd) run(c config) error {
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	p := groove.NewGroovePipeline(qs, ss)
+	p.QueryPath = cmd.Queries
+
+	measures := measurements()
+	names := cmd.Measurements
+	if len(names) == 0 {
+		for name := range measures {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	err = lookup("measurements", names, func(name string) bool {
+		v, ok := measures[name]
+		p.Measurements = append(p.Measurements, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	writer, ok := measurementWriters[cmd.OutputFormat]
+	if !ok {
+		return fmt.Errorf("unknown measurement format %s", cmd.OutputFormat)
+	}
+	p.MeasurementFormatters = []output.MeasurementFormatter{output.MeasurementFormatterFor(writer)}
+
+	return execute(p, pipelineOutputs{measurements: cmd.Output})
+}
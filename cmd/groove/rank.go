@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/rank"
+	"github.com/hscells/trecresults"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+type rankCmd struct {
+	Format  string    `help:"Format of the queries" arg:"-f" default:"medline"`
+	Options string    `help:"Path to JSON file of CLF options" arg:"-c"`
+	Analyse []string  `help:"Query processors to apply to both documents and queries" arg:"-a,separate"`
+	Scorer  []string  `help:"Re-rank the documents retrieved from the statistics source instead of using CLF (bm25, dirichlet, tfidf, vsm); several scorers are combined linearly" arg:"-s,separate"`
+	Weights []float64 `help:"Weights of the linear combination of scorers (default: 1 each)" arg:"-w,separate"`
+	Learn   string    `help:"Path to qrels file to learn the weights of the linear combination of scorers from" arg:"--learn"`
+	Fields  []string  `help:"Fields of the documents to score when re-ranking (default: every field)" arg:"separate"`
+	N       float64   `help:"Number of documents in the collection when re-ranking (default: read from the statistics source)" arg:"--collection-size"`
+	Output  string    `help:"Name of run file" arg:"-o,required"`
+	Queries string    `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd rankCmd) run(c config) error {
+	var options rank.CLFOptions
+	if len(cmd.Options) > 0 {
+		b, err := ioutil.ReadFile(cmd.Options)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(b, &options)
+		if err != nil {
+			return err
+		}
+	}
+	options.CLF = true
+
+	var analysers []preprocess.QueryProcessor
+	err := lookup("query processors", cmd.Analyse, func(name string) bool {
+		v, ok := queryProcessors[name]
+		analysers = append(analysers, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	if len(analysers) > 0 {
+		rank.Analyser = preprocess.Chain(analysers...)
+		rank.AnalyserName = strings.Join(cmd.Analyse, "+")
+	}
+	if len(cmd.Scorer) > 0 {
+		return cmd.rerank(c)
+	}
+
+	e, err := c.entrez()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	p := groove.NewGroovePipeline(qs, e)
+	p.QueryPath = cmd.Queries
+	p.CLF = options
+	p.OutputTrec.Path = cmd.Output
+
+	return execute(p, pipelineOutputs{trecResults: cmd.Output})
+}
+
+var rerankScorers = map[string]func() rank.Scorer{
+	"bm25":      func() rank.Scorer { return &rank.SourceBM25Scorer{K1: 1.2, B: 0.75} },
+	"dirichlet": func() rank.Scorer { return &rank.SourceDirichletScorer{Mu: 2000} },
+	"tfidf":     func() rank.Scorer { return &rank.SourceTFIDFScorer{} },
+	"vsm":       func() rank.Scorer { return &rank.SourceVectorSpaceScorer{} },
+}
+
+// rerank ranks the documents retrieved by each query from the statistics source, so that they can be screened in
+// order of their score.
+func (cmd rankCmd) rerank(c config) error {
+	var scorers []rank.Scorer
+	err := lookup("scorers", cmd.Scorer, func(name string) bool {
+		fn, ok := rerankScorers[name]
+		if ok {
+			scorers = append(scorers, fn())
+		}
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	if len(cmd.Weights) > 0 && len(cmd.Weights) != len(scorers) {
+		return fmt.Errorf("%d weights given for %d scorers", len(cmd.Weights), len(scorers))
+	}
+
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	queries, err := qs.Load(cmd.Queries)
+	if err != nil {
+		return err
+	}
+
+	options := []func(*rank.ReRanker){rank.ReRankFields(cmd.Fields...), rank.ReRankRunName(c.runName), rank.ReRankCollectionSize(cmd.N)}
+	r := rank.NewReRanker(ss, nil, options...)
+	var scorer rank.Scorer
+	switch {
+	case len(cmd.Learn) > 0:
+		f, err := os.Open(cmd.Learn)
+		if err != nil {
+			return err
+		}
+		qrels, err := trecresults.QrelsFromReader(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		linear, err := r.Learn(queries, qrels, scorers...)
+		if err != nil {
+			return fmt.Errorf("unable to learn weights of scorers: %v", err)
+		}
+		log.Printf("learned weights %v for scorers %v\n", linear.Weights, cmd.Scorer)
+		scorer = linear
+	case len(scorers) == 1:
+		scorer = scorers[0]
+	default:
+		weights := cmd.Weights
+		if len(weights) == 0 {
+			weights = make([]float64, len(scorers))
+			for i := range weights {
+				weights[i] = 1
+			}
+		}
+		scorer = rank.LinearScorer{Scorers: scorers, Weights: weights}
+	}
+	r = rank.NewReRanker(ss, scorer, options...)
+
+	w, err := openOutput(cmd.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, q := range queries {
+		results, err := r.ReRank(q)
+		if err != nil {
+			return fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		for _, result := range results {
+			_, err = fmt.Fprintln(w, result.String())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
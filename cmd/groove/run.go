@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/output"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/rank"
+	"github.com/hscells/groove/retrieval"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"io/ioutil"
+	"os"
+)
+
+type runCmd struct {
+	Pipeline string `help:"Path to pipeline configuration" arg:"required,positional"`
+}
+
+// pipelineConfig is the JSON configuration of a pipeline, of the form:
+//
+//	{
+//	  "statistic": {"source": "entrez", "search": {"size": 100000, "run_name": "run"}},
+//	  "query": {"format": "medline", "path": "queries/", "pubdates": "pubdates.tsv"},
+//	  "preprocess": ["lowercase"],
+//	  "filters": "filters/",
+//	  "transformations": ["simplify", "rct_sensitive"],
+//	  "measurements": ["AvgIDF"],
+//	  "evaluations": ["precision", "recall"],
+//	  "results_handlers": ["deduplicate", "cutoff:1000"],
+//	  "clf": {"clf": true, ...},
+//	  "stream": false,
+//	  "output": {
+//	    "measurements": {"format": ["json"], "output": "measurements.json"},
+//	    "trec_results": {"output": "run.res"},
+//	    "evaluations": {"qrels": "qrels.txt", "format": ["json"], "output": "evaluation.json"},
+//	    "transformations": {"output": "transformed/"}
+//	  }
+//	}
+//
+// Transformations may also be the names of the search filters in the directory of filters (see
+// preprocess.LoadFilterLibrary). Evaluations may use a file of seed studies ("seeds", see eval.ReadSeeds) in place
+// of qrels, and topics without qrels are evaluated using the seed studies in their metadata. Credentials for the
+// statistics source are read from the shared configuration file.
+type pipelineConfig struct {
+	Statistic struct {
+		Source string `json:"source"`
+		Search struct {
+			Size    int    `json:"size"`
+			RunName string `json:"run_name"`
+		} `json:"search"`
+	} `json:"statistic"`
+	Query struct {
+		Format   string `json:"format"`
+		Path     string `json:"path"`
+		PubDates string `json:"pubdates"`
+	} `json:"query"`
+	Preprocess      []string        `json:"preprocess"`
+	Filters         string          `json:"filters"`
+	Transformations []string        `json:"transformations"`
+	Measurements    []string        `json:"measurements"`
+	Evaluations     []string        `json:"evaluations"`
+	ResultsHandlers []string        `json:"results_handlers"`
+	CLF             rank.CLFOptions `json:"clf"`
+	Stream          bool            `json:"stream"`
+	Output          struct {
+		Measurements struct {
+			Format []string `json:"format"`
+			Output string   `json:"output"`
+		} `json:"measurements"`
+		TrecResults struct {
+			Output string `json:"output"`
+		} `json:"trec_results"`
+		Evaluations struct {
+			Qrels  string   `json:"qrels"`
+			Seeds  string   `json:"seeds"`
+			Format []string `json:"format"`
+			Output string   `json:"output"`
+		} `json:"evaluations"`
+		Transformations struct {
+			Output string `json:"output"`
+		} `json:"transformations"`
+	} `json:"output"`
+}
+
+// pipelineOutputs are the files the results of a pipeline are written to.
+type pipelineOutputs struct {
+	measurements    string
+	trecResults     string
+	evaluations     string
+	transformations string
+}
+
+var (
+	measurementWriters = map[string]output.MeasurementWriter{
+		"json":      output.JsonMeasurementWriter,
+		"csv":       output.CsvMeasurementWriter,
+		"trec_eval": output.TrecEvalMeasurementWriter,
+		"markdown":  output.MarkdownMeasurementWriter,
+		"latex":     output.LatexMeasurementWriter,
+		"arrow":     output.ArrowMeasurementWriter,
+	}
+	evaluationWriters = map[string]output.EvaluationWriter{
+		"json":      output.JsonEvaluationWriter,
+		"trec_eval": output.TrecEvalEvaluationWriter,
+		"markdown":  output.MarkdownEvaluationWriter,
+		"latex":     output.LatexEvaluationWriter,
+		"arrow":     output.ArrowEvaluationWriter,
+	}
+)
+
+func (cmd runCmd) run(c config) error {
+	b, err := ioutil.ReadFile(cmd.Pipeline)
+	if err != nil {
+		return err
+	}
+	var pc pipelineConfig
+	err = json.Unmarshal(b, &pc)
+	if err != nil {
+		return err
+	}
+
+	if len(pc.Statistic.Source) > 0 {
+		c.source = pc.Statistic.Source
+	}
+	if pc.Statistic.Search.Size > 0 {
+		c.size = pc.Statistic.Search.Size
+	}
+	if len(pc.Statistic.Search.RunName) > 0 {
+		c.runName = pc.Statistic.Search.RunName
+	}
+
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(pc.Query.Format)
+	if err != nil {
+		return err
+	}
+
+	p := groove.NewGroovePipeline(qs, ss)
+	p.QueryPath = pc.Query.Path
+	p.PubDatesFile = pc.Query.PubDates
+	p.CLF = pc.CLF
+	p.StreamQueries = pc.Stream
+
+	err = lookup("query processors", pc.Preprocess, func(name string) bool {
+		v, ok := queryProcessors[name]
+		p.Preprocess = append(p.Preprocess, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	filters, err := loadFilters(pc.Filters)
+	if err != nil {
+		return err
+	}
+	err = configureTransformations(&p.Transformations, pc.Transformations, filters)
+	if err != nil {
+		return err
+	}
+
+	measures := measurements()
+	err = lookup("measurements", pc.Measurements, func(name string) bool {
+		v, ok := measures[name]
+		p.Measurements = append(p.Measurements, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	err = lookup("measurement formats", pc.Output.Measurements.Format, func(name string) bool {
+		v, ok := measurementWriters[name]
+		p.MeasurementFormatters = append(p.MeasurementFormatters, output.MeasurementFormatterFor(v))
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pc.Evaluations) > 0 {
+		N, err := ss.CollectionSize()
+		if err != nil {
+			return err
+		}
+		evals := evaluators(N)
+		err = lookup("evaluation measures", pc.Evaluations, func(name string) bool {
+			v, ok := evals[name]
+			p.Evaluations = append(p.Evaluations, v)
+			return ok
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(pc.Output.Evaluations.Qrels) > 0 || len(pc.Output.Evaluations.Seeds) > 0 {
+		if len(pc.Output.Evaluations.Qrels) > 0 {
+			f, err := os.Open(pc.Output.Evaluations.Qrels)
+			if err != nil {
+				return err
+			}
+			p.EvaluationFormatters.EvaluationQrels, err = trecresults.QrelsFromReader(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		} else {
+			seeds, err := eval.LoadSeeds(pc.Output.Evaluations.Seeds)
+			if err != nil {
+				return err
+			}
+			p.EvaluationFormatters.EvaluationQrels = seeds.Qrels()
+		}
+		err = lookup("evaluation formats", pc.Output.Evaluations.Format, func(name string) bool {
+			v, ok := evaluationWriters[name]
+			p.EvaluationFormatters.EvaluationFormatters = append(p.EvaluationFormatters.EvaluationFormatters, output.EvaluationFormatterFor(v))
+			return ok
+		})
+		if err != nil {
+			return err
+		}
+	}
+	p.OutputTrec.Path = pc.Output.TrecResults.Output
+
+	var fetcher retrieval.DocumentFetcher
+	if e, ok := ss.(stats.EntrezStatisticsSource); ok {
+		fetcher = e
+	}
+	p.ResultsHandlers, err = retrieval.ParseResultsHandlers(pc.ResultsHandlers, fetcher)
+	if err != nil {
+		return err
+	}
+
+	return execute(p, pipelineOutputs{
+		measurements:    pc.Output.Measurements.Output,
+		trecResults:     pc.Output.TrecResults.Output,
+		evaluations:     pc.Output.Evaluations.Output,
+		transformations: pc.Output.Transformations.Output,
+	})
+}
+
+// configureTransformations adds the named Boolean and metadata transformations, and search filters, to a pipeline.
+func configureTransformations(t *preprocess.QueryTransformations, names []string, filters preprocess.FilterLibrary) error {
+	return lookup("transformations", names, func(name string) bool {
+		if v, ok := booleanTransformations[name]; ok {
+			t.BooleanTransformations = append(t.BooleanTransformations, v)
+			return true
+		}
+		if f, ok := filters[name]; ok {
+			t.BooleanTransformations = append(t.BooleanTransformations, f.Apply)
+			return true
+		}
+		if v, ok := metadataTransformations[name]; ok {
+			t.MetadataTransformations = append(t.MetadataTransformations, v)
+			return true
+		}
+		return false
+	})
+}
+
+// execute runs a pipeline, writing each of the results of the pipeline to the configured outputs.
+func execute(p groove.Pipeline, o pipelineOutputs) error {
+	// Formatted measurements and evaluations are written to files, or to stdout if there is no file.
+	formatted := output.NewFormatterSink(func(r pipeline.Result) error {
+		switch r.Type {
+		case pipeline.Measurement:
+			return writeOutputs(o.measurements, r.Measurements)
+		case pipeline.Evaluation:
+			return writeOutputs(o.evaluations, r.Evaluations)
+		}
+		return nil
+	},
+		output.FormatMeasurements(p.MeasurementFormatters...),
+		output.FormatQueryMeasurements(p.QueryFormatters...),
+		output.FormatEvaluations(p.EvaluationFormatters.EvaluationFormatters...))
+
+	var files []func(*output.FileSink)
+	if len(o.trecResults) > 0 {
+		files = append(files, output.RunFile(o.trecResults))
+	}
+	if len(o.transformations) > 0 {
+		files = append(files, output.TransformationsDir(o.transformations))
+	}
+
+	return p.Run(pipeline.NewLogProgress(), formatted, output.NewFileSink(files...))
+}
+
+// writeOutputs writes the formatted outputs of a pipeline. Multiple outputs are suffixed with their index.
+func writeOutputs(file string, outputs []string) error {
+	for i, out := range outputs {
+		fn := file
+		if len(fn) > 0 && i > 0 {
+			fn = fmt.Sprintf("%s.%d", file, i)
+		}
+		w, err := openOutput(fn)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(out))
+		if err != nil {
+			return err
+		}
+		err = w.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFilters loads the library of search filters in a directory. Without a directory, the library is empty. Filters
+// cannot have the name of a transformation, as they are configured as transformations.
+func loadFilters(dir string) (preprocess.FilterLibrary, error) {
+	if len(dir) == 0 {
+		return nil, nil
+	}
+	filters, err := preprocess.LoadFilterLibrary(dir)
+	if err != nil {
+		return nil, err
+	}
+	for name := range filters {
+		_, boolean := booleanTransformations[name]
+		_, metadata := metadataTransformations[name]
+		if boolean || metadata {
+			return nil, fmt.Errorf("search filter %s has the name of a transformation", name)
+		}
+	}
+	return filters, nil
+}
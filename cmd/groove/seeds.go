@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/eval"
+	"log"
+)
+
+type seedsCmd struct {
+	Format  string  `help:"Format of the queries" arg:"-f" default:"medline"`
+	Seeds   string  `help:"Path to a file of seed studies (default: the seed studies in the metadata of queries)" arg:"-s"`
+	Minutes float64 `help:"Minutes to screen each retrieved study" arg:"-m" default:"0.5"`
+	Output  string  `help:"Name of report file (JSON lines)" arg:"-o"`
+	Queries string  `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd seedsCmd) run(c config) error {
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	queries, err := qs.Load(cmd.Queries)
+	if err != nil {
+		return err
+	}
+
+	seeds := eval.SeedsFromMetadata(queries...)
+	if len(cmd.Seeds) > 0 {
+		seeds, err = eval.LoadSeeds(cmd.Seeds)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := openOutput(cmd.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	enc := json.NewEncoder(w)
+
+	workload := eval.ScreeningWorkload{MinutesPerStudy: cmd.Minutes}
+	cache := combinator.NewMapQueryCache()
+	for _, q := range queries {
+		s, ok := seeds[q.Topic]
+		if !ok {
+			log.Printf("topic %s has no seed studies\n", q.Topic)
+			continue
+		}
+		tree, _, err := combinator.NewLogicalTree(q, ss, cache)
+		if err != nil {
+			return fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		err = enc.Encode(eval.NewSeedReport(q, tree, cache, s, workload))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
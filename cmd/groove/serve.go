@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/hscells/groove/analysis"
+	"github.com/hscells/groove/learning"
+	"github.com/hscells/groove/refinement"
+	"log"
+	"sort"
+)
+
+type serveCmd struct {
+	Addr         string   `help:"Address to serve the refinement API on" arg:"-a" default:"localhost:8080"`
+	Measurements []string `help:"Which measurements to compute for queries" arg:"-m,separate"`
+	Variations   []string `help:"Which learning transformations to suggest query variations with (default: all)" arg:"-v,separate"`
+	Suggestions  int      `help:"Maximum number of suggestions to make for a query" arg:"-n" default:"10"`
+}
+
+func (cmd serveCmd) run(c config) error {
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return err
+	}
+
+	measures := measurements()
+	var ms []analysis.Measurement
+	err = lookup("measurements", cmd.Measurements, func(name string) bool {
+		v, ok := measures[name]
+		ms = append(ms, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	names := cmd.Variations
+	if len(names) == 0 {
+		for name := range variationTransformers {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var transformations []learning.Transformation
+	err = lookup("variations", names, func(name string) bool {
+		v, ok := variationTransformers[name]
+		transformations = append(transformations, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	// Suggestions are only ranked by what they retrieve, so the features of variations are not needed.
+	learning.ComputeFeatures = false
+
+	r := refinement.NewRefiner(ss,
+		refinement.RefinerMeasurements(ms...),
+		refinement.RefinerTransformations(transformations...),
+		refinement.RefinerSuggestions(cmd.Suggestions))
+	log.Printf("serving query refinement at http://%s/refine\n", cmd.Addr)
+	return refinement.ListenAndServe(cmd.Addr, r)
+}
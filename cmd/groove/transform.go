@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/learning"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/transmute"
+	"github.com/hscells/transmute/backend"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+)
+
+type transformCmd struct {
+	Format          string   `help:"Format of the queries" arg:"-f" default:"medline"`
+	Syntax          string   `help:"Syntax to write the transformed queries in (pubmed, medline, cqr)" arg:"-x" default:"pubmed"`
+	Preprocess      []string `help:"Which query processors to apply" arg:"-p,separate"`
+	Transformations []string `help:"Which Boolean transformations or search filters to apply" arg:"-t,separate"`
+	Filters         string   `help:"Path to directory of search filters"`
+	Variations      []string `help:"Which learning transformations to generate query variations with" arg:"-v,separate"`
+	Expand          bool     `help:"Expand queries with terms from the documents they retrieve from the statistics source"`
+	Documents       int      `help:"Number of top documents used to expand queries" default:"10"`
+	Terms           int      `help:"Number of terms to expand queries with" default:"5"`
+	Expansion       string   `help:"How expansion terms are selected (rm3, rocchio)" default:"rm3"`
+	Insertion       string   `help:"How expansion terms are inserted into queries (cooccurrence, best, all)" default:"cooccurrence"`
+	Field           string   `help:"Field of the retrieved documents to select expansion terms from (required by rm3)"`
+	Output          string   `help:"Directory to write transformed queries to" arg:"-o,required"`
+	Queries         string   `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+// variationTransformers are the transformations from the learning package that can be used to generate query
+// variations, keyed by the name used on the command line.
+var variationTransformers = map[string]learning.Transformation{
+	"logical_operator":      learning.NewLogicalOperatorTransformer(),
+	"adjacency_range":       learning.NewAdjacencyRangeTransformer(),
+	"mesh_explosion":        learning.NewMeSHExplosionTransformer(),
+	"field_restrictions":    learning.NewFieldRestrictionsTransformer(),
+	"adjacency_replacement": learning.NewAdjacencyReplacementTransformer(),
+	"clause_removal":        learning.NewClauseRemovalTransformer(),
+	"mesh_parent":           learning.NewMeshParentTransformer(),
+}
+
+func (cmd transformCmd) run(c config) error {
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	var processors []preprocess.QueryProcessor
+	err = lookup("query processors", cmd.Preprocess, func(name string) bool {
+		v, ok := queryProcessors[name]
+		processors = append(processors, v)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	var transformations preprocess.QueryTransformations
+	filters, err := loadFilters(cmd.Filters)
+	if err != nil {
+		return err
+	}
+	err = configureTransformations(&transformations, cmd.Transformations, filters)
+	if err != nil {
+		return err
+	}
+	if cmd.Expand {
+		prf, err := cmd.expansion(c)
+		if err != nil {
+			return err
+		}
+		transformations.BooleanTransformations = append(transformations.BooleanTransformations, prf.Expand)
+	}
+	variations := cmd.Variations
+	sort.Strings(variations)
+	err = lookup("variations", variations, func(name string) bool {
+		_, ok := variationTransformers[name]
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	it, err := query.NewQueryIterator(qs, cmd.Queries)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := it.Err(); err != nil {
+			log.Println(err)
+			continue
+		}
+		q := it.Query()
+
+		for _, p := range processors {
+			q = q.WithQuery(preprocess.ProcessQuery(q.Query, p))
+		}
+		for _, t := range transformations.BooleanTransformations {
+			q = q.WithQuery(t(q.Query, q.Topic)())
+		}
+		for _, t := range transformations.MetadataTransformations {
+			q = q.WithQuery(t(q)())
+		}
+
+		if len(variations) == 0 {
+			err := writeQuery(path.Join(cmd.Output, q.Topic), cmd.Syntax, q.Query)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Each variation of a query is written to a directory named after the topic of the query.
+		for _, name := range variations {
+			candidates, err := variationTransformers[name].Apply(q.Query)
+			if err != nil {
+				return err
+			}
+			for i, candidate := range candidates {
+				err := writeQuery(path.Join(cmd.Output, q.Topic, fmt.Sprintf("%s_%d", name, i)), cmd.Syntax, candidate)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// expansion creates the pseudo-relevance feedback expansion of queries using the configured statistics source.
+func (cmd transformCmd) expansion(c config) (preprocess.PRFExpansion, error) {
+	method := preprocess.ExpansionMethod(cmd.Expansion)
+	switch method {
+	case preprocess.RM3Expansion, preprocess.RocchioExpansion:
+	default:
+		return preprocess.PRFExpansion{}, fmt.Errorf("unknown expansion method %s", cmd.Expansion)
+	}
+	if method == preprocess.RM3Expansion && len(cmd.Field) == 0 {
+		return preprocess.PRFExpansion{}, fmt.Errorf("rm3 expansion requires a field")
+	}
+	insertion := preprocess.InsertionStrategy(cmd.Insertion)
+	switch insertion {
+	case preprocess.InsertCooccurring, preprocess.InsertBestClause, preprocess.InsertAllClauses:
+	default:
+		return preprocess.PRFExpansion{}, fmt.Errorf("unknown insertion strategy %s", cmd.Insertion)
+	}
+	ss, err := c.statisticsSource()
+	if err != nil {
+		return preprocess.PRFExpansion{}, err
+	}
+	return preprocess.NewPRFExpansion(ss,
+		preprocess.PRFDocuments(cmd.Documents),
+		preprocess.PRFTerms(cmd.Terms),
+		preprocess.PRFMethod(method),
+		preprocess.PRFInsertion(insertion),
+		preprocess.PRFField(cmd.Field)), nil
+}
+
+// writeQuery compiles a query into a syntax and writes it to a file, creating any missing directories.
+func writeQuery(file, syntax string, q cqr.CommonQueryRepresentation) error {
+	var (
+		s   string
+		err error
+	)
+	switch syntax {
+	case "pubmed":
+		s, err = transmute.CompileCqr2PubMed(q)
+	case "medline":
+		s, err = transmute.CompileCqr2Medline(q)
+	case "cqr":
+		s, err = backend.NewCQRQuery(q).String()
+	default:
+		err = fmt.Errorf("unknown query syntax %s", syntax)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(file), 0777)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte(s), 0664)
+}
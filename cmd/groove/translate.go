@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/query"
+	"log"
+)
+
+type translateCmd struct {
+	Format    string   `help:"Format of the queries" arg:"-f" default:"medline"`
+	Syntaxes  []string `help:"Which syntaxes to translate queries into and back (default: pubmed, medline, cqr and elasticsearch)" arg:"-s,separate"`
+	Retrieval bool     `help:"Compare the documents retrieved by queries and their translations from the statistics source"`
+	Output    string   `help:"Name of report file (JSON lines)" arg:"-o"`
+	Queries   string   `help:"Path to directory of queries" arg:"required,positional"`
+}
+
+func (cmd translateCmd) run(c config) error {
+	var options []func(*query.TranslationChecker)
+	if len(cmd.Syntaxes) > 0 {
+		var translations []query.Translation
+		err := lookup("syntaxes", cmd.Syntaxes, func(name string) bool {
+			t, ok := query.Translations[name]
+			translations = append(translations, t)
+			return ok
+		})
+		if err != nil {
+			return err
+		}
+		options = append(options, query.TranslationSyntaxes(translations...))
+	}
+	if cmd.Retrieval {
+		ss, err := c.statisticsSource()
+		if err != nil {
+			return err
+		}
+		options = append(options, query.TranslationRetrieval(ss))
+	}
+	checker := query.NewTranslationChecker(options...)
+
+	qs, err := queriesSource(cmd.Format)
+	if err != nil {
+		return err
+	}
+	it, err := query.NewQueryIterator(qs, cmd.Queries)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	w, err := openOutput(cmd.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	enc := json.NewEncoder(w)
+
+	for it.Next() {
+		if err := it.Err(); err != nil {
+			log.Println(err)
+			continue
+		}
+		q := it.Query()
+		checks, err := checker.Check(q)
+		if err != nil {
+			return fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		for _, check := range checks {
+			if !check.Ok() {
+				log.Printf("topic %s changes when translated to %s\n", q.Topic, check.Syntax)
+			}
+			err = enc.Encode(check)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package combinator
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+)
+
+// Reason is why a clause is responsible for a document being retrieved or not.
+type Reason string
+
+const (
+	// Excluded clauses do not retrieve a document, so an `and` clause they are part of does not retrieve it either.
+	Excluded Reason = "excluded"
+	// Removed clauses are the excluded children of a `not` clause that retrieve, and so remove, a document.
+	Removed Reason = "removed"
+	// Admitted clauses are the branches of an `or` clause that retrieve a document.
+	Admitted Reason = "admitted"
+)
+
+// Loss is a clause of a query that is responsible for the query not retrieving a document or, when admitted, for
+// retrieving it.
+type Loss struct {
+	// Path is the location of the clause in the query, as the index of each child from the root (e.g. /0/2).
+	Path   string
+	Query  cqr.CommonQueryRepresentation
+	Reason Reason
+}
+
+// Attribute finds, for each document the tree does not retrieve, the clauses responsible for it not being retrieved.
+// A document is lost by the deepest clauses that do not retrieve it: every child of an `and` clause that does not
+// retrieve the document, an `or` clause where none of its children retrieve it, the first child of a `not` clause,
+// or the children of a `not` clause that remove it. Documents that are retrieved are not included.
+func (root LogicalTree) Attribute(docs []Document, cache QueryCacher) map[Document][]Loss {
+	a := newAttribution(cache)
+	losses := make(map[Document][]Loss)
+	for _, doc := range docs {
+		if a.retrieves(root.Root, "", doc) {
+			continue
+		}
+		losses[doc] = a.excluded(root.Root, "", doc)
+	}
+	return losses
+}
+
+// Admit finds, for each document the tree retrieves, the branches of `or` clauses responsible for it being
+// retrieved. The deepest branches are preferred, so a branch that itself contains an `or` clause is only blamed
+// through that clause. If the query has no `or` clauses, the whole query is blamed. Documents that are not retrieved
+// are not included.
+func (root LogicalTree) Admit(docs []Document, cache QueryCacher) map[Document][]Loss {
+	a := newAttribution(cache)
+	blames := make(map[Document][]Loss)
+	for _, doc := range docs {
+		if !a.retrieves(root.Root, "", doc) {
+			continue
+		}
+		b := a.admitted(root.Root, "", doc)
+		if len(b) == 0 {
+			b = []Loss{{Path: "/", Query: root.Root.Query(), Reason: Admitted}}
+		}
+		blames[doc] = b
+	}
+	return blames
+}
+
+// attribution memoises the documents retrieved by each clause of a tree, keyed by path, while attributing blame.
+type attribution struct {
+	cache QueryCacher
+	sets  map[string]map[Document]struct{}
+}
+
+func newAttribution(cache QueryCacher) attribution {
+	return attribution{
+		cache: cache,
+		sets:  make(map[string]map[Document]struct{}),
+	}
+}
+
+func (a attribution) retrieves(node LogicalTreeNode, path string, doc Document) bool {
+	set, ok := a.sets[path]
+	if !ok {
+		set = node.Documents(a.cache).Set()
+		a.sets[path] = set
+	}
+	_, ok = set[doc]
+	return ok
+}
+
+// childPath is the path of the i-th child of the clause at a path.
+func childPath(path string, i int) string {
+	return fmt.Sprintf("%s/%d", path, i)
+}
+
+// blame creates the blame of the clause at a path; the root clause has the path "/".
+func blame(node LogicalTreeNode, path string, reason Reason) Loss {
+	if len(path) == 0 {
+		path = "/"
+	}
+	return Loss{Path: path, Query: node.Query(), Reason: reason}
+}
+
+// excluded finds the clauses under a node, which does not retrieve a document, that are responsible for the loss.
+func (a attribution) excluded(node LogicalTreeNode, path string, doc Document) []Loss {
+	c, ok := node.(Combinator)
+	if !ok || len(c.Clauses) == 0 {
+		return []Loss{blame(node, path, Excluded)}
+	}
+
+	var blames []Loss
+	switch c.Operator.(type) {
+	case andOperator:
+		for i, clause := range c.Clauses {
+			if !a.retrieves(clause, childPath(path, i), doc) {
+				blames = append(blames, a.excluded(clause, childPath(path, i), doc)...)
+			}
+		}
+	case notOperator:
+		if !a.retrieves(c.Clauses[0], childPath(path, 0), doc) {
+			return a.excluded(c.Clauses[0], childPath(path, 0), doc)
+		}
+		for i, clause := range c.Clauses[1:] {
+			if a.retrieves(clause, childPath(path, i+1), doc) {
+				blames = append(blames, blame(clause, childPath(path, i+1), Removed))
+			}
+		}
+	}
+	// An `or` clause loses a document as a whole, as there is no one alternative that should have retrieved it.
+	if len(blames) == 0 {
+		return []Loss{blame(node, path, Excluded)}
+	}
+	return blames
+}
+
+// admitted finds the branches of `or` clauses under a node, which retrieves a document, that retrieve it.
+func (a attribution) admitted(node LogicalTreeNode, path string, doc Document) []Loss {
+	c, ok := node.(Combinator)
+	if !ok || len(c.Clauses) == 0 {
+		return nil
+	}
+
+	var blames []Loss
+	switch c.Operator.(type) {
+	case orOperator:
+		for i, clause := range c.Clauses {
+			if !a.retrieves(clause, childPath(path, i), doc) {
+				continue
+			}
+			b := a.admitted(clause, childPath(path, i), doc)
+			if len(b) == 0 {
+				b = []Loss{blame(clause, childPath(path, i), Admitted)}
+			}
+			blames = append(blames, b...)
+		}
+	case andOperator:
+		for i, clause := range c.Clauses {
+			blames = append(blames, a.admitted(clause, childPath(path, i), doc)...)
+		}
+	case notOperator:
+		blames = a.admitted(c.Clauses[0], childPath(path, 0), doc)
+	}
+	return blames
+}
@@ -0,0 +1,106 @@
+package combinator_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/transmute/fields"
+	"reflect"
+	"testing"
+)
+
+func TestAttribute(t *testing.T) {
+	cache := combinator.NewMapQueryCache()
+	atoms := make(map[string]combinator.Atom)
+	for term, docs := range map[string]combinator.Documents{
+		"a": {1, 2},
+		"b": {3},
+		"c": {1, 3, 4, 5},
+		"d": {1, 3, 5, 6},
+		"e": {3},
+	} {
+		k := cqr.NewKeyword(term, fields.TitleAbstract)
+		if err := cache.Set(k, docs); err != nil {
+			t.Fatal(err)
+		}
+		atoms[term] = combinator.NewAtom(k)
+	}
+
+	// (a OR b) AND c AND (d NOT e)
+	or := cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{atoms["a"].Query(), atoms["b"].Query()})
+	not := cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{atoms["d"].Query(), atoms["e"].Query()})
+	and := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{or, atoms["c"].Query(), not})
+	tree := combinator.LogicalTree{
+		Root: combinator.NewCombinator(and, combinator.AndOperator,
+			combinator.NewCombinator(or, combinator.OrOperator, atoms["a"], atoms["b"]),
+			atoms["c"],
+			combinator.NewCombinator(not, combinator.NotOperator, atoms["d"], atoms["e"])),
+	}
+
+	losses := tree.Attribute([]combinator.Document{1, 2, 3, 4, 7}, cache)
+	want := map[combinator.Document][]string{
+		2: {"/1", "/2/0"},
+		3: {"/2/1"},
+		4: {"/0", "/2/0"},
+		7: {"/0", "/1", "/2/0"},
+	}
+	got := make(map[combinator.Document][]string)
+	for doc, loss := range losses {
+		for _, l := range loss {
+			got[doc] = append(got[doc], l.Path)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	cache := combinator.NewMapQueryCache()
+	atoms := make(map[string]combinator.Atom)
+	for term, docs := range map[string]combinator.Documents{
+		"a": {1, 2},
+		"b": {2, 3},
+		"c": {1, 2, 3},
+		"d": {3},
+	} {
+		k := cqr.NewKeyword(term, fields.TitleAbstract)
+		if err := cache.Set(k, docs); err != nil {
+			t.Fatal(err)
+		}
+		atoms[term] = combinator.NewAtom(k)
+	}
+
+	// ((a OR b) AND c) NOT d
+	or := cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{atoms["a"].Query(), atoms["b"].Query()})
+	and := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{or, atoms["c"].Query()})
+	not := cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{and, atoms["d"].Query()})
+	tree := combinator.LogicalTree{
+		Root: combinator.NewCombinator(not, combinator.NotOperator,
+			combinator.NewCombinator(and, combinator.AndOperator,
+				combinator.NewCombinator(or, combinator.OrOperator, atoms["a"], atoms["b"]),
+				atoms["c"]),
+			atoms["d"]),
+	}
+
+	admitted := tree.Admit([]combinator.Document{1, 2, 3, 4}, cache)
+	want := map[combinator.Document][]string{
+		1: {"/0/0/0"},
+		2: {"/0/0/0", "/0/0/1"},
+	}
+	got := make(map[combinator.Document][]string)
+	for doc, loss := range admitted {
+		for _, l := range loss {
+			if l.Reason != combinator.Admitted {
+				t.Errorf("expected %s to be admitted, got %s", l.Path, l.Reason)
+			}
+			got[doc] = append(got[doc], l.Path)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if losses := tree.Attribute([]combinator.Document{3}, cache); losses[3][0].Reason != combinator.Removed {
+		t.Errorf("expected 3 to be removed, got %v", losses[3])
+	}
+}
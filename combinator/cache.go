@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/hashicorp/golang-lru"
 	"github.com/hscells/cqr"
+	"github.com/hscells/groove/metrics"
 	"github.com/peterbourgon/diskv"
 	"io/ioutil"
 	"os"
@@ -68,8 +69,10 @@ type MapQueryCache struct {
 // Get looks up results in a map.
 func (m MapQueryCache) Get(query cqr.CommonQueryRepresentation) (Documents, error) {
 	if d, ok := m.m[HashCQR(query)]; ok {
+		metrics.Cache("query.memory", true)
 		return d, nil
 	}
+	metrics.Cache("query.memory", false)
 	return Documents{}, ErrCacheMiss
 }
 
@@ -94,6 +97,7 @@ type DiskvQueryCache struct {
 // Get looks up results from disk.
 func (d DiskvQueryCache) Get(query cqr.CommonQueryRepresentation) (Documents, error) {
 	b, err := d.Read(strconv.Itoa(int(HashCQR(query))))
+	metrics.Cache("query.diskv", err == nil)
 	if err != nil {
 		return Documents{}, ErrCacheMiss
 	}
@@ -153,15 +157,18 @@ func NewFileQueryCache(path string) QueryCacher {
 func (f FileQueryCache) Get(query cqr.CommonQueryRepresentation) (Documents, error) {
 	h := HashCQR(query)
 	if v, ok := f.cache.Get(h); ok {
+		metrics.Cache("query.file", true)
 		return v.(Documents), nil
 	}
 
 	fn := path.Join(f.path, fmt.Sprintf("%v", h))
 	if _, err := os.Stat(fn); err != nil && os.IsNotExist(err) {
+		metrics.Cache("query.file", false)
 		return nil, ErrCacheMiss
 	} else if err != nil {
 		return nil, err
 	}
+	metrics.Cache("query.file", true)
 	b, err := ioutil.ReadFile(fn)
 	if err != nil {
 		return nil, err
@@ -366,12 +366,16 @@ func NewCombinator(query cqr.BooleanQuery, operator Operator, clauses ...Logical
 	}
 }
 
-// HashCQR creates a hash of the query.
+// hashVersion is hashed along with the canonical form of queries, so that results cached on disk using the hashes of
+// an earlier canonical form are not reused. It must be changed whenever the canonical form changes.
+const hashVersion = "2"
+
+// HashCQR creates a hash of the canonical form of the query, so logically identical queries have the same hash.
 func HashCQR(representation cqr.CommonQueryRepresentation) uint64 {
 	if representation == nil {
 		return 0
 	}
-	return crc64.Checksum([]byte(representation.String()), crc64.MakeTable(crc64.ISO))
+	return crc64.Checksum([]byte(hashVersion+":"+Canonical(representation)), crc64.MakeTable(crc64.ISO))
 	//h := fnv.New64a()
 	//h.Write([]byte(representation.String()))
 	//return h.Sum64()
@@ -0,0 +1,224 @@
+package combinator
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute"
+	"github.com/hscells/transmute/fields"
+	"sort"
+	"strings"
+)
+
+// fieldAliases maps abbreviated field names onto the canonical field names.
+var fieldAliases = map[string]string{
+	"ti":   fields.Title,
+	"ab":   fields.Abstract,
+	"tiab": fields.TitleAbstract,
+	"tw":   fields.TextWord,
+	"mh":   fields.MeshHeadings,
+	"mesh": fields.MeshHeadings,
+	"majr": fields.MeSHMajorTopic,
+	"sh":   fields.MeSHSubheading,
+	"pt":   fields.PublicationType,
+	"dp":   fields.PublicationDate,
+}
+
+// Normalise rewrites a query into a canonical form without changing the documents it retrieves. Nested clauses with
+// the same operator are flattened, the children of commutative clauses are deduplicated and sorted, field names are
+// canonicalised, options set to their default value are removed, and clauses with a single child are replaced by
+// that child. Logically identical queries have the same normalised form. The query passed in is not modified.
+func Normalise(query cqr.CommonQueryRepresentation) cqr.CommonQueryRepresentation {
+	return normalise(query, normaliseOptions)
+}
+
+// NormaliseClauses rewrites a query into a canonical form like Normalise, but keeps every option as it is set, so
+// that queries can be compared by how they are written as well as by the documents they retrieve.
+func NormaliseClauses(query cqr.CommonQueryRepresentation) cqr.CommonQueryRepresentation {
+	return normalise(query, copyOptions)
+}
+
+func normalise(query cqr.CommonQueryRepresentation, options func(map[string]interface{}) map[string]interface{}) cqr.CommonQueryRepresentation {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		return normaliseKeyword(q, options)
+	case cqr.BooleanQuery:
+		return normaliseBoolean(q, options)
+	}
+	return query
+}
+
+// defaultOptions are options whose value is equivalent to the option not being set. Other options are kept, even when
+// they are false: a MeSH heading that is not exploded (e.g. Humans[mh:noexp]) retrieves different documents to one
+// where explosion is left to the search engine (e.g. Humans[mh]).
+var defaultOptions = map[string]interface{}{
+	"truncated": false,
+}
+
+// normaliseOptions removes options that are set to their default value.
+func normaliseOptions(options map[string]interface{}) map[string]interface{} {
+	normalised := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		if d, ok := defaultOptions[k]; ok && d == v {
+			continue
+		}
+		normalised[k] = v
+	}
+	return normalised
+}
+
+// copyOptions copies options without removing any.
+func copyOptions(options map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		copied[k] = v
+	}
+	return copied
+}
+
+func normaliseKeyword(q cqr.Keyword, options func(map[string]interface{}) map[string]interface{}) cqr.Keyword {
+	q.QueryString = strings.Join(strings.Fields(q.QueryString), " ")
+
+	seen := make(map[string]bool, len(q.Fields))
+	f := make([]string, 0, len(q.Fields))
+	for _, field := range q.Fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if alias, ok := fieldAliases[field]; ok {
+			field = alias
+		}
+		if !seen[field] {
+			seen[field] = true
+			f = append(f, field)
+		}
+	}
+	sort.Strings(f)
+	q.Fields = f
+
+	q.Options = options(q.Options)
+	return q
+}
+
+func normaliseBoolean(q cqr.BooleanQuery, options func(map[string]interface{}) map[string]interface{}) cqr.CommonQueryRepresentation {
+	q.Operator = strings.ToLower(strings.TrimSpace(q.Operator))
+	q.Options = options(q.Options)
+
+	children := make([]cqr.CommonQueryRepresentation, 0, len(q.Children))
+	for _, child := range q.Children {
+		children = append(children, normalise(child, options))
+	}
+
+	switch q.Operator {
+	case cqr.AND, cqr.OR:
+		children = sortChildren(flatten(q.Operator, children))
+	case cqr.NOT:
+		// The first child is what is excluded from, so only the excluded children may be reordered. The
+		// first child may itself be a NOT clause: (a NOT b) NOT c is a NOT b NOT c.
+		if len(children) > 0 {
+			if first, ok := children[0].(cqr.BooleanQuery); ok && first.Operator == cqr.NOT && len(first.Children) > 0 {
+				children = append(append([]cqr.CommonQueryRepresentation{}, first.Children...), children[1:]...)
+			}
+			children = append(children[:1:1], sortChildren(children[1:])...)
+		}
+	}
+	q.Children = children
+
+	// A commutative clause with a single child is that child.
+	if len(q.Children) == 1 && (q.Operator == cqr.AND || q.Operator == cqr.OR) {
+		return q.Children[0]
+	}
+	return q
+}
+
+// flatten lifts the children of nested clauses that have the same operator into the parent clause.
+func flatten(operator string, children []cqr.CommonQueryRepresentation) []cqr.CommonQueryRepresentation {
+	flat := make([]cqr.CommonQueryRepresentation, 0, len(children))
+	for _, child := range children {
+		if c, ok := child.(cqr.BooleanQuery); ok && c.Operator == operator {
+			flat = append(flat, c.Children...)
+			continue
+		}
+		flat = append(flat, child)
+	}
+	return flat
+}
+
+// sortChildren sorts children by their canonical form, removing any duplicates.
+func sortChildren(children []cqr.CommonQueryRepresentation) []cqr.CommonQueryRepresentation {
+	keys := make(map[string]cqr.CommonQueryRepresentation, len(children))
+	for _, child := range children {
+		keys[canonical(child)] = child
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	unique := make([]cqr.CommonQueryRepresentation, len(sorted))
+	for i, k := range sorted {
+		unique[i] = keys[k]
+	}
+	return unique
+}
+
+// canonical serialises an already normalised query deterministically.
+func canonical(query cqr.CommonQueryRepresentation) string {
+	var b strings.Builder
+	writeCanonical(&b, query)
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, query cqr.CommonQueryRepresentation) {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		fmt.Fprintf(b, "%q[%s]", q.QueryString, strings.Join(q.Fields, ","))
+		writeOptions(b, q.Options)
+	case cqr.BooleanQuery:
+		b.WriteString(q.Operator)
+		writeOptions(b, q.Options)
+		b.WriteString("(")
+		for i, child := range q.Children {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			writeCanonical(b, child)
+		}
+		b.WriteString(")")
+	case nil:
+	default:
+		b.WriteString(q.String())
+	}
+}
+
+// writeOptions writes options in order of their keys.
+func writeOptions(b *strings.Builder, options map[string]interface{}) {
+	if len(options) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(b, "%s=%v", k, options[k])
+	}
+	b.WriteString("}")
+}
+
+// Canonical is the canonical form of a query, which is the same for any logically identical queries.
+func Canonical(query cqr.CommonQueryRepresentation) string {
+	return canonical(Normalise(query))
+}
+
+// PubMedString writes a query in PubMed syntax, falling back to the string representation of the query when it cannot
+// be compiled.
+func PubMedString(query cqr.CommonQueryRepresentation) string {
+	s, err := transmute.CompileCqr2PubMed(query)
+	if err != nil {
+		return query.String()
+	}
+	return s
+}
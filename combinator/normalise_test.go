@@ -0,0 +1,67 @@
+package combinator_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/transmute/fields"
+	"testing"
+)
+
+func TestNormalise(t *testing.T) {
+	a := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("heart  attack", fields.TitleAbstract),
+			cqr.NewKeyword("myocardial infarction", fields.TitleAbstract),
+		}),
+		cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("Humans", fields.MeshHeadings).SetOption("truncated", false),
+		}),
+	})
+	b := cqr.NewBooleanQuery("AND", []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("Humans", "mh"),
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("myocardial infarction", fields.TitleAbstract),
+			cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+				cqr.NewKeyword("heart attack", "tiab"),
+				cqr.NewKeyword("myocardial infarction", fields.TitleAbstract),
+			}),
+		}),
+	})
+
+	if combinator.Canonical(a) != combinator.Canonical(b) {
+		t.Errorf("expected logically identical queries to have the same canonical form:\n%s\n%s", combinator.Canonical(a), combinator.Canonical(b))
+	}
+	if combinator.HashCQR(a) != combinator.HashCQR(b) {
+		t.Errorf("expected logically identical queries to have the same hash")
+	}
+
+	n, ok := combinator.Normalise(b).(cqr.BooleanQuery)
+	if !ok {
+		t.Fatalf("expected normalised query to be a Boolean query")
+	}
+	if len(n.Children) != 2 {
+		t.Errorf("expected 2 children, got %d", len(n.Children))
+	}
+	if len(b.Children) != 2 || b.Children[0].(cqr.Keyword).Fields[0] != "mh" {
+		t.Errorf("expected original query to be unmodified")
+	}
+
+	exploded := cqr.NewKeyword("Humans", fields.MeshHeadings).SetOption(cqr.ExplodedString, true)
+	notExploded := cqr.NewKeyword("Humans", fields.MeshHeadings).SetOption(cqr.ExplodedString, false)
+	unset := cqr.NewKeyword("Humans", fields.MeshHeadings)
+	if combinator.HashCQR(notExploded) == combinator.HashCQR(unset) || combinator.HashCQR(notExploded) == combinator.HashCQR(exploded) {
+		t.Errorf("expected a MeSH heading that is not exploded to differ from other MeSH headings")
+	}
+
+	c := cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("animals", fields.MeshHeadings),
+		cqr.NewKeyword("humans", fields.MeshHeadings),
+	})
+	d := cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("humans", fields.MeshHeadings),
+		cqr.NewKeyword("animals", fields.MeshHeadings),
+	})
+	if combinator.Canonical(c) == combinator.Canonical(d) {
+		t.Errorf("expected NOT clauses with different first children to differ")
+	}
+}
@@ -0,0 +1,225 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/trecresults"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExplanationFeatures are the names of the features of an explanation (see Explanation.Features), in order.
+var ExplanationFeatures = []string{
+	"Missed",
+	"Noise",
+	"MaxClauseExcluded",
+	"MaxClauseRemoved",
+	"MaxClauseAdmitted",
+	"ExcludingClauses",
+	"RemovingClauses",
+}
+
+// ExplainedClause is a clause of a query that is responsible for a document being retrieved or not.
+type ExplainedClause struct {
+	Path   string            `json:"path"`
+	Query  string            `json:"query"`
+	Reason combinator.Reason `json:"reason"`
+}
+
+// DocumentExplanation is a document and the clauses of a query responsible for it being retrieved or not.
+type DocumentExplanation struct {
+	Document string            `json:"document"`
+	Clauses  []ExplainedClause `json:"clauses"`
+}
+
+// ClauseExplanation is the number of documents a clause of a query is responsible for.
+type ClauseExplanation struct {
+	Path  string `json:"path"`
+	Query string `json:"query"`
+	// Excluded and Removed are the number of relevant documents the clause is responsible for missing.
+	Excluded int `json:"excluded"`
+	Removed  int `json:"removed"`
+	// Admitted is the number of non-relevant documents the clause is responsible for retrieving.
+	Admitted int `json:"admitted"`
+}
+
+// Explanation explains why a query misses relevant documents and retrieves non-relevant documents.
+type Explanation struct {
+	Topic     string `json:"topic"`
+	Retrieved int    `json:"retrieved"`
+	Relevant  int    `json:"relevant"`
+	// Missed are the relevant documents that are not retrieved, with the `and` branches that excluded them and the
+	// `not` clauses that removed them.
+	Missed []DocumentExplanation `json:"missed"`
+	// Noise are the non-relevant documents that are retrieved, with the `or` branches that admitted them.
+	Noise []DocumentExplanation `json:"noise"`
+	// Clauses are the clauses responsible for any missed or non-relevant documents, ordered by path.
+	Clauses []ClauseExplanation `json:"clauses"`
+}
+
+// Explain explains why the query of a logical tree misses the relevant documents in qrels, and why it retrieves the
+// non-relevant documents it does (see combinator.LogicalTree.Attribute and combinator.LogicalTree.Admit).
+func Explain(q pipeline.Query, tree combinator.LogicalTree, cache combinator.QueryCacher, qrels trecresults.Qrels) Explanation {
+	docs := tree.Documents(cache)
+	retrieved := docs.Set()
+
+	relevant := make(map[combinator.Document]struct{})
+	var missed []combinator.Document
+	for _, qrel := range qrels {
+		if qrel.Score <= RelevanceGrade {
+			continue
+		}
+		id, err := strconv.Atoi(qrel.DocId)
+		if err != nil {
+			continue
+		}
+		relevant[combinator.Document(id)] = struct{}{}
+		if _, ok := retrieved[combinator.Document(id)]; !ok {
+			missed = append(missed, combinator.Document(id))
+		}
+	}
+	var noise []combinator.Document
+	for _, doc := range docs {
+		if _, ok := relevant[doc]; !ok {
+			noise = append(noise, doc)
+		}
+	}
+
+	e := Explanation{
+		Topic:     q.Topic,
+		Retrieved: len(docs),
+		Relevant:  len(relevant),
+		Missed:    explainDocuments(tree.Attribute(missed, cache)),
+		Noise:     explainDocuments(tree.Admit(noise, cache)),
+	}
+
+	clauses := make(map[string]*ClauseExplanation)
+	for _, d := range append(append([]DocumentExplanation{}, e.Missed...), e.Noise...) {
+		for _, c := range d.Clauses {
+			ce, ok := clauses[c.Path]
+			if !ok {
+				ce = &ClauseExplanation{Path: c.Path, Query: c.Query}
+				clauses[c.Path] = ce
+			}
+			switch c.Reason {
+			case combinator.Excluded:
+				ce.Excluded++
+			case combinator.Removed:
+				ce.Removed++
+			case combinator.Admitted:
+				ce.Admitted++
+			}
+		}
+	}
+	e.Clauses = make([]ClauseExplanation, 0, len(clauses))
+	for _, c := range clauses {
+		e.Clauses = append(e.Clauses, *c)
+	}
+	sort.Slice(e.Clauses, func(i, j int) bool {
+		return e.Clauses[i].Path < e.Clauses[j].Path
+	})
+	return e
+}
+
+// explainDocuments converts the clauses blamed for documents into explanations, ordered by document.
+func explainDocuments(blames map[combinator.Document][]combinator.Loss) []DocumentExplanation {
+	docs := make([]combinator.Document, 0, len(blames))
+	for doc := range blames {
+		docs = append(docs, doc)
+	}
+	sort.Sort(combinator.Documents(docs))
+
+	// The same clauses are blamed for many documents, so each clause is only compiled once.
+	compiled := make(map[string]string)
+	explanations := make([]DocumentExplanation, len(docs))
+	for i, doc := range docs {
+		explanations[i].Document = doc.String()
+		for _, b := range blames[doc] {
+			q, ok := compiled[b.Path]
+			if !ok {
+				q = combinator.PubMedString(b.Query)
+				compiled[b.Path] = q
+			}
+			explanations[i].Clauses = append(explanations[i].Clauses, ExplainedClause{
+				Path:   b.Path,
+				Query:  q,
+				Reason: b.Reason,
+			})
+		}
+	}
+	return explanations
+}
+
+// Features summarises an explanation as features (see ExplanationFeatures). The maximum number of documents any one
+// clause is responsible for is normalised by the number of missed or non-relevant documents.
+func (e Explanation) Features() map[string]float64 {
+	var excluded, removed, admitted, excluding, removing float64
+	for _, c := range e.Clauses {
+		excluded = math.Max(excluded, float64(c.Excluded))
+		removed = math.Max(removed, float64(c.Removed))
+		admitted = math.Max(admitted, float64(c.Admitted))
+		if c.Excluded > 0 {
+			excluding++
+		}
+		if c.Removed > 0 {
+			removing++
+		}
+	}
+	return map[string]float64{
+		"Missed":            float64(len(e.Missed)),
+		"Noise":             float64(len(e.Noise)),
+		"MaxClauseExcluded": ratio(excluded, len(e.Missed)),
+		"MaxClauseRemoved":  ratio(removed, len(e.Missed)),
+		"MaxClauseAdmitted": ratio(admitted, len(e.Noise)),
+		"ExcludingClauses":  excluding,
+		"RemovingClauses":   removing,
+	}
+}
+
+func ratio(n float64, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return n / float64(d)
+}
+
+// WriteMarkdown writes an explanation as a Markdown report: the clauses responsible for missed and non-relevant
+// documents, followed by each missed relevant document. Non-relevant documents are only summarised by clause, as
+// there are usually too many to list.
+func (e Explanation) WriteMarkdown(w io.Writer) error {
+	escape := strings.NewReplacer("|", `\|`).Replace
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", e.Topic)
+	fmt.Fprintf(&b, "%d retrieved, %d of %d relevant missed, %d non-relevant retrieved.\n\n",
+		e.Retrieved, len(e.Missed), e.Relevant, len(e.Noise))
+
+	if len(e.Clauses) > 0 {
+		b.WriteString("| Clause | Query | Excluded | Removed | Admitted |\n")
+		b.WriteString("| --- | --- | ---: | ---: | ---: |\n")
+		for _, c := range e.Clauses {
+			fmt.Fprintf(&b, "| %s | `%s` | %d | %d | %d |\n", c.Path, escape(c.Query), c.Excluded, c.Removed, c.Admitted)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(e.Missed) > 0 {
+		b.WriteString("| Missed | Clauses |\n")
+		b.WriteString("| --- | --- |\n")
+		for _, d := range e.Missed {
+			clauses := make([]string, len(d.Clauses))
+			for i, c := range d.Clauses {
+				clauses[i] = fmt.Sprintf("%s %s", c.Path, c.Reason)
+			}
+			fmt.Fprintf(&b, "| %s | %s |\n", d.Document, strings.Join(clauses, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
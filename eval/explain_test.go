@@ -0,0 +1,61 @@
+package eval_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/transmute/fields"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	cache := combinator.NewMapQueryCache()
+	a := cqr.NewKeyword("a", fields.TitleAbstract)
+	b := cqr.NewKeyword("b", fields.TitleAbstract)
+	c := cqr.NewKeyword("c", fields.TitleAbstract)
+	for i, docs := range []combinator.Documents{{1, 2}, {3, 4}, {1, 3, 5}} {
+		if err := cache.Set([]cqr.Keyword{a, b, c}[i], docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// (a OR b) AND c retrieves 1 and 3.
+	or := cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{a, b})
+	and := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{or, c})
+	tree := combinator.LogicalTree{
+		Root: combinator.NewCombinator(and, combinator.AndOperator,
+			combinator.NewCombinator(or, combinator.OrOperator, combinator.NewAtom(a), combinator.NewAtom(b)),
+			combinator.NewAtom(c)),
+	}
+	q := pipeline.NewQuery("1", "1", and)
+	qrels := eval.Seeds{"1": {"1", "2", "5"}}.Qrels().Qrels["1"]
+
+	e := eval.Explain(q, tree, cache, qrels)
+	if e.Retrieved != 2 || e.Relevant != 3 || len(e.Missed) != 2 || len(e.Noise) != 1 {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+	if m := e.Missed[0]; m.Document != "2" || len(m.Clauses) != 1 || m.Clauses[0].Path != "/1" {
+		t.Errorf("expected 2 to be excluded by /1, got %+v", m)
+	}
+	if m := e.Missed[1]; m.Document != "5" || len(m.Clauses) != 1 || m.Clauses[0].Path != "/0" {
+		t.Errorf("expected 5 to be excluded by /0, got %+v", m)
+	}
+	if n := e.Noise[0]; n.Document != "3" || len(n.Clauses) != 1 || n.Clauses[0].Path != "/0/1" {
+		t.Errorf("expected 3 to be admitted by /0/1, got %+v", n)
+	}
+
+	features := e.Features()
+	if features["MaxClauseExcluded"] != 0.5 || features["ExcludingClauses"] != 2 {
+		t.Errorf("unexpected features %v", features)
+	}
+
+	var s strings.Builder
+	if err := e.WriteMarkdown(&s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s.String(), "| 2 | /1 excluded |") {
+		t.Errorf("expected missed documents in report, got:\n%s", s.String())
+	}
+}
@@ -0,0 +1,205 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/trecresults"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SeedsMetadataKey is the key of the seed studies in the extra metadata of a query, as a list of PMIDs separated by
+// commas or spaces.
+const SeedsMetadataKey = "seeds"
+
+var (
+	// RelativeRecall is the recall of a set of seed studies, which estimates the recall of a query when the full set
+	// of relevant studies is not known.
+	RelativeRecall = relativeRecall{}
+	// ScreeningHours estimates the time, in hours, it takes to screen the title and abstract of every retrieved
+	// study, at thirty seconds per study.
+	ScreeningHours = ScreeningWorkload{MinutesPerStudy: 0.5}
+)
+
+type relativeRecall struct{}
+
+// ScreeningWorkload estimates the time, in hours, it takes to screen the studies retrieved by a query.
+type ScreeningWorkload struct {
+	MinutesPerStudy float64
+}
+
+func (relativeRecall) Score(results *trecresults.ResultList, qrels trecresults.Qrels) float64 {
+	return Recall.Score(results, qrels)
+}
+
+func (relativeRecall) Name() string {
+	return "RelativeRecall"
+}
+
+func (s ScreeningWorkload) Score(results *trecresults.ResultList, qrels trecresults.Qrels) float64 {
+	return float64(len(*results)) * s.MinutesPerStudy / 60
+}
+
+func (s ScreeningWorkload) Name() string {
+	return "ScreeningWorkload"
+}
+
+// Seeds are the PMIDs of the known relevant studies (seed studies) of topics.
+type Seeds map[string][]string
+
+// ReadSeeds reads the seed studies of topics from a tab-separated file. Each line contains a topic followed by one
+// or more PMIDs, separated by tabs, commas or spaces, and a topic may span several lines, e.g.:
+//
+//	CD008122	25367456	20818732
+//	CD008122	19923471
+//	CD008587	23418354,21335232
+//
+// Empty lines and lines starting with # are ignored.
+func ReadSeeds(r io.Reader) (Seeds, error) {
+	seeds := make(Seeds)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("line %d: expected topic and seed studies", n)
+		}
+		topic := strings.TrimSpace(parts[0])
+		seeds[topic] = append(seeds[topic], splitSeeds(parts[1])...)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// LoadSeeds loads the seed studies of topics from a file (see ReadSeeds).
+func LoadSeeds(path string) (Seeds, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seeds, err := ReadSeeds(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return seeds, nil
+}
+
+// SeedsFromMetadata reads the seed studies of queries from their metadata (see SeedsMetadataKey). Queries without
+// seed studies are not included.
+func SeedsFromMetadata(queries ...pipeline.Query) Seeds {
+	seeds := make(Seeds)
+	for _, q := range queries {
+		if v, ok := q.Metadata.Get(SeedsMetadataKey); ok {
+			if pmids := splitSeeds(v); len(pmids) > 0 {
+				seeds[q.Topic] = append(seeds[q.Topic], pmids...)
+			}
+		}
+	}
+	return seeds
+}
+
+// splitSeeds splits a list of PMIDs separated by tabs, commas or spaces.
+func splitSeeds(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// Qrels creates relevance assessments in which the seed studies are relevant, so that seed studies can be used with
+// any evaluator (e.g. Recall is the relative recall and NumRelRet is the number of seed studies found).
+func (s Seeds) Qrels() trecresults.QrelsFile {
+	f := trecresults.QrelsFile{Qrels: make(map[string]trecresults.Qrels, len(s))}
+	for topic, pmids := range s {
+		qrels := make(trecresults.Qrels, len(pmids))
+		for _, pmid := range pmids {
+			qrels[pmid] = &trecresults.Qrel{
+				Topic:     topic,
+				Iteration: "0",
+				DocId:     pmid,
+				Score:     RelevanceGrade + 1,
+			}
+		}
+		f.Qrels[topic] = qrels
+	}
+	return f
+}
+
+// SeedLoss is a seed study that a query does not retrieve, and the clauses of the query responsible.
+type SeedLoss struct {
+	Seed    string       `json:"seed"`
+	Clauses []LostClause `json:"clauses"`
+}
+
+// LostClause is a clause of a query that is responsible for a seed study not being retrieved.
+type LostClause struct {
+	Path  string `json:"path"`
+	Query string `json:"query"`
+}
+
+// SeedReport is the evaluation of a query using seed studies.
+type SeedReport struct {
+	Topic          string     `json:"topic"`
+	Seeds          int        `json:"seeds"`
+	Retrieved      int        `json:"retrieved"`
+	Found          []string   `json:"found"`
+	Lost           []SeedLoss `json:"lost,omitempty"`
+	RelativeRecall float64    `json:"relative_recall"`
+	// ScreeningHours is the estimated time to screen the retrieved studies (see ScreeningWorkload).
+	ScreeningHours float64 `json:"screening_hours"`
+}
+
+// NewSeedReport evaluates the query of a logical tree using seed studies. Seed studies that are not retrieved are
+// attributed to the clauses that lost them (see combinator.LogicalTree.Attribute).
+func NewSeedReport(q pipeline.Query, tree combinator.LogicalTree, cache combinator.QueryCacher, seeds []string, workload ScreeningWorkload) SeedReport {
+	docs := tree.Documents(cache)
+	results := docs.Results(q, q.Topic)
+	qrels := Seeds{q.Topic: seeds}.Qrels().Qrels[q.Topic]
+
+	report := SeedReport{
+		Topic:          q.Topic,
+		Seeds:          len(qrels),
+		Retrieved:      len(docs),
+		Found:          []string{},
+		RelativeRecall: RelativeRecall.Score(&results, qrels),
+		ScreeningHours: workload.Score(&results, qrels),
+	}
+
+	retrieved := docs.Set()
+	var lost []combinator.Document
+	for pmid := range qrels {
+		id, err := strconv.Atoi(pmid)
+		if err != nil {
+			report.Lost = append(report.Lost, SeedLoss{Seed: pmid})
+			continue
+		}
+		if _, ok := retrieved[combinator.Document(id)]; ok {
+			report.Found = append(report.Found, pmid)
+			continue
+		}
+		lost = append(lost, combinator.Document(id))
+	}
+	for doc, losses := range tree.Attribute(lost, cache) {
+		loss := SeedLoss{Seed: doc.String()}
+		for _, l := range losses {
+			loss.Clauses = append(loss.Clauses, LostClause{Path: l.Path, Query: combinator.PubMedString(l.Query)})
+		}
+		report.Lost = append(report.Lost, loss)
+	}
+
+	sort.Strings(report.Found)
+	sort.Slice(report.Lost, func(i, j int) bool {
+		return report.Lost[i].Seed < report.Lost[j].Seed
+	})
+	return report
+}
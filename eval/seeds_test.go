@@ -0,0 +1,49 @@
+package eval_test
+
+import (
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/trecresults"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeeds(t *testing.T) {
+	seeds, err := eval.ReadSeeds(strings.NewReader(`# topic	pmids
+CD008122	25367456	20818732
+CD008122	19923471
+
+CD008587	23418354,21335232
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := eval.Seeds{
+		"CD008122": {"25367456", "20818732", "19923471"},
+		"CD008587": {"23418354", "21335232"},
+	}
+	if !reflect.DeepEqual(seeds, want) {
+		t.Errorf("expected %v, got %v", want, seeds)
+	}
+
+	q := pipeline.NewQuery("CD008587", "CD008587", nil)
+	q.Metadata.Set(eval.SeedsMetadataKey, "23418354, 21335232")
+	if m := eval.SeedsFromMetadata(q); !reflect.DeepEqual(m, eval.Seeds{"CD008587": want["CD008587"]}) {
+		t.Errorf("expected seeds from metadata, got %v", m)
+	}
+
+	results := trecresults.ResultList{
+		{Topic: "CD008122", DocId: "25367456"},
+		{Topic: "CD008122", DocId: "1"},
+		{Topic: "CD008122", DocId: "19923471"},
+		{Topic: "CD008122", DocId: "2"},
+	}
+	qrels := seeds.Qrels().Qrels["CD008122"]
+	if rr := eval.RelativeRecall.Score(&results, qrels); rr != 2.0/3.0 {
+		t.Errorf("expected relative recall of 2/3, got %f", rr)
+	}
+	if h := eval.ScreeningHours.Score(&results, qrels); h != 2.0/60.0 {
+		t.Errorf("expected 2 minutes of screening, got %f hours", h)
+	}
+}
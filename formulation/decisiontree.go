@@ -5,499 +5,422 @@ import (
 	"github.com/bbalet/stopwords"
 	"github.com/hscells/cqr"
 	"github.com/hscells/groove/combinator"
-	"github.com/hscells/groove/stats"
 	"github.com/hscells/guru"
 	"github.com/hscells/transmute/fields"
-	"github.com/xtgo/set"
-	"gopkg.in/cheggaaa/pb.v1"
-	"gopkg.in/jdkato/prose.v2"
 	"math"
 	"sort"
 	"strings"
+	"unicode"
 )
 
+// DecisionTreeFormulator formulates a query by learning rules that separate relevant studies from non-relevant
+// studies. A cost-sensitive decision tree is grown over the attributes of the studies (see AttributeExtractor), and
+// the paths of the tree that lead to relevant studies are converted into a minimal query in disjunctive normal form.
 type DecisionTreeFormulator struct {
 	// Topic of the query.
 	topic string
-	// Terms identified as candidate query terms.
-	N        [][]string          // Attributes.
-	labels   map[string]bool     // map[pmid] -> TRUE?FALSE
-	training map[string][]string // map[attribute] -> []pmid
 
-	statistics stats.EntrezStatisticsSource
-}
-
-type invertedIndex map[string]map[string]float64
-
-type leaf int
-
-const (
-	NA    leaf = 0
-	TRUE       = 1
-	FALSE      = -1
-)
+	positive, negative guru.MedlineDocuments
+	extractors         []AttributeExtractor
 
-type tree struct {
-	value leaf
-
-	left      *tree
-	candidate string
-	right     *tree
+	// cost is the cost of missing a relevant study relative to retrieving a non-relevant study.
+	cost       float64
+	maxDepth   int
+	minSupport int
 }
 
-//func insert(t *tree, candidate string, value bool)
+// DecisionTreeOption configures a decision tree formulator.
+type DecisionTreeOption func(dt *DecisionTreeFormulator)
 
-func buildIndex(docs guru.MedlineDocuments, index invertedIndex) error {
-	bar := pb.StartNew(len(docs))
-	for _, doc := range docs {
-		clean := stopwords.CleanString(fmt.Sprintf("%s. %s", doc.TI, doc.AB), "en", false)
-		d, err := prose.NewDocument(clean)
-		if err != nil {
-			return err
-		}
-		for _, term := range d.Tokens() {
-			if _, ok := index[term.Text]; !ok {
-				index[term.Text] = make(map[string]float64)
-			}
-			index[term.Text][doc.PMID]++
-		}
-		bar.Increment()
+// DecisionTreeAttributes sets the attributes of studies the tree is grown over (default: title and abstract terms,
+// MeSH headings, and publication types).
+func DecisionTreeAttributes(extractors ...AttributeExtractor) DecisionTreeOption {
+	return func(dt *DecisionTreeFormulator) {
+		dt.extractors = extractors
 	}
-	bar.Finish()
-	return nil
 }
 
-func (i invertedIndex) tf(term string, pmid string) float64 {
-	return i[term][pmid]
+// DecisionTreeCost sets the cost of missing a relevant study relative to retrieving a non-relevant study (default:
+// 4). Costs greater than one favour recall over precision.
+func DecisionTreeCost(cost float64) DecisionTreeOption {
+	return func(dt *DecisionTreeFormulator) {
+		dt.cost = cost
+	}
 }
 
-func (i invertedIndex) df(term string, pmid string) float64 {
-	return float64(len(i[term]))
+// DecisionTreeMaxDepth sets the maximum depth of the tree, and so the maximum number of conditions of a rule
+// (default: 4).
+func DecisionTreeMaxDepth(depth int) DecisionTreeOption {
+	return func(dt *DecisionTreeFormulator) {
+		dt.maxDepth = depth
+	}
 }
 
-func Entropy(positive, negative float64) float64 {
-	if negative == 0 || positive == 0 {
-		return 0
+// DecisionTreeMinSupport sets the minimum number of relevant studies an attribute must appear in, and a rule must
+// identify, to be learned (default: 2).
+func DecisionTreeMinSupport(support int) DecisionTreeOption {
+	return func(dt *DecisionTreeFormulator) {
+		dt.minSupport = support
 	}
-	samples := positive + negative
-	return -((positive / samples) * math.Log2(positive/samples)) - ((negative / samples) * math.Log2(negative/samples))
 }
 
-func InformationGain(attr string, training map[string][]string, labels map[string]bool) (float64, []string, []string) {
-	var (
-		numPos, numNeg                 float64
-		lhsPos, lhsNeg, rhsPos, rhsNeg float64
-		lhs, rhs                       []string
-	)
-
-	for _, label := range labels {
-		if label {
-			numPos++
-		} else {
-			numNeg++
-		}
+// NewDecisionTreeFormulator creates a new decision tree formulator for a topic, which learns from relevant (positive)
+// and non-relevant (negative) studies.
+func NewDecisionTreeFormulator(topic string, positive, negative guru.MedlineDocuments, options ...DecisionTreeOption) (*DecisionTreeFormulator, error) {
+	if len(positive) == 0 || len(negative) == 0 {
+		return nil, fmt.Errorf("both relevant and non-relevant studies are needed to learn from for topic %s", topic)
 	}
-
-	for pmidLabel, label := range labels {
-		pmidFound := false
-		for _, pmidAttr := range training[attr] {
-			if pmidLabel == pmidAttr {
-				pmidFound = true
-				break
-			}
-		}
-		if pmidFound {
-			lhs = append(lhs, pmidLabel)
-			if label {
-				lhsPos++
-			} else {
-				lhsNeg++
-			}
-		} else {
-			rhs = append(rhs, pmidLabel)
-			if label {
-				rhsPos++
-			} else {
-				rhsNeg++
-			}
-		}
+	dt := &DecisionTreeFormulator{
+		topic:      topic,
+		positive:   positive,
+		negative:   negative,
+		extractors: []AttributeExtractor{TitleAbstractAttributes, MeSHAttributes, PublicationTypeAttributes},
+		cost:       4,
+		maxDepth:   4,
+		minSupport: 2,
 	}
-
-	if numPos == 0 || numNeg == 0 {
-		return 0, lhs, rhs
+	for _, option := range options {
+		option(dt)
 	}
-
-	entropy := Entropy(numPos, numNeg)
-
-	var (
-		a, b float64
-	)
-	a = ((lhsPos + lhsNeg) / (numPos + numNeg)) * Entropy(lhsPos, lhsNeg)
-	b = ((rhsPos + rhsNeg) / (numPos + numNeg)) * Entropy(rhsPos, rhsNeg)
-
-	//fmt.Println(attr, numPos, numNeg, entropy)
-	//fmt.Println(attr, lhsPos, lhsNeg, (lhsPos+lhsNeg)/(numPos+numNeg), Entropy(lhsPos, lhsNeg))
-	//fmt.Println(attr, rhsPos, rhsNeg, (rhsPos+rhsNeg)/(numPos+numNeg), Entropy(rhsPos, rhsNeg))
-	//fmt.Println(attr, entropy, a, b)
-
-	return entropy - (a + b), lhs, rhs
+	return dt, nil
 }
 
-type _pmids []string
-
-func (p _pmids) Len() int {
-	return len(p)
+// Attribute is a property of a study that a decision tree can split on, such as a term in the title or abstract.
+type Attribute struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
 }
 
-func (p _pmids) Less(i, j int) bool {
-	return p[i] < p[j]
+func (a Attribute) String() string {
+	return fmt.Sprintf("%s[%s]", a.Value, a.Field)
 }
 
-func (p _pmids) Swap(i, j int) {
-	p[i], p[j] = p[j], p[i]
+// Keyword is the keyword of a query that matches studies with the attribute. MeSH headings are not exploded, as a
+// study is only indexed with the heading itself.
+func (a Attribute) Keyword() cqr.Keyword {
+	k := cqr.NewKeyword(a.Value, a.Field)
+	if a.Field == fields.MeshHeadings {
+		k = k.SetOption(cqr.ExplodedString, false).(cqr.Keyword)
+	}
+	return k
 }
 
-func ID3(training map[string][]string, labels map[string]bool, attrs []string) *tree {
-	// Create a root node for the tree.
-	node := &tree{}
+// AttributeExtractor extracts the attributes of a study.
+type AttributeExtractor func(doc guru.MedlineDocument) []Attribute
 
-	var (
-		posLabels, negLabels int
-	)
-	for _, label := range labels {
-		if label {
-			posLabels++
-		} else {
-			negLabels++
+// TitleAbstractAttributes are the terms in the title and abstract of a study, excluding stopwords and terms shorter
+// than three characters.
+func TitleAbstractAttributes(doc guru.MedlineDocument) []Attribute {
+	clean := stopwords.CleanString(strings.ToLower(fmt.Sprintf("%s. %s", doc.TI, doc.AB)), "en", false)
+	terms := strings.FieldsFunc(clean, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-'
+	})
+	var attrs []Attribute
+	for _, term := range terms {
+		term = strings.Trim(term, "-")
+		if len(term) < 3 {
+			continue
 		}
+		attrs = append(attrs, Attribute{Field: fields.TitleAbstract, Value: term})
 	}
+	return attrs
+}
 
-	// If all examples are positive, Return the single-node tree Root, with label = +.
-	if posLabels == len(labels) {
-		node.value = TRUE
-		return node
-	}
-
-	// If all examples are negative, Return the single-node tree Root, with label = -.
-	if negLabels == len(labels) {
-		node.value = FALSE
-		return node
-	}
-
-	// If number of predicting attributes is empty, then Return the single node tree Root,
-	// with label = most common value of the target attribute in the examples.
-	if len(attrs) == 0 {
-		if posLabels >= negLabels {
-			node.value = TRUE
-		} else {
-			node.value = FALSE
+// MeSHAttributes are the MeSH headings of a study, without subheadings or major topic markers.
+func MeSHAttributes(doc guru.MedlineDocument) []Attribute {
+	attrs := make([]Attribute, 0, len(doc.MH))
+	for _, mh := range doc.MH {
+		mh = strings.TrimSpace(strings.Replace(strings.Split(mh, "/")[0], "*", "", -1))
+		if len(mh) > 0 {
+			attrs = append(attrs, Attribute{Field: fields.MeshHeadings, Value: mh})
 		}
-		return node
 	}
+	return attrs
+}
 
-	// bestAttr is the Attribute that best classifies examples.
-	var (
-		bestAttr string
-		lhs, rhs []string
-		attrIdx  int
-	)
-	gain := 0.0
-	for i, attr := range attrs {
-		g, l, r := InformationGain(attr, training, labels)
-		if g > gain {
-			bestAttr = attr
-			gain = g
-			lhs, rhs = l, r
-			attrIdx = i
+// PublicationTypeAttributes are the publication types of a study.
+func PublicationTypeAttributes(doc guru.MedlineDocument) []Attribute {
+	attrs := make([]Attribute, 0, len(doc.PT))
+	for _, pt := range doc.PT {
+		if pt = strings.TrimSpace(pt); len(pt) > 0 {
+			attrs = append(attrs, Attribute{Field: fields.PublicationType, Value: pt})
 		}
 	}
+	return attrs
+}
 
-	if gain == 0 {
-		node.value = FALSE
-		return node
+// Entropy is the entropy of a set of positive and negative examples.
+func Entropy(positive, negative float64) float64 {
+	if negative == 0 || positive == 0 {
+		return 0
 	}
+	samples := positive + negative
+	return -((positive / samples) * math.Log2(positive/samples)) - ((negative / samples) * math.Log2(negative/samples))
+}
 
-	// Decision Tree attribute for Root = A.
-	node.candidate = bestAttr
+// ruleTree is a node of a decision tree. Leaves have no children, and are labelled relevant or not.
+type ruleTree struct {
+	attribute Attribute
+	// present and absent are the subtrees of studies with and without the attribute.
+	present, absent *ruleTree
 
-	// For each possible value, vi, of A,
+	relevant bool
+}
 
-	// If Examples(vi) is empty then below this new branch add a leaf node
-	// with label = most common target value in the examples.
-	if len(lhs) == 0 {
-		if posLabels >= negLabels {
-			node.left = &tree{
-				value: TRUE,
-			}
-		} else {
-			node.left = &tree{
-				value: FALSE,
-			}
-		}
-	} else { // Else below this new branch add the subtree ID3().
-		t := make(map[string][]string)
-		for attr, pmids := range training {
-			p := append(_pmids(pmids), _pmids(lhs)...)
-			s := set.Union(p, len(pmids))
-			t[attr] = p[:s]
-		}
-		// Only retain labels for attribute.
-		l := make(map[string]bool)
-		for pmidLabel, label := range labels {
-			pmidFound := false
-			for _, pmidAttr := range lhs {
-				if pmidLabel == pmidAttr {
-					pmidFound = true
-					break
-				}
-			}
-			if !pmidFound {
-				l[pmidLabel] = label
-			}
-		}
-		a := make([]string, len(attrs)-1)
-		copy(a[:attrIdx], attrs[:attrIdx])
-		copy(a[attrIdx:], attrs[attrIdx+1:])
-		node.left = ID3(t, l, a)
-	}
+func (t *ruleTree) leaf() bool {
+	return t.present == nil
+}
 
-	if len(rhs) == 0 {
-		if posLabels >= negLabels {
-			node.right = &tree{
-				value: TRUE,
-			}
-		} else {
-			node.right = &tree{
-				value: FALSE,
-			}
-		}
-	} else {
-		t := make(map[string][]string)
-		for attr, pmids := range training {
-			p := append(_pmids(pmids), _pmids(rhs)...)
-			s := set.Union(p, len(pmids))
-			t[attr] = p[:s]
-		}
-		l := make(map[string]bool)
-		for pmidLabel, label := range labels {
-			pmidFound := false
-			for _, pmidAttr := range rhs {
-				if pmidLabel == pmidAttr {
-					pmidFound = true
-					break
-				}
-			}
-			if !pmidFound {
-				l[pmidLabel] = label
-			}
+// rules are the conjunctions of conditions along the paths of the tree to leaves labelled relevant.
+func (t *ruleTree) rules(conditions []Condition) RuleSet {
+	if t.leaf() {
+		if !t.relevant {
+			return nil
 		}
-		a := make([]string, len(attrs)-1)
-		copy(a[:attrIdx], attrs[:attrIdx])
-		copy(a[attrIdx:], attrs[attrIdx+1:])
-		node.right = ID3(t, l, a)
+		return RuleSet{{Conditions: append([]Condition(nil), conditions...)}}
 	}
-
-	return node
+	n := len(conditions)
+	rules := t.present.rules(append(conditions[:n:n], Condition{Attribute: t.attribute}))
+	return append(rules, t.absent.rules(append(conditions[:n:n], Condition{Attribute: t.attribute, Negated: true}))...)
 }
 
-func (t *tree) walk(n int) {
-	for i := 0; i < n; i++ {
-		fmt.Print(".")
-	}
-	if t.value == NA {
-		fmt.Printf("(%s)\n", t.candidate)
-		fmt.Print("Y")
-		t.left.walk(n + 1)
-		fmt.Print("N")
-		t.right.walk(n + 1)
-	} else {
-		fmt.Printf("=> [%v]\n", t.value)
-	}
+// learner grows cost-sensitive decision trees over the attributes of labelled studies.
+type learner struct {
+	labels []bool
+	// postings are the studies each attribute appears in, in order.
+	postings map[Attribute][]int
+	// attributes are the attributes that may be split on, in order.
+	attributes []Attribute
+
+	cost       float64
+	maxDepth   int
+	minSupport int
 }
 
-func (t *tree) extract() []cqr.CommonQueryRepresentation {
-	var bqs []cqr.CommonQueryRepresentation
-
-	if t.left.value == TRUE || t.right.value == TRUE {
-		bqs = append(bqs, cqr.NewKeyword(t.candidate, fields.TitleAbstract))
+// newLearner creates a learner for studies labelled relevant or not. Only attributes that appear in at least
+// minSupport relevant studies are split on.
+func newLearner(docs [][]Attribute, labels []bool, cost float64, maxDepth, minSupport int) learner {
+	l := learner{
+		labels:     labels,
+		postings:   make(map[Attribute][]int),
+		cost:       cost,
+		maxDepth:   maxDepth,
+		minSupport: minSupport,
 	}
-
-	//if t.left.value == FALSE || t.right.value == FALSE {
-	//
-	//}
-
-	if t.left.value == NA {
-		extracted := t.left.extract()
-		for _, e := range extracted {
-			bqs = append(bqs, cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
-				cqr.NewKeyword(t.candidate, fields.TitleAbstract),
-				e,
-			}))
+	support := make(map[Attribute]int)
+	for i, attrs := range docs {
+		seen := make(map[Attribute]bool, len(attrs))
+		for _, a := range attrs {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+			l.postings[a] = append(l.postings[a], i)
+			if labels[i] {
+				support[a]++
+			}
 		}
 	}
-
-	if t.right.value == NA {
-		extracted := t.right.extract()
-		for _, e := range extracted {
-			bqs = append(bqs, cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
-				cqr.NewKeyword(t.candidate, fields.TitleAbstract),
-				e,
-			}))
+	for a, n := range support {
+		if n >= minSupport {
+			l.attributes = append(l.attributes, a)
 		}
 	}
-
-	return bqs
+	sort.Slice(l.attributes, func(i, j int) bool {
+		if l.attributes[i].Field != l.attributes[j].Field {
+			return l.attributes[i].Field < l.attributes[j].Field
+		}
+		return l.attributes[i].Value < l.attributes[j].Value
+	})
+	return l
 }
 
-func (dt DecisionTreeFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []SupplementalData, error) {
-	var (
-		S []cqr.CommonQueryRepresentation
-	)
-	trees := make([]*tree, len(dt.N))
-	fmt.Println("generating queries using decision trees")
-	bar := pb.StartNew(len(dt.N))
-	for i, can := range dt.N {
-		t := ID3(dt.training, dt.labels, can)
-		trees[i] = t
-		bar.Increment()
-	}
-	bar.Finish()
-
-	seen := make(map[uint64]bool)
-	for _, tree := range trees {
-		for _, query := range tree.extract() {
-			hash := combinator.HashCQR(query)
-			if _, ok := seen[hash]; !ok {
-				S = append(S, query)
-				seen[hash] = true
-			}
+func (l learner) count(docs []int) (pos, neg int) {
+	for _, d := range docs {
+		if l.labels[d] {
+			pos++
+		} else {
+			neg++
 		}
 	}
+	return
+}
 
-	return S, nil, nil
+// weight is the cost-sensitive weight of a set of examples, in which relevant studies are weighted by the cost.
+func (l learner) weight(pos, neg int) float64 {
+	return l.cost*float64(pos) + float64(neg)
 }
 
-func (dt DecisionTreeFormulator) Method() string {
-	return "dt"
+func (l learner) entropy(pos, neg int) float64 {
+	return Entropy(l.cost*float64(pos), float64(neg))
 }
 
-func (dt DecisionTreeFormulator) Topic() string {
-	return dt.topic
+// gain is the cost-sensitive information gain of splitting examples into those with and without an attribute.
+func (l learner) gain(pos, neg, withPos, withNeg int) float64 {
+	withoutPos, withoutNeg := pos-withPos, neg-withNeg
+	total := l.weight(pos, neg)
+	return l.entropy(pos, neg) -
+		l.weight(withPos, withNeg)/total*l.entropy(withPos, withNeg) -
+		l.weight(withoutPos, withoutNeg)/total*l.entropy(withoutPos, withoutNeg)
 }
 
-func NewDecisionTreeFormulator(topic string, positive, negative guru.MedlineDocuments) (*DecisionTreeFormulator, error) {
+// grow grows a tree over some studies. A node is labelled relevant when it contains at least minSupport relevant
+// studies, and the cost of missing them outweighs retrieving the non-relevant studies. Splits that do not change the
+// label of any study are pruned.
+func (l learner) grow(docs []int, used map[Attribute]bool, depth int) *ruleTree {
+	pos, neg := l.count(docs)
+	node := &ruleTree{relevant: pos >= l.minSupport && l.cost*float64(pos) >= float64(neg)}
+	if pos < l.minSupport || neg == 0 || depth >= l.maxDepth {
+		return node
+	}
+
+	in := make(map[int]bool, len(docs))
+	for _, d := range docs {
+		in[d] = true
+	}
+
 	var (
-		err error
-		N   [][]string
+		best Attribute
+		gain float64
+		with []int
 	)
-
-	docLens := make(map[string]float64)
-	fmt.Println("computing doc lens")
-	bar := pb.StartNew(len(positive))
-	for _, doc := range positive {
-		clean := stopwords.CleanString(fmt.Sprintf("%s. %s", doc.TI, doc.AB), "en", false)
-		d, err := prose.NewDocument(clean)
-		if err != nil {
-			return nil, err
+	for _, a := range l.attributes {
+		if used[a] {
+			continue
 		}
-		docLens[doc.PMID] = float64(len(d.Tokens()))
-		bar.Increment()
-	}
-	bar.Finish()
-
-	// Construct the inverted indexes.
-	indexPositive := make(invertedIndex)
-	indexNegative := make(invertedIndex)
-	fmt.Println("building index [0/1]")
-	err = buildIndex(positive, indexPositive)
-	if err != nil {
-		return nil, err
-	}
-	if len(negative) > 0 {
-		fmt.Println("building index [1/1]")
-		err = buildIndex(negative, indexNegative)
-		if err != nil {
-			return nil, err
+		var w []int
+		for _, d := range l.postings[a] {
+			if in[d] {
+				w = append(w, d)
+			}
 		}
-	}
-
-	// Using a language model, construct the sets of attributes, N.
-	lm := make(map[string]float64)
-	fmt.Println("creating language model from in-memory index")
-	bar = pb.StartNew(len(indexPositive))
-	for term, dv := range indexPositive {
-		prelTf := 0.0
-		prelDl := 0.0
-		for pmid, tf := range dv {
-			prelTf += tf
-			prelDl += docLens[pmid]
+		if len(w) == 0 || len(w) == len(docs) {
+			continue
+		}
+		withPos, withNeg := l.count(w)
+		if g := l.gain(pos, neg, withPos, withNeg); g > gain {
+			best, gain, with = a, g, w
 		}
-		lm[term] = prelTf / prelDl
-		bar.Increment()
 	}
-	bar.Finish()
-	type term struct {
-		a string  // attribute.
-		v float64 // value.
+	if gain <= 0 {
+		return node
 	}
 
-	var terms []term
-
-	for k, v := range lm {
-		terms = append(terms, term{
-			a: k,
-			v: v,
-		})
+	inWith := make(map[int]bool, len(with))
+	for _, d := range with {
+		inWith[d] = true
+	}
+	var without []int
+	for _, d := range docs {
+		if !inWith[d] {
+			without = append(without, d)
+		}
 	}
 
-	sort.Slice(terms, func(i, j int) bool {
-		return terms[i].v > terms[j].v
-	})
+	used[best] = true
+	node.attribute = best
+	node.present = l.grow(with, used, depth+1)
+	node.absent = l.grow(without, used, depth+1)
+	delete(used, best)
 
-	attrs := make([]string, len(terms))
-	for i, term := range terms {
-		attrs[i] = term.a
+	if node.present.leaf() && node.absent.leaf() && node.present.relevant == node.absent.relevant {
+		node.relevant = node.present.relevant
+		node.present, node.absent = nil, nil
 	}
+	return node
+}
+
+// RuleExplanation explains a rule of a formulated query using the studies it was learned from.
+type RuleExplanation struct {
+	Rule
+	// Query is the clause of the query for the rule, in PubMed syntax.
+	Query string `json:"query,omitempty"`
+	// Relevant and NonRelevant are the number of relevant and non-relevant studies the rule identifies.
+	Relevant    int     `json:"relevant"`
+	NonRelevant int     `json:"non_relevant"`
+	Recall      float64 `json:"recall"`
+	Precision   float64 `json:"precision"`
+	// Excluded is why the rule is not part of the query, if it is not.
+	Excluded string `json:"excluded,omitempty"`
+}
 
-	for i := 5; i <= 100; i += 5 {
-		if len(attrs) >= i {
-			N = append(N, attrs[:i])
+// Formulate learns rules from the relevant and non-relevant studies and formulates a single query from them. The
+// supplemental data explains each rule.
+func (dt DecisionTreeFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []SupplementalData, error) {
+	docs := make([][]Attribute, 0, len(dt.positive)+len(dt.negative))
+	labels := make([]bool, 0, cap(docs))
+	for _, set := range []struct {
+		docs     guru.MedlineDocuments
+		relevant bool
+	}{{dt.positive, true}, {dt.negative, false}} {
+		for _, doc := range set.docs {
+			var attrs []Attribute
+			for _, extract := range dt.extractors {
+				attrs = append(attrs, extract(doc)...)
+			}
+			docs = append(docs, attrs)
+			labels = append(labels, set.relevant)
 		}
 	}
 
-	labels := make(map[string]bool)       // map[pmid] -> TRUE?FALSE
-	training := make(map[string][]string) // map[attribute] -> []pmid
-
-	fmt.Println("creating training data from language model")
-	bar = pb.StartNew(len(attrs))
-	for _, attr := range attrs {
-		for _, doc := range positive {
-			clean := stopwords.CleanString(fmt.Sprintf("%s. %s", doc.TI, doc.AB), "en", false)
-			if strings.Contains(clean, attr) {
-				training[attr] = append(training[attr], doc.PMID)
+	l := newLearner(docs, labels, dt.cost, dt.maxDepth, dt.minSupport)
+	all := make([]int, len(docs))
+	for i := range all {
+		all[i] = i
+	}
+	rules := l.grow(all, make(map[Attribute]bool), 0).rules(nil).Minimise()
+
+	var clauses []cqr.CommonQueryRepresentation
+	explanations := make([]RuleExplanation, len(rules))
+	for i, rule := range rules {
+		e := RuleExplanation{Rule: rule}
+		for j, attrs := range docs {
+			if !rule.Matches(attrs) {
+				continue
 			}
-		}
-		for _, doc := range negative {
-			clean := stopwords.CleanString(fmt.Sprintf("%s. %s", doc.TI, doc.AB), "en", false)
-			if strings.Contains(clean, attr) {
-				training[attr] = append(training[attr], doc.PMID)
+			if labels[j] {
+				e.Relevant++
+			} else {
+				e.NonRelevant++
 			}
 		}
-		bar.Increment()
+		e.Recall = float64(e.Relevant) / float64(len(dt.positive))
+		if e.Relevant+e.NonRelevant > 0 {
+			e.Precision = float64(e.Relevant) / float64(e.Relevant+e.NonRelevant)
+		}
+		if q, ok := rule.Query(); ok {
+			clauses = append(clauses, q)
+			e.Query = combinator.PubMedString(q)
+		} else {
+			e.Excluded = "the rule only has conditions on the absence of attributes"
+		}
+		explanations[i] = e
 	}
-	bar.Finish()
 
-	for _, doc := range positive {
-		labels[doc.PMID] = true
+	if len(clauses) == 0 {
+		return nil, nil, fmt.Errorf("no rules that can be expressed as a query were learned for topic %s", dt.topic)
 	}
-	for _, doc := range negative {
-		labels[doc.PMID] = false
+	q := clauses[0]
+	if len(clauses) > 1 {
+		q = cqr.NewBooleanQuery(cqr.OR, clauses)
 	}
 
-	return &DecisionTreeFormulator{
-		training: training,
-		labels:   labels,
-		N:        N,
-		topic:    topic,
-	}, nil
+	sup := SupplementalData{
+		Name: "rules",
+		Data: []Data{
+			{
+				Name:  dt.topic + ".json",
+				Value: jsonData{explanations},
+			},
+		},
+	}
+	return []cqr.CommonQueryRepresentation{q}, []SupplementalData{sup}, nil
+}
+
+func (dt DecisionTreeFormulator) Method() string {
+	return "dt"
+}
+
+func (dt DecisionTreeFormulator) Topic() string {
+	return dt.topic
 }
@@ -0,0 +1,428 @@
+package formulation
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/go-unidecode"
+	"github.com/hscells/transmute/fields"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// DictionaryFormat is the format of a concept dictionary file.
+type DictionaryFormat string
+
+const (
+	// TSVDictionary files contain a CUI and a term on each line, separated by a tab, optionally followed by the source
+	// of the term. The first term of a concept is its preferred term, and terms with the source MSH are MeSH headings:
+	//
+	//	C0027051	Myocardial Infarction	MSH
+	//	C0027051	heart attack
+	//
+	// Empty lines and lines starting with # are ignored.
+	TSVDictionary DictionaryFormat = "tsv"
+	// RRFDictionary files are in the format of the MRCONSO.RRF file of the UMLS (or a subset of it). Only English terms
+	// that are not suppressed are loaded.
+	RRFDictionary DictionaryFormat = "rrf"
+)
+
+// Concept is a concept (e.g. a UMLS CUI) and the terms that refer to it.
+type Concept struct {
+	CUI       string
+	Preferred string
+	// MeSH is the MeSH heading of the concept, if it has one.
+	MeSH  string
+	Terms []string
+}
+
+// ConceptMatch is a span of text that refers to a concept.
+type ConceptMatch struct {
+	CUI string
+	// Text is the span of text that was matched, and Term is the term of the concept it matched.
+	Text string
+	Term string
+	// Score is the similarity of the text to the term, which is 1 for exact matches.
+	Score float64
+}
+
+// ConceptDictionary matches text to concepts using a dictionary loaded locally, in place of MetaMap or QuickUMLS.
+// Text is matched greedily from left to right, preferring the longest span of text that matches a term exactly, and
+// otherwise the span most similar to a term (by the overlap of their character trigrams).
+type ConceptDictionary struct {
+	// Threshold is the minimum similarity of text to a term for an approximate match (default: 0.8). Approximate
+	// matching is disabled when the threshold is 1 or more.
+	Threshold float64
+
+	concepts map[string]*Concept
+	// terms are the CUIs of each normalised term.
+	terms map[string][]string
+	// norms are the normalised terms, trigrams is the index of the terms containing each trigram, and size is the
+	// number of distinct trigrams in each term.
+	norms    []string
+	trigrams map[string][]int
+	size     []int
+	// maxTokens is the number of tokens in the longest term.
+	maxTokens int
+}
+
+// minApproximate is the minimum length of text that is matched approximately; shorter text is often an acronym.
+const minApproximate = 4
+
+// NewConceptDictionary creates a new, empty, concept dictionary.
+func NewConceptDictionary() *ConceptDictionary {
+	return &ConceptDictionary{
+		Threshold: 0.8,
+		concepts:  make(map[string]*Concept),
+		terms:     make(map[string][]string),
+		trigrams:  make(map[string][]int),
+	}
+}
+
+// Add adds a term to the concept with a CUI. The first term of a concept is its preferred term unless another is
+// added as preferred, and a term added as a MeSH heading becomes the MeSH heading of the concept.
+func (d *ConceptDictionary) Add(cui, term string, preferred, mesh bool) {
+	term = strings.TrimSpace(term)
+	tokens := normaliseTokens(term)
+	if len(cui) == 0 || len(tokens) == 0 {
+		return
+	}
+
+	c, ok := d.concepts[cui]
+	if !ok {
+		c = &Concept{CUI: cui, Preferred: term}
+		d.concepts[cui] = c
+	}
+	if preferred {
+		c.Preferred = term
+	}
+	if mesh && len(c.MeSH) == 0 {
+		c.MeSH = term
+	}
+
+	norm := strings.Join(tokens, " ")
+	cuis, ok := d.terms[norm]
+	for _, existing := range cuis {
+		if existing == cui {
+			return
+		}
+	}
+	c.Terms = append(c.Terms, term)
+	d.terms[norm] = append(cuis, cui)
+	if len(tokens) > d.maxTokens {
+		d.maxTokens = len(tokens)
+	}
+
+	// Only the first occurrence of a normalised term is indexed for approximate matching.
+	if ok {
+		return
+	}
+	id := len(d.norms)
+	d.norms = append(d.norms, norm)
+	grams := trigrams(norm)
+	for g := range grams {
+		d.trigrams[g] = append(d.trigrams[g], id)
+	}
+	d.size = append(d.size, len(grams))
+}
+
+// Concept is the concept with a CUI.
+func (d *ConceptDictionary) Concept(cui string) (Concept, bool) {
+	c, ok := d.concepts[cui]
+	if !ok {
+		return Concept{}, false
+	}
+	return *c, true
+}
+
+// Len is the number of concepts in the dictionary.
+func (d *ConceptDictionary) Len() int {
+	return len(d.concepts)
+}
+
+// Match finds the concepts referred to in some text.
+func (d *ConceptDictionary) Match(text string) []ConceptMatch {
+	tokens := normaliseTokens(text)
+	var matches []ConceptMatch
+	for i := 0; i < len(tokens); {
+		m, n := d.longest(tokens[i:])
+		matches = append(matches, m...)
+		i += n
+	}
+	return matches
+}
+
+// longest matches the longest span of text at the start of some tokens, returning the matches and the number of
+// tokens matched (at least one).
+func (d *ConceptDictionary) longest(tokens []string) ([]ConceptMatch, int) {
+	n := d.maxTokens
+	if n > len(tokens) {
+		n = len(tokens)
+	}
+	for ; n > 0; n-- {
+		span := strings.Join(tokens[:n], " ")
+		if cuis, ok := d.terms[span]; ok {
+			matches := make([]ConceptMatch, len(cuis))
+			for i, cui := range cuis {
+				matches[i] = ConceptMatch{CUI: cui, Text: span, Term: d.term(cui, span), Score: 1}
+			}
+			return matches, n
+		}
+		if m := d.approximate(span); len(m) > 0 {
+			return m, n
+		}
+	}
+	return nil, 1
+}
+
+// approximate matches a span of text to the most similar term, if it is similar enough.
+func (d *ConceptDictionary) approximate(span string) []ConceptMatch {
+	if d.Threshold >= 1 || len(span) < minApproximate {
+		return nil
+	}
+	grams := trigrams(span)
+	shared := make(map[int]int)
+	for g := range grams {
+		for _, id := range d.trigrams[g] {
+			shared[id]++
+		}
+	}
+
+	best, score := -1, 0.0
+	for id, n := range shared {
+		s := float64(n) / float64(len(grams)+d.size[id]-n)
+		if s < d.Threshold {
+			continue
+		}
+		// Ties are broken by the terms themselves, so that matching does not depend on the order of the map.
+		if best < 0 || s > score || (s == score && d.norms[id] < d.norms[best]) {
+			best, score = id, s
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	norm := d.norms[best]
+	cuis := d.terms[norm]
+	matches := make([]ConceptMatch, len(cuis))
+	for i, cui := range cuis {
+		matches[i] = ConceptMatch{CUI: cui, Text: span, Term: d.term(cui, norm), Score: score}
+	}
+	return matches
+}
+
+// term is the term of a concept, as it was added, that normalises to a normalised term.
+func (d *ConceptDictionary) term(cui, norm string) string {
+	for _, t := range d.concepts[cui].Terms {
+		if strings.Join(normaliseTokens(t), " ") == norm {
+			return t
+		}
+	}
+	return norm
+}
+
+// normaliseTokens lowercases text, folds it to ASCII, and splits it into tokens of letters and numbers.
+func normaliseTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(unidecode.Unidecode(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// trigrams are the distinct character trigrams of text, padded with a space either side.
+func trigrams(text string) map[string]struct{} {
+	t := []rune(" " + text + " ")
+	grams := make(map[string]struct{}, len(t))
+	for i := 0; i+3 <= len(t); i++ {
+		grams[string(t[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+// ReadConceptDictionary reads a concept dictionary in a format.
+func ReadConceptDictionary(r io.Reader, format DictionaryFormat) (*ConceptDictionary, error) {
+	d := NewConceptDictionary()
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		switch format {
+		case TSVDictionary:
+			if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.Split(line, "\t")
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("line %d: expected CUI and term", n)
+			}
+			mesh := len(parts) > 2 && strings.TrimSpace(parts[2]) == "MSH"
+			d.Add(strings.TrimSpace(parts[0]), parts[1], false, mesh)
+		case RRFDictionary:
+			// CUI|LAT|TS|LUI|STT|SUI|ISPREF|AUI|SAUI|SCUI|SDUI|SAB|TTY|CODE|STR|SRL|SUPPRESS|CVF|
+			parts := strings.Split(line, "|")
+			if len(parts) < 17 {
+				return nil, fmt.Errorf("line %d: expected at least 17 fields, got %d", n, len(parts))
+			}
+			if parts[1] != "ENG" || (parts[16] != "N" && len(parts[16]) > 0) {
+				continue
+			}
+			preferred := parts[2] == "P" && parts[4] == "PF" && parts[6] == "Y"
+			mesh := parts[11] == "MSH" && parts[12] == "MH"
+			d.Add(parts[0], parts[14], preferred, mesh)
+		default:
+			return nil, fmt.Errorf("unknown concept dictionary format %s", format)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadConceptDictionary loads a concept dictionary from a file. Files with the extension .rrf are read as UMLS
+// MRCONSO.RRF files; any other file is read as tab-separated.
+func LoadConceptDictionary(path string) (*ConceptDictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format := TSVDictionary
+	if strings.ToLower(filepath.Ext(path)) == ".rrf" {
+		format = RRFDictionary
+	}
+	d, err := ReadConceptDictionary(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return d, nil
+}
+
+// DictionaryEntityExtractor extracts CUI entities from queries using a concept dictionary, without MetaMap.
+type DictionaryEntityExtractor struct {
+	dictionary *ConceptDictionary
+}
+
+// NewDictionaryEntityExtractor creates a new entity extractor that uses a concept dictionary.
+func NewDictionaryEntityExtractor(dictionary *ConceptDictionary) DictionaryEntityExtractor {
+	return DictionaryEntityExtractor{dictionary: dictionary}
+}
+
+// Extract replaces each keyword with the terms of the concepts matched in it, annotated with their CUI. Keywords
+// that do not match any concept are left unchanged.
+func (x DictionaryEntityExtractor) Extract(query cqr.CommonQueryRepresentation) (cqr.CommonQueryRepresentation, error) {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		seen := make(map[ConceptMatch]bool)
+		var keywords []cqr.CommonQueryRepresentation
+		for _, m := range x.dictionary.Match(q.QueryString) {
+			key := ConceptMatch{CUI: m.CUI, Term: m.Term}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keywords = append(keywords, cqr.NewKeyword(m.Term, q.Fields...).SetOption(Entity, m.CUI))
+		}
+		if len(keywords) == 0 {
+			return q, nil
+		}
+		if len(keywords) == 1 {
+			return keywords[0], nil
+		}
+		return cqr.NewBooleanQuery(cqr.OR, keywords), nil
+	case cqr.BooleanQuery:
+		children := make([]cqr.CommonQueryRepresentation, len(q.Children))
+		for i, child := range q.Children {
+			var err error
+			children[i], err = x.Extract(child)
+			if err != nil {
+				return nil, err
+			}
+		}
+		q.Children = children
+		return q, nil
+	}
+	return query, nil
+}
+
+// Concepts finds the CUIs of the concepts matched in text (see ConceptCandidates).
+func (x DictionaryEntityExtractor) Concepts(text string) ([]string, error) {
+	seen := make(map[string]bool)
+	var cuis []string
+	for _, m := range x.dictionary.Match(text) {
+		if !seen[m.CUI] {
+			seen[m.CUI] = true
+			cuis = append(cuis, m.CUI)
+		}
+	}
+	return cuis, nil
+}
+
+// DictionaryKeywordMapper maps entities (CUIs) to keywords using a concept dictionary, without MetaMap.
+type DictionaryKeywordMapper struct {
+	mapper MetaMapMapper
+}
+
+// NewDictionaryKeywordMapper creates a new keyword mapper from a mapper, such as DictionaryPreferred,
+// DictionaryAliases, or DictionaryMeSH.
+func NewDictionaryKeywordMapper(mapper MetaMapMapper) DictionaryKeywordMapper {
+	return DictionaryKeywordMapper{mapper: mapper}
+}
+
+// Map maps the entity of a keyword to keywords.
+func (m DictionaryKeywordMapper) Map(keyword cqr.Keyword) ([]cqr.CommonQueryRepresentation, error) {
+	return m.mapper(keyword)
+}
+
+// dictionaryConcept is the concept of the entity of a keyword.
+func dictionaryConcept(d *ConceptDictionary, keyword cqr.Keyword) (Concept, bool) {
+	cui, ok := keyword.GetOption(Entity).(string)
+	if !ok {
+		return Concept{}, false
+	}
+	return d.Concept(cui)
+}
+
+// DictionaryPreferred maps entities to the preferred term of their concept.
+func DictionaryPreferred(d *ConceptDictionary) MetaMapMapper {
+	return func(keyword cqr.Keyword) ([]cqr.CommonQueryRepresentation, error) {
+		c, ok := dictionaryConcept(d, keyword)
+		if !ok {
+			return []cqr.CommonQueryRepresentation{keyword}, nil
+		}
+		return []cqr.CommonQueryRepresentation{cqr.NewKeyword(c.Preferred, keyword.Fields...)}, nil
+	}
+}
+
+// DictionaryAliases maps entities to every term of their concept; terms of more than one word are phrases.
+func DictionaryAliases(d *ConceptDictionary) MetaMapMapper {
+	return func(keyword cqr.Keyword) ([]cqr.CommonQueryRepresentation, error) {
+		c, ok := dictionaryConcept(d, keyword)
+		if !ok {
+			return []cqr.CommonQueryRepresentation{keyword}, nil
+		}
+		mappings := make([]cqr.CommonQueryRepresentation, len(c.Terms))
+		for i, t := range c.Terms {
+			if strings.ContainsRune(t, ' ') {
+				t = fmt.Sprintf(`"%s"`, t)
+			}
+			mappings[i] = cqr.NewKeyword(t, keyword.Fields...)
+		}
+		return mappings, nil
+	}
+}
+
+// DictionaryMeSH maps entities of concepts with a MeSH heading to that heading, and otherwise uses another mapper.
+func DictionaryMeSH(d *ConceptDictionary, mapper MetaMapMapper) MetaMapMapper {
+	return func(keyword cqr.Keyword) ([]cqr.CommonQueryRepresentation, error) {
+		c, ok := dictionaryConcept(d, keyword)
+		if !ok || len(c.MeSH) == 0 {
+			return mapper(keyword)
+		}
+		return []cqr.CommonQueryRepresentation{cqr.NewKeyword(c.MeSH, fields.MeSHTerms)}, nil
+	}
+}
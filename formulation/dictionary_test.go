@@ -0,0 +1,168 @@
+package formulation
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/guru"
+	"github.com/hscells/transmute/fields"
+	"strings"
+	"testing"
+)
+
+const testDictionary = `# CUI	term	source
+C0027051	Myocardial Infarction	MSH
+C0027051	heart attack
+C0004057	Aspirin	MSH
+C0004057	acetylsalicylic acid
+C0018787	heart
+`
+
+func TestConceptDictionary(t *testing.T) {
+	d, err := ReadConceptDictionary(strings.NewReader(testDictionary), TSVDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 3 {
+		t.Fatalf("expected 3 concepts, got %d", d.Len())
+	}
+
+	// The longest match (heart attack) is preferred over a shorter one (heart), and approximate matches are found.
+	matches := d.Match("Heart attack treated with acetylsalicylic acids")
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", matches)
+	}
+	if matches[0].CUI != "C0027051" || matches[0].Term != "heart attack" || matches[0].Score != 1 {
+		t.Errorf("unexpected match %+v", matches[0])
+	}
+	if matches[1].CUI != "C0004057" || matches[1].Score >= 1 {
+		t.Errorf("expected an approximate match of aspirin, got %+v", matches[1])
+	}
+
+	d.Threshold = 1
+	if matches := d.Match("acetylsalicylic acids"); len(matches) != 0 {
+		t.Errorf("expected no matches without approximate matching, got %v", matches)
+	}
+}
+
+func TestReadConceptDictionaryRRF(t *testing.T) {
+	rrf := `C0027051|ENG|P|L0027051|PF|S0064875|Y|A0090843||M0014340|D009203|MSH|MH|D009203|Myocardial Infarction|0|N||
+C0027051|ENG|S|L0018752|PF|S0046928|Y|A0066453||M0014340|D009203|MSH|ET|D009203|Heart Attack|0|N||
+C0027051|FRE|P|L0187391|PF|S0271372|Y|A0301393||M0014340|D009203|MSHFRE|MH|D009203|Infarctus du myocarde|3|N||
+C0027051|ENG|S|L9999999|PF|S9999999|Y|A9999999||M0014340|D009203|MSH|ET|D009203|Suppressed Term|0|O||
+`
+	d, err := ReadConceptDictionary(strings.NewReader(rrf), RRFDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := d.Concept("C0027051")
+	if !ok {
+		t.Fatal("expected the concept to be loaded")
+	}
+	if c.Preferred != "Myocardial Infarction" || c.MeSH != "Myocardial Infarction" || len(c.Terms) != 2 {
+		t.Errorf("unexpected concept %+v", c)
+	}
+}
+
+func TestDictionaryFormulation(t *testing.T) {
+	d, err := ReadConceptDictionary(strings.NewReader(testDictionary), TSVDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := NewDictionaryEntityExtractor(d).Extract(cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("heart attack", fields.TitleAbstract),
+		cqr.NewKeyword("placebo", fields.TitleAbstract),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := q.(cqr.BooleanQuery).Children
+	if cui, _ := children[0].(cqr.Keyword).GetOption(Entity).(string); cui != "C0027051" {
+		t.Errorf("expected heart attack to be annotated with C0027051, got %v", children[0])
+	}
+	if children[1].(cqr.Keyword).GetOption(Entity) != nil {
+		t.Errorf("expected placebo to be left unchanged, got %v", children[1])
+	}
+
+	mapper := NewDictionaryKeywordMapper(DictionaryMeSH(d, DictionaryPreferred(d)))
+	keywords, err := mapper.Map(children[0].(cqr.Keyword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keywords) != 1 || keywords[0].(cqr.Keyword).QueryString != "Myocardial Infarction" || keywords[0].(cqr.Keyword).Fields[0] != fields.MeSHTerms {
+		t.Errorf("expected heart attack to be mapped to its MeSH heading, got %v", keywords)
+	}
+
+	keywords, err = NewDictionaryKeywordMapper(DictionaryAliases(d)).Map(children[0].(cqr.Keyword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keywords) != 2 || keywords[1].(cqr.Keyword).QueryString != `"heart attack"` {
+		t.Errorf("expected every term of the concept, got %v", keywords)
+	}
+}
+
+func TestDictionaryRelevanceFeedback(t *testing.T) {
+	d, err := ReadConceptDictionary(strings.NewReader(testDictionary), TSVDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := NewDictionaryEntityExtractor(d)
+	cuis, err := x.Concepts("aspirin after a heart attack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cuis) != 2 || cuis[0] != "C0004057" || cuis[1] != "C0027051" {
+		t.Errorf("expected the concepts of aspirin and heart attack, got %v", cuis)
+	}
+
+	embeddings, err := ReadConceptEmbeddings(strings.NewReader(`"","V1","V2"
+"C0027051",1,0
+"C0004057",0,1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := embeddings.Vec("C0004057"); len(v) != 2 || v[0] != 0 || v[1] != 1 {
+		t.Errorf("expected the embedding of aspirin to be read, got %v", v)
+	}
+	q := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("myocardial infarction", fields.TitleAbstract).SetOption(Entity, "C0027051"),
+		cqr.NewKeyword("aspirin", fields.TitleAbstract).SetOption(Entity, "C0004057"),
+	})
+	docs := guru.MedlineDocuments{{TI: "Acetylsalicylic acid.", AB: "Mortality was reduced."}}
+	rf, err := RelevanceFeedback(q, docs, x, embeddings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The concept of the feedback keyword is most similar to the concept of aspirin.
+	clause, ok := rf.(cqr.BooleanQuery).Children[1].(cqr.BooleanQuery)
+	if !ok || len(clause.Children) != 2 || clause.Children[1].(cqr.Keyword).GetOption(Entity) != "C0004057" {
+		t.Errorf("expected the feedback keyword to be added to the clause of aspirin, got %v", rf)
+	}
+}
+
+// titleComposer composes a query of a single keyword, the title.
+type titleComposer struct{}
+
+func (titleComposer) Compose(text string) (cqr.CommonQueryRepresentation, error) {
+	return cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{cqr.NewKeyword(text, fields.TitleAbstract)}), nil
+}
+
+func TestRelevanceFeedbackEmbeddings(t *testing.T) {
+	d, err := ReadConceptDictionary(strings.NewReader(testDictionary), TSVDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadConceptEmbeddings(strings.NewReader("C0027051,1,0\nC0004057,0,one\n"))
+	if err == nil {
+		t.Errorf("expected an embedding that is not a number to be an error")
+	}
+
+	// Relevance feedback fails without embeddings, rather than connecting to a cui2vec server.
+	f := NewConceptualFormulator("aspirin", "1", titleComposer{}, NewDictionaryEntityExtractor(d), nil, NewDictionaryKeywordMapper(DictionaryPreferred(d)), []int{1}, stats.EntrezStatisticsSource{})
+	_, _, err = f.Formulate()
+	if err == nil || !strings.Contains(err.Error(), "embeddings") {
+		t.Errorf("expected relevance feedback without embeddings to be an error, got %v", err)
+	}
+}
@@ -51,6 +51,21 @@ func (m MetaMapEntityExtractor) Extract(query cqr.CommonQueryRepresentation) (cq
 	return query, nil
 }
 
+// Concepts finds the CUIs of the candidates MetaMap is most confident in for text (see ConceptCandidates).
+func (m MetaMapEntityExtractor) Concepts(text string) ([]string, error) {
+	candidates, err := m.client.Candidates(text)
+	if err != nil {
+		return nil, err
+	}
+	var cuis []string
+	for _, c := range candidates {
+		if c.CandidateScore == "-1000" {
+			cuis = append(cuis, c.CandidateCUI)
+		}
+	}
+	return cuis, nil
+}
+
 func NewMetaMapEntityExtractor(client metawrap.HTTPClient) MetaMapEntityExtractor {
 	return MetaMapEntityExtractor{
 		client: client,
@@ -2,13 +2,17 @@
 package formulation
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/hscells/cqr"
 	"github.com/hscells/groove/eval"
 	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/preprocess"
 	"github.com/hscells/groove/stats"
 	"github.com/hscells/guru"
+	"github.com/hscells/meshexp"
 	"github.com/hscells/trecresults"
+	"path"
 	"strconv"
 )
 
@@ -30,6 +34,15 @@ type DataMarshaler interface {
 	Marshal() ([]byte, error)
 }
 
+// jsonData is data written to disk as JSON.
+type jsonData struct {
+	v interface{}
+}
+
+func (d jsonData) Marshal() ([]byte, error) {
+	return json.MarshalIndent(d.v, "", "  ")
+}
+
 // SupplementalData is extra data than may output from the formulation process.
 type SupplementalData struct {
 	Name string
@@ -46,30 +59,48 @@ type ConceptualFormulator struct {
 	EntityExpander
 	KeywordMapper
 
-	s              stats.EntrezStatisticsSource
-	FeedbackDocs   []int
-	postProcessing []PostProcess
+	s            stats.EntrezStatisticsSource
+	FeedbackDocs []int
+	// FeedbackEmbeddings are the embeddings of concepts used for relevance feedback (see LoadConceptEmbeddings and
+	// NewCui2VecEmbeddings). They must be set when there are FeedbackDocs.
+	FeedbackEmbeddings ConceptEmbeddings
+	postProcessing     []PostProcess
 }
 
-// ObjectiveFormulator formulates queries according to the objective approach.
-// This implementation writes files to disk as a side effect which can be later be used for analysis.
+// ObjectiveFormulator formulates queries according to the objective approach. The approach is made up of stages: the
+// relevant studies are split into development, validation, and unseen sets; terms are analysed in the development set
+// and classified into categories; MeSH headings are suggested; and then a query is constructed. The intermediate
+// artefacts of each stage are returned as supplemental data, which can later be used for analysis.
 type ObjectiveFormulator struct {
-	seed                                   int
-	Folder, Pubdates, SemTypes, MetaMapURL string
-	query                                  pipeline.Query
-	s                                      stats.EntrezStatisticsSource
-	qrels                                  trecresults.Qrels
-	MeSHK                                  []int
-	DevK, PopK                             []float64
-	minDocs                                int
+	query      pipeline.Query
+	s          stats.EntrezStatisticsSource
+	qrels      trecresults.Qrels
+	MeSHK      []int
+	DevK, PopK []float64
+	minDocs    int
 
 	population     BackgroundCollection
 	splitter       Splitter
 	analyser       TermAnalyser
+	classifier     TermClassifier
+	suggester      MeSHSuggester
+	constructor    QueryConstructor
+	dates          preprocess.BooleanTransformation
 	postProcessing []PostProcess
 	optimisation   eval.Evaluator
 }
 
+// ObjectiveDerivation is the result of deriving queries using the objective approach.
+type ObjectiveDerivation struct {
+	Query         cqr.CommonQueryRepresentation
+	QueryWithMeSH cqr.CommonQueryRepresentation
+	Development   []guru.MedlineDocument
+	Validation    []guru.MedlineDocument
+	Unseen        []guru.MedlineDocument
+	// Stages are the intermediate artefacts of each stage of the derivation.
+	Stages []Data
+}
+
 type ObjectiveOption func(o *ObjectiveFormulator)
 
 func ObjectiveGrid(devK, popK []float64, meshK []int) ObjectiveOption {
@@ -80,18 +111,49 @@ func ObjectiveGrid(devK, popK []float64, meshK []int) ObjectiveOption {
 	}
 }
 
+// ObjectiveSplitter sets how the relevant studies are split (default: randomly, 50% development, 25% validation).
 func ObjectiveSplitter(spitter Splitter) ObjectiveOption {
 	return func(o *ObjectiveFormulator) {
 		o.splitter = spitter
 	}
 }
 
+// ObjectiveAnalyser sets how terms are analysed in the development set (default: document frequency).
 func ObjectiveAnalyser(analyser TermAnalyser) ObjectiveOption {
 	return func(o *ObjectiveFormulator) {
 		o.analyser = analyser
 	}
 }
 
+// ObjectiveTermClassifier sets how query terms are classified into categories.
+func ObjectiveTermClassifier(classifier TermClassifier) ObjectiveOption {
+	return func(o *ObjectiveFormulator) {
+		o.classifier = classifier
+	}
+}
+
+// ObjectiveMeSHSuggester sets how MeSH headings are suggested (default: the most frequent in the development set).
+func ObjectiveMeSHSuggester(suggester MeSHSuggester) ObjectiveOption {
+	return func(o *ObjectiveFormulator) {
+		o.suggester = suggester
+	}
+}
+
+// ObjectiveQueryConstructor sets how queries are constructed from the categories (default: ConstructQuery).
+func ObjectiveQueryConstructor(constructor QueryConstructor) ObjectiveOption {
+	return func(o *ObjectiveFormulator) {
+		o.constructor = constructor
+	}
+}
+
+// ObjectiveDateRestrictions restricts the constructed queries to the publication dates of the topic (e.g.
+// preprocess.TopicDates.Restrict).
+func ObjectiveDateRestrictions(dates preprocess.BooleanTransformation) ObjectiveOption {
+	return func(o *ObjectiveFormulator) {
+		o.dates = dates
+	}
+}
+
 func ObjectivePostProcessing(processes ...PostProcess) ObjectiveOption {
 	return func(o *ObjectiveFormulator) {
 		o.postProcessing = processes
@@ -126,26 +188,23 @@ func ObjectiveQuery(query pipeline.Query) ObjectiveOption {
 	}
 }
 
-func NewObjectiveFormulator(query pipeline.Query, s stats.EntrezStatisticsSource, qrels trecresults.Qrels, population BackgroundCollection, folder, pubdates, semTypes, metamapURL string, optimisation eval.Evaluator, options ...ObjectiveOption) *ObjectiveFormulator {
+// NewObjectiveFormulator creates a new objective formulator for the topic of a query, using the relevant studies in
+// qrels. Query terms are classified with a term classifier (e.g. MetaMapTermClassifier).
+func NewObjectiveFormulator(query pipeline.Query, s stats.EntrezStatisticsSource, qrels trecresults.Qrels, population BackgroundCollection, classifier TermClassifier, optimisation eval.Evaluator, options ...ObjectiveOption) *ObjectiveFormulator {
 	o := &ObjectiveFormulator{
 		s:            s,
 		qrels:        qrels,
 		population:   population,
 		query:        query,
-		Folder:       folder,
-		Pubdates:     pubdates,
-		SemTypes:     semTypes,
-		MetaMapURL:   metamapURL,
-		splitter:     RandomSplitter(1000),
+		splitter:     NewRandomSplitter(1000, 0.5, 0.25),
 		analyser:     TermFrequencyAnalyser,
+		classifier:   classifier,
+		constructor:  ConstructQuery,
 		optimisation: optimisation,
 		minDocs:      50,
-		//DevK:         []float64{0.20},
-		//PopK:         []float64{0.02},
-		//MeSHK:        []int{20},
-		DevK:  []float64{0.05, 0.10, 0.15, 0.20, 0.25, 0.30},
-		PopK:  []float64{0.001, 0.01, 0.02, 0.05, 0.10, 0.20},
-		MeSHK: []int{1, 5, 10, 15, 20, 25},
+		DevK:         []float64{0.05, 0.10, 0.15, 0.20, 0.25, 0.30},
+		PopK:         []float64{0.001, 0.01, 0.02, 0.05, 0.10, 0.20},
+		MeSHK:        []int{1, 5, 10, 15, 20, 25},
 	}
 
 	for _, option := range options {
@@ -155,77 +214,92 @@ func NewObjectiveFormulator(query pipeline.Query, s stats.EntrezStatisticsSource
 	return o
 }
 
-func (o ObjectiveFormulator) Derive() (cqr.CommonQueryRepresentation, cqr.CommonQueryRepresentation, []guru.MedlineDocument, []guru.MedlineDocument, []guru.MedlineDocument, error) {
+// Derive derives a query with, and a query without, MeSH headings from the relevant studies.
+func (o ObjectiveFormulator) Derive() (ObjectiveDerivation, error) {
+	if o.classifier == nil {
+		return ObjectiveDerivation{}, fmt.Errorf("objective formulator has no term classifier")
+	}
+	suggester := o.suggester
+	if suggester == nil {
+		tree, err := meshexp.Default()
+		if err != nil {
+			return ObjectiveDerivation{}, err
+		}
+		suggester = NewFrequentMeSHSuggester(tree)
+	}
+
 	// Identify the relevant studies using relevance assessments.
 	var docs []int
-	var nonrel []*trecresults.Qrel
 	for _, rel := range o.qrels {
 		if rel.Score > 0 {
 			v, err := strconv.Atoi(rel.DocId)
 			if err != nil {
-				panic(err)
+				return ObjectiveDerivation{}, err
 			}
 			docs = append(docs, v)
-		} else {
-			nonrel = append(nonrel, rel)
 		}
 	}
 
 	if len(docs) <= o.minDocs {
-		return nil, nil, nil, nil, nil, fmt.Errorf("not enough relevant studies (minimmum %d)", o.minDocs)
+		return ObjectiveDerivation{}, fmt.Errorf("not enough relevant studies (minimmum %d)", o.minDocs)
 	}
 
 	// Fetch those relevant documents.
 	test, err := fetchDocuments(docs, o.s)
 	if err != nil {
-		panic(err)
+		return ObjectiveDerivation{}, err
 	}
 
-	fmt.Printf("%d documents in test set\n", len(test))
-
 	// Split the 'test' set into dev, val, and unseen.
 	dev, val, unseen := o.splitter.Split(test)
-	fmt.Printf("%d dev, %d val, %d unseen\n", len(dev), len(val), len(unseen))
 
 	// Perform 'term frequency analysis' on the development set.
 	devTerms, err := o.analyser(dev)
 	if err != nil {
-		panic(err)
+		return ObjectiveDerivation{}, err
 	}
 
-	q1, q2, err := o.derive(devTerms, dev, val, o.population, o.optimisation)
+	q1, q2, stages, err := o.derive(devTerms, dev, val, suggester)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return ObjectiveDerivation{}, err
 	}
 
 	// Post-Processing.
 	for _, postProcessor := range o.postProcessing {
 		q1, err = postProcessor(q1)
 		if err != nil {
-			return nil, nil, nil, nil, nil, err
+			return ObjectiveDerivation{}, err
 		}
 		q2, err = postProcessor(q2)
 		if err != nil {
-			return nil, nil, nil, nil, nil, err
+			return ObjectiveDerivation{}, err
 		}
 	}
 
-	return q1, q2, unseen, dev, val, nil
+	return ObjectiveDerivation{
+		Query:         q1,
+		QueryWithMeSH: q2,
+		Development:   dev,
+		Validation:    val,
+		Unseen:        unseen,
+		Stages:        stages,
+	}, nil
 }
 
-// Formulate returns two queries: one with MeSH terms and one without. It also returns the set of unseen documents for evaluation later.
+// Formulate returns two queries: one with MeSH terms and one without. It also returns the set of unseen documents for
+// evaluation later, and the artefacts of each stage of the derivation.
 func (o ObjectiveFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []SupplementalData, error) {
-	q1, q2, unseen, dev, val, err := o.Derive()
+	d, err := o.Derive()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	resNoMesh, err := o.s.Execute(pipeline.NewQuery("objective_nomesh", o.Topic(), q1), o.s.SearchOptions())
+	resNoMesh, err := o.s.Execute(pipeline.NewQuery("objective_nomesh", o.Topic(), d.Query), o.s.SearchOptions())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	resMesh, err := o.s.Execute(pipeline.NewQuery("objective_mesh", o.Topic(), q2), o.s.SearchOptions())
+	resMesh, err := o.s.Execute(pipeline.NewQuery("objective_mesh", o.Topic(), d.QueryWithMeSH), o.s.SearchOptions())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -235,15 +309,15 @@ func (o ObjectiveFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []Sup
 		Data: []Data{
 			{
 				Name:  "unseen.qrels",
-				Value: MakeQrels(unseen, o.Topic()),
+				Value: MakeQrels(d.Unseen, o.Topic()),
 			},
 			{
 				Name:  "dev.qrels",
-				Value: MakeQrels(dev, o.Topic()),
+				Value: MakeQrels(d.Development, o.Topic()),
 			},
 			{
 				Name:  "val.qrels",
-				Value: MakeQrels(val, o.Topic()),
+				Value: MakeQrels(d.Validation, o.Topic()),
 			},
 			{
 				Name:  "without_mesh.res",
@@ -256,7 +330,13 @@ func (o ObjectiveFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []Sup
 		},
 	}
 
-	return []cqr.CommonQueryRepresentation{q1, q2}, []SupplementalData{sup}, nil
+	// Supplemental files are shared by every topic, so the stages of each topic are kept apart.
+	stages := SupplementalData{
+		Name: path.Join("stages", o.Topic()),
+		Data: d.Stages,
+	}
+
+	return []cqr.CommonQueryRepresentation{d.Query, d.QueryWithMeSH}, []SupplementalData{sup, stages}, nil
 }
 
 func (o ObjectiveFormulator) Method() string {
@@ -304,12 +384,20 @@ func (t ConceptualFormulator) Formulate() ([]cqr.CommonQueryRepresentation, []Su
 
 	// Relevance Feedback.
 	if len(t.FeedbackDocs) > 0 {
+		// Relevance feedback maps the terms of the feedback documents to concepts using the entity extractor.
+		candidates, ok := t.EntityExtractor.(ConceptCandidates)
+		if !ok {
+			return nil, nil, fmt.Errorf("relevance feedback requires an entity extractor that finds the concepts of text")
+		}
+		if t.FeedbackEmbeddings == nil {
+			return nil, nil, fmt.Errorf("relevance feedback requires the embeddings of concepts")
+		}
+
 		docs, err := t.s.Fetch(t.FeedbackDocs)
 		if err != nil {
 			return nil, nil, err
 		}
-
-		q, err = RelevanceFeedback(q, docs, t.EntityExtractor.(MetaMapEntityExtractor).client)
+		q, err = RelevanceFeedback(q, docs, candidates, t.FeedbackEmbeddings)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -2,14 +2,12 @@ package formulation
 
 import (
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"github.com/hscells/cqr"
 	"github.com/hscells/ghost"
 	"github.com/hscells/groove/combinator"
 	"github.com/hscells/groove/eval"
 	"github.com/hscells/groove/pipeline"
-	"github.com/hscells/groove/preprocess"
 	"github.com/hscells/groove/stats"
 	"github.com/hscells/guru"
 	"github.com/hscells/meshexp"
@@ -17,7 +15,6 @@ import (
 	"github.com/hscells/transmute/fields"
 	"github.com/hscells/trecresults"
 	"io"
-	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
@@ -40,6 +37,26 @@ type BackgroundCollection interface {
 	Size() (float64, error)
 }
 
+// TermCategories are query terms classified into the conditions, treatments, and study types of a query.
+type TermCategories struct {
+	Conditions []string `json:"conditions"`
+	Treatments []string `json:"treatments"`
+	StudyTypes []string `json:"study_types"`
+}
+
+// TermClassifier classifies query terms into categories. Terms that do not belong to any category are discarded.
+type TermClassifier interface {
+	Classify(terms []string) (TermCategories, error)
+}
+
+// MeSHSuggester suggests at most k MeSH headings, classified into categories, for a development set.
+type MeSHSuggester interface {
+	Suggest(dev []guru.MedlineDocument, k int) (TermCategories, error)
+}
+
+// QueryConstructor constructs a query from the keywords of each category.
+type QueryConstructor func(conditions, treatments, studyTypes []cqr.Keyword) cqr.CommonQueryRepresentation
+
 // -----------------------------------------------------------
 
 type TermStatistics map[string]float64
@@ -58,13 +75,21 @@ type PubMedSet struct {
 	e stats.EntrezStatisticsSource
 }
 
+// pubMedRetries is the number of times the document frequency of a term is requested from PubMed before giving up.
+const pubMedRetries = 3
+
 func (p PubMedSet) Statistic(term string) (float64, error) {
-doDf:
-	df, err := p.e.DocumentFrequency(term, fields.TitleAbstract)
-	if err != nil {
-		goto doDf
+	var (
+		df  float64
+		err error
+	)
+	for i := 0; i < pubMedRetries; i++ {
+		df, err = p.e.DocumentFrequency(term, fields.TitleAbstract)
+		if err == nil {
+			return df, nil
+		}
 	}
-	return df, nil
+	return 0, err
 }
 
 func (p PubMedSet) Size() (float64, error) {
@@ -77,6 +102,13 @@ func NewPubMedSet(e stats.EntrezStatisticsSource) PubMedSet {
 	}
 }
 
+// termStatistic is the statistic of a term, and its proportion of the size of the collection it was computed on.
+type termStatistic struct {
+	Term       string  `json:"term"`
+	Statistic  float64 `json:"statistic"`
+	Proportion float64 `json:"proportion"`
+}
+
 type mappingPair struct {
 	CUI  string
 	Abbr string
@@ -90,9 +122,9 @@ type semTypeMapping map[string]string
 type queryCategory int
 
 type evaluation struct {
-	Development map[string]float64
-	Validation  map[string]float64
-	Unseen      map[string]float64
+	Development map[string]float64 `json:"development"`
+	Validation  map[string]float64 `json:"validation"`
+	Unseen      map[string]float64 `json:"unseen,omitempty"`
 }
 
 const (
@@ -113,12 +145,11 @@ var (
 // GetPopulationSet retrieves a set of publications to form a population set.
 func GetPopulationSet(e stats.EntrezStatisticsSource, analyser TermAnalyser) (BackgroundCollection, error) {
 	e.Limit = 10000
-	pmids, err := e.Search(`("0000"[Date - Publication] : "2018"[Date - Publication])`, e.SearchSize(10000), )
+	pmids, err := e.Search(`("0000"[Date - Publication] : "2018"[Date - Publication])`, e.SearchSize(10000))
 	if err != nil {
 		return nil, err
 	}
 	e.Limit = 0
-	fmt.Println("fetching docs")
 
 	// Perform 'term frequency analysis' on the population.
 	docs, err := fetchDocuments(pmids, e)
@@ -134,8 +165,8 @@ func GetPopulationSet(e stats.EntrezStatisticsSource, analyser TermAnalyser) (Ba
 	return PopulationSet(pop), nil
 }
 
-// FetchDocuments retrieves the references to studies of target PMID(s).
-func fetchDocuments(refs []int, e stats.EntrezStatisticsSource) ([]guru.MedlineDocument, error) {
+// fetchDocuments retrieves the references to studies of target PMID(s).
+func fetchDocuments(refs []int, e stats.EntrezStatisticsSource) (fetched []guru.MedlineDocument, err error) {
 	// Open the document store.
 	d, err := os.UserCacheDir()
 	if err != nil {
@@ -143,8 +174,14 @@ func fetchDocuments(refs []int, e stats.EntrezStatisticsSource) ([]guru.MedlineD
 	}
 	g, err := ghost.Open(path.Join(d, "/ghost/groove/objective/docs"), ghost.NewGobSchema(guru.MedlineDocument{}))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	// Close the document store.
+	defer func() {
+		if cerr := g.Close(); err == nil {
+			err = cerr
+		}
+	}()
 
 	// Try to retrieve as many docs from disk as possible.
 	var (
@@ -168,13 +205,11 @@ func fetchDocuments(refs []int, e stats.EntrezStatisticsSource) ([]guru.MedlineD
 		}
 	}
 
-	fmt.Println("found", len(references), "fetching", len(fetching))
-
 	if len(fetching) > 0 {
 		// Retrieve the documents of the references.
 		docs, err = e.Fetch(fetching)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		// Put the docs in the store.
@@ -183,29 +218,38 @@ func fetchDocuments(refs []int, e stats.EntrezStatisticsSource) ([]guru.MedlineD
 			if err != nil {
 				return nil, err
 			}
-			//fmt.Println("put", d.PMID)
 		}
 	}
-	// Close the document store.
-	err = g.Close()
-	if err != nil {
-		return nil, err
-	}
 
 	return append(references, docs...), nil
 }
 
-type RandomSplitter int64
+// RandomSplitter randomly splits documents into development, validation, and unseen sets. Development and Validation
+// are the proportions of documents in the development and validation sets; the remaining documents are unseen.
+type RandomSplitter struct {
+	Seed        int64
+	Development float64
+	Validation  float64
+}
+
+// NewRandomSplitter creates a new random splitter. The same seed always splits the same documents the same way.
+func NewRandomSplitter(seed int64, development, validation float64) RandomSplitter {
+	return RandomSplitter{
+		Seed:        seed,
+		Development: development,
+		Validation:  validation,
+	}
+}
 
-// splitTest creates three slices of documents: 2:4 development, 1:4 validation, 1:4 unseen.
+// Split shuffles a copy of the documents and splits them; the documents themselves are not modified.
 func (r RandomSplitter) Split(docs []guru.MedlineDocument) ([]guru.MedlineDocument, []guru.MedlineDocument, []guru.MedlineDocument) {
-	rand.Seed(int64(r))
-	rand.Shuffle(len(docs), func(i, j int) {
+	docs = append([]guru.MedlineDocument(nil), docs...)
+	rand.New(rand.NewSource(r.Seed)).Shuffle(len(docs), func(i, j int) {
 		docs[i], docs[j] = docs[j], docs[i]
 	})
 
-	devSplit := float64(len(docs)) * 0.5
-	valSplit := float64(len(docs)) * 0.25
+	devSplit := float64(len(docs)) * r.Development
+	valSplit := float64(len(docs)) * r.Validation
 
 	var (
 		dev    []guru.MedlineDocument
@@ -253,11 +297,16 @@ func loadSemTypesMapping(filename string) (semTypeMapping, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 	r := csv.NewReader(f)
 	r.Comma = '|'
 	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
 	for {
 		if row, err := r.Read(); err == nil {
+			if len(row) < 5 {
+				return nil, fmt.Errorf("%s: expected at least 5 fields, got %d", filename, len(row))
+			}
 			m[row[1]] = row[4]
 		} else if err == io.EOF {
 			break
@@ -284,57 +333,79 @@ func cutDevelopmentTerms(t TermStatistics, dev []guru.MedlineDocument, cut float
 }
 
 // cutDevelopmentTermsWithPopulation takes TermStatistics where the DF in the population collection is <= 2%.
-func cutDevelopmentTermsWithPopulation(dev []string, population BackgroundCollection, topic, folder string, cut float64) []string {
+func cutDevelopmentTermsWithPopulation(dev []string, population BackgroundCollection, cut float64) ([]string, []termStatistic, error) {
 	size, err := population.Size()
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	n := size * cut // Term must be <= 2% of population DF.
 	var t []string
 
-	type pair struct {
-		K string
-		V float64
-		P float64
-	}
 	terms := make(TermStatistics)
 
 	for _, term := range dev {
-		fmt.Println(" -", term)
-		if df, err := population.Statistic(term); err == nil {
-			if float64(df) <= n {
-				terms[term] = df
-				t = append(t, term)
-			}
-		} else {
-			panic(err)
+		df, err := population.Statistic(term)
+		if err != nil {
+			return nil, nil, err
+		}
+		if float64(df) <= n {
+			terms[term] = df
+			t = append(t, term)
 		}
 	}
 
-	var pairs []pair
+	var pairs []termStatistic
 	for k, v := range terms {
-		pairs = append(pairs, pair{K: k, V: v, P: float64(v) / size})
+		pairs = append(pairs, termStatistic{Term: k, Statistic: v, Proportion: float64(v) / size})
 	}
 
 	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].V > pairs[j].V
+		return pairs[i].Statistic > pairs[j].Statistic
 	})
 
-	f, err := os.OpenFile(path.Join("./document_frequency", folder, topic+".population.json"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
-	if err != nil {
-		panic(err)
+	return t, pairs, nil
+}
+
+// MetaMapTermClassifier classifies terms by the semantic type MetaMap maps them to.
+type MetaMapTermClassifier struct {
+	client   metawrap.HTTPClient
+	semTypes semTypeMapping
+}
+
+// NewMetaMapTermClassifier creates a new term classifier using the MetaMap service at a URL. The semantic types file
+// maps semantic types to categories (in the pipe-separated format of the semantic types of the UMLS); semantic types
+// not in the file, or all semantic types if the file is empty, are classified by their semantic group.
+func NewMetaMapTermClassifier(url, semTypesFile string) (MetaMapTermClassifier, error) {
+	c := MetaMapTermClassifier{
+		client:   metawrap.HTTPClient{URL: url},
+		semTypes: make(semTypeMapping),
+	}
+	if len(semTypesFile) > 0 {
+		var err error
+		c.semTypes, err = loadSemTypesMapping(semTypesFile)
+		if err != nil {
+			return MetaMapTermClassifier{}, err
+		}
 	}
+	return c, nil
+}
 
-	err = json.NewEncoder(f).Encode(pairs)
+// Classify maps terms to CUIs and classifies them by their semantic type.
+func (m MetaMapTermClassifier) Classify(terms []string) (TermCategories, error) {
+	mapping, err := metaMapTerms(terms, m.client)
 	if err != nil {
-		panic(err)
+		return TermCategories{}, err
 	}
-
-	return t
+	conditions, treatments, studyTypes := classifyQueryTerms(terms, mapping, m.semTypes)
+	return TermCategories{
+		Conditions: conditions,
+		Treatments: treatments,
+		StudyTypes: studyTypes,
+	}, nil
 }
 
-// metaMapTerms maps TermStatistics to CUIs.
-func metaMapTerms(terms []string, client metawrap.HTTPClient) (mapping, error) {
+// metaMapTerms maps TermStatistics to CUIs. Mappings are cached, including terms which could not be mapped.
+func metaMapTerms(terms []string, client metawrap.HTTPClient) (cuis mapping, err error) {
 	// Open the document store.
 	d, err := os.UserCacheDir()
 	if err != nil {
@@ -342,74 +413,40 @@ func metaMapTerms(terms []string, client metawrap.HTTPClient) (mapping, error) {
 	}
 	g, err := ghost.Open(path.Join(d, "/ghost/groove/objective/metamap"), ghost.NewGobSchema(mappingPair{}))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	cuis := make(mapping)
+	defer func() {
+		if cerr := g.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	cuis = make(mapping)
 	for _, term := range terms {
-		if !g.Contains(term) {
-			candidates, err := client.Candidates(term)
-			if err != nil {
-				return nil, err
-			}
-			found := false
-			for _, candidate := range candidates {
-				if strings.ToLower(candidate.CandidateMatched) == term {
-					p := mappingPair{
-						CUI:  candidate.CandidateCUI,
-						Abbr: candidate.SemTypes[0],
-					}
-					cuis[term] = p
-					err := g.Put(term, p)
-					if err != nil {
-						panic(err)
-					}
-					found = true
-					fmt.Println("put term", term)
-					break
-				}
-			}
+		var p mappingPair
+		if g.Contains(term) && g.Get(term, &p) == nil {
+			cuis[term] = p
+			continue
+		}
 
-			if !found {
-				err := g.Put(term, mappingPair{})
-				if err != nil {
-					panic(err)
-				}
-			}
-		} else {
-			var p mappingPair
-			err := g.Get(term, &p)
-			if err != nil {
-				//panic(err)
-				candidates, err := client.Candidates(term)
-				if err != nil {
-					return nil, err
-				}
-				//found := false
-				for _, candidate := range candidates {
-					if strings.ToLower(candidate.CandidateMatched) == term {
-						p := mappingPair{
-							CUI:  candidate.CandidateCUI,
-							Abbr: candidate.SemTypes[0],
-						}
-						cuis[term] = p
-						err := g.Put(term, p)
-						if err != nil {
-							panic(err)
-						}
-						//found = true
-						fmt.Println("put term", term)
-						break
-					}
+		candidates, err := client.Candidates(term)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			if strings.ToLower(candidate.CandidateMatched) == term && len(candidate.SemTypes) > 0 {
+				p = mappingPair{
+					CUI:  candidate.CandidateCUI,
+					Abbr: candidate.SemTypes[0],
 				}
+				cuis[term] = p
+				break
 			}
-			cuis[term] = p
-			fmt.Println("get term", term)
 		}
-	}
-
-	err = g.Close()
-	if err != nil {
-		return nil, err
+		err = g.Put(term, p)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return cuis, nil
@@ -485,7 +522,6 @@ var rejectMeSH = []string{"humans", "animals", "aged", "adult", "male", "female"
 // FilterQueryTerms reduces further query TermStatistics by identifying the best combination
 // of TermStatistics based on how many relevant documents they retrieve from the development set.
 func FilterQueryTerms(conditions, treatments, studyTypes []string, field string, development trecresults.Qrels, e stats.EntrezStatisticsSource) ([]string, []string, []string, error) {
-	eval.RelevanceGrade = 0
 	terms := make([][]string, 3)
 	terms[0] = conditions
 	terms[1] = treatments
@@ -699,7 +735,7 @@ func makeKeywords(conditions, treatments, studyTypes []string) ([]cqr.Keyword, [
 	return keywords[0], keywords[1], keywords[2]
 }
 
-// MakeQrels creates a set of relevance assessments from some medline documents.
+// MakeQrels creates a set of relevance assessments from some medline documents, in which every document is relevant.
 func MakeQrels(docs []guru.MedlineDocument, topic string) trecresults.Qrels {
 	qrels := make(trecresults.Qrels)
 	for _, doc := range docs {
@@ -707,13 +743,25 @@ func MakeQrels(docs []guru.MedlineDocument, topic string) trecresults.Qrels {
 			Topic:     topic,
 			Iteration: "0",
 			DocId:     doc.PMID,
-			Score:     1,
+			Score:     eval.RelevanceGrade + 1,
 		}
 	}
 	return qrels
 }
 
-func addMeSHTerms(conditions, treatments, studyTypes []cqr.Keyword, dev []guru.MedlineDocument, e stats.EntrezStatisticsSource, topic string, k int, folder string) ([]cqr.Keyword, []cqr.Keyword, []cqr.Keyword, error) {
+// FrequentMeSHSuggester suggests the MeSH headings most frequently assigned to the studies in a development set,
+// classified by their location in the MeSH tree. Check tags (e.g. Humans) are never suggested.
+type FrequentMeSHSuggester struct {
+	tree *meshexp.MeSHTree
+}
+
+// NewFrequentMeSHSuggester creates a new MeSH suggester which classifies headings using a MeSH tree.
+func NewFrequentMeSHSuggester(tree *meshexp.MeSHTree) FrequentMeSHSuggester {
+	return FrequentMeSHSuggester{tree: tree}
+}
+
+// Suggest suggests the k most frequent MeSH headings of the development set.
+func (m FrequentMeSHSuggester) Suggest(dev []guru.MedlineDocument, k int) (TermCategories, error) {
 	subheadingsFreq := make(map[string]int)
 	for _, doc := range dev {
 		for _, mh := range doc.MH {
@@ -726,7 +774,6 @@ func addMeSHTerms(conditions, treatments, studyTypes []cqr.Keyword, dev []guru.M
 				mh = strings.Split(mh, "/")[0]
 			}
 			mh = strings.Replace(mh, "*", "", -1)
-			fmt.Println(mh)
 			subheadingsFreq[mh]++
 		skip:
 		}
@@ -738,95 +785,48 @@ func addMeSHTerms(conditions, treatments, studyTypes []cqr.Keyword, dev []guru.M
 	}
 
 	var subheadings []pair
-	for k, v := range subheadingsFreq {
+	for mh, freq := range subheadingsFreq {
 		subheadings = append(subheadings, pair{
-			mh:   k,
-			freq: v,
+			mh:   mh,
+			freq: freq,
 		})
 	}
-
-	var topSubheadings []string
-	if len(subheadingsFreq) < k {
-		for _, p := range subheadings {
-			topSubheadings = append(topSubheadings, p.mh)
-		}
-	} else {
-		sort.Slice(subheadings, func(i, j int) bool {
+	sort.Slice(subheadings, func(i, j int) bool {
+		if subheadings[i].freq != subheadings[j].freq {
 			return subheadings[i].freq > subheadings[j].freq
-		})
-		for i := 0; i < k; i++ {
-			topSubheadings = append(topSubheadings, subheadings[i].mh)
 		}
+		return subheadings[i].mh < subheadings[j].mh
+	})
+	if len(subheadings) > k {
+		subheadings = subheadings[:k]
 	}
 
-	fmt.Println("top subheadings:")
-	for i, sh := range topSubheadings {
-		fmt.Println(i, sh)
-	}
-
-	f, err := os.OpenFile(path.Join("./top_subheadings", folder, topic), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
-	if err != nil {
-		panic(err)
-	}
-
-	err = json.NewEncoder(f).Encode(topSubheadings)
-	if err != nil {
-		panic(err)
-	}
-
-	tree, err := meshexp.Default()
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	var (
-		c []string
-		t []string
-		s []string
-	)
-
-	for _, mh := range topSubheadings {
-		categorised := classifyMeSHCategory(mh, tree)
-		for _, category := range categorised {
+	var c TermCategories
+	for _, p := range subheadings {
+		for _, category := range classifyMeSHCategory(p.mh, m.tree) {
 			switch category {
 			case condition:
-				c = append(c, mh)
+				c.Conditions = append(c.Conditions, p.mh)
 			case treatment:
-				t = append(t, mh)
+				c.Treatments = append(c.Treatments, p.mh)
 			case studyType:
-				s = append(s, mh)
+				c.StudyTypes = append(c.StudyTypes, p.mh)
 			}
 		}
 	}
 
-	c, t, s, err = FilterQueryTerms(c, t, s, fields.MeshHeadings, MakeQrels(dev, topic), e)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	for _, v := range c {
-		conditions = append(conditions, cqr.NewKeyword(v, fields.MeshHeadings))
-	}
-
-	for _, v := range t {
-		treatments = append(treatments, cqr.NewKeyword(v, fields.MeshHeadings))
-	}
-
-	for _, v := range s {
-		studyTypes = append(studyTypes, cqr.NewKeyword(v, fields.MeshHeadings))
-	}
-
-	return conditions, treatments, studyTypes, nil
+	return c, nil
 }
 
 func classifyMeSHCategory(mh string, tree *meshexp.MeSHTree) []queryCategory {
 	references := tree.Reference(mh)
-	fmt.Println(mh)
 	seen := make(map[uint8]bool)
 	var cats []queryCategory
 	for _, ref := range references {
+		if len(ref.TreeLocation) == 0 || len(ref.TreeLocation[0]) == 0 {
+			continue
+		}
 		c := ref.TreeLocation[0][0]
-		fmt.Println("->", string(c), ref.MedicalSubjectHeading)
 		switch c {
 		case 'A', 'B', 'C', 'F', 'G', 'H', 'M':
 			if _, ok := seen[c]; !ok {
@@ -850,8 +850,17 @@ func classifyMeSHCategory(mh string, tree *meshexp.MeSHTree) []queryCategory {
 	return cats
 }
 
+// appendKeywords appends terms as keywords of a field to a copy of some keywords.
+func appendKeywords(keywords []cqr.Keyword, terms []string, field string) []cqr.Keyword {
+	k := append([]cqr.Keyword(nil), keywords...)
+	for _, term := range terms {
+		k = append(k, cqr.NewKeyword(term, field))
+	}
+	return k
+}
+
 // ConstructQuery takes three slices and creates a query from them.
-func constructQuery(conditions, treatments, studyTypes []cqr.Keyword) cqr.CommonQueryRepresentation {
+func ConstructQuery(conditions, treatments, studyTypes []cqr.Keyword) cqr.CommonQueryRepresentation {
 	q := cqr.NewBooleanQuery(cqr.AND, nil)
 	conditionsClause := cqr.NewBooleanQuery(cqr.OR, nil)
 	treatmentsClause := cqr.NewBooleanQuery(cqr.OR, nil)
@@ -887,26 +896,17 @@ func constructQuery(conditions, treatments, studyTypes []cqr.Keyword) cqr.Common
 // evaluate computes evaluation measures for each of the dev, val, and unseen sets.
 func evaluate(query cqr.CommonQueryRepresentation, e stats.EntrezStatisticsSource, dev, val, unseen []guru.MedlineDocument, topic string) (evaluation, error) {
 	// Execute the query and find the effectiveness.
-	//d, err := os.UserCacheDir()
-	//if err != nil {
-	//	return evaluation{}, err
-	//}
-	//filecache := combinator.NewFileQueryCache(path.Join(d, "filecache"))
-	//pq := pipeline.NewQuery("0", "0", query)
-	//tree, _, err := combinator.NewLogicalTree(pq, e, filecache)
-	//if err != nil {
-	//	panic(err)
-	//}
-	//results := tree.Documents(filecache).Results(pq, "0")
 	results, err := e.Execute(pipeline.NewQuery("", "", query), e.SearchOptions())
 	if err != nil {
 		return evaluation{}, err
 	}
-	eval.RelevanceGrade = 0
 	ev := []eval.Evaluator{eval.NumRel, eval.NumRet, eval.NumRelRet, eval.Recall, eval.Precision, eval.F1Measure, eval.F05Measure, eval.F3Measure, eval.NNR}
 	devEval := eval.Evaluate(ev, &results, trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{"0": MakeQrels(dev, topic)}}, "0")
 	valEval := eval.Evaluate(ev, &results, trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{"0": MakeQrels(val, topic)}}, "0")
-	unseenEval := eval.Evaluate(ev, &results, trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{"0": MakeQrels(unseen, topic)}}, "0")
+	var unseenEval map[string]float64
+	if len(unseen) > 0 {
+		unseenEval = eval.Evaluate(ev, &results, trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{"0": MakeQrels(unseen, topic)}}, "0")
+	}
 	return evaluation{
 		Development: devEval,
 		Validation:  valEval,
@@ -914,43 +914,55 @@ func evaluate(query cqr.CommonQueryRepresentation, e stats.EntrezStatisticsSourc
 	}, nil
 }
 
-// RankTerms ranks term statistics.
-func rankTerms(t TermStatistics, dev []guru.MedlineDocument, topic, folder string) []string {
-	type pair struct {
-		K string
-		V float64
-		P float64
-	}
-
-	var pairs []pair
+// rankTerms ranks term statistics, returning the ranked terms and their statistics.
+func rankTerms(t TermStatistics, dev []guru.MedlineDocument) ([]string, []termStatistic) {
+	var pairs []termStatistic
 	for k, v := range t {
-		pairs = append(pairs, pair{K: k, V: v, P: float64(v) / float64(len(dev))})
+		pairs = append(pairs, termStatistic{Term: k, Statistic: v, Proportion: float64(v) / float64(len(dev))})
 	}
 
 	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].V > pairs[j].V
+		if pairs[i].Statistic != pairs[j].Statistic {
+			return pairs[i].Statistic > pairs[j].Statistic
+		}
+		return pairs[i].Term < pairs[j].Term
 	})
 
-	f, err := os.OpenFile(path.Join("./statistics", folder, topic+".development.json"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
-	if err != nil {
-		panic(err)
-	}
-
-	err = json.NewEncoder(f).Encode(pairs)
-	if err != nil {
-		panic(err)
-	}
-
 	terms := make([]string, len(pairs))
 	for i, v := range pairs {
-		terms[i] = v.K
+		terms[i] = v.Term
 	}
 
-	return terms
+	return terms, pairs
+}
+
+// termClassification is the classification of query terms, before and after filtering.
+type termClassification struct {
+	Classified TermCategories `json:"classified"`
+	Filtered   TermCategories `json:"filtered"`
 }
 
-// derive actually performs the objective derivation for the objective method.
-func (o ObjectiveFormulator) derive(devDF TermStatistics, dev, val []guru.MedlineDocument, population BackgroundCollection, m eval.Evaluator) (cqr.CommonQueryRepresentation, cqr.CommonQueryRepresentation, error) {
+// filterTerms filters classified terms (see FilterQueryTerms).
+func (o ObjectiveFormulator) filterTerms(c TermCategories, field string, dev trecresults.Qrels) (TermCategories, error) {
+	var (
+		f   TermCategories
+		err error
+	)
+	f.Conditions, f.Treatments, f.StudyTypes, err = FilterQueryTerms(c.Conditions, c.Treatments, c.StudyTypes, field, dev, o.s)
+	return f, err
+}
+
+// restrict restricts a query to the publication dates of the topic, if there are date restrictions.
+func (o ObjectiveFormulator) restrict(q cqr.CommonQueryRepresentation) cqr.CommonQueryRepresentation {
+	if o.dates == nil {
+		return q
+	}
+	return o.dates(q, o.query.Topic)()
+}
+
+// derive actually performs the objective derivation for the objective method. The artefacts of each stage, for each
+// parameter of the grid search, are returned alongside the queries.
+func (o ObjectiveFormulator) derive(devDF TermStatistics, dev, val []guru.MedlineDocument, suggester MeSHSuggester) (cqr.CommonQueryRepresentation, cqr.CommonQueryRepresentation, []Data, error) {
 	var (
 		bestEval float64
 		bestD    float64
@@ -963,67 +975,51 @@ func (o ObjectiveFormulator) derive(devDF TermStatistics, dev, val []guru.Medlin
 
 		bestQ         cqr.CommonQueryRepresentation
 		bestQWithMesh cqr.CommonQueryRepresentation
-	)
 
-	// Load the sem type mapping.
-	semTypes, err := loadSemTypesMapping(o.SemTypes)
-	if err != nil {
-		return nil, nil, err
-	}
+		stages []Data
+	)
 
-	fmt.Printf("grid search over %v, %v\n", o.DevK, o.PopK)
+	m := o.optimisation
+	devQrels := MakeQrels(dev, o.Topic())
 
 	// Grid search over dev and pop values for the best query on validation.
 	for _, d := range o.DevK {
-		for _, p := range o.PopK {
-			fmt.Println(d, p)
-
-			fmt.Println("cutting terms")
-			// Take terms from dev.
-			cut := cutDevelopmentTerms(devDF, dev, d)
-
-			fmt.Println("ranking terms")
-			// Rank the cut terms in dev.
-			terms := rankTerms(cut, dev, o.query.Topic, o.Folder)
+		// Take terms from dev, and rank them.
+		terms, ranked := rankTerms(cutDevelopmentTerms(devDF, dev, d), dev)
+		stages = append(stages, Data{Name: fmt.Sprintf("development_terms_%g.json", d), Value: jsonData{ranked}})
 
-			fmt.Println("cutting dev terms with population")
+		for _, p := range o.PopK {
 			// Identify dev TermStatistics which appear in <= 2% of the DF of the population set.
-			queryTerms := cutDevelopmentTermsWithPopulation(terms, population, o.query.Topic, o.Folder, p)
-
-			fmt.Println("mapping terms")
-			// Map sem types in TermStatistics.
-			mapping, err := metaMapTerms(queryTerms, metawrap.HTTPClient{URL: o.MetaMapURL})
+			queryTerms, population, err := cutDevelopmentTermsWithPopulation(terms, o.population, p)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
+			stages = append(stages, Data{Name: fmt.Sprintf("population_terms_%g_%g.json", d, p), Value: jsonData{population}})
 
-			fmt.Println("classifying terms")
 			// Classify query TermStatistics.
-			conditions, treatments, studyTypes := classifyQueryTerms(queryTerms, mapping, semTypes)
+			classified, err := o.classifier.Classify(queryTerms)
+			if err != nil {
+				return nil, nil, nil, err
+			}
 
-			fmt.Println("creating keywords")
 			// Create keywords for the proceeding query.
-			conditionsKeywords, treatmentsKeywords, studyTypesKeywords := makeKeywords(conditions, treatments, studyTypes)
+			conditionsKeywords, treatmentsKeywords, studyTypesKeywords := makeKeywords(classified.Conditions, classified.Treatments, classified.StudyTypes)
 
-			fmt.Println("filtering keywords")
 			// And then filter the query TermStatistics.
-			conditions, treatments, studyTypes, err = FilterQueryTerms(conditions, treatments, studyTypes, fields.TitleAbstract, MakeQrels(dev, o.Topic()), o.s)
+			filtered, err := o.filterTerms(classified, fields.TitleAbstract, devQrels)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
+			stages = append(stages, Data{Name: fmt.Sprintf("classification_%g_%g.json", d, p), Value: jsonData{termClassification{Classified: classified, Filtered: filtered}}})
 
-			fmt.Println("constructing final query")
 			// Create the query from the three categories.
-			q := constructQuery(conditionsKeywords, treatmentsKeywords, studyTypesKeywords)
-			fmt.Println(q)
-			q = preprocess.DateRestrictions(o.Pubdates)(q, o.query.Topic)()
-			fmt.Println(q)
+			q := o.restrict(o.constructor(conditionsKeywords, treatmentsKeywords, studyTypesKeywords))
 
-			fmt.Println("evaluating final query")
 			ev, err := evaluate(q, o.s, dev, val, nil, o.Topic())
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
+			stages = append(stages, Data{Name: fmt.Sprintf("evaluation_%g_%g.json", d, p), Value: jsonData{ev}})
 
 			if ev.Validation[m.Name()] > bestEval {
 				bestEval = ev.Validation[m.Name()]
@@ -1036,23 +1032,34 @@ func (o ObjectiveFormulator) derive(devDF TermStatistics, dev, val []guru.Medlin
 			}
 		}
 	}
-	fmt.Println("completed grid search")
+
+	if bestQ == nil {
+		return nil, nil, nil, fmt.Errorf("no query derived for topic %s scores above zero for %s on the validation set", o.Topic(), m.Name())
+	}
 
 	// Grid search parameters of k for the number of mesh keywords to add to a query.
 	bestEval = 0.0
 	for _, k := range o.MeSHK {
-		fmt.Println(k)
-		conditionsKeywordsWithMeSH, treatmentsKeywordsWithMeSH, studyTypesKeywordsWithMeSH, err := addMeSHTerms(bestConditions, bestTreatments, bestStudyTypes, dev, o.s, o.query.Topic, k, o.Folder)
+		suggested, err := suggester.Suggest(dev, k)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
-		qWithMeSH := constructQuery(conditionsKeywordsWithMeSH, treatmentsKeywordsWithMeSH, studyTypesKeywordsWithMeSH)
-		qWithMeSH = preprocess.DateRestrictions(o.Pubdates)(qWithMeSH, o.query.Topic)()
+		filtered, err := o.filterTerms(suggested, fields.MeshHeadings, devQrels)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stages = append(stages, Data{Name: fmt.Sprintf("mesh_%d.json", k), Value: jsonData{termClassification{Classified: suggested, Filtered: filtered}}})
+
+		qWithMeSH := o.restrict(o.constructor(
+			appendKeywords(bestConditions, filtered.Conditions, fields.MeshHeadings),
+			appendKeywords(bestTreatments, filtered.Treatments, fields.MeshHeadings),
+			appendKeywords(bestStudyTypes, filtered.StudyTypes, fields.MeshHeadings)))
 
 		ev, err := evaluate(qWithMeSH, o.s, dev, val, nil, o.Topic())
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
+		stages = append(stages, Data{Name: fmt.Sprintf("evaluation_mesh_%d.json", k), Value: jsonData{ev}})
 
 		if ev.Validation[m.Name()] > bestEval {
 			bestEval = ev.Validation[m.Name()]
@@ -1061,19 +1068,19 @@ func (o ObjectiveFormulator) derive(devDF TermStatistics, dev, val []guru.Medlin
 		}
 	}
 
-	v := make(map[string]interface{})
-	v["d"] = bestD
-	v["p"] = bestP
-	v["m"] = bestM
-	b, err := json.Marshal(v)
-	if err != nil {
-		return nil, nil, err
+	// Without any MeSH headings, the query with MeSH is the query without.
+	if bestQWithMesh == nil {
+		bestQWithMesh = bestQ
 	}
 
-	err = ioutil.WriteFile(path.Join("./objective_qf/params", o.Folder, o.query.Topic+"params.json"), b, 0664)
-	if err != nil {
-		return nil, nil, err
-	}
+	stages = append(stages, Data{
+		Name: "params.json",
+		Value: jsonData{map[string]interface{}{
+			"d": bestD,
+			"p": bestP,
+			"m": bestM,
+		}},
+	})
 
-	return bestQ, bestQWithMesh, nil
+	return bestQ, bestQWithMesh, stages, nil
 }
@@ -0,0 +1,43 @@
+package formulation
+
+import (
+	"github.com/hscells/guru"
+	"strconv"
+	"testing"
+)
+
+func TestRandomSplitter(t *testing.T) {
+	docs := make([]guru.MedlineDocument, 100)
+	for i := range docs {
+		docs[i].PMID = strconv.Itoa(i)
+	}
+
+	s := NewRandomSplitter(1000, 0.6, 0.3)
+	dev, val, unseen := s.Split(docs)
+	if len(dev) != 60 || len(val) != 30 || len(unseen) != 10 {
+		t.Fatalf("expected a 60/30/10 split, got %d/%d/%d", len(dev), len(val), len(unseen))
+	}
+
+	seen := make(map[string]bool)
+	for _, set := range [][]guru.MedlineDocument{dev, val, unseen} {
+		for _, doc := range set {
+			if seen[doc.PMID] {
+				t.Fatalf("document %s is in more than one set", doc.PMID)
+			}
+			seen[doc.PMID] = true
+		}
+	}
+
+	for i, doc := range docs {
+		if doc.PMID != strconv.Itoa(i) {
+			t.Fatal("expected the documents to be left unmodified")
+		}
+	}
+
+	dev2, _, _ := s.Split(docs)
+	for i := range dev {
+		if dev[i].PMID != dev2[i].PMID {
+			t.Fatal("expected the same seed to split documents the same way")
+		}
+	}
+}
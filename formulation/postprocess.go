@@ -1,14 +1,17 @@
 package formulation
 
 import (
+	"encoding/csv"
 	"fmt"
 	rake "github.com/afjoseph/RAKE.Go"
 	"github.com/hscells/cqr"
 	"github.com/hscells/cui2vec"
 	"github.com/hscells/groove/analysis"
 	"github.com/hscells/guru"
-	"github.com/hscells/metawrap"
 	"github.com/hscells/transmute/fields"
+	"io"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -43,13 +46,96 @@ func avgVecs(vs ...[]float64) []float64 {
 	return v
 }
 
-func RelevanceFeedback(query cqr.CommonQueryRepresentation, docs guru.MedlineDocuments, mm metawrap.HTTPClient) (cqr.CommonQueryRepresentation, error) {
+// ConceptCandidates finds the concepts (e.g. UMLS CUIs) referred to in text, for relevance feedback. Both
+// MetaMapEntityExtractor and DictionaryEntityExtractor find candidate concepts.
+type ConceptCandidates interface {
+	Concepts(text string) ([]string, error)
+}
+
+// ConceptEmbeddings are the embeddings of concepts, such as those served by a cui2vec client.
+type ConceptEmbeddings interface {
+	Vec(cui string) ([]float64, error)
+}
+
+// ConceptEmbeddingsFunc adapts a function to the ConceptEmbeddings interface.
+type ConceptEmbeddingsFunc func(cui string) ([]float64, error)
+
+func (f ConceptEmbeddingsFunc) Vec(cui string) ([]float64, error) {
+	return f(cui)
+}
+
+// conceptVectors are embeddings of concepts held in memory.
+type conceptVectors map[string][]float64
+
+func (v conceptVectors) Vec(cui string) ([]float64, error) {
+	return v[cui], nil
+}
+
+// ReadConceptEmbeddings reads the embeddings of concepts as comma-separated values, one concept per line, where the
+// first value is the CUI and the remaining values are its embedding (e.g. the cui2vec_pretrained.csv file distributed
+// with cui2vec). A header line, and quotes around values, are permitted.
+func ReadConceptEmbeddings(r io.Reader) (ConceptEmbeddings, error) {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.ReuseRecord = true
+	vecs := make(conceptVectors)
+	for n := 1; ; n++ {
+		record, err := c.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected CUI and embedding", n)
+		}
+		v := make([]float64, len(record)-1)
+		for i, value := range record[1:] {
+			v[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			// The header of the file names the dimensions rather than containing values.
+			if n == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		vecs[strings.TrimSpace(record[0])] = v
+	}
+	return vecs, nil
+}
+
+// LoadConceptEmbeddings loads the embeddings of concepts from a comma-separated file (see ReadConceptEmbeddings).
+func LoadConceptEmbeddings(path string) (ConceptEmbeddings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	// Open a connection to vector client.
-	client, err := cui2vec.NewVecClient("localhost:8003")
+	e, err := ReadConceptEmbeddings(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return e, nil
+}
+
+// NewCui2VecEmbeddings connects to a cui2vec server for the embeddings of concepts.
+func NewCui2VecEmbeddings(addr string) (ConceptEmbeddings, error) {
+	client, err := cui2vec.NewVecClient(addr)
 	if err != nil {
 		return nil, err
 	}
+	return ConceptEmbeddingsFunc(client.Vec), nil
+}
+
+// RelevanceFeedback adds the keywords of feedback documents to the clause of a query most similar to them. Keywords
+// are mapped to concepts by a ConceptCandidates, and compared to clauses using the embeddings of the concepts.
+func RelevanceFeedback(query cqr.CommonQueryRepresentation, docs guru.MedlineDocuments, candidates ConceptCandidates, client ConceptEmbeddings) (cqr.CommonQueryRepresentation, error) {
 
 	// Function for embedding a clause by averaging child vectors.
 	var embed func(q cqr.CommonQueryRepresentation) []float64
@@ -100,7 +186,7 @@ func RelevanceFeedback(query cqr.CommonQueryRepresentation, docs guru.MedlineDoc
 	for keyword := range keywords {
 
 		// Obtain CUIs for a keyword.
-		concepts, err := mm.Candidates(keyword)
+		concepts, err := candidates.Concepts(keyword)
 		if err != nil {
 			return nil, err
 		}
@@ -110,19 +196,14 @@ func RelevanceFeedback(query cqr.CommonQueryRepresentation, docs guru.MedlineDoc
 		// For each of the extracted CUIs.
 		for _, concept := range concepts {
 
-			if concept.CandidateScore != "-1000" {
-				fmt.Println(" - [x] (too low score)", concept.CandidateCUI)
-				continue
-			}
-
 			// Obtain an embedding for the CUI.
-			embedding, _ := client.Vec(concept.CandidateCUI)
+			embedding, _ := client.Vec(concept)
 			if len(embedding) == 0 {
-				fmt.Println(" - [x] (no embeddings)", concept.CandidateCUI)
+				fmt.Println(" - [x] (no embeddings)", concept)
 				continue
 			}
 
-			fmt.Println(" - [√]", concept.CandidateCUI)
+			fmt.Println(" - [√]", concept)
 
 			// Find the clause to add the CUI to using the most similar clause.
 			var highestSim float64
@@ -149,7 +230,7 @@ func RelevanceFeedback(query cqr.CommonQueryRepresentation, docs guru.MedlineDoc
 			case cqr.Keyword:
 				child = cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{nq})
 			}
-			kw := cqr.NewKeyword(keyword, fields.TitleAbstract).SetOption(Entity, concept.CandidateCUI)
+			kw := cqr.NewKeyword(keyword, fields.TitleAbstract).SetOption(Entity, concept)
 			child.Children = append(child.Children, kw)
 			bq.Children[clause] = child
 		}
@@ -0,0 +1,191 @@
+package formulation
+
+import (
+	"github.com/hscells/cqr"
+	"sort"
+	"strings"
+)
+
+// Condition is the presence, or absence if negated, of an attribute.
+type Condition struct {
+	Attribute
+	Negated bool `json:"negated,omitempty"`
+}
+
+func (c Condition) String() string {
+	if c.Negated {
+		return "NOT " + c.Attribute.String()
+	}
+	return c.Attribute.String()
+}
+
+// less orders conditions by field, then value, then presence.
+func (c Condition) less(o Condition) bool {
+	if c.Field != o.Field {
+		return c.Field < o.Field
+	}
+	if c.Value != o.Value {
+		return c.Value < o.Value
+	}
+	return !c.Negated && o.Negated
+}
+
+// Rule is a conjunction of conditions that identifies relevant studies.
+type Rule struct {
+	Conditions []Condition `json:"conditions"`
+}
+
+func (r Rule) String() string {
+	s := make([]string, len(r.Conditions))
+	for i, c := range r.Conditions {
+		s[i] = c.String()
+	}
+	return strings.Join(s, " AND ")
+}
+
+// Matches is whether a study with some attributes satisfies every condition of the rule.
+func (r Rule) Matches(attrs []Attribute) bool {
+	has := make(map[Attribute]bool, len(attrs))
+	for _, a := range attrs {
+		has[a] = true
+	}
+	for _, c := range r.Conditions {
+		if has[c.Attribute] == c.Negated {
+			return false
+		}
+	}
+	return true
+}
+
+// Query converts the rule into a query: the attributes that must be present, combined with `and`, excluding the
+// attributes that must be absent. A rule without any attributes that must be present cannot be expressed as a query.
+func (r Rule) Query() (cqr.CommonQueryRepresentation, bool) {
+	var present, absent []cqr.CommonQueryRepresentation
+	for _, c := range r.Conditions {
+		if c.Negated {
+			absent = append(absent, c.Keyword())
+		} else {
+			present = append(present, c.Keyword())
+		}
+	}
+	if len(present) == 0 {
+		return nil, false
+	}
+	q := present[0]
+	if len(present) > 1 {
+		q = cqr.NewBooleanQuery(cqr.AND, present)
+	}
+	if len(absent) > 0 {
+		q = cqr.NewBooleanQuery(cqr.NOT, append([]cqr.CommonQueryRepresentation{q}, absent...))
+	}
+	return q, true
+}
+
+// normalise sorts the conditions of a rule and removes duplicates. Rules that contradict themselves are not valid.
+func (r Rule) normalise() (Rule, bool) {
+	seen := make(map[Condition]bool, len(r.Conditions))
+	var conditions []Condition
+	for _, c := range r.Conditions {
+		if seen[Condition{Attribute: c.Attribute, Negated: !c.Negated}] {
+			return Rule{}, false
+		}
+		if !seen[c] {
+			seen[c] = true
+			conditions = append(conditions, c)
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].less(conditions[j])
+	})
+	return Rule{Conditions: conditions}, true
+}
+
+// subsumes is whether every condition of the rule is a condition of another rule, so that the rule identifies every
+// study the other rule does.
+func (r Rule) subsumes(o Rule) bool {
+	conditions := make(map[Condition]bool, len(o.Conditions))
+	for _, c := range o.Conditions {
+		conditions[c] = true
+	}
+	for _, c := range r.Conditions {
+		if !conditions[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// merge combines two rules which differ only in the presence of one attribute into a rule without that condition.
+func (r Rule) merge(o Rule) (Rule, bool) {
+	if len(r.Conditions) != len(o.Conditions) {
+		return Rule{}, false
+	}
+	differ := -1
+	for i := range r.Conditions {
+		if r.Conditions[i] == o.Conditions[i] {
+			continue
+		}
+		if differ >= 0 || r.Conditions[i].Attribute != o.Conditions[i].Attribute {
+			return Rule{}, false
+		}
+		differ = i
+	}
+	if differ < 0 {
+		return Rule{}, false
+	}
+	conditions := append(append([]Condition(nil), r.Conditions[:differ]...), r.Conditions[differ+1:]...)
+	return Rule{Conditions: conditions}, true
+}
+
+// RuleSet is a disjunction of rules.
+type RuleSet []Rule
+
+// Minimise simplifies a rule set into an equivalent rule set with fewer rules and conditions: rules which differ only
+// in the presence of one attribute are merged, and rules subsumed by a more general rule are removed. Rules are ordered
+// by their number of conditions, and then by their conditions.
+func (rs RuleSet) Minimise() RuleSet {
+	var rules RuleSet
+	for _, r := range rs {
+		if n, ok := r.normalise(); ok {
+			rules = append(rules, n)
+		}
+	}
+
+	for merged := true; merged; {
+		merged = false
+	merging:
+		for i := 0; i < len(rules); i++ {
+			for j := i + 1; j < len(rules); j++ {
+				if m, ok := rules[i].merge(rules[j]); ok {
+					rules[i] = m
+					rules = append(rules[:j], rules[j+1:]...)
+					merged = true
+					break merging
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].Conditions) != len(rules[j].Conditions) {
+			return len(rules[i].Conditions) < len(rules[j].Conditions)
+		}
+		return rules[i].String() < rules[j].String()
+	})
+
+	// A rule is only kept if no rule before it, which has at most as many conditions, subsumes it.
+	var minimal RuleSet
+	for _, r := range rules {
+		subsumed := false
+		for _, m := range minimal {
+			if m.subsumes(r) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			minimal = append(minimal, r)
+		}
+	}
+	return minimal
+}
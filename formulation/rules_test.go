@@ -0,0 +1,97 @@
+package formulation
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/guru"
+	"github.com/hscells/transmute/fields"
+	"strconv"
+	"testing"
+)
+
+func TestMinimise(t *testing.T) {
+	a := Attribute{Field: fields.TitleAbstract, Value: "stroke"}
+	b := Attribute{Field: fields.MeshHeadings, Value: "Thrombolytic Therapy"}
+	c := Attribute{Field: fields.PublicationType, Value: "Review"}
+
+	rules := RuleSet{
+		{Conditions: []Condition{{Attribute: a}, {Attribute: b}}},
+		{Conditions: []Condition{{Attribute: b}, {Attribute: a, Negated: true}}},
+		{Conditions: []Condition{{Attribute: b}, {Attribute: c}}},
+		{Conditions: []Condition{{Attribute: c}, {Attribute: c, Negated: true}}},
+		{Conditions: []Condition{{Attribute: a}, {Attribute: c, Negated: true}}},
+	}.Minimise()
+
+	// a AND b, and NOT a AND b merge into b, which subsumes b AND c. The contradictory rule is removed.
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d: %v", len(rules), rules)
+	}
+	if rules[0].String() != b.String() {
+		t.Errorf("expected the first rule to be %s, got %s", b, rules[0])
+	}
+	if rules[1].String() != "stroke[title_abstract] AND NOT Review[pt]" &&
+		rules[1].String() != "NOT Review[pt] AND stroke[title_abstract]" {
+		t.Errorf("unexpected second rule %s", rules[1])
+	}
+
+	if _, ok := (Rule{Conditions: []Condition{{Attribute: c, Negated: true}}}).Query(); ok {
+		t.Error("expected a rule of only absent attributes not to be expressible as a query")
+	}
+}
+
+func TestAttributeKeyword(t *testing.T) {
+	k := Attribute{Field: fields.MeshHeadings, Value: "Stroke"}.Keyword()
+	if exploded, ok := k.Options[cqr.ExplodedString].(bool); !ok || exploded {
+		t.Errorf("expected MeSH headings not to be exploded, got %v", k.Options)
+	}
+	k = Attribute{Field: fields.TitleAbstract, Value: "stroke"}.Keyword()
+	if _, ok := k.Options[cqr.ExplodedString]; ok {
+		t.Errorf("expected terms to have no explosion option, got %v", k.Options)
+	}
+}
+
+func TestDecisionTreeFormulator(t *testing.T) {
+	var positive, negative guru.MedlineDocuments
+	for i := 0; i < 10; i++ {
+		positive = append(positive, guru.MedlineDocument{
+			PMID: strconv.Itoa(i),
+			TI:   "Thrombolysis for acute stroke",
+			PT:   []string{"Randomized Controlled Trial"},
+		})
+		negative = append(negative, guru.MedlineDocument{
+			PMID: strconv.Itoa(100 + i),
+			TI:   "Thrombolysis for myocardial infarction",
+			PT:   []string{"Randomized Controlled Trial"},
+		})
+	}
+	// Stroke studies that are reviews are not relevant.
+	for i := 0; i < 5; i++ {
+		negative = append(negative, guru.MedlineDocument{
+			PMID: strconv.Itoa(200 + i),
+			TI:   "Thrombolysis for acute stroke",
+			PT:   []string{"Review"},
+		})
+	}
+
+	dt, err := NewDecisionTreeFormulator("1", positive, negative, DecisionTreeCost(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries, sup, err := dt.Formulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("expected one query, got %d", len(queries))
+	}
+	if len(sup) != 1 || len(sup[0].Data) != 1 {
+		t.Fatal("expected the rules to be explained")
+	}
+
+	rules := sup[0].Data[0].Value.(jsonData).v.([]RuleExplanation)
+	if len(rules) != 1 {
+		t.Fatalf("expected one rule, got %v", rules)
+	}
+	if rules[0].Relevant != 10 || rules[0].NonRelevant != 0 || rules[0].Recall != 1 || rules[0].Precision != 1 {
+		t.Errorf("expected the rule to identify every relevant study and no others, got %+v", rules[0])
+	}
+}
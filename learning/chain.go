@@ -5,10 +5,12 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/hscells/cqr"
 	"github.com/hscells/groove/analysis"
 	"github.com/hscells/groove/combinator"
 	"github.com/hscells/groove/eval"
 	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/query"
 	"github.com/hscells/groove/stats"
 	"github.com/hscells/transmute"
 	"github.com/hscells/transmute/backend"
@@ -40,6 +42,10 @@ type QueryChain struct {
 	QrelsFile           trecresults.QrelsFile
 	GenerationExplorer  QueryChainGenerationExplorer
 	ComputeFeatures     bool
+	// ExplanationQrels are relevance assessments (e.g. the seed studies of topics) used to explain why generated
+	// candidates miss relevant documents and retrieve non-relevant ones. When set, the features of the explanations
+	// are added to the features of the candidates of those topics (see WithExplanationFeatures).
+	ExplanationQrels trecresults.QrelsFile
 }
 
 // Generate will create test data sampling using random stratified sampling.
@@ -95,6 +101,21 @@ func (qc *QueryChain) Generate() error {
 
 			}
 
+			// The query written to file is the PubMed translation, so any way in which it changes is logged.
+			repr, err := s2.Representation()
+			if err != nil {
+				log.Printf("topic %s: skipping candidate that cannot be translated to PubMed: %v\n", cq.Topic, err)
+				continue
+			}
+			translated, ok := repr.(cqr.CommonQueryRepresentation)
+			if !ok {
+				log.Printf("topic %s: skipping candidate that cannot be translated to PubMed\n", cq.Topic)
+				continue
+			}
+			if d := query.CompareQueries(candidate.Query, translated); len(d) > 0 {
+				log.Printf("topic %s: candidate changes when translated to PubMed: %v\n", cq.Topic, d)
+			}
+
 			gq := pipeline.NewQuery(cq.Name, cq.Topic, candidate.Query)
 
 			tree, _, err := combinator.NewLogicalTree(gq, qc.StatisticsSource, qc.QueryCacher)
@@ -119,8 +140,13 @@ func (qc *QueryChain) Generate() error {
 				return err
 			}
 
+			features := candidate.Features
+			if qrels, ok := qc.ExplanationQrels.Qrels[gq.Topic]; ok {
+				features = WithExplanationFeatures(features, eval.Explain(gq, tree, qc.QueryCacher, qrels))
+			}
+
 			// Lock and write the results for each evaluation metric to file.
-			lf := NewLearntFeature(candidate.Features)
+			lf := NewLearntFeature(features)
 			lf.Topic = gq.Topic
 			lf.Comment = fn
 			lf.Scores = make([]float64, len(qc.Evaluators))
@@ -7,6 +7,7 @@ import (
 	"github.com/hscells/cqr"
 	"github.com/hscells/groove/analysis"
 	"github.com/hscells/groove/analysis/preqpp"
+	"github.com/hscells/groove/eval"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/groove/stats"
 	"github.com/xtgo/set"
@@ -94,6 +95,35 @@ var MeasurementFeatureKeys = map[string]int{
 // Chain of transformations !!THIS MUST BE THE LAST FEATURE IN THE LIST!!
 var ChainFeatures = chainFeatures + len(MeasurementFeatureKeys)*2
 
+// ExplanationFeatures creates features from an explanation of why a query misses relevant documents and retrieves
+// non-relevant documents (see eval.Explain). They are numbered from ChainFeatures, in place of the chain of
+// transformations (see WithExplanationFeatures).
+func ExplanationFeatures(e eval.Explanation) Features {
+	scores := e.Features()
+	ff := make(Features, len(eval.ExplanationFeatures))
+	for i, name := range eval.ExplanationFeatures {
+		ff[i] = NewFeature(ChainFeatures+i, scores[name])
+	}
+	return ff
+}
+
+// WithExplanationFeatures adds the features of an explanation to the features of a candidate query, moving the chain
+// of transformations after them. Only the features returned are renumbered, so models learnt from them must be used
+// with candidates that are explained in the same way.
+func WithExplanationFeatures(ff Features, e eval.Explanation) Features {
+	explained := make(Features, 0, len(ff)+len(eval.ExplanationFeatures))
+	var chain Features
+	for _, f := range ff {
+		if f.ID >= ChainFeatures {
+			chain = append(chain, NewFeature(f.ID+len(eval.ExplanationFeatures), f.Score))
+			continue
+		}
+		explained = append(explained, f)
+	}
+	explained = append(explained, ExplanationFeatures(e)...)
+	return append(explained, chain...)
+}
+
 // NewFeature creates a new feature with the specified ID and `Score`.
 func NewFeature(id int, score float64) Feature {
 	return Feature{id, score}
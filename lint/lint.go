@@ -0,0 +1,191 @@
+// Package lint checks Boolean queries for mistakes before they are executed. A linter walks a query and applies
+// rules to each clause, reporting findings with the path of the clause and the severity of the problem.
+package lint
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a finding is.
+type Severity int
+
+const (
+	// Info findings are stylistic and do not change the results of a query.
+	Info Severity = iota
+	// Warning findings are likely to change the results of a query in an unintended way.
+	Warning
+	// Error findings will cause a query to fail, or to retrieve nothing.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Path is the location of a clause in a query, as the index of each child from the root.
+type Path []int
+
+// Child creates the path of the i-th child of a clause.
+func (p Path) Child(i int) Path {
+	c := make(Path, len(p)+1)
+	copy(c, p)
+	c[len(p)] = i
+	return c
+}
+
+// String formats a path, e.g. /0/2 is the third child of the first child of the root.
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, i := range p {
+		b.WriteString("/")
+		b.WriteString(strconv.Itoa(i))
+	}
+	return b.String()
+}
+
+// Finding is a problem found in a query.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Path     Path
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s (%s)", f.Severity, f.Path, f.Message, f.Rule)
+}
+
+// Findings are the findings of linting a query.
+type Findings []Finding
+
+// Max is the highest severity of the findings.
+func (f Findings) Max() Severity {
+	max := Info
+	for _, finding := range f {
+		if finding.Severity > max {
+			max = finding.Severity
+		}
+	}
+	return max
+}
+
+// Rule checks a single clause of a query.
+type Rule func(query cqr.CommonQueryRepresentation, path Path) []Finding
+
+// Linter applies rules to every clause of a query.
+type Linter struct {
+	rules []Rule
+}
+
+// DefaultRules are the rules a linter applies when none are specified.
+var DefaultRules = []Rule{
+	EmptyClause,
+	LeadingNot,
+	RedundantNesting,
+	ShortTruncation(3),
+	DuplicateKeywords,
+	UnknownMeSH,
+	AdjacencyDistance,
+}
+
+// BleveFields are the query fields supported by the Bleve statistics source.
+var BleveFields = []string{
+	stats.BleveTitleField, stats.BleveAbstractField, stats.BleveMeSHField, stats.BlevePublicationTypeField,
+	stats.BlevePublicationDateField, fields.Title, fields.Abstract, fields.TitleAbstract, fields.MeshHeadings,
+	fields.MeSHTerms, fields.MeSHMajorTopic, fields.MeSHSubheading, fields.FloatingMeshHeadings,
+	fields.MajorFocusMeshHeading, fields.PublicationType, fields.PublicationDate,
+}
+
+// PubMedFields are the query fields supported by PubMed, and so by the Entrez statistics source.
+var PubMedFields = []string{
+	fields.Title, fields.Abstract, fields.TitleAbstract, fields.TextWord, fields.AllFields, fields.MeshHeadings,
+	fields.MeSHTerms, fields.MeSHMajorTopic, fields.MeSHSubheading, fields.FloatingMeshHeadings,
+	fields.MajorFocusMeshHeading, fields.PublicationType, fields.PublicationDate,
+}
+
+// SupportedFields are the query fields a statistics source can search. A nil slice means that the source places no
+// restriction on fields; e.g. an Elasticsearch index may contain any fields.
+func SupportedFields(ss stats.StatisticsSource) []string {
+	switch ss.(type) {
+	case *stats.BleveStatisticsSource:
+		return BleveFields
+	case stats.EntrezStatisticsSource, *stats.EntrezStatisticsSource:
+		return PubMedFields
+	}
+	return nil
+}
+
+// NewLinter creates a new linter. Without options, the default rules are applied.
+func NewLinter(options ...func(*Linter)) Linter {
+	l := Linter{}
+	for _, option := range options {
+		option(&l)
+	}
+	if l.rules == nil {
+		l.rules = DefaultRules
+	}
+	return l
+}
+
+// LinterRules adds rules to the linter in place of the default rules.
+func LinterRules(rules ...Rule) func(*Linter) {
+	return func(l *Linter) {
+		l.rules = append(l.rules, rules...)
+	}
+}
+
+// LinterStatisticsSource applies the default rules as well as checking fields are supported by the statistics
+// source the query will be executed on.
+func LinterStatisticsSource(ss stats.StatisticsSource) func(*Linter) {
+	return LinterFields(SupportedFields(ss)...)
+}
+
+// LinterFields applies the default rules as well as checking only the specified fields are used.
+func LinterFields(supported ...string) func(*Linter) {
+	return func(l *Linter) {
+		if l.rules == nil {
+			l.rules = append([]Rule{}, DefaultRules...)
+		}
+		if len(supported) > 0 {
+			l.rules = append(l.rules, UnsupportedFields(supported...))
+		}
+	}
+}
+
+// Lint applies the rules of the linter to every clause in a query.
+func (l Linter) Lint(query cqr.CommonQueryRepresentation) Findings {
+	return l.lint(query, Path{})
+}
+
+func (l Linter) lint(query cqr.CommonQueryRepresentation, path Path) (findings Findings) {
+	for _, rule := range l.rules {
+		findings = append(findings, rule(query, path)...)
+	}
+	if q, ok := query.(cqr.BooleanQuery); ok {
+		for i, child := range q.Children {
+			findings = append(findings, l.lint(child, path.Child(i))...)
+		}
+	}
+	return
+}
+
+// Lint applies the default rules to a query.
+func Lint(query cqr.CommonQueryRepresentation) Findings {
+	return NewLinter().Lint(query)
+}
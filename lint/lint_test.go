@@ -0,0 +1,134 @@
+package lint
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	query := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("heart attack", fields.TitleAbstract),
+			cqr.NewKeyword("ab*", fields.TitleAbstract).SetOption("truncated", true),
+			cqr.NewKeyword("Heart Attack", fields.TitleAbstract),
+		}),
+		cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("myocardial", fields.Title),
+			cqr.NewKeyword("infarction", fields.Title),
+		}),
+		cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("animals", fields.Abstract),
+		}),
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{}),
+		cqr.NewBooleanQuery("adj", []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("cardiac", fields.TextWord),
+			cqr.NewKeyword("arrest", fields.TextWord),
+		}),
+	})
+
+	linter := NewLinter(LinterRules(EmptyClause, LeadingNot, RedundantNesting, ShortTruncation(3), DuplicateKeywords, AdjacencyDistance),
+		LinterFields(fields.Title, fields.Abstract, fields.TitleAbstract))
+	findings := linter.Lint(query)
+
+	want := map[string]Severity{
+		"short-truncation /0/1":  Warning,
+		"duplicate-keyword /0/2": Info,
+		"redundant-nesting /1":   Info,
+		"leading-not /2":         Error,
+		"empty-clause /3":        Error,
+		"adjacency-distance /4":  Warning,
+		"unsupported-field /4/0": Error,
+		"unsupported-field /4/1": Error,
+	}
+	if len(findings) != len(want) {
+		t.Errorf("expected %d findings, got %d: %v", len(want), len(findings), findings)
+	}
+	for _, finding := range findings {
+		key := finding.Rule + " " + finding.Path.String()
+		if severity, ok := want[key]; !ok {
+			t.Errorf("unexpected finding %s", finding)
+		} else if severity != finding.Severity {
+			t.Errorf("expected %s to be %s, got %s", key, severity, finding.Severity)
+		}
+	}
+	if findings.Max() != Error {
+		t.Errorf("expected maximum severity to be error, got %s", findings.Max())
+	}
+}
+
+func TestAdjacencyDistance(t *testing.T) {
+	children := []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("cardiac", fields.TextWord),
+		cqr.NewKeyword("arrest", fields.TextWord),
+	}
+	tests := []struct {
+		query cqr.BooleanQuery
+		want  []Severity
+	}{
+		{cqr.NewBooleanQuery("adj3", children), nil},
+		{cqr.NewBooleanQuery("ADJ5", children), nil},
+		{cqr.NewBooleanQuery("adj", children).SetOption("distance", 2).(cqr.BooleanQuery), nil},
+		{cqr.NewBooleanQuery("adj", children), []Severity{Warning}},
+		{cqr.NewBooleanQuery("adjx", children), []Severity{Error}},
+		{cqr.NewBooleanQuery("adj0", children), []Severity{Error}},
+	}
+	for _, test := range tests {
+		findings := AdjacencyDistance(test.query, Path{})
+		if len(findings) != len(test.want) {
+			t.Errorf("%s: expected %d findings, got %v", test.query.Operator, len(test.want), findings)
+			continue
+		}
+		for i, finding := range findings {
+			if finding.Severity != test.want[i] {
+				t.Errorf("%s: expected %s, got %s", test.query.Operator, test.want[i], finding.Severity)
+			}
+		}
+	}
+}
+
+func TestLeadingNot(t *testing.T) {
+	a, b, c := cqr.NewKeyword("a", fields.Title), cqr.NewKeyword("b", fields.Title), cqr.NewKeyword("c", fields.Title)
+	query := cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+			cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{a, b}),
+			c,
+		}),
+		b,
+	})
+	findings := LeadingNot(query, Path{})
+	if len(findings) != 1 || findings[0].Path.String() != "/0/0" || findings[0].Severity != Warning {
+		t.Errorf("expected a NOT clause beginning a nested group to be found, got %v", findings)
+	}
+
+	query = cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{a, cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{b, c})}),
+		b,
+	})
+	if findings := LeadingNot(query, Path{}); len(findings) != 0 {
+		t.Errorf("expected a NOT clause that does not begin the first operand to be allowed, got %v", findings)
+	}
+}
+
+func TestSupportedFields(t *testing.T) {
+	contains := func(fields []string, field string) bool {
+		for _, f := range fields {
+			if f == field {
+				return true
+			}
+		}
+		return false
+	}
+	entrez := SupportedFields(stats.EntrezStatisticsSource{})
+	if !contains(entrez, fields.TextWord) || !contains(entrez, fields.MeSHSubheading) {
+		t.Errorf("expected the PubMed fields for Entrez, got %v", entrez)
+	}
+	bleve := SupportedFields(&stats.BleveStatisticsSource{})
+	if contains(bleve, fields.TextWord) || !contains(bleve, stats.BleveMeSHField) {
+		t.Errorf("expected the Bleve fields for Bleve, got %v", bleve)
+	}
+	if f := SupportedFields(&stats.ElasticsearchStatisticsSource{}); f != nil {
+		t.Errorf("expected no restriction on the fields of Elasticsearch, got %v", f)
+	}
+}
@@ -0,0 +1,200 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/analysis"
+	"github.com/hscells/transmute/fields"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// meshFields are the fields that contain MeSH headings.
+var meshFields = map[string]bool{
+	fields.MeshHeadings:          true,
+	fields.MeSHTerms:             true,
+	fields.MeSHMajorTopic:        true,
+	fields.MajorFocusMeshHeading: true,
+	fields.FloatingMeshHeadings:  true,
+}
+
+// operator normalises the operator of a Boolean query.
+func operator(q cqr.BooleanQuery) string {
+	return strings.ToLower(strings.TrimSpace(q.Operator))
+}
+
+// isTruncated reports whether a keyword uses truncation.
+func isTruncated(kw cqr.Keyword) bool {
+	if truncated, ok := kw.Options["truncated"].(bool); ok && truncated {
+		return true
+	}
+	return strings.Contains(kw.QueryString, "*")
+}
+
+// EmptyClause finds Boolean clauses without any children and keywords without any text.
+func EmptyClause(query cqr.CommonQueryRepresentation, path Path) []Finding {
+	switch q := query.(type) {
+	case cqr.BooleanQuery:
+		if len(q.Children) == 0 {
+			return []Finding{{Rule: "empty-clause", Severity: Error, Path: path, Message: fmt.Sprintf("%s clause has no children", strings.ToUpper(q.Operator))}}
+		}
+	case cqr.Keyword:
+		if len(strings.TrimSpace(q.QueryString)) == 0 {
+			return []Finding{{Rule: "empty-clause", Severity: Error, Path: path, Message: "keyword has no text"}}
+		}
+	}
+	return nil
+}
+
+// LeadingNot finds NOT clauses without a first operand to exclude documents from, i.e., queries that begin with NOT.
+// NOT clauses that begin the first operand of another NOT clause, however deeply they are nested, are also found.
+func LeadingNot(query cqr.CommonQueryRepresentation, path Path) []Finding {
+	if q, ok := query.(cqr.BooleanQuery); ok && operator(q) == cqr.NOT {
+		if len(q.Children) == 1 {
+			return []Finding{{Rule: "leading-not", Severity: Error, Path: path, Message: "NOT is the first operand, so there is nothing to exclude documents from"}}
+		}
+		if len(q.Children) > 1 {
+			first, p := q.Children[0], path.Child(0)
+			for {
+				c, ok := first.(cqr.BooleanQuery)
+				if !ok || len(c.Children) == 0 {
+					break
+				}
+				if operator(c) == cqr.NOT {
+					return []Finding{{Rule: "leading-not", Severity: Warning, Path: p, Message: "first operand of NOT begins with a NOT clause"}}
+				}
+				first, p = c.Children[0], p.Child(0)
+			}
+		}
+	}
+	return nil
+}
+
+// RedundantNesting finds AND and OR clauses nested directly inside a clause with the same operator, which can be
+// flattened into their parent.
+func RedundantNesting(query cqr.CommonQueryRepresentation, path Path) (findings []Finding) {
+	q, ok := query.(cqr.BooleanQuery)
+	if !ok {
+		return nil
+	}
+	op := operator(q)
+	if op != cqr.AND && op != cqr.OR {
+		return nil
+	}
+	for i, child := range q.Children {
+		if c, ok := child.(cqr.BooleanQuery); ok && operator(c) == op {
+			findings = append(findings, Finding{Rule: "redundant-nesting", Severity: Info, Path: path.Child(i), Message: fmt.Sprintf("%s clause nested inside %s clause can be flattened", strings.ToUpper(op), strings.ToUpper(op))})
+		}
+	}
+	return
+}
+
+// ShortTruncation creates a rule that finds truncated keywords whose stem is shorter than n characters (e.g. ab*),
+// which match far too many terms.
+func ShortTruncation(n int) Rule {
+	return func(query cqr.CommonQueryRepresentation, path Path) []Finding {
+		kw, ok := query.(cqr.Keyword)
+		if !ok || !isTruncated(kw) {
+			return nil
+		}
+		for _, term := range strings.Fields(kw.QueryString) {
+			if !strings.Contains(term, "*") {
+				continue
+			}
+			stem := strings.Trim(term[:strings.Index(term, "*")], `"`)
+			if len([]rune(stem)) < n {
+				return []Finding{{Rule: "short-truncation", Severity: Warning, Path: path, Message: fmt.Sprintf("truncated stem %q is shorter than %d characters", stem, n)}}
+			}
+		}
+		return nil
+	}
+}
+
+// keywordKey identifies keywords that retrieve the same documents.
+func keywordKey(kw cqr.Keyword) string {
+	f := append([]string{}, kw.Fields...)
+	sort.Strings(f)
+	exploded, _ := kw.Options[cqr.ExplodedString].(bool)
+	return fmt.Sprintf("%s|%s|%t|%t", strings.ToLower(strings.TrimSpace(kw.QueryString)), strings.Join(f, ","), exploded, isTruncated(kw))
+}
+
+// DuplicateKeywords finds keywords repeated within the same OR clause.
+func DuplicateKeywords(query cqr.CommonQueryRepresentation, path Path) (findings []Finding) {
+	q, ok := query.(cqr.BooleanQuery)
+	if !ok || operator(q) != cqr.OR {
+		return nil
+	}
+	seen := make(map[string]int)
+	for i, child := range q.Children {
+		kw, ok := child.(cqr.Keyword)
+		if !ok {
+			continue
+		}
+		key := keywordKey(kw)
+		if j, ok := seen[key]; ok {
+			findings = append(findings, Finding{Rule: "duplicate-keyword", Severity: Info, Path: path.Child(i), Message: fmt.Sprintf("keyword %q duplicates %s", kw.QueryString, path.Child(j))})
+			continue
+		}
+		seen[key] = i
+	}
+	return
+}
+
+// UnknownMeSH finds MeSH headings that are not in the MeSH tree, which are usually misspelt or out of date.
+func UnknownMeSH(query cqr.CommonQueryRepresentation, path Path) []Finding {
+	kw, ok := query.(cqr.Keyword)
+	if !ok || analysis.MeSHTree == nil {
+		return nil
+	}
+	for _, field := range kw.Fields {
+		if !meshFields[field] {
+			continue
+		}
+		heading := strings.TrimSpace(strings.NewReplacer("*", "", `"`, "").Replace(kw.QueryString))
+		if len(analysis.MeSHTree.Reference(heading)) == 0 {
+			return []Finding{{Rule: "unknown-mesh", Severity: Warning, Path: path, Message: fmt.Sprintf("MeSH heading %q is not in the MeSH tree", heading)}}
+		}
+		return nil
+	}
+	return nil
+}
+
+// AdjacencyDistance finds adjacency operators that do not specify a distance, either as a suffix of the operator (e.g.
+// adj3) or as an option, and operators with a distance that is not a positive number.
+func AdjacencyDistance(query cqr.CommonQueryRepresentation, path Path) []Finding {
+	q, ok := query.(cqr.BooleanQuery)
+	if !ok || !strings.HasPrefix(operator(q), "adj") {
+		return nil
+	}
+	if distance := strings.TrimPrefix(operator(q), "adj"); len(distance) > 0 {
+		if n, err := strconv.Atoi(distance); err != nil || n < 1 {
+			return []Finding{{Rule: "adjacency-distance", Severity: Error, Path: path, Message: fmt.Sprintf("adjacency distance %q is not a positive number", distance)}}
+		}
+		return nil
+	}
+	if _, ok := q.Options["distance"]; ok {
+		return nil
+	}
+	return []Finding{{Rule: "adjacency-distance", Severity: Warning, Path: path, Message: "adjacency operator has no distance"}}
+}
+
+// UnsupportedFields creates a rule that finds keywords using fields other than those supported.
+func UnsupportedFields(supported ...string) Rule {
+	s := make(map[string]bool, len(supported))
+	for _, field := range supported {
+		s[field] = true
+	}
+	return func(query cqr.CommonQueryRepresentation, path Path) (findings []Finding) {
+		kw, ok := query.(cqr.Keyword)
+		if !ok {
+			return nil
+		}
+		for _, field := range kw.Fields {
+			if !s[field] {
+				findings = append(findings, Finding{Rule: "unsupported-field", Severity: Error, Path: path, Message: fmt.Sprintf("field %q is not supported", field)})
+			}
+		}
+		return
+	}
+}
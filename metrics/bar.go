@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressBar is a reporter that draws a progress bar for the topics of a pipeline on a terminal, along with the
+// progress of any other stages, the mean latency of each topic, the cache hit ratio, and the number of backend
+// requests.
+type ProgressBar struct {
+	mu     sync.Mutex
+	w      io.Writer
+	width  int
+	stages []string
+	done   map[string]int
+	total  map[string]int
+
+	topics       int
+	topicLatency time.Duration
+	hits, misses int
+	requests     int
+	failures     int
+
+	drawn time.Time
+}
+
+// NewProgressBar creates a progress bar that is drawn to w (usually os.Stderr).
+func NewProgressBar(w io.Writer) *ProgressBar {
+	return &ProgressBar{
+		w:     w,
+		width: 30,
+		done:  make(map[string]int),
+		total: make(map[string]int),
+	}
+}
+
+// Progress updates the progress of a stage and redraws the bar.
+func (b *ProgressBar) Progress(stage string, done, total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.done[stage]; !ok {
+		b.stages = append(b.stages, stage)
+	}
+	b.done[stage] = done
+	b.total[stage] = total
+	// Only redraw at most ten times a second, unless a stage has completed.
+	if time.Since(b.drawn) > 100*time.Millisecond || done == total {
+		b.draw()
+	}
+}
+
+// Count does nothing; counters are not shown on the bar.
+func (b *ProgressBar) Count(stage, counter string, delta int) {}
+
+// Latency records the latency of topics.
+func (b *ProgressBar) Latency(stage, topic string, d time.Duration) {
+	if stage != "topic" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topics++
+	b.topicLatency += d
+}
+
+// Cache records a cache hit or miss.
+func (b *ProgressBar) Cache(name string, hit bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+}
+
+// Request records a backend request.
+func (b *ProgressBar) Request(backend string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	if err != nil {
+		b.failures++
+	}
+}
+
+// Finish draws the bar a final time and moves to a new line.
+func (b *ProgressBar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draw()
+	fmt.Fprintln(b.w)
+}
+
+func (b *ProgressBar) draw() {
+	b.drawn = time.Now()
+	var s strings.Builder
+	s.WriteString("\r")
+	for i, stage := range b.stages {
+		done, total := b.done[stage], b.total[stage]
+		if i > 0 {
+			s.WriteString(" | ")
+		}
+		if i == 0 && total > 0 {
+			n := b.width * done / total
+			if n > b.width {
+				n = b.width
+			}
+			fmt.Fprintf(&s, "[%s%s] ", strings.Repeat("=", n), strings.Repeat(" ", b.width-n))
+		}
+		if total > 0 {
+			fmt.Fprintf(&s, "%s %d/%d", stage, done, total)
+		} else {
+			fmt.Fprintf(&s, "%s %d", stage, done)
+		}
+	}
+	if b.topics > 0 {
+		fmt.Fprintf(&s, " | %.1fs/topic", (b.topicLatency / time.Duration(b.topics)).Seconds())
+	}
+	if b.hits+b.misses > 0 {
+		fmt.Fprintf(&s, " | cache %.0f%%", 100*float64(b.hits)/float64(b.hits+b.misses))
+	}
+	if b.requests > 0 {
+		fmt.Fprintf(&s, " | %d requests", b.requests)
+		if b.failures > 0 {
+			fmt.Fprintf(&s, " (%d failed)", b.failures)
+		}
+	}
+	// Clear anything left over from a longer line.
+	s.WriteString("\033[K")
+	_, _ = io.WriteString(b.w, s.String())
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONLines is a reporter that writes each report as a line of JSON, e.g.:
+//
+//	{"time":"2019-04-01T12:00:00Z","type":"progress","stage":"topics","done":3,"total":30}
+//	{"time":"2019-04-01T12:00:00Z","type":"latency","stage":"topic","topic":"CD008122","seconds":12.5}
+type JSONLines struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONLines creates a reporter that writes to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{enc: json.NewEncoder(w)}
+}
+
+func (j *JSONLines) write(typ string, fields map[string]interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fields["time"] = time.Now().UTC()
+	fields["type"] = typ
+	if err := j.enc.Encode(fields); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// Err is the first error that occurred writing reports.
+func (j *JSONLines) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Progress writes a progress report.
+func (j *JSONLines) Progress(stage string, done, total int) {
+	j.write("progress", map[string]interface{}{"stage": stage, "done": done, "total": total})
+}
+
+// Count writes a change to a counter.
+func (j *JSONLines) Count(stage, counter string, delta int) {
+	j.write("count", map[string]interface{}{"stage": stage, "counter": counter, "delta": delta})
+}
+
+// Latency writes a latency.
+func (j *JSONLines) Latency(stage, topic string, d time.Duration) {
+	fields := map[string]interface{}{"stage": stage, "seconds": d.Seconds()}
+	if len(topic) > 0 {
+		fields["topic"] = topic
+	}
+	j.write("latency", fields)
+}
+
+// Cache writes a cache hit or miss.
+func (j *JSONLines) Cache(name string, hit bool) {
+	j.write("cache", map[string]interface{}{"cache": name, "hit": hit})
+}
+
+// Request writes a request made to a backend.
+func (j *JSONLines) Request(backend string, err error) {
+	fields := map[string]interface{}{"backend": backend}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	j.write("request", fields)
+}
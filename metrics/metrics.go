@@ -0,0 +1,116 @@
+// Package metrics provides reporting of the progress and metrics of groove components, such as the number of topics
+// completed, the latency of each topic, cache hit ratios, and the number of requests made to backends.
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Reporter receives progress and metrics from groove components.
+type Reporter interface {
+	// Progress reports that done of total items of a stage have been completed. The total is zero if it is not known.
+	Progress(stage string, done, total int)
+	// Count adds delta to a named counter of a stage (e.g. the number of errors).
+	Count(stage, counter string, delta int)
+	// Latency records the time taken for a topic in a stage. The topic is empty for stages not specific to a topic.
+	Latency(stage, topic string, d time.Duration)
+	// Cache records a hit or miss of a cache.
+	Cache(name string, hit bool)
+	// Request records a request made to a backend (e.g. "entrez.esearch") and the error of the request, if any.
+	Request(backend string, err error)
+}
+
+var (
+	mu        sync.RWMutex
+	reporters []Reporter
+)
+
+// Register adds reporters which receive the progress and metrics of all groove components.
+func Register(r ...Reporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	reporters = append(reporters, r...)
+}
+
+// Reset removes all of the registered reporters.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	reporters = nil
+}
+
+// Default is a reporter that reports to each of the registered reporters.
+var Default Reporter = registered{}
+
+type registered struct{}
+
+func (registered) Progress(stage string, done, total int) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reporters {
+		r.Progress(stage, done, total)
+	}
+}
+
+func (registered) Count(stage, counter string, delta int) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reporters {
+		r.Count(stage, counter, delta)
+	}
+}
+
+func (registered) Latency(stage, topic string, d time.Duration) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reporters {
+		r.Latency(stage, topic, d)
+	}
+}
+
+func (registered) Cache(name string, hit bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reporters {
+		r.Cache(name, hit)
+	}
+}
+
+func (registered) Request(backend string, err error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reporters {
+		r.Request(backend, err)
+	}
+}
+
+// Progress reports progress to the registered reporters.
+func Progress(stage string, done, total int) {
+	Default.Progress(stage, done, total)
+}
+
+// Count adds to a counter of the registered reporters.
+func Count(stage, counter string, delta int) {
+	Default.Count(stage, counter, delta)
+}
+
+// Latency reports a latency to the registered reporters.
+func Latency(stage, topic string, d time.Duration) {
+	Default.Latency(stage, topic, d)
+}
+
+// Since reports the time since start as a latency to the registered reporters.
+func Since(stage, topic string, start time.Time) {
+	Default.Latency(stage, topic, time.Since(start))
+}
+
+// Cache reports a cache hit or miss to the registered reporters.
+func Cache(name string, hit bool) {
+	Default.Cache(name, hit)
+}
+
+// Request reports a request made to a backend to the registered reporters.
+func Request(backend string, err error) {
+	Default.Request(backend, err)
+}
@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/hscells/groove/metrics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := metrics.NewPrometheus()
+	metrics.Register(p)
+	defer metrics.Reset()
+
+	metrics.Progress("topics", 1, 2)
+	metrics.Cache("statistics", true)
+	metrics.Cache("statistics", false)
+	metrics.Cache("statistics", true)
+	metrics.Request("entrez.esearch", nil)
+	metrics.Request("entrez.esearch", errors.New("timeout"))
+	metrics.Latency("topic", "CD008122", 2*time.Second)
+
+	var b bytes.Buffer
+	err := p.Write(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`groove_progress_done{stage="topics"} 1`,
+		`groove_progress_total{stage="topics"} 2`,
+		`groove_cache_requests_total{cache="statistics",result="hit"} 2`,
+		`groove_cache_requests_total{cache="statistics",result="miss"} 1`,
+		`groove_backend_requests_total{backend="entrez.esearch",status="error"} 1`,
+		`groove_latency_seconds_sum{stage="topic"} 2`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %s in:\n%s", want, b.String())
+		}
+	}
+}
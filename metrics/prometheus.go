@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prometheus is a reporter that exposes metrics in the Prometheus text exposition format. It can be served over HTTP
+// (see ServePrometheus) so a local Prometheus server can scrape the progress of long running experiments.
+type Prometheus struct {
+	mu       sync.Mutex
+	progress map[string][2]int
+	counts   map[[2]string]int
+	latency  map[string]*latency
+	cache    map[[2]string]int
+	requests map[[2]string]int
+}
+
+type latency struct {
+	count int
+	sum   float64
+}
+
+// NewPrometheus creates a reporter that collects metrics for Prometheus.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		progress: make(map[string][2]int),
+		counts:   make(map[[2]string]int),
+		latency:  make(map[string]*latency),
+		cache:    make(map[[2]string]int),
+		requests: make(map[[2]string]int),
+	}
+}
+
+// ServePrometheus creates a Prometheus reporter and serves its metrics at /metrics on an address (e.g.
+// "localhost:9090"). The server runs until the program exits.
+func ServePrometheus(addr string) (*Prometheus, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPrometheus()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p)
+	go http.Serve(l, mux)
+	return p, nil
+}
+
+// Progress sets the progress of a stage.
+func (p *Prometheus) Progress(stage string, done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress[stage] = [2]int{done, total}
+}
+
+// Count adds to a counter of a stage.
+func (p *Prometheus) Count(stage, counter string, delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[[2]string{stage, counter}] += delta
+}
+
+// Latency adds to the latency of a stage. Latencies are not labelled by topic.
+func (p *Prometheus) Latency(stage, topic string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.latency[stage]
+	if !ok {
+		l = &latency{}
+		p.latency[stage] = l
+	}
+	l.count++
+	l.sum += d.Seconds()
+}
+
+// Cache counts a cache hit or miss.
+func (p *Prometheus) Cache(name string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache[[2]string{name, result}]++
+}
+
+// Request counts a request made to a backend.
+func (p *Prometheus) Request(backend string, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[[2]string{backend, status}]++
+}
+
+// ServeHTTP writes the metrics.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = p.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text exposition format.
+func (p *Prometheus) Write(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var lines []string
+	metric := func(name, help, typ string, samples []string) {
+		if len(samples) == 0 {
+			return
+		}
+		sort.Strings(samples)
+		lines = append(lines, fmt.Sprintf("# HELP %s %s", name, help), fmt.Sprintf("# TYPE %s %s", name, typ))
+		lines = append(lines, samples...)
+	}
+
+	var done, total []string
+	for stage, v := range p.progress {
+		done = append(done, fmt.Sprintf("groove_progress_done{stage=%s} %d", quote(stage), v[0]))
+		total = append(total, fmt.Sprintf("groove_progress_total{stage=%s} %d", quote(stage), v[1]))
+	}
+	metric("groove_progress_done", "Number of items of a stage that have been completed.", "gauge", done)
+	metric("groove_progress_total", "Number of items of a stage (zero if unknown).", "gauge", total)
+
+	var counts []string
+	for k, v := range p.counts {
+		counts = append(counts, fmt.Sprintf("groove_stage_count_total{stage=%s,counter=%s} %d", quote(k[0]), quote(k[1]), v))
+	}
+	metric("groove_stage_count_total", "Counters of pipeline stages.", "counter", counts)
+
+	var latencies []string
+	for stage, l := range p.latency {
+		latencies = append(latencies,
+			fmt.Sprintf("groove_latency_seconds_count{stage=%s} %d", quote(stage), l.count),
+			fmt.Sprintf("groove_latency_seconds_sum{stage=%s} %g", quote(stage), l.sum))
+	}
+	metric("groove_latency_seconds", "Time taken by each stage.", "summary", latencies)
+
+	var cache []string
+	for k, v := range p.cache {
+		cache = append(cache, fmt.Sprintf("groove_cache_requests_total{cache=%s,result=%s} %d", quote(k[0]), quote(k[1]), v))
+	}
+	metric("groove_cache_requests_total", "Cache lookups by result (hit or miss).", "counter", cache)
+
+	var requests []string
+	for k, v := range p.requests {
+		requests = append(requests, fmt.Sprintf("groove_backend_requests_total{backend=%s,status=%s} %d", quote(k[0]), quote(k[1]), v))
+	}
+	metric("groove_backend_requests_total", "Requests made to backends by status (ok or error).", "counter", requests)
+
+	if len(lines) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// quote quotes a label value.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
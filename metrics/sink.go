@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/hscells/groove/pipeline"
+)
+
+// Sink reports the events of a groove pipeline as progress and metrics.
+type Sink struct {
+	r           Reporter
+	done, total int
+}
+
+// NewSink creates a pipeline sink that reports to r.
+func NewSink(r Reporter) *Sink {
+	return &Sink{r: r}
+}
+
+// Handle reports the event.
+func (s *Sink) Handle(e pipeline.Event) error {
+	switch v := e.(type) {
+	case pipeline.StartEvent:
+		s.total = v.Topics
+		s.r.Progress("topics", s.done, s.total)
+	case pipeline.TransformationEvent:
+		s.r.Latency("transformation", v.Query.Topic, v.Elapsed)
+	case pipeline.MeasurementEvent:
+		s.r.Latency("measurement", v.Query.Topic, v.Elapsed)
+	case pipeline.ResultsEvent:
+		s.r.Latency("retrieval", v.Query.Topic, v.Elapsed)
+		s.r.Count("retrieval", "results", len(v.Results))
+	case pipeline.EvaluationEvent:
+		s.r.Count("evaluation", "topics", 1)
+	case pipeline.TopicEvent:
+		s.done++
+		if v.Skipped {
+			s.r.Count("topics", "skipped", 1)
+		} else {
+			s.r.Latency("topic", v.Query.Topic, v.Elapsed)
+		}
+		s.r.Progress("topics", s.done, s.total)
+	case pipeline.ErrorEvent:
+		s.r.Count("topics", "errors", 1)
+	case pipeline.DoneEvent:
+		s.r.Latency("pipeline", "", v.Elapsed)
+	}
+	return nil
+}
+
+// Close does nothing.
+func (s *Sink) Close() error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"io"
+	"math"
+)
+
+// ArrowMeasurementWriter writes results as an Arrow IPC file, which can be loaded directly into data frames (e.g.
+// using pyarrow or pandas). The file contains a single record batch with a `topic` column and a (nullable) float
+// column for each measurement; missing values (NaN) are written as nulls.
+func ArrowMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	fields := make([]arrow.Field, len(headers)+1)
+	fields[0] = arrow.Field{Name: "topic", Type: arrow.BinaryTypes.String}
+	for i, header := range headers {
+		fields[i+1] = arrow.Field{Name: header, Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	mem := memory.NewGoAllocator()
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+
+	b.Field(0).(*array.StringBuilder).AppendValues(topics, nil)
+	for i := range headers {
+		fb := b.Field(i + 1).(*array.Float64Builder)
+		for _, v := range data[i] {
+			if math.IsNaN(v) {
+				fb.AppendNull()
+			} else {
+				fb.Append(v)
+			}
+		}
+	}
+
+	record := b.NewRecord()
+	defer record.Release()
+
+	fw, err := ipc.NewFileWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		return err
+	}
+	err = fw.Write(record)
+	if err != nil {
+		fw.Close()
+		return err
+	}
+	return fw.Close()
+}
+
+// ArrowEvaluationWriter writes evaluation results as an Arrow IPC file.
+var ArrowEvaluationWriter = EvaluationWriterFor(ArrowMeasurementWriter)
@@ -2,17 +2,106 @@
 package output
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"math"
+	"sort"
 )
 
 // EvaluationFormatter is used in the a groove pipeline to output evaluation results.
 type EvaluationFormatter func(map[string]map[string]float64) (string, error)
 
-// JsonMeasurementFormatter outputs results in a JSON format.
+// EvaluationWriter is like an EvaluationFormatter, however it streams the formatted evaluation results to a writer
+// rather than building a string. Results are keyed by topic and then by measure.
+type EvaluationWriter func(w io.Writer, results map[string]map[string]float64) error
+
+// EvaluationFormatterFor adapts an evaluation writer into a formatter for use in a pipeline.
+func EvaluationFormatterFor(writer EvaluationWriter) EvaluationFormatter {
+	return func(results map[string]map[string]float64) (string, error) {
+		var b bytes.Buffer
+		err := writer(&b, results)
+		if err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+}
+
+// EvaluationWriterFor adapts a measurement writer into an evaluation writer. Topics and measures are sorted, and
+// measures missing for a topic are NaN (which measurement writers treat as missing values).
+func EvaluationWriterFor(writer MeasurementWriter) EvaluationWriter {
+	return func(w io.Writer, results map[string]map[string]float64) error {
+		topics, measures, data := evaluationTable(results)
+		return writer(w, topics, measures, data)
+	}
+}
+
+// evaluationTable converts evaluation results into the layout used for measurements.
+func evaluationTable(results map[string]map[string]float64) (topics, measures []string, data [][]float64) {
+	seen := make(map[string]bool)
+	for topic, evals := range results {
+		topics = append(topics, topic)
+		for measure := range evals {
+			if !seen[measure] {
+				seen[measure] = true
+				measures = append(measures, measure)
+			}
+		}
+	}
+	sort.Strings(topics)
+	sort.Strings(measures)
+
+	data = make([][]float64, len(measures))
+	for i, measure := range measures {
+		data[i] = make([]float64, len(topics))
+		for j, topic := range topics {
+			v, ok := results[topic][measure]
+			if !ok {
+				v = math.NaN()
+			}
+			data[i][j] = v
+		}
+	}
+	return
+}
+
+// JsonEvaluationFormatter outputs results in a JSON format.
 func JsonEvaluationFormatter(results map[string]map[string]float64) (string, error) {
-	v, err := json.MarshalIndent(results, "", "    ")
+	return EvaluationFormatterFor(JsonEvaluationWriter)(results)
+}
+
+// JsonEvaluationWriter writes results in a JSON format. Values that are not a number or are infinite (which JSON
+// cannot represent) are written as null.
+func JsonEvaluationWriter(w io.Writer, results map[string]map[string]float64) error {
+	m := make(map[string]map[string]jsonFloat, len(results))
+	for topic, evals := range results {
+		m[topic] = jsonFloats(evals)
+	}
+	v, err := json.MarshalIndent(m, "", "    ")
 	if err != nil {
-		return "", err
+		return err
+	}
+	_, err = w.Write(v)
+	return err
+}
+
+// jsonFloat is a float that is encoded as null when it is not a number or is infinite.
+type jsonFloat float64
+
+// MarshalJSON encodes the float, or null when JSON is unable to represent it.
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+// jsonFloats converts values keyed by name for encoding as JSON.
+func jsonFloats(values map[string]float64) map[string]jsonFloat {
+	m := make(map[string]jsonFloat, len(values))
+	for k, v := range values {
+		m[k] = jsonFloat(v)
 	}
-	return string(v), nil
+	return m
 }
@@ -0,0 +1,55 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hscells/groove/output"
+	"math"
+	"testing"
+)
+
+func TestJsonEvaluationWriter(t *testing.T) {
+	results := map[string]map[string]float64{
+		"1": {"AP": 0.5, "NDCG": math.NaN()},
+		"2": {"AP": math.Inf(1), "NDCG": 0},
+	}
+	var b bytes.Buffer
+	err := output.JsonEvaluationWriter(&b, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]map[string]*float64
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := decoded["1"]["AP"]; v == nil || *v != 0.5 {
+		t.Errorf("expected numbers to be written, got %v", v)
+	}
+	if v := decoded["2"]["NDCG"]; v == nil || *v != 0 {
+		t.Errorf("expected zero to be written, got %v", v)
+	}
+	if decoded["1"]["NDCG"] != nil || decoded["2"]["AP"] != nil {
+		t.Errorf("expected NaN and infinite values to be written as null, got %s", b.String())
+	}
+}
+
+func TestJsonMeasurementWriter(t *testing.T) {
+	var b bytes.Buffer
+	err := output.JsonMeasurementWriter(&b, []string{"1", "2"}, []string{"RetrievalSize"}, [][]float64{{10, math.NaN()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]map[string]*float64
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := decoded["1"]["RetrievalSize"]; v == nil || *v != 10 {
+		t.Errorf("expected numbers to be written, got %v", v)
+	}
+	if v, ok := decoded["2"]["RetrievalSize"]; !ok || v != nil {
+		t.Errorf("expected a missing measurement to be written as null, got %s", b.String())
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"github.com/hscells/groove/pipeline"
+	"io"
 	"strconv"
 )
 
@@ -12,38 +14,102 @@ import (
 // used directly since there are some assumptions made about the inputs; for instance, the length of each argument.
 type MeasurementFormatter func(topics, headers []string, data [][]float64) (string, error)
 
+// MeasurementWriter is like a MeasurementFormatter, however it streams the formatted measurements to a writer rather
+// than building a string. Data is indexed by header and then by topic.
+type MeasurementWriter func(w io.Writer, topics, headers []string, data [][]float64) error
+
+// QueryMeasurementFormatter is like a MeasurementFormatter, however it receives the queries that were measured rather
+// than only their topics, so that the metadata of each query can be included in the output.
+type QueryMeasurementFormatter func(queries []pipeline.Query, headers []string, data [][]float64) (string, error)
+
+// MeasurementFormatterFor adapts a measurement writer into a formatter for use in a pipeline.
+func MeasurementFormatterFor(writer MeasurementWriter) MeasurementFormatter {
+	return func(topics, headers []string, data [][]float64) (string, error) {
+		var b bytes.Buffer
+		err := writer(&b, topics, headers, data)
+		if err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+}
+
 // JsonMeasurementFormatter outputs results in a JSON format.
 func JsonMeasurementFormatter(topics, headers []string, data [][]float64) (string, error) {
-	m := map[string]map[string]float64{}
+	return MeasurementFormatterFor(JsonMeasurementWriter)(topics, headers, data)
+}
+
+// JsonMeasurementWriter writes results in a JSON format. Missing values (NaN) and infinite values are written as null.
+func JsonMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	m := map[string]map[string]jsonFloat{}
 	for j, topic := range topics {
-		m[topic] = map[string]float64{}
+		m[topic] = map[string]jsonFloat{}
 		for i, header := range headers {
-			m[topic][header] = data[i][j]
+			m[topic][header] = jsonFloat(data[i][j])
 		}
 	}
 
 	v, err := json.MarshalIndent(m, "", "    ")
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(v), nil
+	_, err = w.Write(v)
+	return err
 }
 
 // CsvMeasurementFormatter outputs results in CSV format.
 func CsvMeasurementFormatter(topics, headers []string, data [][]float64) (string, error) {
-	b := bytes.NewBufferString("")
-	w := csv.NewWriter(b)
+	return MeasurementFormatterFor(CsvMeasurementWriter)(topics, headers, data)
+}
+
+// CsvMeasurementWriter writes results in CSV format. When there are no measurements, only the header is written.
+func CsvMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	cw := csv.NewWriter(w)
 	h := []string{"Topic"}
 	h = append(h, headers...)
-	w.Write(h)
-	for j := range data[0] {
+	err := cw.Write(h)
+	if err != nil {
+		return err
+	}
+	for j, topic := range topics {
 		record := make([]string, len(data)+1)
-		record[0] = topics[j]
+		record[0] = topic
 		for i := range data {
 			record[i+1] = strconv.FormatFloat(data[i][j], 'f', -1, 64)
 		}
-		w.Write(record)
+		err := cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// JsonQueryMeasurementFormatter outputs results in a JSON format, including the metadata of each query.
+func JsonQueryMeasurementFormatter(queries []pipeline.Query, headers []string, data [][]float64) (string, error) {
+	type queryMeasurements struct {
+		Name         string               `json:"name"`
+		Metadata     pipeline.Metadata    `json:"metadata"`
+		Measurements map[string]jsonFloat `json:"measurements"`
 	}
-	w.Flush()
-	return b.String(), nil
+
+	m := map[string]queryMeasurements{}
+	for j, q := range queries {
+		qm := queryMeasurements{
+			Name:         q.Name,
+			Metadata:     q.Metadata,
+			Measurements: map[string]jsonFloat{},
+		}
+		for i, header := range headers {
+			qm.Measurements[header] = jsonFloat(data[i][j])
+		}
+		m[q.Topic] = qm
+	}
+
+	v, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
 }
@@ -0,0 +1,294 @@
+package output
+
+import (
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/transmute/backend"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+)
+
+// accumulator collects the measurements and evaluations of a pipeline so they can be formatted once the pipeline is
+// done.
+type accumulator struct {
+	headers      []string
+	queries      []pipeline.Query
+	measurements []map[string]float64
+	evaluations  map[string]map[string]float64
+}
+
+func (a *accumulator) handle(e pipeline.Event) {
+	switch v := e.(type) {
+	case pipeline.StartEvent:
+		a.headers = v.Measurements
+	case pipeline.MeasurementEvent:
+		a.queries = append(a.queries, v.Query)
+		a.measurements = append(a.measurements, v.Measurements)
+	case pipeline.EvaluationEvent:
+		if a.evaluations == nil {
+			a.evaluations = make(map[string]map[string]float64)
+		}
+		a.evaluations[v.Query.Topic] = v.Evaluation
+	}
+}
+
+// table arranges the measurements in the layout expected by measurement formatters.
+func (a *accumulator) table() (topics []string, data [][]float64) {
+	topics = make([]string, len(a.queries))
+	for j, q := range a.queries {
+		topics[j] = q.Topic
+	}
+	data = make([][]float64, len(a.headers))
+	for i, header := range a.headers {
+		data[i] = make([]float64, len(a.queries))
+		for j, m := range a.measurements {
+			v, ok := m[header]
+			if !ok {
+				v = math.NaN()
+			}
+			data[i][j] = v
+		}
+	}
+	return
+}
+
+// FormatterSink formats the measurements and evaluations of a pipeline into strings once the pipeline is done. The
+// formatted strings are emitted as pipeline results with the Measurement and Evaluation types, and errors formatting
+// them are emitted as results with the Error type.
+type FormatterSink struct {
+	accumulator
+	measurementFormatters []MeasurementFormatter
+	queryFormatters       []QueryMeasurementFormatter
+	evaluationFormatters  []EvaluationFormatter
+	emit                  func(r pipeline.Result) error
+}
+
+// FormatMeasurements adds measurement formatters to the sink.
+func FormatMeasurements(formatters ...MeasurementFormatter) func(*FormatterSink) {
+	return func(s *FormatterSink) {
+		s.measurementFormatters = append(s.measurementFormatters, formatters...)
+	}
+}
+
+// FormatQueryMeasurements adds query measurement formatters to the sink.
+func FormatQueryMeasurements(formatters ...QueryMeasurementFormatter) func(*FormatterSink) {
+	return func(s *FormatterSink) {
+		s.queryFormatters = append(s.queryFormatters, formatters...)
+	}
+}
+
+// FormatEvaluations adds evaluation formatters to the sink.
+func FormatEvaluations(formatters ...EvaluationFormatter) func(*FormatterSink) {
+	return func(s *FormatterSink) {
+		s.evaluationFormatters = append(s.evaluationFormatters, formatters...)
+	}
+}
+
+// NewFormatterSink creates a sink that formats results and passes them to emit.
+func NewFormatterSink(emit func(r pipeline.Result) error, options ...func(*FormatterSink)) *FormatterSink {
+	s := &FormatterSink{emit: emit}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Handle collects the measurements and evaluations, and formats them when the pipeline is done.
+func (s *FormatterSink) Handle(e pipeline.Event) error {
+	s.handle(e)
+	if _, ok := e.(pipeline.DoneEvent); !ok {
+		return nil
+	}
+	err := s.format()
+	if err != nil {
+		_ = s.emit(pipeline.Result{
+			Error: err,
+			Type:  pipeline.Error,
+		})
+	}
+	return err
+}
+
+// format formats the measurements and evaluations that have been collected.
+func (s *FormatterSink) format() error {
+	if len(s.measurementFormatters) > 0 || len(s.queryFormatters) > 0 {
+		topics, data := s.table()
+		var outputs []string
+		for _, formatter := range s.measurementFormatters {
+			o, err := formatter(topics, s.headers, data)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, o)
+		}
+		for _, formatter := range s.queryFormatters {
+			o, err := formatter(s.queries, s.headers, data)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, o)
+		}
+		err := s.emit(pipeline.Result{
+			Measurements: outputs,
+			Type:         pipeline.Measurement,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(s.evaluationFormatters) > 0 {
+		evaluations := make([]string, len(s.evaluationFormatters))
+		for i, formatter := range s.evaluationFormatters {
+			o, err := formatter(s.evaluations)
+			if err != nil {
+				return err
+			}
+			evaluations[i] = o
+		}
+		return s.emit(pipeline.Result{
+			Evaluations: evaluations,
+			Type:        pipeline.Evaluation,
+		})
+	}
+	return nil
+}
+
+// Close does nothing.
+func (s *FormatterSink) Close() error {
+	return nil
+}
+
+// FileSink writes the outputs of a pipeline to files. Results are written to a run as each topic completes, the
+// transformed queries are written to a directory (one file per topic), and measurements and evaluations are written
+// once the pipeline is done.
+type FileSink struct {
+	accumulator
+	runFile          string
+	runOptions       []func(*TrecWriter)
+	run              *TrecWriter
+	measurementFiles map[string]MeasurementWriter
+	evaluationFiles  map[string]EvaluationWriter
+	transformations  string
+}
+
+// RunFile writes the results of the pipeline to a run file. Existing runs are appended to, so that a pipeline
+// that was interrupted can be resumed.
+func RunFile(file string, options ...func(*TrecWriter)) func(*FileSink) {
+	return func(s *FileSink) {
+		s.runFile = file
+		s.runOptions = append([]func(*TrecWriter){AppendRun()}, options...)
+	}
+}
+
+// MeasurementsFile writes the measurements of the pipeline to a file.
+func MeasurementsFile(file string, writer MeasurementWriter) func(*FileSink) {
+	return func(s *FileSink) {
+		s.measurementFiles[file] = writer
+	}
+}
+
+// EvaluationsFile writes the evaluations of the pipeline to a file.
+func EvaluationsFile(file string, writer EvaluationWriter) func(*FileSink) {
+	return func(s *FileSink) {
+		s.evaluationFiles[file] = writer
+	}
+}
+
+// TransformationsDir writes the transformed queries of the pipeline to a directory.
+func TransformationsDir(dir string) func(*FileSink) {
+	return func(s *FileSink) {
+		s.transformations = dir
+	}
+}
+
+// NewFileSink creates a sink that writes the outputs of a pipeline to files.
+func NewFileSink(options ...func(*FileSink)) *FileSink {
+	s := &FileSink{
+		measurementFiles: make(map[string]MeasurementWriter),
+		evaluationFiles:  make(map[string]EvaluationWriter),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Handle writes the outputs of the event to the configured files. Results that are not a valid run are reported as
+// a RunError, and are not written.
+func (s *FileSink) Handle(e pipeline.Event) error {
+	s.handle(e)
+	switch v := e.(type) {
+	case pipeline.ResultsEvent:
+		if len(s.runFile) == 0 {
+			return nil
+		}
+		if s.run == nil {
+			var err error
+			s.run, err = CreateTrecWriter(s.runFile, s.runOptions...)
+			if err != nil {
+				return err
+			}
+		}
+		err := s.run.Write(v.Results)
+		if err != nil {
+			return err
+		}
+		// Results are flushed as they arrive so that an interrupted pipeline can be resumed.
+		return s.run.Flush()
+	case pipeline.TransformationEvent:
+		if len(s.transformations) == 0 {
+			return nil
+		}
+		q, err := backend.NewCQRQuery(v.Query.Query).String()
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(s.transformations, 0777)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path.Join(s.transformations, v.Query.Topic), []byte(q), 0664)
+	case pipeline.DoneEvent:
+		topics, data := s.table()
+		for file, writer := range s.measurementFiles {
+			err := writeFile(file, func(f *os.File) error {
+				return writer(f, topics, s.headers, data)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for file, writer := range s.evaluationFiles {
+			err := writeFile(file, func(f *os.File) error {
+				return writer(f, s.evaluations)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the run file.
+func (s *FileSink) Close() error {
+	if s.run != nil {
+		return s.run.Close()
+	}
+	return nil
+}
+
+// writeFile creates (or truncates) a file and writes to it.
+func writeFile(file string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
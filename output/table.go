@@ -0,0 +1,125 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// table describes how the rows of a table are written in a markup language.
+type table struct {
+	begin  func(columns int) string
+	row    func(cells []string) string
+	rule   string
+	end    string
+	escape func(s string) string
+	pm     string
+}
+
+var (
+	markdown = table{
+		begin: func(columns int) string { return "" },
+		row: func(cells []string) string {
+			return "| " + strings.Join(cells, " | ") + " |\n"
+		},
+		escape: strings.NewReplacer("|", `\|`).Replace,
+		pm:     "±",
+	}
+	latex = table{
+		begin: func(columns int) string {
+			return fmt.Sprintf("\\begin{tabular}{l%s}\n\\toprule\n", strings.Repeat("r", columns-1))
+		},
+		row: func(cells []string) string {
+			return strings.Join(cells, " & ") + " \\\\\n"
+		},
+		rule: "\\midrule\n",
+		end:  "\\bottomrule\n\\end{tabular}\n",
+		escape: strings.NewReplacer(
+			`\`, `\textbackslash{}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+			"{", `\{`, "}", `\}`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`).Replace,
+		pm: "$\\pm$",
+	}
+)
+
+// write writes a table with a row per topic and a final row containing the mean and standard deviation of each
+// column. Missing values (NaN) are written as a dash and are excluded from the summary.
+func (t table) write(w io.Writer, topics, headers []string, data [][]float64) error {
+	var b strings.Builder
+	b.WriteString(t.begin(len(headers) + 1))
+
+	cells := make([]string, len(headers)+1)
+	cells[0] = "Topic"
+	for i, header := range headers {
+		cells[i+1] = t.escape(header)
+	}
+	b.WriteString(t.row(cells))
+	if len(t.rule) > 0 {
+		b.WriteString(t.rule)
+	} else {
+		for i := range cells {
+			cells[i] = "---"
+			if i > 0 {
+				cells[i] = "---:"
+			}
+		}
+		b.WriteString(t.row(cells))
+	}
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		return err
+	}
+
+	for j, topic := range topics {
+		cells[0] = t.escape(topic)
+		for i := range headers {
+			cells[i+1] = formatCell(data[i][j])
+		}
+		_, err := io.WriteString(w, t.row(cells))
+		if err != nil {
+			return err
+		}
+	}
+
+	b.Reset()
+	b.WriteString(t.rule)
+	cells[0] = "Mean " + t.pm + " SD"
+	for i := range headers {
+		mean, sd, n := summarise(data[i])
+		if n == 0 {
+			cells[i+1] = "-"
+			continue
+		}
+		cells[i+1] = fmt.Sprintf("%s %s %s", formatCell(mean), t.pm, formatCell(sd))
+	}
+	b.WriteString(t.row(cells))
+	b.WriteString(t.end)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// formatCell formats a value in a table.
+func formatCell(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", v)
+}
+
+// MarkdownMeasurementWriter writes results as a Markdown table, with the mean and standard deviation of each
+// measurement in the last row.
+func MarkdownMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	return markdown.write(w, topics, headers, data)
+}
+
+// LatexMeasurementWriter writes results as a LaTeX table (using booktabs rules), with the mean and standard deviation
+// of each measurement in the last row.
+func LatexMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	return latex.write(w, topics, headers, data)
+}
+
+// MarkdownEvaluationWriter writes evaluation results as a Markdown table.
+var MarkdownEvaluationWriter = EvaluationWriterFor(MarkdownMeasurementWriter)
+
+// LatexEvaluationWriter writes evaluation results as a LaTeX table.
+var LatexEvaluationWriter = EvaluationWriterFor(LatexMeasurementWriter)
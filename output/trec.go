@@ -1,9 +1,335 @@
 package output
 
-import "github.com/hscells/trecresults"
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/hscells/trecresults"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
 
 // TrecResults represents the output format for trec results.
 type TrecResults struct {
 	Path    string
 	Results trecresults.ResultList
 }
+
+// RunError describes why a result list is not a valid TREC run.
+type RunError struct {
+	Topic  string
+	DocID  string
+	Reason string
+}
+
+func (e RunError) Error() string {
+	if len(e.DocID) > 0 {
+		return fmt.Sprintf("invalid run for topic %s (document %s): %s", e.Topic, e.DocID, e.Reason)
+	}
+	return fmt.Sprintf("invalid run for topic %s: %s", e.Topic, e.Reason)
+}
+
+// TrecWriter writes result lists to a TREC run as they are produced (e.g. from the TrecResult results of a
+// pipeline). Each result list is sorted by rank and validated before it is written: a topic may only be written
+// once, each document may only appear once per topic, ranks must be strictly increasing, and every result must have
+// the same run name. Lists that are not ranked at all (e.g. the results of a Boolean query, where every rank is zero)
+// are ranked by their position in the list.
+type TrecWriter struct {
+	w          *bufio.Writer
+	closers    []io.Closer
+	gzip       bool
+	append     bool
+	sortTopics bool
+	runName    string
+	topics     map[string]bool
+	buffered   map[string]trecresults.ResultList
+}
+
+// GzipRun configures the writer to compress the run. Runs are always compressed when the path ends in `.gz`.
+func GzipRun() func(*TrecWriter) {
+	return func(t *TrecWriter) {
+		t.gzip = true
+	}
+}
+
+// AppendRun configures the writer to append to an existing run file rather than truncating it.
+func AppendRun() func(*TrecWriter) {
+	return func(t *TrecWriter) {
+		t.append = true
+	}
+}
+
+// SortRunTopics configures the writer to hold result lists in memory until it is closed, so that the topics in the
+// run are sorted. Otherwise, topics are written in the order they are received.
+func SortRunTopics() func(*TrecWriter) {
+	return func(t *TrecWriter) {
+		t.sortTopics = true
+	}
+}
+
+// RunName configures the run name every result must have.
+func RunName(name string) func(*TrecWriter) {
+	return func(t *TrecWriter) {
+		t.runName = name
+	}
+}
+
+// NewTrecWriter creates a writer that writes a run to w.
+func NewTrecWriter(w io.Writer, options ...func(*TrecWriter)) *TrecWriter {
+	t := &TrecWriter{
+		topics:   make(map[string]bool),
+		buffered: make(map[string]trecresults.ResultList),
+	}
+	for _, option := range options {
+		option(t)
+	}
+	if t.gzip {
+		gz := gzip.NewWriter(w)
+		t.closers = append(t.closers, gz)
+		w = gz
+	}
+	t.w = bufio.NewWriter(w)
+	return t
+}
+
+// CreateTrecWriter creates a writer that writes a run to a file.
+func CreateTrecWriter(path string, options ...func(*TrecWriter)) (*TrecWriter, error) {
+	var t TrecWriter
+	for _, option := range options {
+		option(&t)
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if t.append {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flag, 0664)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		options = append(options, GzipRun())
+	}
+	w := NewTrecWriter(f, options...)
+	w.closers = append(w.closers, f)
+	return w, nil
+}
+
+// Write validates a result list for a single topic and writes it to the run. Invalid lists are not written.
+func (t *TrecWriter) Write(list trecresults.ResultList) error {
+	if len(list) == 0 {
+		return nil
+	}
+	list = rankList(list)
+	topic := list[0].Topic
+	if t.topics[topic] {
+		return RunError{Topic: topic, Reason: "topic has already been written"}
+	}
+	if len(t.runName) == 0 {
+		t.runName = list[0].RunName
+	}
+	err := validateList(topic, t.runName, list)
+	if err != nil {
+		return err
+	}
+	t.topics[topic] = true
+
+	if t.sortTopics {
+		t.buffered[topic] = list
+		return nil
+	}
+	return t.writeList(list)
+}
+
+func (t *TrecWriter) writeList(list trecresults.ResultList) error {
+	for _, r := range list {
+		_, err := t.w.WriteString(r.String() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered results to the underlying writer. Topics held in memory to be sorted are not written
+// until the writer is closed.
+func (t *TrecWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// Close writes any remaining results and closes the run.
+func (t *TrecWriter) Close() error {
+	topics := make([]string, 0, len(t.buffered))
+	for topic := range t.buffered {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		err := t.writeList(t.buffered[topic])
+		if err != nil {
+			return err
+		}
+	}
+	t.buffered = make(map[string]trecresults.ResultList)
+
+	err := t.w.Flush()
+	for _, c := range t.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// WriteRun writes the result lists of a run to a file, sorted by topic and rank.
+func WriteRun(path string, results map[string]trecresults.ResultList, options ...func(*TrecWriter)) error {
+	w, err := CreateTrecWriter(path, append(options, SortRunTopics())...)
+	if err != nil {
+		return err
+	}
+	for _, topic := range sortedTopics(results) {
+		err := w.Write(results[topic])
+		if err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// OpenRun opens a run file for reading, decompressing it if it is gzipped.
+func OpenRun(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return readCloser{Reader: gz, closers: []io.Closer{gz, f}}, nil
+	}
+	return readCloser{Reader: r, closers: []io.Closer{f}}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ValidateRun checks a run before it is evaluated. Each result list is validated as it would be by a TrecWriter. If
+// any topics are provided (e.g. the topics of the qrels, see QrelsTopics), topics in the run that are not one of these
+// topics and topics that are missing from the run are also reported.
+func ValidateRun(results map[string]trecresults.ResultList, topics ...string) []error {
+	var errs []error
+
+	var runName string
+	for _, topic := range sortedTopics(results) {
+		list := results[topic]
+		if len(list) == 0 {
+			continue
+		}
+		if len(runName) == 0 {
+			runName = list[0].RunName
+		}
+		if err := validateList(topic, runName, rankList(list)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(topics) > 0 {
+		expected := make(map[string]bool)
+		for _, topic := range topics {
+			expected[topic] = true
+			if _, ok := results[topic]; !ok {
+				errs = append(errs, RunError{Topic: topic, Reason: "topic is missing from the run"})
+			}
+		}
+		for _, topic := range sortedTopics(results) {
+			if !expected[topic] {
+				errs = append(errs, RunError{Topic: topic, Reason: "topic is not in the list of topics"})
+			}
+		}
+	}
+	return errs
+}
+
+// QrelsTopics lists the topics of a qrels file.
+func QrelsTopics(qrels trecresults.QrelsFile) []string {
+	topics := make([]string, 0, len(qrels.Qrels))
+	for topic := range qrels.Qrels {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// validateList checks that a result list sorted by rank is a valid run for a topic.
+func validateList(topic, runName string, list trecresults.ResultList) error {
+	docs := make(map[string]bool, len(list))
+	for i, r := range list {
+		if r.Topic != topic {
+			return RunError{Topic: topic, DocID: r.DocId, Reason: fmt.Sprintf("result has a different topic %s", r.Topic)}
+		}
+		if r.RunName != runName {
+			return RunError{Topic: topic, DocID: r.DocId, Reason: fmt.Sprintf("run name %s does not match %s", r.RunName, runName)}
+		}
+		if docs[r.DocId] {
+			return RunError{Topic: topic, DocID: r.DocId, Reason: "document appears more than once"}
+		}
+		docs[r.DocId] = true
+		if i > 0 && r.Rank <= list[i-1].Rank {
+			return RunError{Topic: topic, DocID: r.DocId, Reason: fmt.Sprintf("rank %d is not greater than the previous rank", r.Rank)}
+		}
+	}
+	return nil
+}
+
+// rankList sorts a copy of a result list by rank. When no result in the list has been ranked, the results are instead
+// ranked by their position in the list.
+func rankList(list trecresults.ResultList) trecresults.ResultList {
+	sorted := make(trecresults.ResultList, len(list))
+	copy(sorted, list)
+	ranked := false
+	for _, r := range list {
+		if r.Rank != 0 {
+			ranked = true
+			break
+		}
+	}
+	if !ranked {
+		for i, r := range list {
+			result := *r
+			result.Rank = int64(i + 1)
+			sorted[i] = &result
+		}
+		return sorted
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Rank < sorted[j].Rank
+	})
+	return sorted
+}
+
+func sortedTopics(results map[string]trecresults.ResultList) []string {
+	topics := make([]string, 0, len(results))
+	for topic := range results {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
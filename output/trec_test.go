@@ -0,0 +1,59 @@
+package output_test
+
+import (
+	"github.com/hscells/groove/output"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/trecresults"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSinkUnrankedResults(t *testing.T) {
+	run := filepath.Join(t.TempDir(), "run")
+	s := output.NewFileSink(output.RunFile(run))
+
+	// Boolean retrieval (e.g. from Entrez) does not rank its results.
+	unranked := trecresults.ResultList{
+		{Topic: "1", Iteration: "0", DocId: "30", RunName: "boolean"},
+		{Topic: "1", Iteration: "0", DocId: "10", RunName: "boolean"},
+		{Topic: "1", Iteration: "0", DocId: "20", RunName: "boolean"},
+	}
+	err := s.Handle(pipeline.ResultsEvent{Query: pipeline.Query{Topic: "1"}, Results: unranked})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ranked lists are still validated.
+	ranked := trecresults.ResultList{
+		{Topic: "2", Iteration: "0", DocId: "10", Rank: 1, RunName: "boolean"},
+		{Topic: "2", Iteration: "0", DocId: "20", Rank: 1, RunName: "boolean"},
+	}
+	err = s.Handle(pipeline.ResultsEvent{Query: pipeline.Query{Topic: "2"}, Results: ranked})
+	if _, ok := err.(output.RunError); !ok {
+		t.Errorf("expected a run error for a list with duplicate ranks, got %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != len(unranked) {
+		t.Fatalf("expected only the unranked list to be written, got %q", b)
+	}
+	for i, line := range lines {
+		f := strings.Fields(line)
+		if f[2] != unranked[i].DocId || f[3] != []string{"1", "2", "3"}[i] {
+			t.Errorf("expected results to be ranked by their position in the list, got %q", line)
+		}
+	}
+	if unranked[0].Rank != 0 {
+		t.Errorf("expected the results of the event not to be modified")
+	}
+}
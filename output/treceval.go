@@ -0,0 +1,65 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// TrecEvalMeasurementWriter writes results in the format of `trec_eval -q`: one `measure topic value` line per
+// measure and topic, followed by a line per measure for the mean over all topics (with the topic `all`).
+func TrecEvalMeasurementWriter(w io.Writer, topics, headers []string, data [][]float64) error {
+	for j, topic := range topics {
+		for i, header := range headers {
+			if math.IsNaN(data[i][j]) {
+				continue
+			}
+			_, err := fmt.Fprintf(w, "%-22s\t%s\t%.4f\n", header, topic, data[i][j])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, header := range headers {
+		mean, _, n := summarise(data[i])
+		if n == 0 {
+			continue
+		}
+		_, err := fmt.Fprintf(w, "%-22s\t%s\t%.4f\n", header, "all", mean)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrecEvalEvaluationWriter writes evaluation results in the format of `trec_eval -q`.
+var TrecEvalEvaluationWriter = EvaluationWriterFor(TrecEvalMeasurementWriter)
+
+// TrecEvalEvaluationFormatter outputs evaluation results in the format of `trec_eval -q`.
+var TrecEvalEvaluationFormatter = EvaluationFormatterFor(TrecEvalEvaluationWriter)
+
+// summarise computes the mean and sample standard deviation of values, ignoring NaN values. The number of values
+// that were not NaN is also returned.
+func summarise(values []float64) (mean, sd float64, n int) {
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			mean += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN(), math.NaN(), 0
+	}
+	mean /= float64(n)
+	if n == 1 {
+		return mean, 0, n
+	}
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sd += (v - mean) * (v - mean)
+		}
+	}
+	sd = math.Sqrt(sd / float64(n-1))
+	return mean, sd, n
+}
@@ -3,20 +3,20 @@ package groove
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"github.com/hscells/groove/analysis"
 	"github.com/hscells/groove/combinator"
 	"github.com/hscells/groove/eval"
 	"github.com/hscells/groove/formulation"
 	"github.com/hscells/groove/learning"
+	"github.com/hscells/groove/metrics"
 	"github.com/hscells/groove/output"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/groove/preprocess"
 	"github.com/hscells/groove/query"
 	"github.com/hscells/groove/rank"
+	"github.com/hscells/groove/retrieval"
 	"github.com/hscells/groove/stats"
-	"github.com/hscells/headway"
 	"github.com/hscells/transmute"
 	"github.com/hscells/trecresults"
 	"github.com/peterbourgon/diskv"
@@ -24,8 +24,12 @@ import (
 	"log"
 	"os"
 	"path"
+	"reflect"
+	"runtime"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -33,12 +37,14 @@ import (
 type Pipeline struct {
 	QueryPath             string
 	PubDatesFile          string
+	DateRestrictions      preprocess.TopicDates
 	QueriesSource         query.QueriesSource
 	StatisticsSource      stats.StatisticsSource
 	Preprocess            []preprocess.QueryProcessor
 	Transformations       preprocess.QueryTransformations
 	Measurements          []analysis.Measurement
 	MeasurementFormatters []output.MeasurementFormatter
+	QueryFormatters       []output.QueryMeasurementFormatter
 	MeasurementExecutor   analysis.MeasurementExecutor
 	Evaluations           []eval.Evaluator
 	EvaluationFormatters  EvaluationOutputFormat
@@ -47,6 +53,8 @@ type Pipeline struct {
 	Model                 learning.Model
 	ModelConfiguration    ModelConfiguration
 	QueryFormulator       formulation.Formulator
+	StreamQueries         bool
+	ResultsHandlers       retrieval.Chain
 
 	CLF rank.CLFOptions
 }
@@ -92,6 +100,44 @@ func MeasurementOutput(formatter ...output.MeasurementFormatter) func() interfac
 	}
 }
 
+// QueryMeasurementOutput adds outputs that have access to the metadata of queries to the pipeline.
+func QueryMeasurementOutput(formatter ...output.QueryMeasurementFormatter) func() interface{} {
+	return func() interface{} {
+		return formatter
+	}
+}
+
+// ResultsHandlers adds handlers that are applied, in order, to the results of each query before they are evaluated
+// and output.
+func ResultsHandlers(handlers ...retrieval.ResultsHandler) func() interface{} {
+	return func() interface{} {
+		return retrieval.NewChain(handlers...)
+	}
+}
+
+// StreamQueries configures the pipeline to load, execute, and evaluate queries one at a time rather than loading
+// all of the queries up front. Queries are only streamed when nothing else in the pipeline requires all of the queries
+// (e.g. measurements or models).
+func StreamQueries() func() interface{} {
+	return func() interface{} {
+		return streamQueries(true)
+	}
+}
+
+// streamQueries is the type of the StreamQueries component.
+type streamQueries bool
+
+// DateRestrictions restricts the queries of the pipeline to the publication dates of their topic, loaded from a TSV,
+// CSV or JSON file (see preprocess.LoadTopicDates). The file is loaded when the pipeline is run.
+func DateRestrictions(file string) func() interface{} {
+	return func() interface{} {
+		return pubDatesFile(file)
+	}
+}
+
+// pubDatesFile is the type of the DateRestrictions component.
+type pubDatesFile string
+
 // TrecOutput configures trec output.
 func TrecOutput(path string) func() interface{} {
 	return func() interface{} {
@@ -119,6 +165,21 @@ func EvaluationOutput(qrels string, formatters ...output.EvaluationFormatter) fu
 	}
 }
 
+// SeedEvaluationOutput configures evaluation using the seed studies of topics, loaded from a file (see
+// eval.LoadSeeds), in place of qrels.
+func SeedEvaluationOutput(seeds string, formatters ...output.EvaluationFormatter) func() interface{} {
+	s, err := eval.LoadSeeds(seeds)
+	if err != nil {
+		panic(err)
+	}
+	return func() interface{} {
+		return EvaluationOutputFormat{
+			EvaluationQrels:      s.Qrels(),
+			EvaluationFormatters: formatters,
+		}
+	}
+}
+
 // NewGroovePipeline creates a new groove pipeline. The query source and statistics source are required. Additional
 // components are provided via the optional functional arguments.
 func NewGroovePipeline(qs query.QueriesSource, ss stats.StatisticsSource, components ...func() interface{}) Pipeline {
@@ -136,29 +197,302 @@ func NewGroovePipeline(qs query.QueriesSource, ss stats.StatisticsSource, compon
 			gp.Measurements = v
 		case []output.MeasurementFormatter:
 			gp.MeasurementFormatters = v
+		case []output.QueryMeasurementFormatter:
+			gp.QueryFormatters = v
 		case preprocess.QueryTransformations:
 			gp.Transformations = v
+		case retrieval.Chain:
+			gp.ResultsHandlers = v
+		case streamQueries:
+			gp.StreamQueries = bool(v)
+		case pubDatesFile:
+			gp.PubDatesFile = string(v)
+		case EvaluationOutputFormat:
+			gp.EvaluationFormatters = v
 		}
 	}
 
 	return gp
 }
 
-// Execute runs a groove pipeline for a particular directory of queries.
-//noinspection GoNilness
+// transform applies the preprocessing and transformations of the pipeline to a query, returning the transformed
+// query and the names of the preprocessors and transformations applied to it.
+func (p Pipeline) transform(q pipeline.Query) (pipeline.Query, []string) {
+	var applied []string
+	for _, p := range p.Preprocess {
+		q = q.WithQuery(preprocess.ProcessQuery(q.Query, p))
+		applied = append(applied, funcName(p))
+	}
+
+	// Apply any transformations.
+	for i, t := range p.Transformations.BooleanTransformations {
+		fmt.Println(q.Topic, i)
+		q = q.WithQuery(t(q.Query, q.Topic)())
+		applied = append(applied, funcName(t))
+	}
+	for _, t := range p.Transformations.MetadataTransformations {
+		q = q.WithQuery(t(q)())
+		applied = append(applied, funcName(t))
+	}
+	for _, t := range p.Transformations.ElasticsearchTransformations {
+		if s, ok := p.StatisticsSource.(*stats.ElasticsearchStatisticsSource); ok {
+			q = q.WithQuery(t(q.Query, s)())
+			applied = append(applied, funcName(t))
+		} else {
+			log.Fatal("Elasticsearch transformations only work with an Elasticsearch statistics source.")
+		}
+	}
+
+	// Date restrictions are applied last so that the publication date constraint is not transformed.
+	if p.DateRestrictions != nil {
+		q = q.WithQuery(p.DateRestrictions.Restrict(q.Query, q.Topic)())
+		applied = append(applied, funcName(p.DateRestrictions.Restrict))
+	}
+	return q, applied
+}
+
+// funcName is the name of a function, qualified by the name of its package rather than its path.
+func funcName(f interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	return strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], "-fm")
+}
+
+// streams reports if the queries of the pipeline can be streamed, i.e., nothing in the pipeline requires all of the
+// queries to be loaded at once.
+func (p Pipeline) streams() bool {
+	return p.StreamQueries &&
+		p.Model == nil &&
+		!p.CLF.CLF &&
+		len(p.Measurements) == 0 &&
+		p.executes()
+}
+
+// executes reports if the queries of the pipeline are executed, i.e., there are results to output or evaluate.
+func (p Pipeline) executes() bool {
+	return len(p.OutputTrec.Path) > 0 || len(p.Evaluations) > 0 || len(p.EvaluationFormatters.EvaluationFormatters) > 0
+}
+
+// completedTopics are the topics that already have results in the trec results output of the pipeline. These topics
+// do not need to be executed again.
+func (p Pipeline) completedTopics() (map[string]bool, error) {
+	completed := make(map[string]bool)
+	if len(p.OutputTrec.Path) == 0 {
+		return completed, nil
+	}
+	f, err := output.OpenRun(p.OutputTrec.Path)
+	if os.IsNotExist(err) {
+		return completed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := trecresults.ResultsFromReader(f)
+	if err != nil {
+		return nil, err
+	}
+	for topic := range r.Results {
+		completed[topic] = true
+	}
+	return completed, nil
+}
+
+// emitter sends the events of a pipeline to its sinks. The first error returned by a sink is kept.
+type emitter struct {
+	mu    sync.Mutex
+	sinks []pipeline.Sink
+	err   error
+}
+
+func (e *emitter) emit(ev pipeline.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.sinks {
+		if err := s.Handle(ev); err != nil {
+			log.Println(err)
+			if e.err == nil {
+				e.err = err
+			}
+		}
+	}
+}
+
+// transformQuery applies the preprocessing and transformations of the pipeline to a query.
+func (p Pipeline) transformQuery(e *emitter, q pipeline.Query) pipeline.Query {
+	start := time.Now()
+	t, applied := p.transform(q)
+	e.emit(pipeline.TransformationEvent{Query: t, Original: q.Query, Applied: applied, Elapsed: time.Since(start)})
+	return t
+}
+
+// executeQuery retrieves the results of a query, applies the results handlers, and evaluates the results.
+func (p Pipeline) executeQuery(e *emitter, q pipeline.Query, execute func(q pipeline.Query) (trecresults.ResultList, error)) error {
+	start := time.Now()
+	results, err := execute(q)
+	if err != nil {
+		return err
+	}
+	err = p.ResultsHandlers.Handle(&results)
+	if err != nil {
+		return err
+	}
+	e.emit(pipeline.ResultsEvent{Query: q, Results: results, Elapsed: time.Since(start)})
+
+	if len(p.Evaluations) > 0 {
+		// Topics without qrels are evaluated using the seed studies in their metadata, if there are any.
+		qrels := p.EvaluationFormatters.EvaluationQrels
+		if _, ok := qrels.Qrels[q.Topic]; !ok {
+			if seeds := eval.SeedsFromMetadata(q); len(seeds) > 0 {
+				qrels = seeds.Qrels()
+			}
+		}
+		e.emit(pipeline.EvaluationEvent{
+			Query:      q,
+			Evaluation: eval.Evaluate(p.Evaluations, &results, qrels, q.Topic),
+		})
+	}
+	return nil
+}
+
+// search executes a query using the statistics source of the pipeline.
+func (p Pipeline) search(q pipeline.Query) (trecresults.ResultList, error) {
+	return p.StatisticsSource.Execute(q, p.StatisticsSource.SearchOptions())
+}
+
+// executeStream executes and evaluates queries one at a time as they are loaded from the query source. Errors loading
+// individual queries do not stop the pipeline.
+func (p Pipeline) executeStream(e *emitter) error {
+	it, err := query.NewQueryIterator(p.QueriesSource, p.QueryPath)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	completed, err := p.completedTopics()
+	if err != nil {
+		return err
+	}
+
+	for it.Next() {
+		if err := it.Err(); err != nil {
+			e.emit(pipeline.ErrorEvent{Err: err})
+			continue
+		}
+
+		q := it.Query()
+		if completed[q.Topic] {
+			e.emit(pipeline.TopicEvent{Query: q, Skipped: true})
+			continue
+		}
+		log.Printf("starting topic %v\n", q.Topic)
+		start := time.Now()
+		q = p.transformQuery(e, q)
+
+		err := p.executeQuery(e, q, p.search)
+		if err != nil {
+			e.emit(pipeline.ErrorEvent{Topic: q.Topic, Err: err})
+			continue
+		}
+		e.emit(pipeline.TopicEvent{Query: q, Elapsed: time.Since(start)})
+	}
+	return nil
+}
+
+// Run executes the pipeline, sending events to each of the sinks as they happen. Errors that stop the pipeline are
+// returned (and sent as an error event), while errors for individual queries are only sent as events. If a sink
+// returns an error, the pipeline continues and the first such error is returned. The progress of the pipeline is
+// also reported to the registered metrics reporters.
+func (p Pipeline) Run(sinks ...pipeline.Sink) error {
+	sinks = append(sinks, metrics.NewSink(metrics.Default))
+	e := &emitter{sinks: sinks}
+	start := time.Now()
+
+	err := p.run(e)
+	if err != nil {
+		e.emit(pipeline.ErrorEvent{Err: err})
+	}
+	e.emit(pipeline.DoneEvent{Elapsed: time.Since(start)})
+
+	for _, s := range sinks {
+		if cerr := s.Close(); e.err == nil {
+			e.err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+// Execute runs a groove pipeline, sending the results through a channel. Measurements and evaluations are formatted
+// using the formatters of the pipeline. The channel is closed once the pipeline is done.
 func (p Pipeline) Execute(c chan pipeline.Result) {
 	defer close(c)
+	send := func(r pipeline.Result) error {
+		c <- r
+		return nil
+	}
+	_ = p.Run(
+		pipeline.NewLogProgress(),
+		output.NewFormatterSink(send,
+			output.FormatMeasurements(p.MeasurementFormatters...),
+			output.FormatQueryMeasurements(p.QueryFormatters...),
+			output.FormatEvaluations(p.EvaluationFormatters.EvaluationFormatters...)),
+		resultSink{send: send, trecResults: len(p.OutputTrec.Path) > 0})
+}
+
+// resultSink converts the events of a pipeline into the results sent through the channel of Execute.
+type resultSink struct {
+	send        func(r pipeline.Result) error
+	trecResults bool
+}
+
+func (s resultSink) Handle(e pipeline.Event) error {
+	switch v := e.(type) {
+	case pipeline.ResultsEvent:
+		if !s.trecResults {
+			return nil
+		}
+		results := v.Results
+		return s.send(pipeline.Result{
+			Topic:       v.Query.Topic,
+			Metadata:    v.Query.Metadata,
+			TrecResults: &results,
+			Type:        pipeline.TrecResult,
+		})
+	case pipeline.TransformationEvent:
+		q := v.Query
+		return s.send(pipeline.Result{
+			Transformation: pipeline.QueryResult{Name: q.Name, Topic: q.Topic, Transformation: q.Query, Metadata: q.Metadata},
+			Type:           pipeline.Transformation,
+		})
+	case pipeline.ErrorEvent:
+		return s.send(pipeline.Result{
+			Topic: v.Topic,
+			Error: v.Err,
+			Type:  pipeline.Error,
+		})
+	case pipeline.DoneEvent:
+		return s.send(pipeline.Result{
+			Type: pipeline.Done,
+		})
+	}
+	return nil
+}
+
+func (s resultSink) Close() error {
+	return nil
+}
+
+// run executes the stages of the pipeline.
+//noinspection GoNilness
+func (p Pipeline) run(e *emitter) error {
 	log.Println("starting groove pipeline...")
 
 	// TODO this method needs some serious refactoring done to it.
 
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		c <- pipeline.Result{
-			Error: err,
-			Type:  pipeline.Error,
-		}
-		return
+		return err
 	}
 
 	// Configure caches.
@@ -175,17 +509,36 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 
 	p.MeasurementExecutor = analysis.NewDiskMeasurementExecutor(statisticsCache)
 
+	if len(p.PubDatesFile) > 0 && p.DateRestrictions == nil {
+		p.DateRestrictions, err = preprocess.LoadTopicDates(p.PubDatesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	headers := make([]string, len(p.Measurements))
+	for i, measure := range p.Measurements {
+		headers[i] = measure.Name()
+	}
+	evaluations := make([]string, len(p.Evaluations))
+	for i, evaluation := range p.Evaluations {
+		evaluations[i] = evaluation.Name()
+	}
+
 	// Only perform this section if there are some queries.
-	if len(p.QueryPath) > 0 {
+	if len(p.QueryPath) > 0 && p.streams() {
+		log.Println("streaming queries...")
+		e.emit(pipeline.StartEvent{Measurements: headers, Evaluations: evaluations})
+		err := p.executeStream(e)
+		if err != nil {
+			return err
+		}
+	} else if len(p.QueryPath) > 0 {
 		log.Println("loading queries...")
 		// Load and process the queries.
 		queries, err := p.QueriesSource.Load(p.QueryPath)
 		if err != nil {
-			c <- pipeline.Result{
-				Error: err,
-				Type:  pipeline.Error,
-			}
-			return
+			return err
 		}
 
 		// Here we need to configure how the queries are loaded into each learning model.
@@ -198,15 +551,6 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 			}
 		}
 
-		//if len(p.PubDatesFile) > 0 {
-		//	log.Println("adding date restrictions to queries...")
-		//	for i, cq := range queries {
-		//		log.Println(cq.Topic)
-		//		q := preprocess.DateRestrictions(p.PubDatesFile)(cq.Query, cq.Topic)()
-		//		queries[i].Query = q
-		//	}
-		//}
-
 		log.Println("sorting queries by complexity...")
 
 		// Sort the transformed queries by size.
@@ -219,173 +563,62 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 		}
 		fmt.Println()
 
+		e.emit(pipeline.StartEvent{Topics: len(queries), Measurements: headers, Evaluations: evaluations})
+
 		// This means preprocessing the query.
 		measurementQueries := make([]pipeline.Query, len(queries))
-		topics := make([]string, len(queries))
 		for i, q := range queries {
-			topics[i] = q.Topic
-			// Ensure there is a processed query.
-
-			// And apply the processing if there is any.
-			for _, p := range p.Preprocess {
-				q = pipeline.NewQuery(q.Name, q.Topic, preprocess.ProcessQuery(q.Query, p))
-			}
-
-			// Apply any transformations.
-			for i, t := range p.Transformations.BooleanTransformations {
-				fmt.Println(q.Topic, i)
-				q = pipeline.NewQuery(q.Name, q.Topic, t(q.Query, q.Topic)())
-			}
-			for _, t := range p.Transformations.ElasticsearchTransformations {
-				if s, ok := p.StatisticsSource.(*stats.ElasticsearchStatisticsSource); ok {
-					q = pipeline.NewQuery(q.Name, q.Topic, t(q.Query, s)())
-				} else {
-					log.Fatal("Elasticsearch transformations only work with an Elasticsearch statistics source.")
-				}
-			}
-			measurementQueries[i] = q
+			// Apply the processing and transformations if there are any.
+			measurementQueries[i] = p.transformQuery(e, q)
 		}
 
 		// Compute measurements for each of the queries.
-		// The measurements are computed in parallel.
-		N := len(p.Measurements)
-		headers := make([]string, N)
-		data := make([][]float64, N)
-
-		for i, measure := range p.Measurements {
-			headers[i] = measure.Name()
-		}
-
-		// Only perform the measurements if there are some measurement formatters to output them to.
-		if len(p.MeasurementFormatters) > 0 {
-			for _, m := range measurementQueries {
-				measurements, err := p.MeasurementExecutor.Execute(m, p.StatisticsSource, p.Measurements...)
+		if len(p.Measurements) > 0 {
+			for _, q := range measurementQueries {
+				start := time.Now()
+				measurements, err := p.MeasurementExecutor.Execute(q, p.StatisticsSource, p.Measurements...)
 				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
+					e.emit(pipeline.ErrorEvent{Topic: q.Topic, Err: err})
+					continue
 				}
+				m := make(map[string]float64, len(measurements))
 				for i, measurement := range measurements {
-					data[i] = append(data[i], measurement)
+					m[headers[i]] = measurement
 				}
-			}
+				e.emit(pipeline.MeasurementEvent{Query: q, Measurements: m, Elapsed: time.Since(start)})
 
-			// Format the measurement results into specified formats.
-			outputs := make([]string, len(p.MeasurementFormatters))
-			for i, formatter := range p.MeasurementFormatters {
-				if len(data) > 0 && len(topics) != len(data[0]) {
-					c <- pipeline.Result{
-						Error: errors.New("the length of topics and data must be the same"),
-						Type:  pipeline.Error,
-					}
+				// When the queries are not executed, the topic is complete once it has been measured.
+				if !p.executes() {
+					e.emit(pipeline.TopicEvent{Query: q, Elapsed: time.Since(start)})
 				}
-				outputs[i], err = formatter(topics, headers, data)
-				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
-				}
-			}
-			c <- pipeline.Result{
-				Measurements: outputs,
-				Type:         pipeline.Measurement,
-			}
-		}
-
-		var hw *headway.Client
-		loghw := false
-		if len(p.CLF.HeadwayServer) > 0 {
-			hw = headway.NewClient(p.CLF.HeadwayServer, fmt.Sprintf("@harry groove pipeline [#%d]", time.Now().Unix()))
-			if hw != nil {
-				loghw = true
 			}
 		}
 
-		if (len(p.OutputTrec.Path) > 0 || len(p.EvaluationFormatters.EvaluationFormatters) > 0) && p.CLF.CLF {
-			// Store the measurements to be output later.
-
-			f, err := os.OpenFile(p.OutputTrec.Path, os.O_RDONLY, 0664)
-			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
-			}
-
-			r, err := trecresults.ResultsFromReader(f)
+		if p.executes() && p.CLF.CLF {
+			completed, err := p.completedTopics()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
-			f.Close()
 
-			measurements := make(map[string]map[string]float64)
-			for i, q := range measurementQueries {
-				if _, ok := r.Results[q.Topic]; ok {
-					log.Printf("already completed topic %v, so skipping it\n", q.Topic)
+			for _, q := range measurementQueries {
+				if completed[q.Topic] {
+					e.emit(pipeline.TopicEvent{Query: q, Skipped: true})
 					continue
 				}
 				log.Printf("starting topic %v\n", q.Topic)
-				results, err := rank.CLF(q, p.StatisticsSource.(stats.EntrezStatisticsSource), p.CLF)
+				start := time.Now()
+				err := p.executeQuery(e, q, func(q pipeline.Query) (trecresults.ResultList, error) {
+					return rank.CLF(q, p.StatisticsSource.(stats.EntrezStatisticsSource), p.CLF)
+				})
 				if err != nil {
-					if loghw {
-						err = hw.Send(float64(i), float64(len(measurementQueries)), err.Error())
-						if err != nil {
-							log.Println(err)
-						}
-					}
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
-				}
-				if loghw {
-					err = hw.Send(float64(i), float64(len(measurementQueries)), fmt.Sprintf("[measurement] topic %s", q.Topic))
-					if err != nil {
-						log.Println(err)
-					}
-				}
-				// Set the evaluation results.
-				if len(p.Evaluations) > 0 {
-					measurements[q.Topic] = eval.Evaluate(p.Evaluations, &results, p.EvaluationFormatters.EvaluationQrels, q.Topic)
-				}
-
-				// MeasurementOutput the trec results.
-				if len(p.OutputTrec.Path) > 0 {
-					c <- pipeline.Result{
-						Topic:       q.Topic,
-						TrecResults: &results,
-						Type:        pipeline.TrecResult,
-					}
-				}
-
-				// Send the transformation through the channel.
-				c <- pipeline.Result{
-					Transformation: pipeline.QueryResult{Name: q.Name, Topic: q.Topic, Transformation: q.Query},
-					Type:           pipeline.Transformation,
+					e.emit(pipeline.ErrorEvent{Topic: q.Topic, Err: err})
+					continue
 				}
-
-				log.Printf("completed topic %v\n", q.Topic)
+				e.emit(pipeline.TopicEvent{Query: q, Elapsed: time.Since(start)})
 			}
-			if loghw {
-				_ = hw.Send(float64(len(measurementQueries)), float64(len(measurementQueries)), "[measurement] done!")
-			}
-
-		} else if len(p.OutputTrec.Path) > 0 || len(p.EvaluationFormatters.EvaluationFormatters) > 0 {
+		} else if p.executes() {
 			// This section is run concurrently, since the results can sometimes get quite large and we don't want to eat ram.
 
-			// Store the measurements to be output later.
-			measurements := make(map[string]map[string]float64)
-
 			// Set the limit to how many goroutines can be run.
 			// http://jmoiron.net/blog/limiting-concurrency-in-go/
 			concurrency := 1 //runtime.NumCPU()
@@ -394,112 +627,35 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 
 			log.Printf("starting to execute queries with %d goroutines\n", concurrency)
 
-			f, err := os.OpenFile(p.OutputTrec.Path, os.O_RDONLY, 0664)
+			completed, err := p.completedTopics()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 
-			r, err := trecresults.ResultsFromReader(f)
-			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
-			}
-			f.Close()
-
 			sem := make(chan bool, concurrency)
-			for i, q := range measurementQueries {
+			for _, q := range measurementQueries {
 				sem <- true
-				go func(idx int, query pipeline.Query) {
+				go func(query pipeline.Query) {
 					defer func() { <-sem }()
-					if _, ok := r.Results[q.Topic]; ok {
-						log.Printf("already completed topic %v, so skipping it\n", q.Topic)
+					if completed[query.Topic] {
+						e.emit(pipeline.TopicEvent{Query: query, Skipped: true})
 						return
 					}
 					log.Printf("starting topic %v\n", query.Topic)
-					//
-					//tree, cache, err := combinator.NewLogicalTree(query, p.StatisticsSource, p.QueryCache)
-					//if err != nil {
-					//	c <- pipeline.Result{
-					//		Topic: query.Topic,
-					//		Error: err,
-					//		Type:  pipeline.Error,
-					//	}
-					//	return
-					//}
-					//docIds := tree.Documents(cache)
-					//if err != nil {
-					//	c <- pipeline.Result{
-					//		Topic: query.Topic,
-					//		Error: err,
-					//		Type:  pipeline.Error,
-					//	}
-					//	return
-					//}
-					//trecResults := docIds.Results(query, query.Name)
-					trecResults, err := p.StatisticsSource.Execute(query, p.StatisticsSource.SearchOptions())
+					start := time.Now()
+					err := p.executeQuery(e, query, p.search)
 					if err != nil {
-						if loghw {
-							_ = hw.Send(float64(i), float64(len(measurementQueries)), err.Error())
-						}
-						panic(err)
-					}
-
-					// Set the evaluation results.
-					if len(p.Evaluations) > 0 {
-						measurements[query.Topic] = eval.Evaluate(p.Evaluations, &trecResults, p.EvaluationFormatters.EvaluationQrels, query.Topic)
-					}
-
-					// MeasurementOutput the trec results.
-					if len(p.OutputTrec.Path) > 0 {
-						c <- pipeline.Result{
-							Topic:       query.Topic,
-							TrecResults: &trecResults,
-							Type:        pipeline.TrecResult,
-						}
-					}
-
-					// Send the transformation through the channel.
-					c <- pipeline.Result{
-						Transformation: pipeline.QueryResult{Name: query.Name, Topic: query.Topic, Transformation: query.Query},
-						Type:           pipeline.Transformation,
+						e.emit(pipeline.ErrorEvent{Topic: query.Topic, Err: err})
+						return
 					}
-
-					log.Printf("completed topic %v\n", query.Topic)
-				}(i, q)
+					e.emit(pipeline.TopicEvent{Query: query, Elapsed: time.Since(start)})
+				}(q)
 			}
 
 			// Wait until the last goroutine has read from the semaphore.
 			for i := 0; i < cap(sem); i++ {
 				sem <- true
 			}
-
-			// MeasurementOutput the evaluation results.
-			evaluations := make([]string, len(p.EvaluationFormatters.EvaluationFormatters))
-			// Now we can finally get to formatting the evaluation results.
-			for i, f := range p.EvaluationFormatters.EvaluationFormatters {
-				r, err := f(measurements)
-				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
-				}
-				evaluations[i] = r
-			}
-
-			// And send the through the channel.
-			c <- pipeline.Result{
-				Evaluations: evaluations,
-				Type:        pipeline.Evaluation,
-			}
 		}
 	}
 
@@ -508,33 +664,21 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 			log.Println("generating features for model")
 			err := p.Model.Generate()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 		}
 		if p.ModelConfiguration.Train {
 			log.Println("training model")
 			err := p.Model.Train()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 		}
 		if p.ModelConfiguration.Test {
 			log.Println("testing model")
 			err := p.Model.Test()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 
 		}
@@ -545,21 +689,13 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 		// Perform the query formulation.
 		queries, sup, err := p.QueryFormulator.Formulate()
 		if err != nil {
-			c <- pipeline.Result{
-				Error: err,
-				Type:  pipeline.Error,
-			}
-			return
+			return err
 		}
 		for _, s := range sup {
 			// Create the folder the data will be contained in.
 			err := os.MkdirAll(path.Join(p.QueryFormulator.Method(), s.Name), 0777)
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 
 			for _, d := range s.Data {
@@ -567,38 +703,22 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 				// Create and open the file that will contain the data.
 				f, err := os.OpenFile(path.Join(p.QueryFormulator.Method(), s.Name, d.Name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
 				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
+					return err
 				}
 				// Marshal the data into bytes for writing to disk.
 				b, err := d.Value.Marshal()
 				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
+					return err
 				}
 				// Write those bytes to disk.
 				_, err = f.Write(b)
 				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
+					return err
 				}
 				// Close that file.
 				err = f.Close()
 				if err != nil {
-					c <- pipeline.Result{
-						Error: err,
-						Type:  pipeline.Error,
-					}
-					return
+					return err
 				}
 			}
 		}
@@ -606,64 +726,36 @@ func (p Pipeline) Execute(c chan pipeline.Result) {
 		// Create the folder that will contain the formulated query/queries.
 		err = os.MkdirAll(p.QueryFormulator.Method(), 0777)
 		if err != nil {
-			c <- pipeline.Result{
-				Error: err,
-				Type:  pipeline.Error,
-			}
-			return
+			return err
 		}
 		for i, q := range queries {
 			fmt.Println(q)
 			err := os.MkdirAll(path.Join(p.QueryFormulator.Method(), strconv.Itoa(i)), 0777)
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 			// Compile the query to CQR.
 			s, err := transmute.CompileCqr2PubMed(q)
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 			// Open the file that will contain the query.
 			f, err := os.OpenFile(path.Join(p.QueryFormulator.Method(), strconv.Itoa(i), p.QueryFormulator.Topic()), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 			// Write the query to disk.
 			_, err = f.WriteString(s)
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 			// Close the file.
 			err = f.Close()
 			if err != nil {
-				c <- pipeline.Result{
-					Error: err,
-					Type:  pipeline.Error,
-				}
-				return
+				return err
 			}
 		}
 	}
 
-	// Return the formatted results.
-	c <- pipeline.Result{
-		Type: pipeline.Done,
-	}
-	return
+	return nil
 }
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/trecresults"
+	"time"
+)
+
+// Event is something that happened while executing a groove pipeline. Events are sent to the sinks of a pipeline,
+// which can use a type switch on the concrete event types in this package to handle them.
+type Event interface {
+	event()
+}
+
+// StartEvent is sent before any queries are processed. Topics is the number of queries that will be processed, or
+// zero if this is not known ahead of time (e.g. when queries are streamed).
+type StartEvent struct {
+	Topics       int
+	Measurements []string
+	Evaluations  []string
+}
+
+// TransformationEvent is sent once a query has been preprocessed and transformed. Applied are the names of the
+// preprocessors and transformations that were applied to the query, in order (e.g. preprocess.Lowercase).
+type TransformationEvent struct {
+	Query    Query
+	Original cqr.CommonQueryRepresentation
+	Applied  []string
+	Elapsed  time.Duration
+}
+
+// MeasurementEvent contains the measurements of a query, keyed by measurement name.
+type MeasurementEvent struct {
+	Query        Query
+	Measurements map[string]float64
+	Elapsed      time.Duration
+}
+
+// ResultsEvent contains the results of executing a query, after the results handlers have been applied.
+type ResultsEvent struct {
+	Query   Query
+	Results trecresults.ResultList
+	Elapsed time.Duration
+}
+
+// EvaluationEvent contains the evaluation of the results of a query, keyed by evaluation measure.
+type EvaluationEvent struct {
+	Query      Query
+	Evaluation map[string]float64
+}
+
+// TopicEvent is sent once a pipeline has finished with a query. Skipped topics were completed in an earlier
+// execution of the pipeline.
+type TopicEvent struct {
+	Query   Query
+	Skipped bool
+	Elapsed time.Duration
+}
+
+// ErrorEvent is sent when an error occurs. Topic is empty if the error is not specific to a query.
+type ErrorEvent struct {
+	Topic string
+	Err   error
+}
+
+// DoneEvent is the final event of a pipeline.
+type DoneEvent struct {
+	Elapsed time.Duration
+}
+
+func (StartEvent) event()          {}
+func (TransformationEvent) event() {}
+func (MeasurementEvent) event()    {}
+func (ResultsEvent) event()        {}
+func (EvaluationEvent) event()     {}
+func (TopicEvent) event()          {}
+func (ErrorEvent) event()          {}
+func (DoneEvent) event()           {}
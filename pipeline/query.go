@@ -4,16 +4,66 @@ import (
 	"github.com/hscells/cqr"
 )
 
+// Metadata is information about the topic of a query which is not part of the query itself. Query sources populate
+// the metadata when it is available so that later stages of a pipeline do not need to read it from other files.
+type Metadata struct {
+	// Title is the title of the topic (e.g. the title of a systematic review).
+	Title string `json:"title,omitempty"`
+	// Description is a longer description of the topic (e.g. the objective of a systematic review).
+	Description string `json:"description,omitempty"`
+	// Narrative describes what is considered relevant to the topic.
+	Narrative string `json:"narrative,omitempty"`
+	// Syntax is the syntax the original query was written in.
+	Syntax string `json:"syntax,omitempty"`
+	// Original is the query before it was parsed.
+	Original string `json:"original,omitempty"`
+	// PIDs are the identifiers of the documents that are considered for the topic.
+	PIDs []string `json:"pids,omitempty"`
+	// DateFrom and DateTo are the (inclusive) date restrictions of the topic.
+	DateFrom string `json:"date_from,omitempty"`
+	DateTo   string `json:"date_to,omitempty"`
+	// Extra contains any other information about the topic.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Get retrieves extra information about the topic.
+func (m Metadata) Get(key string) (string, bool) {
+	v, ok := m.Extra[key]
+	return v, ok
+}
+
+// Set adds extra information about the topic.
+func (m *Metadata) Set(key, value string) {
+	if m.Extra == nil {
+		m.Extra = make(map[string]string)
+	}
+	m.Extra[key] = value
+}
+
 // Query stores information about a query before it is measured, analysed, or executed.
 // In most circumstances, the `transformed` query should be used, as it is the preprocessed,
 // transformed, and rewritten query.
 type Query struct {
-	Topic string
-	Name  string
-	Query cqr.CommonQueryRepresentation
+	Topic    string
+	Name     string
+	Query    cqr.CommonQueryRepresentation
+	Metadata Metadata
 }
 
 // NewQuery creates a new groove pipeline query.
 func NewQuery(name string, topic string, query cqr.CommonQueryRepresentation) Query {
 	return Query{Name: name, Topic: topic, Query: query}
 }
+
+// WithQuery creates a copy of the query with a different common query representation. The topic, name, and
+// metadata of the query are preserved.
+func (q Query) WithQuery(query cqr.CommonQueryRepresentation) Query {
+	q.Query = query
+	return q
+}
+
+// WithMetadata creates a copy of the query with the specified metadata.
+func (q Query) WithMetadata(metadata Metadata) Query {
+	q.Metadata = metadata
+	return q
+}
@@ -10,6 +10,7 @@ type QueryResult struct {
 	Topic          string
 	Name           string
 	Transformation cqr.CommonQueryRepresentation
+	Metadata       Metadata
 }
 
 // ResultType is the type of result being returned through a pipeline channel.
@@ -33,6 +34,7 @@ const (
 // Result is the output of a groove pipeline.
 type Result struct {
 	Topic          string
+	Metadata       Metadata
 	Measurements   []string
 	Evaluations    []string
 	Transformation QueryResult
@@ -44,8 +46,9 @@ type Result struct {
 // ToGroovePipelineQuery converts a QueryResult into a pipeline query.
 func (qr QueryResult) ToGroovePipelineQuery() Query {
 	return Query{
-		Topic: qr.Topic,
-		Name:  qr.Name,
-		Query: qr.Transformation,
+		Topic:    qr.Topic,
+		Name:     qr.Name,
+		Query:    qr.Transformation,
+		Metadata: qr.Metadata,
 	}
 }
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"github.com/hscells/trecresults"
+	"log"
+	"sync"
+)
+
+// Sink receives the events of a groove pipeline. The events of a pipeline are sent to its sinks in the order the sinks
+// were given, and a sink is closed once the pipeline is done.
+type Sink interface {
+	Handle(e Event) error
+	Close() error
+}
+
+// SinkFunc is a sink that handles events with a function and has nothing to close.
+type SinkFunc func(e Event) error
+
+// Handle calls the function.
+func (f SinkFunc) Handle(e Event) error {
+	return f(e)
+}
+
+// Close does nothing.
+func (f SinkFunc) Close() error {
+	return nil
+}
+
+// Collector is a sink that keeps every event in memory, e.g., for tests or for inspecting the results of a pipeline
+// after it has been executed.
+type Collector struct {
+	mu     sync.Mutex
+	Events []Event
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Handle stores the event.
+func (c *Collector) Handle(e Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Events = append(c.Events, e)
+	return nil
+}
+
+// Close does nothing.
+func (c *Collector) Close() error {
+	return nil
+}
+
+// Measurements are the measurements collected, keyed by topic.
+func (c *Collector) Measurements() map[string]map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]map[string]float64)
+	for _, e := range c.Events {
+		if v, ok := e.(MeasurementEvent); ok {
+			m[v.Query.Topic] = v.Measurements
+		}
+	}
+	return m
+}
+
+// Evaluations are the evaluations collected, keyed by topic.
+func (c *Collector) Evaluations() map[string]map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]map[string]float64)
+	for _, e := range c.Events {
+		if v, ok := e.(EvaluationEvent); ok {
+			m[v.Query.Topic] = v.Evaluation
+		}
+	}
+	return m
+}
+
+// Results are the results collected, keyed by topic.
+func (c *Collector) Results() map[string]trecresults.ResultList {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]trecresults.ResultList)
+	for _, e := range c.Events {
+		if v, ok := e.(ResultsEvent); ok {
+			m[v.Query.Topic] = v.Results
+		}
+	}
+	return m
+}
+
+// Errors are the errors collected.
+func (c *Collector) Errors() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, e := range c.Events {
+		if v, ok := e.(ErrorEvent); ok {
+			errs = append(errs, v.Err)
+		}
+	}
+	return errs
+}
+
+// LogProgress is a sink that logs the progress of a pipeline as each topic is completed.
+type LogProgress struct {
+	total     int
+	completed int
+}
+
+// NewLogProgress creates a sink that reports progress using the standard logger.
+func NewLogProgress() *LogProgress {
+	return &LogProgress{}
+}
+
+// Handle logs the progress of the pipeline.
+func (l *LogProgress) Handle(e Event) error {
+	switch v := e.(type) {
+	case StartEvent:
+		l.total = v.Topics
+	case TopicEvent:
+		l.completed++
+		if v.Skipped {
+			log.Printf("already completed topic %v, so skipping it\n", v.Query.Topic)
+		} else if l.total > 0 {
+			log.Printf("completed topic %v (%d/%d) in %v\n", v.Query.Topic, l.completed, l.total, v.Elapsed)
+		} else {
+			log.Printf("completed topic %v (%d) in %v\n", v.Query.Topic, l.completed, v.Elapsed)
+		}
+	case ErrorEvent:
+		if len(v.Topic) > 0 {
+			log.Printf("error in topic %v: %v\n", v.Topic, v.Err)
+		} else {
+			log.Println(v.Err)
+		}
+	case DoneEvent:
+		log.Printf("completed %d topics in %v\n", l.completed, v.Elapsed)
+	}
+	return nil
+}
+
+// Close does nothing.
+func (l *LogProgress) Close() error {
+	return nil
+}
@@ -1,74 +1,181 @@
 package preprocess
 
 import (
-	"bufio"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
 	"github.com/hscells/transmute/fields"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
 	"strings"
-	"time"
+	"sync"
 )
 
-// DateRestrictions loads a file in the format:
-//
-//	CD008122	19400101	20100114
-// 	CD008587	19920101	20151130
-// 	CD008759	19460101	20160630
-// 	CD008892	19660101	20160301
-// 	CD009175	19500101	20120626
-// 	CD009263	19400101	20120921
-// 	CD009694	19920101	20120831
-// 	CD010213	19460101	20160719
-// 	CD010296	19460101	20160701
-// 	CD010502	19800101	20130201
-// 	CD010657	19400101	20160331
-//
-// Where the fist column is the topic of the query, and the other
-// two columns are the start and end dates of the restriction.
-func DateRestrictions(pubDatesFile string) BooleanTransformation {
-	return func(query cqr.CommonQueryRepresentation, topic string) Transformation {
-		return func() cqr.CommonQueryRepresentation {
-			f, err := os.OpenFile(pubDatesFile, os.O_RDONLY, 0644)
+// TopicDates are the publication date restrictions of topics.
+type TopicDates map[string]stats.DateRange
+
+// DateFormat is the format of a file of publication date restrictions.
+type DateFormat string
+
+const (
+	// TSVDates is a tab-separated file of topic, start date and end date, e.g.:
+	//
+	//	CD008122	19400101	20100114
+	//	CD008587	1992	2015-11
+	TSVDates DateFormat = "tsv"
+	// CSVDates is a comma-separated file of topic, start date and end date. A header row is skipped.
+	CSVDates DateFormat = "csv"
+	// JSONDates is a JSON object mapping topics to start and end dates, e.g.:
+	//
+	//	{"CD008122": {"start": "19400101", "end": "20100114"}}
+	JSONDates DateFormat = "json"
+)
+
+var (
+	topicDatesMu    sync.Mutex
+	topicDatesCache = make(map[string]TopicDates)
+)
+
+// ReadTopicDates reads the publication date restrictions of topics. Dates may be partial (i.e., only a year, or a
+// year and month), in which case the restriction starts at the beginning or ends at the end of the period.
+func ReadTopicDates(r io.Reader, format DateFormat) (TopicDates, error) {
+	switch format {
+	case JSONDates:
+		var restrictions map[string]struct {
+			Start string `json:"start"`
+			End   string `json:"end"`
+		}
+		err := json.NewDecoder(r).Decode(&restrictions)
+		if err != nil {
+			return nil, err
+		}
+		dates := make(TopicDates, len(restrictions))
+		for topic, restriction := range restrictions {
+			dates[topic], err = stats.NewDateRange(restriction.Start, restriction.End)
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("topic %s: %v", topic, err)
 			}
-
-			type restriction struct {
-				topic string
-				start time.Time
-				end   time.Time
+		}
+		return dates, nil
+	case TSVDates, CSVDates:
+		c := csv.NewReader(r)
+		c.FieldsPerRecord = -1
+		c.TrimLeadingSpace = true
+		c.LazyQuotes = true
+		if format == TSVDates {
+			c.Comma = '\t'
+		}
+		dates := make(TopicDates)
+		for n := 1; ; n++ {
+			record, err := c.Read()
+			if err == io.EOF {
+				return dates, nil
+			} else if err != nil {
+				return nil, err
 			}
-			var restrictions []restriction
-
-			s := bufio.NewScanner(f)
-			for s.Scan() {
-				line := strings.Split(s.Text(), "\t")
-				topic, start, end := line[0], line[1], line[2]
-				s, err := time.Parse("20060102", start)
-				if err != nil {
-					panic(err)
-				}
-				e, err := time.Parse("20060102", end)
-				if err != nil {
-					panic(err)
-				}
-				restrictions = append(restrictions, restriction{
-					topic: topic,
-					start: s,
-					end:   e,
-				})
+			if len(record) == 1 && len(strings.TrimSpace(record[0])) == 0 {
+				continue
 			}
-			for _, r := range restrictions {
-				if r.topic == topic {
-					bq := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
-						query,
-						cqr.NewKeyword(fmt.Sprintf("%s:%s", r.start.Format("2006/01"), r.end.Format("2006/01")), fields.PublicationDate),
-					})
-					return bq
+			if len(record) < 2 {
+				return nil, fmt.Errorf("line %d: expected topic, start and end date", n)
+			}
+			end := ""
+			if len(record) > 2 {
+				end = record[2]
+			}
+			dr, err := stats.NewDateRange(record[1], end)
+			if err != nil {
+				// The first row of a CSV file may be a header.
+				if n == 1 && format == CSVDates {
+					continue
 				}
+				return nil, fmt.Errorf("line %d: %v", n, err)
 			}
-			return query
+			dates[strings.TrimSpace(record[0])] = dr
+		}
+	}
+	return nil, fmt.Errorf("unknown date restriction format %s", format)
+}
+
+// LoadTopicDates loads a file of publication date restrictions of topics. The format of the file is determined by
+// its extension (.csv or .json); any other file is read as tab-separated.
+func LoadTopicDates(path string) (TopicDates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format := TSVDates
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		format = CSVDates
+	case ".json":
+		format = JSONDates
+	}
+	dates, err := ReadTopicDates(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return dates, nil
+}
+
+// Restrict restricts a query to the publication dates of its topic. Queries for topics without a restriction are
+// left unchanged.
+func (t TopicDates) Restrict(query cqr.CommonQueryRepresentation, topic string) Transformation {
+	return func() cqr.CommonQueryRepresentation {
+		if r, ok := t[topic]; ok {
+			return restrictDates(query, r)
+		}
+		return query
+	}
+}
+
+// DateRestrictions restricts queries to the publication dates of their topic, loaded from a file (see
+// LoadTopicDates). The file is only loaded once it has been loaded successfully. If the file cannot be loaded, the
+// error is logged and queries are left unchanged.
+func DateRestrictions(pubDatesFile string) BooleanTransformation {
+	topicDatesMu.Lock()
+	defer topicDatesMu.Unlock()
+	dates, ok := topicDatesCache[pubDatesFile]
+	if !ok {
+		var err error
+		dates, err = LoadTopicDates(pubDatesFile)
+		if err != nil {
+			log.Printf("unable to load date restrictions: %v", err)
+		} else {
+			topicDatesCache[pubDatesFile] = dates
+		}
+	}
+	return dates.Restrict
+}
+
+// MetadataDateRestrictions restricts a query to the DateFrom and DateTo of its metadata. Dates may be partial, and in
+// the formats accepted by stats.ParsePartialDate; a missing DateTo leaves the restriction open ended. Queries without a date restriction in
+// their metadata, or with an invalid one, are left unchanged.
+func MetadataDateRestrictions(q pipeline.Query) Transformation {
+	return func() cqr.CommonQueryRepresentation {
+		if len(q.Metadata.DateFrom) == 0 {
+			return q.Query
+		}
+		r, err := stats.NewDateRange(q.Metadata.DateFrom, q.Metadata.DateTo)
+		if err != nil {
+			log.Printf("topic %s: invalid date restriction: %v", q.Topic, err)
+			return q.Query
 		}
+		return restrictDates(q.Query, r)
 	}
 }
+
+// restrictDates adds a publication date clause to a query.
+func restrictDates(query cqr.CommonQueryRepresentation, r stats.DateRange) cqr.CommonQueryRepresentation {
+	return cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		query,
+		cqr.NewKeyword(r.String(), fields.PublicationDate),
+	})
+}
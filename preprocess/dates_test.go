@@ -0,0 +1,74 @@
+package preprocess
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTopicDates(t *testing.T) {
+	tests := []struct {
+		format DateFormat
+		input  string
+	}{
+		{TSVDates, "CD008122\t19400101\t20100114\nCD008587\t1992\t2015-11\n"},
+		{CSVDates, "topic,start,end\nCD008122,1940/01/01,2010/01/14\nCD008587,1992,2015/11\n"},
+		{JSONDates, `{"CD008122": {"start": "19400101", "end": "20100114"}, "CD008587": {"start": "1992", "end": "201511"}}`},
+	}
+	for _, test := range tests {
+		dates, err := ReadTopicDates(strings.NewReader(test.input), test.format)
+		if err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		if len(dates) != 2 {
+			t.Fatalf("%s: expected 2 topics, got %d", test.format, len(dates))
+		}
+		if got := dates["CD008122"].String(); got != "1940/01/01:2010/01/14" {
+			t.Errorf("%s: expected 1940/01/01:2010/01/14, got %s", test.format, got)
+		}
+		r := dates["CD008587"]
+		if !r.Start.Equal(time.Date(1992, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("%s: expected partial start date to be the start of the year, got %v", test.format, r.Start)
+		}
+		if got := r.End.Format("2006/01/02"); got != "2015/11/30" {
+			t.Errorf("%s: expected partial end date to be the end of the month, got %s", test.format, got)
+		}
+	}
+
+	_, err := ReadTopicDates(strings.NewReader("CD008122\tyesterday\t20100114\n"), TSVDates)
+	if err == nil {
+		t.Errorf("expected an error for an invalid date")
+	}
+}
+
+func TestOpenEndedDates(t *testing.T) {
+	dates, err := ReadTopicDates(strings.NewReader("CD008122\t2010\n"), TSVDates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dates["CD008122"].String(); got != "2010/01/01:3000/12/31" {
+		t.Errorf("expected a missing end date to leave the range open, got %s", got)
+	}
+}
+
+func TestDateRestrictions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dates.tsv")
+	q := cqr.NewKeyword("heart attack", fields.TitleAbstract)
+
+	// A file that cannot be loaded leaves queries unchanged, and is not remembered.
+	if _, ok := DateRestrictions(path)(q, "CD008122")().(cqr.Keyword); !ok {
+		t.Errorf("expected the query to be unchanged when the dates cannot be loaded")
+	}
+	err := ioutil.WriteFile(path, []byte("CD008122\t19400101\t20100114\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted, ok := DateRestrictions(path)(q, "CD008122")().(cqr.BooleanQuery)
+	if !ok || len(restricted.Children) != 2 || restricted.Children[1].(cqr.Keyword).QueryString != "1940/01/01:2010/01/14" {
+		t.Errorf("expected the dates to be loaded once the file exists, got %v", restricted)
+	}
+}
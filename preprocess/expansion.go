@@ -0,0 +1,507 @@
+package preprocess
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ExpansionMethod is how expansion terms are selected from the documents retrieved by a query.
+type ExpansionMethod string
+
+const (
+	// RM3Expansion selects the terms that contribute the most to the KL divergence of the relevance model of the
+	// feedback documents from the collection language model.
+	RM3Expansion ExpansionMethod = "rm3"
+	// RocchioExpansion selects the terms with the highest tf-idf weight in the centroid of the feedback documents. The
+	// statistics source must implement CollectionSize (the Terrier source does not).
+	RocchioExpansion ExpansionMethod = "rocchio"
+)
+
+// InsertionStrategy is how expansion terms are inserted into the conceptual clauses of a query.
+type InsertionStrategy string
+
+const (
+	// InsertCooccurring inserts each expansion term into the clause whose terms appear with it in the most feedback
+	// documents. Terms that never appear with the terms of any clause are not inserted.
+	InsertCooccurring InsertionStrategy = "cooccurrence"
+	// InsertBestClause inserts every expansion term into the one clause whose terms appear with them the most.
+	InsertBestClause InsertionStrategy = "best"
+	// InsertAllClauses inserts every expansion term into every clause.
+	InsertAllClauses InsertionStrategy = "all"
+)
+
+// PRFExpansion expands Boolean queries with terms from the top documents they retrieve (pseudo-relevance feedback).
+// The expansion terms are added as alternatives (i.e., `or` siblings) to the conceptual clauses of a query: the `or`
+// clauses, or keywords, which are combined with `and` (or are the first child of a `not`).
+type PRFExpansion struct {
+	source    stats.StatisticsSource
+	documents int
+	terms     int
+	method    ExpansionMethod
+	insertion InsertionStrategy
+	field     string
+	truncate  bool
+}
+
+// textAnalyser is a statistics source that analyses the text of documents (e.g. the Elasticsearch and Bleve sources),
+// so the terms of its term vectors may be stems (e.g. diabet) rather than words.
+type textAnalyser interface {
+	Analyse(text, analyser string) ([]string, error)
+}
+
+// PRFDocuments sets the number of top documents used for feedback (10 by default).
+func PRFDocuments(k int) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.documents = k
+	}
+}
+
+// PRFTerms sets the number of expansion terms added to a query (5 by default).
+func PRFTerms(n int) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.terms = n
+	}
+}
+
+// PRFMethod sets how expansion terms are selected (RM3Expansion by default).
+func PRFMethod(method ExpansionMethod) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.method = method
+	}
+}
+
+// PRFInsertion sets how expansion terms are inserted into a query (InsertCooccurring by default).
+func PRFInsertion(strategy InsertionStrategy) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.insertion = strategy
+	}
+}
+
+// PRFField restricts the expansion terms to the terms of a field of the feedback documents. By default, the terms
+// of every field are used. RM3Expansion requires a field, as the collection language model is of a single field.
+func PRFField(field string) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.field = field
+	}
+}
+
+// PRFTruncate sets whether expansion terms are inserted as truncated keywords (e.g. diabet*), so that stems match the
+// words they were stemmed from (e.g. diabetes and diabetic). By default, terms are truncated when the statistics source
+// analyses text.
+func PRFTruncate(truncate bool) func(*PRFExpansion) {
+	return func(e *PRFExpansion) {
+		e.truncate = truncate
+	}
+}
+
+// NewPRFExpansion creates a pseudo-relevance feedback expansion using documents retrieved from a statistics source.
+func NewPRFExpansion(source stats.StatisticsSource, options ...func(*PRFExpansion)) PRFExpansion {
+	e := PRFExpansion{
+		source:    source,
+		documents: 10,
+		terms:     5,
+		method:    RM3Expansion,
+		insertion: InsertCooccurring,
+	}
+	_, e.truncate = source.(textAnalyser)
+	for _, option := range options {
+		option(&e)
+	}
+	return e
+}
+
+// Expand is a BooleanTransformation that expands a query. If the query cannot be expanded, the error is logged and
+// the query is left unchanged.
+func (e PRFExpansion) Expand(query cqr.CommonQueryRepresentation, topic string) Transformation {
+	return func() cqr.CommonQueryRepresentation {
+		expanded, err := e.expand(query, topic)
+		if err != nil {
+			log.Printf("topic %s: unable to expand query: %v", topic, err)
+			return query
+		}
+		return expanded
+	}
+}
+
+// expansionTerm is a term selected to expand a query.
+type expansionTerm struct {
+	Term  string
+	Score float64
+}
+
+// feedback is the term frequencies of the documents retrieved by a query.
+type feedback struct {
+	docIds []string
+	scores []float64
+	tf     []map[string]float64
+	df     map[string]float64
+}
+
+func (e PRFExpansion) expand(query cqr.CommonQueryRepresentation, topic string) (cqr.CommonQueryRepresentation, error) {
+	clauses := conceptualClauses(query, "")
+	if len(clauses) == 0 {
+		return query, nil
+	}
+	fb, err := e.feedback(query, topic)
+	if err != nil {
+		return nil, err
+	}
+	if len(fb.docIds) == 0 {
+		return query, nil
+	}
+	terms, err := e.selectTerms(fb, queryTerms(query))
+	if err != nil {
+		return nil, err
+	}
+
+	// The co-occurrence of each expansion term with the terms of each clause.
+	cooccurrence := make([][]int, len(terms))
+	for i, t := range terms {
+		cooccurrence[i] = make([]int, len(clauses))
+		for j, c := range clauses {
+			for _, doc := range fb.tf {
+				if _, ok := doc[t.Term]; ok && c.terms.in(doc) {
+					cooccurrence[i][j]++
+				}
+			}
+		}
+	}
+
+	additions := make(map[string][]string)
+	switch e.insertion {
+	case InsertCooccurring:
+		for i, t := range terms {
+			if j := argmax(cooccurrence[i]); cooccurrence[i][j] > 0 {
+				additions[clauses[j].path] = append(additions[clauses[j].path], t.Term)
+			}
+		}
+	case InsertBestClause:
+		totals := make([]int, len(clauses))
+		for i := range terms {
+			for j, n := range cooccurrence[i] {
+				totals[j] += n
+			}
+		}
+		c := clauses[argmax(totals)]
+		for _, t := range terms {
+			additions[c.path] = append(additions[c.path], t.Term)
+		}
+	case InsertAllClauses:
+		for _, c := range clauses {
+			for _, t := range terms {
+				additions[c.path] = append(additions[c.path], t.Term)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown insertion strategy %s", e.insertion)
+	}
+	return insertTerms(query, "", additions, e.truncate), nil
+}
+
+// feedback retrieves the top documents of a query and their term vectors.
+func (e PRFExpansion) feedback(query cqr.CommonQueryRepresentation, topic string) (feedback, error) {
+	options := e.source.SearchOptions()
+	options.Size = e.documents
+	results, err := e.source.Execute(pipeline.NewQuery(topic, topic, query), options)
+	if err != nil {
+		return feedback{}, err
+	}
+	if len(results) > e.documents {
+		results = results[:e.documents]
+	}
+
+	fb := feedback{
+		docIds: make([]string, len(results)),
+		scores: make([]float64, len(results)),
+		tf:     make([]map[string]float64, len(results)),
+		df:     make(map[string]float64),
+	}
+	for i, result := range results {
+		fb.docIds[i] = result.DocId
+		fb.scores[i] = result.Score
+		tv, err := e.source.TermVector(result.DocId)
+		if err != nil {
+			return feedback{}, err
+		}
+		fb.tf[i] = make(map[string]float64)
+		for _, term := range tv {
+			if len(e.field) > 0 && !strings.EqualFold(term.Field, e.field) {
+				continue
+			}
+			t := strings.ToLower(term.Term)
+			fb.tf[i][t] += term.TermFrequency
+			fb.df[t] = math.Max(fb.df[t], term.DocumentFrequency)
+		}
+	}
+	return fb, nil
+}
+
+// selectTerms selects the expansion terms from the feedback documents, excluding the terms of the query.
+func (e PRFExpansion) selectTerms(fb feedback, exclude termSet) ([]expansionTerm, error) {
+	var candidates []string
+	for t := range fb.df {
+		if exclude.matches(t) || !isExpansionTerm(t) {
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+
+	var terms []expansionTerm
+	switch e.method {
+	case RM3Expansion:
+		if len(e.field) == 0 {
+			return nil, fmt.Errorf("rm3 expansion requires a field")
+		}
+		// The relevance model weights each document by its share of the retrieval scores.
+		sum := 0.0
+		for _, s := range fb.scores {
+			sum += s
+		}
+		weights := make([]float64, len(fb.scores))
+		for i, s := range fb.scores {
+			if sum > 0 {
+				weights[i] = s / sum
+			} else {
+				weights[i] = 1 / float64(len(fb.scores))
+			}
+		}
+		lm, err := stats.NewLanguageModel(e.source, fb.docIds, fb.scores, e.field,
+			stats.LanguageModelWeights(weights), stats.LanguageModelField(e.field))
+		if err != nil {
+			return nil, err
+		}
+		if lm.VocabularySize == 0 {
+			return nil, fmt.Errorf("field %s has no vocabulary", e.field)
+		}
+		for _, t := range candidates {
+			p, q := lm.DocumentTermProbability(t), lm.CollectionTermProbability(t)
+			if p == 0 || q == 0 {
+				continue
+			}
+			terms = append(terms, expansionTerm{Term: t, Score: p * math.Log(p/q)})
+		}
+	case RocchioExpansion:
+		N, err := e.source.CollectionSize()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range candidates {
+			idf := math.Log((N + 1) / (fb.df[t] + 1))
+			w := 0.0
+			for _, doc := range fb.tf {
+				w += doc[t] * idf
+			}
+			terms = append(terms, expansionTerm{Term: t, Score: w / float64(len(fb.tf))})
+		}
+	default:
+		return nil, fmt.Errorf("unknown expansion method %s", e.method)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Score != terms[j].Score {
+			return terms[i].Score > terms[j].Score
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	for i, t := range terms {
+		if i == e.terms || t.Score <= 0 {
+			return terms[:i], nil
+		}
+	}
+	return terms, nil
+}
+
+// isExpansionTerm is whether a term is a word that can be added to a query, rather than a stopword or number.
+func isExpansionTerm(term string) bool {
+	if len(term) < 3 || GeneralStopwords[term] {
+		return false
+	}
+	for _, r := range term {
+		if !unicode.IsLetter(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// termSet is the terms of keywords, with truncated terms kept as prefixes.
+type termSet struct {
+	terms    map[string]bool
+	prefixes []string
+}
+
+func newTermSet() termSet {
+	return termSet{terms: make(map[string]bool)}
+}
+
+// add adds the terms of a keyword.
+func (s *termSet) add(k cqr.Keyword) {
+	for _, t := range strings.Fields(strings.ToLower(strings.Replace(k.QueryString, `"`, "", -1))) {
+		if strings.HasSuffix(t, "*") || strings.HasSuffix(t, "$") {
+			s.prefixes = append(s.prefixes, strings.TrimRight(t, "*$"))
+		} else {
+			s.terms[t] = true
+		}
+	}
+}
+
+// matches is whether a term is one of the terms, or starts with one of the prefixes.
+func (s termSet) matches(term string) bool {
+	if s.terms[term] {
+		return true
+	}
+	for _, p := range s.prefixes {
+		if len(p) > 0 && strings.HasPrefix(term, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// in is whether any term of a document matches the terms.
+func (s termSet) in(doc map[string]float64) bool {
+	for t := range doc {
+		if s.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryTerms is the terms of every keyword in a query.
+func queryTerms(query cqr.CommonQueryRepresentation) termSet {
+	s := newTermSet()
+	var walk func(q cqr.CommonQueryRepresentation)
+	walk = func(q cqr.CommonQueryRepresentation) {
+		switch q := q.(type) {
+		case cqr.Keyword:
+			s.add(q)
+		case cqr.BooleanQuery:
+			for _, child := range q.Children {
+				walk(child)
+			}
+		}
+	}
+	walk(query)
+	return s
+}
+
+// conceptualClause is a clause of a query that expansion terms can be inserted into.
+type conceptualClause struct {
+	// path is the location of the clause in the query, as the index of each child from the root (e.g. /0/2).
+	path  string
+	terms termSet
+}
+
+// conceptualClauses finds the `or` clauses and keywords of a query that are combined with `and`, or are the first
+// child of a `not`. Proximity clauses are not expanded.
+func conceptualClauses(query cqr.CommonQueryRepresentation, path string) []conceptualClause {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		return []conceptualClause{{path: path, terms: queryTerms(q)}}
+	case cqr.BooleanQuery:
+		switch strings.ToLower(q.Operator) {
+		case cqr.OR:
+			return []conceptualClause{{path: path, terms: queryTerms(q)}}
+		case cqr.AND:
+			var clauses []conceptualClause
+			for i, child := range q.Children {
+				clauses = append(clauses, conceptualClauses(child, fmt.Sprintf("%s/%d", path, i))...)
+			}
+			return clauses
+		case cqr.NOT:
+			if len(q.Children) > 0 {
+				return conceptualClauses(q.Children[0], path+"/0")
+			}
+		}
+	}
+	return nil
+}
+
+// insertTerms adds terms, keyed by the path of a clause, as keywords to the clauses of a query, truncating them if
+// they are stems. The query is copied rather than modified.
+func insertTerms(query cqr.CommonQueryRepresentation, path string, additions map[string][]string, truncate bool) cqr.CommonQueryRepresentation {
+	if terms, ok := additions[path]; ok {
+		f := expansionFields(query)
+		var children []cqr.CommonQueryRepresentation
+		if q, ok := query.(cqr.BooleanQuery); ok {
+			children = append(children, q.Children...)
+		} else {
+			children = append(children, query)
+		}
+		for _, t := range terms {
+			if truncate {
+				children = append(children, cqr.NewKeyword(t+"*", f...).SetOption("truncated", true))
+				continue
+			}
+			children = append(children, cqr.NewKeyword(t, f...))
+		}
+		if q, ok := query.(cqr.BooleanQuery); ok {
+			q.Children = children
+			return q
+		}
+		return cqr.NewBooleanQuery(cqr.OR, children)
+	}
+
+	q, ok := query.(cqr.BooleanQuery)
+	if !ok {
+		return query
+	}
+	children := make([]cqr.CommonQueryRepresentation, len(q.Children))
+	for i, child := range q.Children {
+		children[i] = insertTerms(child, fmt.Sprintf("%s/%d", path, i), additions, truncate)
+	}
+	q.Children = children
+	return q
+}
+
+// expansionFields are the fields of the first keyword of a clause that is not a MeSH heading, so that expansion
+// terms are searched for in the same way as their siblings. If there is no such keyword, the title and abstract are
+// searched.
+func expansionFields(query cqr.CommonQueryRepresentation) []string {
+	if f := freeTextFields(query); len(f) > 0 {
+		return f
+	}
+	return []string{fields.TitleAbstract}
+}
+
+// freeTextFields are the fields of the first keyword of a query that is not a MeSH heading or publication type.
+func freeTextFields(query cqr.CommonQueryRepresentation) []string {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		for _, field := range q.Fields {
+			switch field {
+			case fields.MeshHeadings, fields.MeSHTerms, fields.MeSHMajorTopic, fields.FloatingMeshHeadings,
+				fields.MeSHSubheading, fields.MajorFocusMeshHeading, fields.PublicationType:
+				return nil
+			}
+		}
+		return q.Fields
+	case cqr.BooleanQuery:
+		for _, child := range q.Children {
+			if f := freeTextFields(child); len(f) > 0 {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// argmax is the index of the largest value, preferring the first.
+func argmax(values []int) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
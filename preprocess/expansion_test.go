@@ -0,0 +1,196 @@
+package preprocess
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/trecresults"
+	"strings"
+	"testing"
+)
+
+// feedbackSource is a statistics source of a handful of documents, each retrieved by any query.
+type feedbackSource struct {
+	docs map[string][]string
+	ids  []string
+	ttf  map[string]float64
+	df   map[string]float64
+}
+
+func (s feedbackSource) SearchOptions() stats.SearchOptions { return stats.SearchOptions{Size: 10} }
+func (s feedbackSource) Parameters() map[string]float64     { return nil }
+func (s feedbackSource) TermFrequency(term, field, document string) (float64, error) {
+	return 0, nil
+}
+func (s feedbackSource) TermVector(document string) (stats.TermVector, error) {
+	var tv stats.TermVector
+	for _, term := range s.docs[document] {
+		tv = append(tv, stats.TermVectorTerm{
+			Term:               term,
+			Field:              fields.TitleAbstract,
+			TermFrequency:      1,
+			TotalTermFrequency: s.ttf[term],
+			DocumentFrequency:  s.df[term],
+		})
+	}
+	return tv, nil
+}
+func (s feedbackSource) DocumentFrequency(term, field string) (float64, error) {
+	return s.df[term], nil
+}
+func (s feedbackSource) TotalTermFrequency(term, field string) (float64, error) {
+	return s.ttf[term], nil
+}
+func (s feedbackSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	return 0, nil
+}
+func (s feedbackSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
+	return float64(len(s.ids)), nil
+}
+func (s feedbackSource) VocabularySize(field string) (float64, error) { return 1000, nil }
+func (s feedbackSource) Execute(query pipeline.Query, options stats.SearchOptions) (trecresults.ResultList, error) {
+	var results trecresults.ResultList
+	for i, id := range s.ids {
+		results = append(results, &trecresults.Result{Topic: query.Topic, DocId: id, Rank: int64(i + 1), Score: 1})
+	}
+	return results, nil
+}
+func (s feedbackSource) CollectionSize() (float64, error) { return 1000, nil }
+
+// stemmingSource is a feedback source whose term vectors are of stemmed text.
+type stemmingSource struct {
+	feedbackSource
+}
+
+func (s stemmingSource) Analyse(text, analyser string) ([]string, error) {
+	return strings.Fields(text), nil
+}
+
+// render writes a query as nested operators and keywords.
+func render(q cqr.CommonQueryRepresentation) string {
+	switch q := q.(type) {
+	case cqr.Keyword:
+		return q.QueryString
+	case cqr.BooleanQuery:
+		s := make([]string, len(q.Children))
+		for i, child := range q.Children {
+			s[i] = render(child)
+		}
+		return "(" + q.Operator + " " + strings.Join(s, " ") + ")"
+	}
+	return ""
+}
+
+func TestPRFExpansion(t *testing.T) {
+	s := feedbackSource{
+		docs: map[string][]string{
+			"1": {"heart", "attack", "myocardial", "infarction", "aspirin", "the"},
+			"2": {"mi", "myocardial", "infarction"},
+			"3": {"aspirin", "acetylsalicylic", "2018"},
+			"4": {"aspirin", "acetylsalicylic", "heart"},
+		},
+		ids: []string{"1", "2", "3", "4"},
+		ttf: map[string]float64{"heart": 50, "attack": 50, "mi": 50, "aspirin": 20, "the": 900, "2018": 10,
+			"myocardial": 10, "infarction": 20, "acetylsalicylic": 5},
+		df: map[string]float64{"heart": 40, "attack": 40, "mi": 40, "aspirin": 15, "the": 900, "2018": 10,
+			"myocardial": 10, "infarction": 20, "acetylsalicylic": 5},
+	}
+	query := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword(`"heart attack"`, fields.Title, fields.Abstract),
+			cqr.NewKeyword("mi", fields.Title, fields.Abstract),
+		}),
+		cqr.NewKeyword("aspirin", fields.MeshHeadings),
+	})
+	original := render(query)
+
+	tests := []struct {
+		name     string
+		options  []func(*PRFExpansion)
+		expected string
+	}{
+		{
+			name:     "rm3",
+			options:  []func(*PRFExpansion){PRFTerms(2), PRFField(fields.TitleAbstract)},
+			expected: `(and (or "heart attack" mi myocardial) (or aspirin acetylsalicylic))`,
+		},
+		{
+			name:     "best",
+			options:  []func(*PRFExpansion){PRFTerms(2), PRFField(fields.TitleAbstract), PRFInsertion(InsertBestClause)},
+			expected: `(and (or "heart attack" mi acetylsalicylic myocardial) aspirin)`,
+		},
+		{
+			name:     "rocchio",
+			options:  []func(*PRFExpansion){PRFTerms(1), PRFMethod(RocchioExpansion), PRFInsertion(InsertAllClauses)},
+			expected: `(and (or "heart attack" mi acetylsalicylic) (or aspirin acetylsalicylic))`,
+		},
+		{
+			name:     "documents",
+			options:  []func(*PRFExpansion){PRFTerms(1), PRFField(fields.TitleAbstract), PRFDocuments(2)},
+			expected: `(and (or "heart attack" mi myocardial) aspirin)`,
+		},
+	}
+	for _, test := range tests {
+		q := NewPRFExpansion(s, test.options...).Expand(query, "1")()
+		if got := render(q); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+		}
+		if got := render(query); got != original {
+			t.Fatalf("%s: expected the original query to be unchanged, got %s", test.name, got)
+		}
+	}
+
+	q := NewPRFExpansion(s, PRFTerms(2), PRFField(fields.TitleAbstract)).Expand(query, "1")().(cqr.BooleanQuery)
+	k := q.Children[1].(cqr.BooleanQuery).Children[1].(cqr.Keyword)
+	if len(k.Fields) != 1 || k.Fields[0] != fields.TitleAbstract {
+		t.Errorf("expected a term added to a MeSH clause to search the title and abstract, got %v", k.Fields)
+	}
+	k = q.Children[0].(cqr.BooleanQuery).Children[2].(cqr.Keyword)
+	if len(k.Fields) != 2 || k.Fields[0] != fields.Title {
+		t.Errorf("expected a term to search the fields of its siblings, got %v", k.Fields)
+	}
+
+	// Without a field, the vocabulary of the collection language model is unknown.
+	if _, err := NewPRFExpansion(s).expand(query, "1"); err == nil {
+		t.Errorf("expected rm3 expansion without a field to be an error")
+	}
+	// Only the terms of the field are expansion terms.
+	if q := NewPRFExpansion(s, PRFField(fields.Title)).Expand(query, "1")(); render(q) != original {
+		t.Errorf("expected no expansion terms from an empty field, got %s", render(q))
+	}
+}
+
+func TestPRFExpansionStems(t *testing.T) {
+	s := stemmingSource{feedbackSource{
+		docs: map[string][]string{
+			"1": {"insulin", "diabet", "glucos"},
+			"2": {"insulin", "diabet"},
+		},
+		ids: []string{"1", "2"},
+		ttf: map[string]float64{"insulin": 10, "diabet": 20, "glucos": 50},
+		df:  map[string]float64{"insulin": 10, "diabet": 20, "glucos": 50},
+	}}
+	query := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("insulin", fields.TitleAbstract),
+	})
+
+	// Stems are truncated so that they match the words they were stemmed from (e.g. diabetes and diabetic).
+	q := NewPRFExpansion(s, PRFTerms(1), PRFField(fields.TitleAbstract)).Expand(query, "1")()
+	if got := render(q); got != "(and (or insulin diabet*))" {
+		t.Errorf("expected the stem to be truncated, got %s", got)
+	}
+	k := q.(cqr.BooleanQuery).Children[0].(cqr.BooleanQuery).Children[1].(cqr.Keyword)
+	if truncated, ok := k.Options["truncated"].(bool); !ok || !truncated {
+		t.Errorf("expected the expansion term to be a truncated keyword, got %v", k.Options)
+	}
+
+	q = NewPRFExpansion(s, PRFTerms(1), PRFField(fields.TitleAbstract), PRFTruncate(false)).Expand(query, "1")()
+	if got := render(q); got != "(and (or insulin diabet))" {
+		t.Errorf("expected the stem not to be truncated, got %s", got)
+	}
+	q = NewPRFExpansion(s.feedbackSource, PRFTerms(1), PRFField(fields.TitleAbstract)).Expand(query, "1")()
+	if got := render(q); got != "(and (or insulin diabet))" {
+		t.Errorf("expected terms of a source that does not analyse text not to be truncated, got %s", got)
+	}
+}
@@ -0,0 +1,233 @@
+package preprocess
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilterCombination is how a search filter is combined with a query.
+type FilterCombination string
+
+const (
+	// FilterAnd restricts a query to the studies the filter retrieves.
+	FilterAnd FilterCombination = "and"
+	// FilterNot removes the studies the filter retrieves from a query (e.g. studies of animals but not humans).
+	FilterNot FilterCombination = "not"
+)
+
+// SearchFilter is a search strategy that is combined with queries to restrict them to a type of study, population,
+// or language (e.g. the Cochrane Highly Sensitive Search Strategy for randomised controlled trials).
+type SearchFilter struct {
+	Name        string
+	Description string
+	Syntax      string
+	Combination FilterCombination
+	Query       cqr.CommonQueryRepresentation
+}
+
+// ReadSearchFilter reads a search filter written in PubMed or Medline syntax. The filter may be preceded by comment
+// lines starting with `#`, which describe the filter, and directives of the form `# key: value`, e.g.:
+//
+//	# Cochrane Highly Sensitive Search Strategy (sensitivity-maximising version, 2008 revision).
+//	# syntax: pubmed
+//	# combine: and
+//	randomized controlled trial[pt] OR controlled clinical trial[pt] OR randomized[tiab] OR placebo[tiab]
+//
+// The syntax directive is either pubmed or medline; if it is missing, the syntax is detected. The combine directive
+// is either and (the default) or not.
+func ReadSearchFilter(name string, r io.Reader) (SearchFilter, error) {
+	f := SearchFilter{Name: name, Combination: FilterAnd}
+	var description, lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if len(lines) > 0 || !(trimmed == "#" || strings.HasPrefix(trimmed, "# ")) {
+			lines = append(lines, line)
+			continue
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+		if i := strings.Index(comment, ":"); i > 0 {
+			value := strings.TrimSpace(comment[i+1:])
+			switch strings.ToLower(comment[:i]) {
+			case "syntax":
+				f.Syntax = strings.ToLower(value)
+				continue
+			case "combine":
+				f.Combination = FilterCombination(strings.ToLower(value))
+				continue
+			}
+		}
+		if len(comment) > 0 {
+			description = append(description, comment)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return SearchFilter{}, err
+	}
+	f.Description = strings.Join(description, " ")
+
+	switch f.Combination {
+	case FilterAnd, FilterNot:
+	default:
+		return SearchFilter{}, fmt.Errorf("filter %s: unknown combination %s", name, f.Combination)
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(f.Syntax) == 0 {
+		f.Syntax = query.DetectSyntax(text)
+	}
+	switch f.Syntax {
+	case query.SyntaxPubMed, query.SyntaxMedline:
+	default:
+		return SearchFilter{}, fmt.Errorf("filter %s: unsupported syntax %s", name, f.Syntax)
+	}
+	q, err := query.ParseQuery(f.Syntax, text)
+	if err != nil {
+		return SearchFilter{}, fmt.Errorf("filter %s: %v", name, err)
+	}
+	f.Query = q
+	return f, nil
+}
+
+// Apply is a BooleanTransformation that combines a query with the filter.
+func (f SearchFilter) Apply(q cqr.CommonQueryRepresentation, _ string) Transformation {
+	return func() cqr.CommonQueryRepresentation {
+		return cqr.NewBooleanQuery(string(f.Combination), []cqr.CommonQueryRepresentation{q, f.Query})
+	}
+}
+
+// FilterLibrary is a collection of search filters, keyed by name.
+type FilterLibrary map[string]SearchFilter
+
+// LoadFilterLibrary loads every search filter in a directory (see ReadSearchFilter). The name of each filter is the
+// name of its file, without the extension. Hidden files and subdirectories are ignored.
+func LoadFilterLibrary(dir string) (FilterLibrary, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	library := make(FilterLibrary)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		if _, ok := library[name]; ok {
+			return nil, fmt.Errorf("filter %s is defined more than once in %s", name, dir)
+		}
+		r, err := os.Open(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		f, err := ReadSearchFilter(name, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		library[name] = f
+	}
+	return library, nil
+}
+
+// Names are the names of the filters in the library, in alphabetical order.
+func (l FilterLibrary) Names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilterEvaluation is how many of the relevant studies retrieved by a query are kept when a search filter is applied
+// to it (the sensitivity of the filter), and how many fewer studies are retrieved (the reduction in workload).
+type FilterEvaluation struct {
+	Filter      string  `json:"filter"`
+	Topic       string  `json:"topic"`
+	Relevant    int     `json:"relevant"`
+	Kept        int     `json:"kept"`
+	Retrieved   int     `json:"retrieved"`
+	Filtered    int     `json:"filtered"`
+	Sensitivity float64 `json:"sensitivity"`
+	Reduction   float64 `json:"reduction"`
+}
+
+func newFilterEvaluation(filter, topic string, relevant, kept, retrieved, filtered int) FilterEvaluation {
+	e := FilterEvaluation{
+		Filter:    filter,
+		Topic:     topic,
+		Relevant:  relevant,
+		Kept:      kept,
+		Retrieved: retrieved,
+		Filtered:  filtered,
+	}
+	if relevant > 0 {
+		e.Sensitivity = float64(kept) / float64(relevant)
+	}
+	if retrieved > 0 {
+		e.Reduction = 1 - float64(filtered)/float64(retrieved)
+	}
+	return e
+}
+
+// EvaluateFilters evaluates search filters by applying them to queries with relevance assessments. The sensitivity
+// of a filter is the proportion of the relevant studies retrieved by a query that are also retrieved once the filter
+// is applied. The evaluation of each filter on each topic is followed by the evaluation of each filter over all
+// topics (with the topic "all"). Topics without relevance assessments are skipped.
+func EvaluateFilters(ss stats.StatisticsSource, queries []pipeline.Query, qrels trecresults.QrelsFile, filters ...SearchFilter) ([]FilterEvaluation, error) {
+	var evaluations []FilterEvaluation
+	totals := make([][4]int, len(filters))
+	for _, q := range queries {
+		topicQrels, ok := qrels.Qrels[q.Topic]
+		if !ok {
+			continue
+		}
+		retrieved, err := stats.GetDocumentIDs(q, ss)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		relevant := relevantDocuments(retrieved, topicQrels)
+
+		for i, f := range filters {
+			filtered, err := stats.GetDocumentIDs(q.WithQuery(f.Apply(q.Query, q.Topic)()), ss)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: filter %s: %v", q.Topic, f.Name, err)
+			}
+			kept := relevantDocuments(filtered, topicQrels)
+			evaluations = append(evaluations, newFilterEvaluation(f.Name, q.Topic, relevant, kept, len(retrieved), len(filtered)))
+			totals[i][0] += relevant
+			totals[i][1] += kept
+			totals[i][2] += len(retrieved)
+			totals[i][3] += len(filtered)
+		}
+	}
+	for i, f := range filters {
+		evaluations = append(evaluations, newFilterEvaluation(f.Name, "all", totals[i][0], totals[i][1], totals[i][2], totals[i][3]))
+	}
+	return evaluations, nil
+}
+
+// relevantDocuments is the number of documents that are relevant.
+func relevantDocuments(docs []uint32, qrels trecresults.Qrels) int {
+	n := 0
+	for _, doc := range docs {
+		if qrel, ok := qrels[strconv.FormatUint(uint64(doc), 10)]; ok && qrel.Score > eval.RelevanceGrade {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,158 @@
+package preprocess
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/trecresults"
+	"strings"
+	"testing"
+)
+
+func TestReadSearchFilter(t *testing.T) {
+	f, err := ReadSearchFilter("rct", strings.NewReader(`# Randomised controlled trials,
+# in PubMed format.
+#
+# syntax: pubmed
+# combine: not
+randomized[tiab] OR placebo[tiab]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "rct" || f.Syntax != "pubmed" || f.Combination != FilterNot {
+		t.Errorf("expected the name, syntax and combination to be read, got %s, %s and %s", f.Name, f.Syntax, f.Combination)
+	}
+	if f.Description != "Randomised controlled trials, in PubMed format." {
+		t.Errorf("expected the comments to describe the filter, got %q", f.Description)
+	}
+	q, ok := f.Query.(cqr.BooleanQuery)
+	if !ok || len(q.Children) != 2 {
+		t.Fatalf("expected the filter to be parsed into an or clause, got %v", f.Query)
+	}
+
+	_, err = ReadSearchFilter("rct", strings.NewReader("# combine: or\nrandomized[tiab]\n"))
+	if err == nil {
+		t.Errorf("expected an error for an unknown combination")
+	}
+}
+
+// filterSource retrieves a set of documents for a query, and a subset of them once it is filtered.
+type filterSource struct {
+	feedbackSource
+	retrieved, filtered []string
+}
+
+func (s filterSource) Execute(query pipeline.Query, options stats.SearchOptions) (trecresults.ResultList, error) {
+	ids := s.retrieved
+	if q, ok := query.Query.(cqr.BooleanQuery); ok && q.Operator == cqr.AND {
+		ids = s.filtered
+	}
+	var results trecresults.ResultList
+	for _, id := range ids {
+		results = append(results, &trecresults.Result{Topic: query.Topic, DocId: id})
+	}
+	return results, nil
+}
+
+func TestEvaluateFilters(t *testing.T) {
+	s := filterSource{
+		retrieved: []string{"1", "2", "3", "4", "5", "6", "7", "8"},
+		filtered:  []string{"1", "2", "5"},
+	}
+	f := SearchFilter{Name: "rct", Combination: FilterAnd, Query: cqr.NewKeyword("randomized", fields.TitleAbstract)}
+	queries := []pipeline.Query{
+		pipeline.NewQuery("1", "1", cqr.NewKeyword("stroke", fields.TitleAbstract)),
+		pipeline.NewQuery("2", "2", cqr.NewKeyword("aspirin", fields.TitleAbstract)),
+	}
+	qrels := trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{
+		"1": {
+			"1": &trecresults.Qrel{Topic: "1", DocId: "1", Score: 2},
+			"3": &trecresults.Qrel{Topic: "1", DocId: "3", Score: 2},
+			"4": &trecresults.Qrel{Topic: "1", DocId: "4", Score: 0},
+		},
+	}}
+
+	evaluations, err := EvaluateFilters(s, queries, qrels, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evaluations) != 2 {
+		t.Fatalf("expected an evaluation of the topic with qrels and over all topics, got %d", len(evaluations))
+	}
+	e := evaluations[0]
+	if e.Topic != "1" || e.Relevant != 2 || e.Kept != 1 || e.Retrieved != 8 || e.Filtered != 3 {
+		t.Errorf("unexpected evaluation %+v", e)
+	}
+	if e.Sensitivity != 0.5 || e.Reduction != 0.625 {
+		t.Errorf("expected a sensitivity of 0.5 and reduction of 0.625, got %f and %f", e.Sensitivity, e.Reduction)
+	}
+	if evaluations[1].Topic != "all" || evaluations[1].Sensitivity != 0.5 {
+		t.Errorf("unexpected evaluation over all topics %+v", evaluations[1])
+	}
+
+	q := f.Apply(queries[0].Query, "1")().(cqr.BooleanQuery)
+	if q.Operator != cqr.AND || len(q.Children) != 2 {
+		t.Errorf("expected the filter to be combined with the query using and, got %v", q)
+	}
+}
+
+// filterKeywords are the keywords of a search filter.
+func filterKeywords(q cqr.CommonQueryRepresentation) []cqr.Keyword {
+	switch q := q.(type) {
+	case cqr.Keyword:
+		return []cqr.Keyword{q}
+	case cqr.BooleanQuery:
+		var keywords []cqr.Keyword
+		for _, child := range q.Children {
+			keywords = append(keywords, filterKeywords(child)...)
+		}
+		return keywords
+	}
+	return nil
+}
+
+func TestLoadFilterLibrary(t *testing.T) {
+	library, err := LoadFilterLibrary("../filters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"diagnostic_accuracy", "english", "humans", "rct_precise", "rct_sensitive", "rct_sign"}
+	if got := library.Names(); strings.Join(got, " ") != strings.Join(names, " ") {
+		t.Fatalf("expected the filters %v, got %v", names, got)
+	}
+	if f := library["humans"]; f.Combination != FilterNot {
+		t.Errorf("expected the humans filter to be combined with not, got %s", f.Combination)
+	}
+
+	// The SIGN filter is in Medline syntax, with `$` truncation and a heading without a field (letter/).
+	sign := library["rct_sign"]
+	if sign.Syntax != "medline" || sign.Combination != FilterAnd {
+		t.Errorf("expected a Medline filter combined with and, got %s and %s", sign.Syntax, sign.Combination)
+	}
+	var letter, truncated bool
+	for _, k := range filterKeywords(sign.Query) {
+		if strings.EqualFold(k.QueryString, "letter") {
+			letter = true
+		}
+		if v, ok := k.Options["truncated"].(bool); v && ok {
+			truncated = true
+		}
+	}
+	if !letter || !truncated {
+		t.Errorf("expected the SIGN filter to have the letter heading and truncated terms, got %v", sign.Query)
+	}
+
+	// Subheadings are not exploded ([sh:noexp]).
+	var subheading bool
+	for _, k := range filterKeywords(library["diagnostic_accuracy"].Query) {
+		exploded, ok := k.Options[cqr.ExplodedString].(bool)
+		if strings.EqualFold(k.QueryString, "diagnosis") && len(k.Fields) == 1 && k.Fields[0] == fields.MeSHSubheading {
+			subheading = ok && !exploded
+		}
+	}
+	if !subheading {
+		t.Errorf("expected the diagnosis subheading not to be exploded, got %v", library["diagnostic_accuracy"].Query)
+	}
+}
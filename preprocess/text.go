@@ -0,0 +1,302 @@
+package preprocess
+
+import (
+	"github.com/kljensen/snowball/english"
+	"github.com/reiver/go-porterstemmer"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Stemmer reduces a single word to its stem.
+type Stemmer func(word string) string
+
+// TokenProcessor is applied to each individual token of a query. Returning an empty string removes the token.
+type TokenProcessor func(token string) string
+
+// StopwordList is a set of words to remove from queries.
+type StopwordList map[string]bool
+
+var (
+	// PorterStemmer stems words using the original Porter algorithm.
+	PorterStemmer Stemmer = func(word string) string {
+		return porterstemmer.StemString(word)
+	}
+	// SnowballStemmer stems words using the English Snowball (Porter2) algorithm.
+	SnowballStemmer Stemmer = func(word string) string {
+		return english.Stem(word, false)
+	}
+
+	// GeneralStopwords is a general-purpose English stopword list.
+	GeneralStopwords = NewStopwordList(
+		"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as", "at", "be",
+		"because", "been", "before", "being", "below", "between", "both", "but", "by", "can", "could", "did", "do",
+		"does", "doing", "down", "during", "each", "few", "for", "from", "further", "had", "has", "have", "having",
+		"he", "her", "here", "hers", "herself", "him", "himself", "his", "how", "i", "if", "in", "into", "is", "it",
+		"its", "itself", "just", "me", "more", "most", "my", "myself", "no", "nor", "not", "now", "of", "off", "on",
+		"once", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own", "same", "she", "should", "so",
+		"some", "such", "than", "that", "the", "their", "theirs", "them", "themselves", "then", "there", "these",
+		"they", "this", "those", "through", "to", "too", "under", "until", "up", "very", "was", "we", "were", "what",
+		"when", "where", "which", "while", "who", "whom", "why", "will", "with", "would", "you", "your", "yours",
+		"yourself", "yourselves",
+	)
+	// PubMedStopwords is the stopword list used by PubMed when processing queries.
+	PubMedStopwords = NewStopwordList(
+		"a", "about", "again", "all", "almost", "also", "although", "always", "among", "an", "and", "another", "any",
+		"are", "as", "at", "be", "because", "been", "before", "being", "between", "both", "but", "by", "can", "could",
+		"did", "do", "does", "done", "due", "during", "each", "either", "enough", "especially", "etc", "for", "found",
+		"from", "further", "had", "has", "have", "having", "here", "how", "however", "i", "if", "in", "into", "is",
+		"it", "its", "itself", "just", "kg", "km", "made", "mainly", "make", "may", "mg", "might", "ml", "mm", "most",
+		"mostly", "must", "nearly", "neither", "no", "nor", "obtained", "of", "often", "on", "our", "overall",
+		"perhaps", "pmid", "quite", "rather", "really", "regarding", "seem", "seen", "several", "should", "show",
+		"showed", "shown", "shows", "significantly", "since", "so", "some", "such", "than", "that", "the", "their",
+		"theirs", "them", "then", "there", "therefore", "these", "they", "this", "those", "through", "thus", "to",
+		"upon", "use", "used", "using", "various", "very", "was", "we", "were", "what", "when", "which", "while",
+		"with", "within", "without", "would",
+	)
+
+	// spellingPrefixes are British and American spellings of medical morphemes that differ only at the start of a
+	// word and do not begin unrelated words, so any inflection may follow them.
+	spellingPrefixes = [][2]string{
+		{"haemorrh", "hemorrh"}, {"haemat", "hemat"}, {"haemoglob", "hemoglob"}, {"haemolys", "hemolys"},
+		{"haemodial", "hemodial"}, {"haemodynam", "hemodynam"}, {"haemophil", "hemophil"}, {"haemostat", "hemostat"},
+		{"anaemi", "anemi"}, {"paediatr", "pediatr"}, {"orthopaed", "orthoped"}, {"gynaecolog", "gynecolog"},
+		{"leukaemi", "leukemi"}, {"ischaemi", "ischemi"}, {"anaesthe", "anesthe"}, {"caesarean", "cesarean"},
+		{"oedema", "edema"}, {"oesophag", "esophag"}, {"oestrogen", "estrogen"}, {"oestradiol", "estradiol"},
+		{"foetal", "fetal"}, {"foetus", "fetus"}, {"diarrhoe", "diarrhe"}, {"faecal", "fecal"}, {"faeces", "feces"},
+	}
+
+	// The inflections of the British and American spellings of each kind of stem. A stem is only respelled when the
+	// rest of the word is exactly one of its inflections, so that unrelated words that share the stem (e.g.
+	// organism, characteristics, laboratory) are left alone.
+	iseInflections = [][2]string{
+		{"e", "e"}, {"es", "es"}, {"ed", "ed"}, {"ing", "ing"}, {"er", "er"}, {"ers", "ers"}, {"ation", "ation"},
+		{"ations", "ations"},
+	}
+	ourInflections = [][2]string{{"our", "or"}, {"ours", "ors"}, {"oured", "ored"}, {"ouring", "oring"}, {"oural", "oral"}}
+	reInflections  = [][2]string{{"re", "er"}, {"res", "ers"}, {"red", "ered"}, {"ring", "ering"}}
+	yseInflections = [][2]string{
+		{"se", "ze"}, {"ses", "zes"}, {"sed", "zed"}, {"sing", "zing"}, {"ser", "zer"}, {"sers", "zers"},
+	}
+	mmeInflections = [][2]string{{"me", ""}, {"mes", "s"}}
+
+	// spellingStems are the British and American stems of words that are spelled differently, and how they are
+	// inflected.
+	spellingStems = []struct {
+		stem        [2]string
+		inflections [][2]string
+	}{
+		{[2]string{"randomis", "randomiz"}, iseInflections}, {[2]string{"hospitalis", "hospitaliz"}, iseInflections},
+		{[2]string{"immunis", "immuniz"}, iseInflections}, {[2]string{"organis", "organiz"}, iseInflections},
+		{[2]string{"optimis", "optimiz"}, iseInflections}, {[2]string{"standardis", "standardiz"}, iseInflections},
+		{[2]string{"characteris", "characteriz"}, iseInflections}, {[2]string{"stabilis", "stabiliz"}, iseInflections},
+		{[2]string{"sterilis", "steriliz"}, iseInflections}, {[2]string{"utilis", "utiliz"}, iseInflections},
+		{[2]string{"visualis", "visualiz"}, iseInflections}, {[2]string{"minimis", "minimiz"}, iseInflections},
+		{[2]string{"categoris", "categoriz"}, iseInflections},
+		{[2]string{"tum", "tum"}, ourInflections}, {[2]string{"behavi", "behavi"}, ourInflections},
+		{[2]string{"col", "col"}, ourInflections}, {[2]string{"lab", "lab"}, ourInflections},
+		{[2]string{"fav", "fav"}, ourInflections}, {[2]string{"od", "od"}, ourInflections},
+		{[2]string{"cent", "cent"}, reInflections}, {[2]string{"fib", "fib"}, reInflections},
+		{[2]string{"lit", "lit"}, reInflections},
+		{[2]string{"analy", "analy"}, yseInflections}, {[2]string{"paraly", "paraly"}, yseInflections},
+		{[2]string{"cataly", "cataly"}, yseInflections},
+		{[2]string{"program", "program"}, mmeInflections},
+	}
+
+	// quotes maps typographic punctuation to its ASCII equivalent.
+	quotes = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`, "«", `"`, "»", `"`,
+		"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "−", "-",
+		"\u00a0", " ", "…", "...",
+		"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ø", "o", "Ø", "O",
+	)
+)
+
+// NewStopwordList creates a stopword list from the given words.
+func NewStopwordList(words ...string) StopwordList {
+	l := make(StopwordList, len(words))
+	for _, word := range words {
+		l[strings.ToLower(word)] = true
+	}
+	return l
+}
+
+// Chain composes several query processors into one, applying them in order.
+func Chain(processors ...QueryProcessor) QueryProcessor {
+	return func(text string) string {
+		for _, processor := range processors {
+			text = processor(text)
+		}
+		return text
+	}
+}
+
+// Tokenise splits text on whitespace, keeping quoted phrases together as a single token.
+func Tokenise(text string) []string {
+	var (
+		tokens []string
+		token  strings.Builder
+		quoted bool
+	)
+	for _, r := range text {
+		switch {
+		case r == '"':
+			token.WriteRune(r)
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// isProtected reports whether a token must be left as-is: quoted phrases, and terms using truncation or
+// wildcards.
+func isProtected(token string) bool {
+	return strings.ContainsAny(token, `"*?$#`)
+}
+
+// isWordRune reports whether r is part of a word rather than surrounding punctuation.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Tokens creates a query processor that applies a token processor to each token in a query. Quoted phrases and
+// terms containing wildcards are not passed to the token processor, and punctuation surrounding a token is kept.
+func Tokens(processor TokenProcessor) QueryProcessor {
+	return func(text string) string {
+		tokens := Tokenise(text)
+		processed := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			if isProtected(token) {
+				processed = append(processed, token)
+				continue
+			}
+			start := strings.IndexFunc(token, isWordRune)
+			if start < 0 {
+				processed = append(processed, token)
+				continue
+			}
+			end := strings.LastIndexFunc(token, isWordRune)
+			_, size := utf8.DecodeRuneInString(token[end:])
+			end += size
+			word := processor(token[start:end])
+			if len(word) == 0 {
+				continue
+			}
+			processed = append(processed, token[:start]+word+token[end:])
+		}
+		return strings.Join(processed, " ")
+	}
+}
+
+// Stem creates a query processor that stems each term in a query.
+func Stem(stemmer Stemmer) QueryProcessor {
+	return Tokens(func(token string) string {
+		return stemmer(strings.ToLower(token))
+	})
+}
+
+// PorterStem stems each term in a query using the Porter stemmer.
+func PorterStem(text string) string {
+	return Stem(PorterStemmer)(text)
+}
+
+// SnowballStem stems each term in a query using the English Snowball stemmer.
+func SnowballStem(text string) string {
+	return Stem(SnowballStemmer)(text)
+}
+
+// RemoveStopwords creates a query processor that removes terms found in any of the stopword lists.
+func RemoveStopwords(lists ...StopwordList) QueryProcessor {
+	return Tokens(func(token string) string {
+		t := strings.ToLower(token)
+		for _, list := range lists {
+			if list[t] {
+				return ""
+			}
+		}
+		return token
+	})
+}
+
+// RemoveGeneralStopwords removes general English stopwords from a query.
+func RemoveGeneralStopwords(text string) string {
+	return RemoveStopwords(GeneralStopwords)(text)
+}
+
+// RemovePubMedStopwords removes PubMed stopwords from a query.
+func RemovePubMedStopwords(text string) string {
+	return RemoveStopwords(PubMedStopwords)(text)
+}
+
+// respell replaces the spelling of a word, where from and to are the indices into the spelling tables. The word keeps
+// its capitalisation.
+func respell(token string, from, to int) string {
+	t := strings.ToLower(token)
+	for _, pair := range spellingPrefixes {
+		if strings.HasPrefix(t, pair[from]) {
+			return matchCase(pair[to]+t[len(pair[from]):], token)
+		}
+	}
+	for _, spelling := range spellingStems {
+		if !strings.HasPrefix(t, spelling.stem[from]) {
+			continue
+		}
+		suffix := t[len(spelling.stem[from]):]
+		for _, inflection := range spelling.inflections {
+			if suffix == inflection[from] {
+				return matchCase(spelling.stem[to]+inflection[to], token)
+			}
+		}
+	}
+	return token
+}
+
+// matchCase capitalises a lowercase word like another word: entirely in upper case, or with an initial capital.
+func matchCase(word, like string) string {
+	r, _ := utf8.DecodeRuneInString(like)
+	switch {
+	case like == strings.ToUpper(like):
+		return strings.ToUpper(word)
+	case unicode.IsUpper(r):
+		return strings.ToUpper(word[:1]) + word[1:]
+	}
+	return word
+}
+
+// AmericanSpelling normalises British spellings of terms to American spellings.
+func AmericanSpelling(text string) string {
+	return Tokens(func(token string) string {
+		return respell(token, 0, 1)
+	})(text)
+}
+
+// BritishSpelling normalises American spellings of terms to British spellings.
+func BritishSpelling(text string) string {
+	return Tokens(func(token string) string {
+		return respell(token, 1, 0)
+	})(text)
+}
+
+// FoldUnicode replaces typographic quotes and dashes with their ASCII equivalents and removes diacritics.
+func FoldUnicode(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, quotes.Replace(text))
+	if err != nil {
+		return text
+	}
+	return folded
+}
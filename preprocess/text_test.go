@@ -0,0 +1,70 @@
+package preprocess
+
+import "testing"
+
+func TestTokens(t *testing.T) {
+	processor := Chain(FoldUnicode, RemoveGeneralStopwords, PorterStem)
+	tests := map[string]string{
+		"the heart attacks":           "heart attack",
+		`cardio* and “heart attacks”`: `cardio* "heart attacks"`,
+		"(attacks),":                  "(attack),",
+		"naïve":                       "naiv",
+	}
+	for text, want := range tests {
+		if got := processor(text); got != want {
+			t.Errorf("processor(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSpelling(t *testing.T) {
+	if got := AmericanSpelling("Haemorrhage tumours laboratory labour"); got != "Hemorrhage tumors laboratory labor" {
+		t.Errorf("AmericanSpelling = %q", got)
+	}
+	if got := BritishSpelling("hemorrhage tumors laboratory labor hemisphere"); got != "haemorrhage tumours laboratory labour hemisphere" {
+		t.Errorf("BritishSpelling = %q", got)
+	}
+}
+
+func TestSpellingWords(t *testing.T) {
+	american := map[string]string{
+		"organism":        "organism",
+		"organisation":    "organization",
+		"organised":       "organized",
+		"characteristics": "characteristics",
+		"characterised":   "characterized",
+		"optimism":        "optimism",
+		"optimising":      "optimizing",
+		"centred":         "centered",
+		"centres":         "centers",
+		"behavioural":     "behavioral",
+		"programmes":      "programs",
+		"analysed":        "analyzed",
+		"Tumours":         "Tumors",
+		"LABOUR":          "LABOR",
+	}
+	for word, want := range american {
+		if got := AmericanSpelling(word); got != want {
+			t.Errorf("AmericanSpelling(%q) = %q, want %q", word, got, want)
+		}
+	}
+
+	british := map[string]string{
+		"organism":        "organism",
+		"organization":    "organisation",
+		"characteristics": "characteristics",
+		"optimism":        "optimism",
+		"centered":        "centred",
+		"center":          "centre",
+		"colors":          "colours",
+		"litter":          "litter",
+		"programs":        "programmes",
+		"programmed":      "programmed",
+		"analyzing":       "analysing",
+	}
+	for word, want := range british {
+		if got := BritishSpelling(word); got != want {
+			t.Errorf("BritishSpelling(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package preprocess
 
 import (
 	"github.com/hscells/cqr"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/transmute/fields"
 	"strings"
 )
@@ -12,6 +14,9 @@ type Transformation func() cqr.CommonQueryRepresentation
 // BooleanTransformation is a transformation that can be made to a Boolean query.
 type BooleanTransformation func(q cqr.CommonQueryRepresentation, topic string) Transformation
 
+// MetadataTransformation is a transformation that can be made to a query using the metadata attached to it.
+type MetadataTransformation func(q pipeline.Query) Transformation
+
 // Transformations is a collection of transformation operations.
 type Transformations []Transformation
 
@@ -21,6 +26,7 @@ type TransformedQueries []cqr.CommonQueryRepresentation
 // QueryTransformations is the information needed to perform query transformations.
 type QueryTransformations struct {
 	BooleanTransformations       []BooleanTransformation
+	MetadataTransformations      []MetadataTransformation
 	ElasticsearchTransformations []ElasticsearchTransformation
 	Output                       string
 }
@@ -46,6 +52,13 @@ func Simplify(query cqr.CommonQueryRepresentation, topic string) Transformation
 	}
 }
 
+// Normalise rewrites a query into its canonical form without changing the documents it retrieves.
+func Normalise(query cqr.CommonQueryRepresentation, _ string) Transformation {
+	return func() cqr.CommonQueryRepresentation {
+		return combinator.Normalise(query)
+	}
+}
+
 // RelaxPhrases replaces phrase queries with OR clauses.
 func RelaxPhrases(query cqr.CommonQueryRepresentation, topic string) Transformation {
 	return func() cqr.CommonQueryRepresentation {
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/hscells/groove/pipeline"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var clefSection = regexp.MustCompile(`^(Topic|Title|Query|Pids|Objective|Type|Protocol):\s*(.*)$`)
+
+// CLEFTARQuerySource loads topic files from the CLEF eHealth technology assisted review (TAR) tasks. Topic files
+// follow the structure of:
+//
+//	Topic: CD009551
+//
+//	Title: Polymerase chain reaction blood tests for the diagnosis of invasive aspergillosis in immunocompromised people
+//
+//	Query:
+//	exp Aspergillosis/
+//	...
+//
+//	Pids:
+//	    25815649
+//	    ...
+//
+// Topic files from 2017 and 2018 contain Ovid Medline queries, while topic files from 2019 may contain either
+// Ovid Medline or PubMed queries. Topics that do not contain a query (e.g. 2018 task 1) use the title as a keyword
+// query instead.
+type CLEFTARQuerySource struct {
+	year   int
+	fields []string
+}
+
+// LoadSingle loads a single CLEF TAR topic file.
+func (c CLEFTARQuerySource) LoadSingle(file string) (pipeline.Query, error) {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return pipeline.Query{}, err
+	}
+
+	var (
+		topic string
+		m     pipeline.Metadata
+	)
+	sections := make(map[string][]string)
+	var section string
+
+	s := bufio.NewScanner(bytes.NewReader(source))
+	for s.Scan() {
+		line := s.Text()
+		if m := clefSection.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			if v := strings.TrimSpace(m[2]); len(v) > 0 {
+				sections[section] = append(sections[section], v)
+			}
+			continue
+		}
+		if len(section) > 0 && len(strings.TrimSpace(line)) > 0 {
+			sections[section] = append(sections[section], line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return pipeline.Query{}, err
+	}
+
+	topic = strings.Join(sections["Topic"], " ")
+	if len(topic) == 0 {
+		_, topic = path.Split(file)
+	}
+	m.Title = strings.TrimSpace(strings.Join(sections["Title"], " "))
+	m.Description = strings.TrimSpace(strings.Join(sections["Objective"], "\n"))
+	for _, pid := range sections["Pids"] {
+		if pid = strings.TrimSpace(pid); len(pid) > 0 {
+			m.PIDs = append(m.PIDs, pid)
+		}
+	}
+	for _, key := range []string{"Type", "Protocol"} {
+		if v, ok := sections[key]; ok {
+			m.Set(strings.ToLower(key), strings.TrimSpace(strings.Join(v, "\n")))
+		}
+	}
+
+	m.Original = strings.Join(sections["Query"], "\n")
+	switch {
+	case len(strings.TrimSpace(m.Original)) == 0:
+		m.Syntax = SyntaxKeyword
+		m.Original = m.Title
+	case c.year >= 2019:
+		m.Syntax = DetectSyntax(m.Original)
+	default:
+		m.Syntax = SyntaxMedline
+	}
+
+	q, err := ParseQuery(m.Syntax, m.Original, c.fields...)
+	if err != nil {
+		return pipeline.Query{}, fmt.Errorf("topic %s: %v", topic, err)
+	}
+	return pipeline.NewQuery(m.Title, topic, q).WithMetadata(m), nil
+}
+
+// Load loads the queries of every CLEF TAR topic file in a directory.
+func (c CLEFTARQuerySource) Load(directory string) ([]pipeline.Query, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []pipeline.Query
+	for _, f := range files {
+		if f.IsDir() || len(f.Name()) == 0 {
+			continue
+		}
+		q, err := c.LoadSingle(path.Join(directory, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// NewCLEFTARQuerySource creates a query source for the topic files of the specified year of CLEF TAR. The fields are
+// used for the keyword queries of topics that do not contain a query.
+func NewCLEFTARQuerySource(year int, fields ...string) CLEFTARQuerySource {
+	return CLEFTARQuerySource{
+		year:   year,
+		fields: fields,
+	}
+}
@@ -0,0 +1,185 @@
+package query
+
+import (
+	"fmt"
+	"github.com/hscells/groove/pipeline"
+	"os"
+	"path"
+	"sort"
+)
+
+// QueryIterator lazily yields queries from a query source. Errors that occur while loading a single query (or a
+// single file of queries) are reported for that query only, and do not stop the iteration. The iterator is used as
+// follows:
+//
+//	for it.Next() {
+//		if err := it.Err(); err != nil {
+//			// Handle the error for this query.
+//			continue
+//		}
+//		q := it.Query()
+//	}
+//	err := it.Close()
+type QueryIterator interface {
+	// Next advances the iterator, returning false when there are no more queries.
+	Next() bool
+	// Query is the query the iterator is currently positioned at.
+	Query() pipeline.Query
+	// Err is the error raised loading the query the iterator is currently positioned at.
+	Err() error
+	// Close releases any resources held by the iterator.
+	Close() error
+}
+
+// IterableQueriesSource is a query source that is able to yield queries lazily.
+type IterableQueriesSource interface {
+	QueriesSource
+	// Iterate creates an iterator over the queries in a directory.
+	Iterate(directory string) (QueryIterator, error)
+}
+
+// SingleQueriesSource is a query source that loads exactly one query from each file.
+type SingleQueriesSource interface {
+	QueriesSource
+	// LoadSingle loads a query from a single file.
+	LoadSingle(file string) (pipeline.Query, error)
+}
+
+// FileError is an error raised loading a single file of queries.
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+// fileIterator yields queries from each file in a directory, in order of the names of the files, loading the queries
+// of each file only when the file is reached.
+type fileIterator struct {
+	directory string
+	names     []string
+	load      func(file string) ([]pipeline.Query, error)
+
+	pending []pipeline.Query
+	query   pipeline.Query
+	err     error
+}
+
+func (it *fileIterator) Next() bool {
+	for {
+		if len(it.pending) > 0 {
+			it.query, it.err = it.pending[0], nil
+			it.pending = it.pending[1:]
+			return true
+		}
+		if len(it.names) == 0 {
+			return false
+		}
+
+		name := it.names[0]
+		it.names = it.names[1:]
+		file := path.Join(it.directory, name)
+
+		fi, err := os.Stat(file)
+		if err != nil {
+			it.query, it.err = pipeline.Query{}, FileError{File: file, Err: err}
+			return true
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		queries, err := it.load(file)
+		if err != nil {
+			it.query, it.err = pipeline.Query{}, FileError{File: file, Err: err}
+			return true
+		}
+		it.pending = queries
+	}
+}
+
+func (it *fileIterator) Query() pipeline.Query {
+	return it.query
+}
+
+func (it *fileIterator) Err() error {
+	return it.err
+}
+
+func (it *fileIterator) Close() error {
+	it.names, it.pending = nil, nil
+	return nil
+}
+
+// NewFileQueryIterator creates an iterator that loads the queries of each file in a directory using the specified
+// function. The names of the files are listed (and sorted, so that runs are reproducible) up front, but the function
+// is only called once the iterator reaches the file.
+func NewFileQueryIterator(directory string, load func(file string) ([]pipeline.Query, error)) (QueryIterator, error) {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return &fileIterator{
+		directory: directory,
+		names:     names,
+		load:      load,
+	}, nil
+}
+
+// sliceIterator yields queries that have already been loaded.
+type sliceIterator struct {
+	queries []pipeline.Query
+	i       int
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.i >= len(it.queries) {
+		return false
+	}
+	it.i++
+	return true
+}
+
+func (it *sliceIterator) Query() pipeline.Query {
+	return it.queries[it.i-1]
+}
+
+func (it *sliceIterator) Err() error {
+	return nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// NewQueryIterator creates an iterator over the queries of any query source. Sources that can iterate over their
+// queries (IterableQueriesSource) or that load one query per file (SingleQueriesSource) are streamed, while all other
+// sources are loaded in full and then iterated over.
+func NewQueryIterator(qs QueriesSource, directory string) (QueryIterator, error) {
+	switch s := qs.(type) {
+	case IterableQueriesSource:
+		return s.Iterate(directory)
+	case SingleQueriesSource:
+		return NewFileQueryIterator(directory, func(file string) ([]pipeline.Query, error) {
+			q, err := s.LoadSingle(file)
+			if err != nil {
+				return nil, err
+			}
+			return []pipeline.Query{q}, nil
+		})
+	}
+
+	queries, err := qs.Load(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &sliceIterator{queries: queries}, nil
+}
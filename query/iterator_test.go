@@ -0,0 +1,110 @@
+package query_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/query"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// singleSource loads the name of each file as a query, failing for files named "bad".
+type singleSource struct{}
+
+func (s singleSource) Load(directory string) ([]pipeline.Query, error) {
+	return nil, errors.New("expected queries to be loaded one file at a time")
+}
+
+func (s singleSource) LoadSingle(file string) (pipeline.Query, error) {
+	name := filepath.Base(file)
+	if name == "bad" {
+		return pipeline.Query{}, errors.New("bad query")
+	}
+	return pipeline.Query{Topic: name}, nil
+}
+
+// sliceSource loads a fixed list of queries.
+type sliceSource []pipeline.Query
+
+func (s sliceSource) Load(directory string) ([]pipeline.Query, error) {
+	return s, nil
+}
+
+func TestFileQueryIterator(t *testing.T) {
+	// More files than are read from a directory at once, created out of order.
+	files := make(map[string]string)
+	for i := 1500; i > 0; i-- {
+		files[fmt.Sprintf("%04d", i)] = ""
+	}
+	files["bad"] = ""
+	dir := writeFiles(t, files)
+	if err := os.Mkdir(filepath.Join(dir, "0000"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := query.NewQueryIterator(singleSource{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topics []string
+	var failed []string
+	for it.Next() {
+		if err := it.Err(); err != nil {
+			var fe query.FileError
+			if !errors.As(err, &fe) {
+				t.Fatalf("expected a file error, got %v", err)
+			}
+			failed = append(failed, filepath.Base(fe.File))
+			continue
+		}
+		topics = append(topics, it.Query().Topic)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topics) != 1500 {
+		t.Fatalf("expected a query for every file except directories and failures, got %d", len(topics))
+	}
+	for i, topic := range topics {
+		if expected := fmt.Sprintf("%04d", i+1); topic != expected {
+			t.Fatalf("expected the files to be iterated over in order, got %s at %d", topic, i)
+		}
+	}
+	if len(failed) != 1 || failed[0] != "bad" {
+		t.Errorf("expected the failure of the bad file to be reported without stopping the iteration, got %v", failed)
+	}
+
+	_, err = query.NewFileQueryIterator(filepath.Join(dir, "missing"), func(file string) ([]pipeline.Query, error) {
+		return nil, nil
+	})
+	if err == nil {
+		t.Errorf("expected an error for a directory that cannot be read")
+	}
+}
+
+func TestNewQueryIterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	it, err := query.NewQueryIterator(sliceSource{{Topic: "1"}, {Topic: "2"}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topics []string
+	for it.Next() {
+		if it.Err() != nil {
+			t.Fatal(it.Err())
+		}
+		topics = append(topics, it.Query().Topic)
+	}
+	if len(topics) != 2 || topics[0] != "1" || topics[1] != "2" {
+		t.Errorf("expected the loaded queries to be iterated over, got %v", topics)
+	}
+}
@@ -0,0 +1,133 @@
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/groove/pipeline"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// jsonlTopic is a single line of a JSONL query file.
+type jsonlTopic struct {
+	Topic       string   `json:"topic"`
+	Name        string   `json:"name"`
+	Query       string   `json:"query"`
+	Syntax      string   `json:"syntax"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	PIDs        []string `json:"pids"`
+	DateFrom    string   `json:"date_from"`
+	DateTo      string   `json:"date_to"`
+}
+
+// jsonlKeys are the keys of a JSONL topic that are not considered extra information.
+var jsonlKeys = map[string]bool{
+	"topic": true, "name": true, "query": true, "syntax": true, "title": true,
+	"description": true, "pids": true, "date_from": true, "date_to": true,
+}
+
+// JSONLQuerySource loads queries from files where each line is a JSON object of the form:
+//
+//	{"topic": "CD009551", "name": "original", "query": "...", "syntax": "medline"}
+//
+// The syntax may be one of medline, pubmed, cqr, or keyword. The optional keys title, description, pids, date_from,
+// and date_to are also read, and any other keys are preserved as extra information about the topic.
+type JSONLQuerySource struct {
+	fields []string
+}
+
+// LoadFile loads all of the queries in a JSONL file.
+func (j JSONLQuerySource) LoadFile(file string) ([]pipeline.Query, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var queries []pipeline.Query
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	n := 0
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		var jt jsonlTopic
+		err := json.Unmarshal([]byte(line), &jt)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+		var raw map[string]interface{}
+		err = json.Unmarshal([]byte(line), &raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+
+		m := pipeline.Metadata{
+			Title:       jt.Title,
+			Description: jt.Description,
+			Syntax:      strings.ToLower(jt.Syntax),
+			Original:    jt.Query,
+			PIDs:        jt.PIDs,
+			DateFrom:    jt.DateFrom,
+			DateTo:      jt.DateTo,
+		}
+		if len(m.Syntax) == 0 {
+			m.Syntax = SyntaxKeyword
+		}
+		for k, v := range raw {
+			if !jsonlKeys[k] {
+				m.Set(k, fmt.Sprintf("%v", v))
+			}
+		}
+
+		name := jt.Name
+		if len(name) == 0 {
+			name = jt.Topic
+		}
+		q, err := ParseQuery(m.Syntax, jt.Query, j.fields...)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: topic %s: %v", file, n, jt.Topic, err)
+		}
+		queries = append(queries, pipeline.NewQuery(name, jt.Topic, q).WithMetadata(m))
+	}
+	return queries, s.Err()
+}
+
+// Load loads the queries from every JSONL file in a directory.
+func (j JSONLQuerySource) Load(directory string) ([]pipeline.Query, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []pipeline.Query
+	for _, f := range files {
+		if f.IsDir() || len(f.Name()) == 0 {
+			continue
+		}
+		qs, err := j.LoadFile(path.Join(directory, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, qs...)
+	}
+	return queries, nil
+}
+
+// Iterate lazily loads the queries from every JSONL file in a directory.
+func (j JSONLQuerySource) Iterate(directory string) (QueryIterator, error) {
+	return NewFileQueryIterator(directory, j.LoadFile)
+}
+
+// NewJSONLQuerySource creates a new JSONL query source. The fields are used for queries with the keyword syntax.
+func NewJSONLQuerySource(fields ...string) JSONLQuerySource {
+	return JSONLQuerySource{fields: fields}
+}
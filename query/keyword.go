@@ -4,6 +4,7 @@ import (
 	"github.com/hscells/cqr"
 	"github.com/hscells/groove/pipeline"
 	"io/ioutil"
+	"path"
 )
 
 // KeywordQuerySource is a source of queries that contain only one "string".
@@ -11,6 +12,23 @@ type KeywordQuerySource struct {
 	fields []string
 }
 
+// LoadSingle loads a single query file "as is".
+func (kw KeywordQuerySource) LoadSingle(file string) (pipeline.Query, error) {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return pipeline.Query{}, err
+	}
+
+	cqrQuery := cqr.Keyword{QueryString: string(source), Fields: kw.fields}
+
+	_, topic := path.Split(file)
+
+	return pipeline.NewQuery(topic, topic, cqrQuery).WithMetadata(pipeline.Metadata{
+		Syntax:   SyntaxKeyword,
+		Original: string(source),
+	}), nil
+}
+
 // Load takes a directory of queries and parses them "as is".
 func (kw KeywordQuerySource) Load(directory string) ([]pipeline.Query, error) {
 	// First, get a list of files in the directory.
@@ -22,16 +40,10 @@ func (kw KeywordQuerySource) Load(directory string) ([]pipeline.Query, error) {
 	// Next, load each query into a CQR keyword query.
 	queries := make([]pipeline.Query, len(files))
 	for i, f := range files {
-		source, err := ioutil.ReadFile(directory + "/" + f.Name())
+		queries[i], err = kw.LoadSingle(directory + "/" + f.Name())
 		if err != nil {
 			return []pipeline.Query{}, err
 		}
-
-		cqrQuery := cqr.Keyword{QueryString: string(source), Fields: kw.fields}
-
-		topic := f.Name()
-
-		queries[i] = pipeline.NewQuery(f.Name(), topic, cqrQuery)
 	}
 
 	// Finally, return the queries.
@@ -1,10 +1,21 @@
 package query
 
 import (
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"github.com/bbalet/stopwords"
+	"github.com/hscells/cqr"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute/fields"
 	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 // ProtocolQuerySource loads systematic review protocols from XML files that
@@ -39,11 +50,17 @@ import (
 //</root>
 // ```
 //
-// The source then generates queries according to the package github.com/hscells/groove/learning/seed.
+// The source generates a query for each protocol by extracting the most frequent terms of the target conditions
+// and of the index tests, combining the terms of each section with OR, and the sections with AND. When neither
+// section is present, the objective is used instead.
 type ProtocolQuerySource struct {
+	maxTerms int
 }
 
-// QuickUMLSProtocolQuerySource uses QuickUMLS to perform additional steps in the query formulation process.
+// QuickUMLSProtocolQuerySource uses QuickUMLS to perform additional steps in the query formulation process. Rather
+// than extracting terms, the sections of the protocol are matched to UMLS concepts, and the matched terms with a
+// similarity of at least the threshold are used. When a statistics source is provided, terms which do not retrieve
+// any documents are removed from the query.
 type QuickUMLSProtocolQuerySource struct {
 	threshold float64
 	url       string
@@ -60,7 +77,53 @@ type protocol struct {
 	ReferenceStandards string `xml:"reference_standards"`
 }
 
-func (ProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
+// sections are the parts of the protocol used to generate a query.
+func (p protocol) sections() []string {
+	var s []string
+	for _, section := range []string{p.TargetConditions, p.IndexTests} {
+		if len(strings.TrimSpace(section)) > 0 {
+			s = append(s, section)
+		}
+	}
+	if len(s) == 0 && len(strings.TrimSpace(p.Objective)) > 0 {
+		s = append(s, p.Objective)
+	}
+	return s
+}
+
+// query creates a query for the protocol from the clauses generated for each section.
+func (p protocol) query(name string, clauses []cqr.CommonQueryRepresentation) (pipeline.Query, error) {
+	var children []cqr.CommonQueryRepresentation
+	for _, clause := range clauses {
+		if bq, ok := clause.(cqr.BooleanQuery); ok && len(bq.Children) == 0 {
+			continue
+		}
+		children = append(children, clause)
+	}
+	if len(children) == 0 {
+		return pipeline.Query{}, fmt.Errorf("no query could be generated for protocol %s", name)
+	}
+
+	var q cqr.CommonQueryRepresentation = cqr.NewBooleanQuery(cqr.AND, children)
+	if len(children) == 1 {
+		q = children[0]
+	}
+	return pipeline.NewQuery(name, name, q).WithMetadata(pipeline.Metadata{
+		Description: strings.TrimSpace(p.Objective),
+		Syntax:      SyntaxCQR,
+		Original:    q.String(),
+		Extra: map[string]string{
+			"type_of_study":       strings.TrimSpace(p.TypeOfStudy),
+			"participants":        strings.TrimSpace(p.Participants),
+			"index_tests":         strings.TrimSpace(p.IndexTests),
+			"target_conditions":   strings.TrimSpace(p.TargetConditions),
+			"reference_standards": strings.TrimSpace(p.ReferenceStandards),
+		},
+	}), nil
+}
+
+// loadProtocols reads and parses every protocol in a directory.
+func loadProtocols(directory string, fn func(name string, p protocol) (pipeline.Query, error)) ([]pipeline.Query, error) {
 	// First, get a list of files in the directory.
 	files, err := ioutil.ReadDir(directory)
 	if err != nil {
@@ -70,15 +133,11 @@ func (ProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
 	// Next, read all files, generating queries for each file.
 	var queries []pipeline.Query
 	for _, f := range files {
-		if f.IsDir() {
-			continue
-		}
-
-		if len(f.Name()) == 0 {
+		if f.IsDir() || len(f.Name()) == 0 {
 			continue
 		}
 
-		source, err := ioutil.ReadFile(directory + "/" + f.Name())
+		source, err := ioutil.ReadFile(path.Join(directory, f.Name()))
 		if err != nil {
 			return nil, err
 		}
@@ -88,46 +147,139 @@ func (ProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		q, err := fn(f.Name(), p)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
 	}
 	return queries, nil
 }
 
-func NewProtocolQuerySource() ProtocolQuerySource {
-	return ProtocolQuerySource{}
+// protocolTerms extracts the most frequent terms in some text, ignoring stopwords.
+func protocolTerms(text string, maxTerms int) []string {
+	tokens := strings.FieldsFunc(stopwords.CleanString(strings.ToLower(text), "en", false), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-'
+	})
+
+	counts := make(map[string]int)
+	var terms []string
+	for _, token := range tokens {
+		token = strings.Trim(token, "-")
+		if len(token) < 3 {
+			continue
+		}
+		if _, ok := counts[token]; !ok {
+			terms = append(terms, token)
+		}
+		counts[token]++
+	}
+
+	// Sort by frequency, breaking ties by the first occurrence of the term.
+	sort.SliceStable(terms, func(i, j int) bool {
+		return counts[terms[i]] > counts[terms[j]]
+	})
+	if maxTerms > 0 && len(terms) > maxTerms {
+		terms = terms[:maxTerms]
+	}
+	return terms
 }
 
-func (q QuickUMLSProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
-	// First, get a list of files in the directory.
-	files, err := ioutil.ReadDir(directory)
+// Load generates a query for every protocol in a directory.
+func (s ProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
+	return loadProtocols(directory, func(name string, p protocol) (pipeline.Query, error) {
+		var clauses []cqr.CommonQueryRepresentation
+		for _, section := range p.sections() {
+			var keywords []cqr.CommonQueryRepresentation
+			for _, term := range protocolTerms(section, s.maxTerms) {
+				keywords = append(keywords, cqr.NewKeyword(term, fields.TitleAbstract))
+			}
+			clauses = append(clauses, cqr.NewBooleanQuery(cqr.OR, keywords))
+		}
+		return p.query(name, clauses)
+	})
+}
+
+// NewProtocolQuerySource creates a new protocol query source. The number of terms extracted from each section of a
+// protocol can optionally be specified (the default is 10).
+func NewProtocolQuerySource(maxTerms ...int) ProtocolQuerySource {
+	s := ProtocolQuerySource{maxTerms: 10}
+	if len(maxTerms) > 0 {
+		s.maxTerms = maxTerms[0]
+	}
+	return s
+}
+
+// quickUMLSCandidate is a concept matched by QuickUMLS.
+type quickUMLSCandidate struct {
+	Term       string  `json:"term"`
+	CUI        string  `json:"cui"`
+	NGram      string  `json:"ngram"`
+	Similarity float64 `json:"similarity"`
+}
+
+// match identifies the UMLS concepts in some text using a QuickUMLS REST service.
+func (q QuickUMLSProtocolQuerySource) match(text string) ([]quickUMLSCandidate, error) {
+	b, err := json.Marshal(map[string]string{"text": text})
 	if err != nil {
 		return nil, err
 	}
+	resp, err := http.Post(q.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("quickumls responded with %s", resp.Status)
+	}
+	var candidates []quickUMLSCandidate
+	err = json.NewDecoder(resp.Body).Decode(&candidates)
+	return candidates, err
+}
 
-	// Next, read all files, generating queries for each file.
-	var queries []pipeline.Query
-	for _, f := range files {
-		if f.IsDir() {
-			continue
-		}
-
-		if len(f.Name()) == 0 {
-			continue
-		}
+// Load generates a query for every protocol in a directory.
+func (q QuickUMLSProtocolQuerySource) Load(directory string) ([]pipeline.Query, error) {
+	return loadProtocols(directory, func(name string, p protocol) (pipeline.Query, error) {
+		var clauses []cqr.CommonQueryRepresentation
+		for _, section := range p.sections() {
+			candidates, err := q.match(section)
+			if err != nil {
+				return pipeline.Query{}, err
+			}
 
-		source, err := ioutil.ReadFile(directory + "/" + f.Name())
-		if err != nil {
-			return nil, err
-		}
+			seen := make(map[string]bool)
+			var keywords []cqr.CommonQueryRepresentation
+			for _, c := range candidates {
+				term := strings.ToLower(strings.TrimSpace(c.Term))
+				if c.Similarity < q.threshold || len(term) == 0 || seen[term] {
+					continue
+				}
+				seen[term] = true
 
-		var p protocol
-		err = xml.Unmarshal(source, &p)
-		if err != nil {
-			return nil, err
+				var kw cqr.CommonQueryRepresentation = cqr.NewKeyword(term, fields.TitleAbstract)
+				if strings.Contains(term, " ") {
+					kw = cqr.NewKeyword(fmt.Sprintf(`"%s"`, term), fields.TitleAbstract)
+				}
+				if q.ss != nil {
+					n, err := q.ss.RetrievalSize(kw)
+					if err != nil {
+						return pipeline.Query{}, err
+					}
+					if n == 0 {
+						continue
+					}
+				}
+				keywords = append(keywords, kw)
+			}
+			clauses = append(clauses, cqr.NewBooleanQuery(cqr.OR, keywords))
 		}
-	}
-	return queries, nil
+		return p.query(name, clauses)
+	})
 }
 
+// NewQuickUMLSProtocolQuerySource creates a new protocol query source that matches concepts using the QuickUMLS
+// REST service at the specified url. The statistics source may be nil.
 func NewQuickUMLSProtocolQuerySource(url string, ss stats.StatisticsSource, threshold float64) QuickUMLSProtocolQuerySource {
 	return QuickUMLSProtocolQuerySource{
 		url:       url,
@@ -0,0 +1,278 @@
+package query_test
+
+import (
+	"encoding/json"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/transmute/fields"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files into a new directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectSyntax(t *testing.T) {
+	tests := map[string]string{
+		"1. exp Aspergillosis/\n2. pcr.ti,ab.\n3. 1 and 2": query.SyntaxMedline,
+		"Aspergillosis[mh] AND pcr[tiab]":                  query.SyntaxPubMed,
+	}
+	for q, expected := range tests {
+		if got := query.DetectSyntax(q); got != expected {
+			t.Errorf("expected %s to be detected as %s, got %s", q, expected, got)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := query.ParseQuery(query.SyntaxKeyword, " invasive aspergillosis ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, ok := q.(cqr.Keyword)
+	if !ok || k.QueryString != "invasive aspergillosis" || len(k.Fields) != 1 || k.Fields[0] != fields.TitleAbstract {
+		t.Errorf("expected a keyword query of the title and abstract, got %v", q)
+	}
+
+	q, err = query.ParseQuery(query.SyntaxMedline, "1. exp Aspergillosis/\n2. pcr.ti,ab.\n3. 1 and 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bq, ok := q.(cqr.BooleanQuery); !ok || bq.Operator != cqr.AND || len(bq.Children) != 2 {
+		t.Errorf("expected the numbered Medline lines to be combined with and, got %v", q)
+	}
+
+	_, err = query.ParseQuery(query.SyntaxPubMed, "  ")
+	if err == nil {
+		t.Errorf("expected an error for an empty query")
+	}
+	_, err = query.ParseQuery("ovid", "pcr.ti,ab.")
+	if err == nil {
+		t.Errorf("expected an error for an unknown syntax")
+	}
+}
+
+func TestCLEFTARQuerySource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"CD009551": `Topic: CD009551
+
+Title: Polymerase chain reaction blood tests for the diagnosis of invasive aspergillosis
+
+Query:
+1. exp Aspergillosis/
+2. pcr.ti,ab.
+3. 1 and 2
+
+Pids:
+    25815649
+    25815650
+`,
+		"CD012345": `Topic: CD012345
+
+Title: Galactomannan detection
+
+Objective: To assess the diagnostic accuracy of galactomannan.
+
+Type: DTA
+`,
+	})
+
+	queries, err := query.NewCLEFTARQuerySource(2017).Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected a query for each topic, got %d", len(queries))
+	}
+
+	q := queries[0]
+	if q.Topic != "CD009551" || q.Metadata.Syntax != query.SyntaxMedline {
+		t.Errorf("expected the Medline query of topic CD009551, got %s in %s", q.Topic, q.Metadata.Syntax)
+	}
+	if len(q.Metadata.PIDs) != 2 || q.Metadata.PIDs[0] != "25815649" {
+		t.Errorf("expected the pids of the topic to be preserved, got %v", q.Metadata.PIDs)
+	}
+	if !strings.HasPrefix(q.Metadata.Title, "Polymerase chain reaction") || !strings.Contains(q.Metadata.Original, "exp Aspergillosis/") {
+		t.Errorf("expected the title and original query to be preserved, got %+v", q.Metadata)
+	}
+
+	q = queries[1]
+	if q.Metadata.Syntax != query.SyntaxKeyword || q.Metadata.Description != "To assess the diagnostic accuracy of galactomannan." {
+		t.Errorf("expected a topic without a query to use its title as a keyword query, got %+v", q.Metadata)
+	}
+	if k, ok := q.Query.(cqr.Keyword); !ok || k.QueryString != "Galactomannan detection" {
+		t.Errorf("expected the title to be the query, got %v", q.Query)
+	}
+	if v, ok := q.Metadata.Get("type"); !ok || v != "DTA" {
+		t.Errorf("expected extra sections to be preserved, got %v", q.Metadata.Extra)
+	}
+}
+
+func TestTRECQuerySource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"topics.sgml": `<top>
+<num> Number: 301
+<title> International Organized Crime
+<desc> Description:
+Identify organizations that participate in international criminal activity.
+<narr> Narrative:
+A relevant document must name the organization.
+</top>
+
+<top>
+<num> Number: 302
+<title> Poliomyelitis and Post-Polio
+</top>
+`,
+		"topics.xml": `<topics>
+<topic number="1">
+  <title>heart attack</title>
+  <description>Treatments for myocardial infarction.</description>
+</topic>
+</topics>
+`,
+	})
+
+	queries, err := query.NewTRECQuerySource([]string{query.TRECTitle, query.TRECDescription}, fields.Title).Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 3 {
+		t.Fatalf("expected every topic of every file to be loaded, got %d", len(queries))
+	}
+
+	topics := make(map[string]int)
+	for i, q := range queries {
+		topics[q.Topic] = i
+	}
+	q := queries[topics["301"]]
+	if q.Metadata.Title != "International Organized Crime" || q.Metadata.Narrative != "A relevant document must name the organization." {
+		t.Errorf("expected the title and narrative to be read, got %+v", q.Metadata)
+	}
+	k, ok := q.Query.(cqr.Keyword)
+	if !ok || k.QueryString != "International Organized Crime Identify organizations that participate in international criminal activity." {
+		t.Errorf("expected the title and description to be the query, got %v", q.Query)
+	}
+	if len(k.Fields) != 1 || k.Fields[0] != fields.Title {
+		t.Errorf("expected the query to search the configured fields, got %v", k.Fields)
+	}
+	if _, ok := topics["302"]; !ok {
+		t.Errorf("expected topic 302 to be loaded")
+	}
+	if q := queries[topics["1"]]; q.Metadata.Description != "Treatments for myocardial infarction." {
+		t.Errorf("expected topics in XML to be read, got %+v", q.Metadata)
+	}
+}
+
+func TestJSONLQuerySource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"queries.jsonl": `{"topic": "1", "name": "original", "query": "Aspergillosis[mh] AND pcr[tiab]", "syntax": "pubmed", "pids": ["10", "11"], "date_to": "2015", "review": "DTA"}
+
+{"topic": "2", "query": "heart attack"}
+`,
+	})
+
+	queries, err := query.NewJSONLQuerySource().Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected a query for each line, got %d", len(queries))
+	}
+
+	q := queries[0]
+	if q.Name != "original" || q.Metadata.Syntax != query.SyntaxPubMed || q.Metadata.DateTo != "2015" || len(q.Metadata.PIDs) != 2 {
+		t.Errorf("unexpected query %+v", q)
+	}
+	if v, ok := q.Metadata.Get("review"); !ok || v != "DTA" {
+		t.Errorf("expected unknown keys to be preserved, got %v", q.Metadata.Extra)
+	}
+	if _, ok := q.Query.(cqr.BooleanQuery); !ok {
+		t.Errorf("expected the PubMed query to be parsed, got %v", q.Query)
+	}
+	if q := queries[1]; q.Name != "2" || q.Metadata.Syntax != query.SyntaxKeyword {
+		t.Errorf("expected the topic to name the query and the syntax to default to keyword, got %+v", q)
+	}
+
+	dir = writeFiles(t, map[string]string{"queries.jsonl": `{"topic": "1", "query": "a", "syntax": "ovid"}`})
+	_, err = query.NewJSONLQuerySource().Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "queries.jsonl:1") {
+		t.Errorf("expected an error locating the line with an unknown syntax, got %v", err)
+	}
+}
+
+const galactomannanProtocol = `<?xml version='1.0' encoding='UTF-8'?>
+<root>
+<objective>To assess the diagnostic accuracy of galactomannan detection.</objective>
+<index_tests>Galactomannan sandwich ELISA of serum galactomannan.</index_tests>
+<target_conditions>Invasive aspergillosis, also called invasive pulmonary aspergillosis.</target_conditions>
+</root>
+`
+
+func TestProtocolQuerySource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"CD007394.xml": galactomannanProtocol})
+	queries, err := query.NewProtocolQuerySource(2).Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("expected a query for the protocol, got %d", len(queries))
+	}
+	q := queries[0]
+	bq, ok := q.Query.(cqr.BooleanQuery)
+	if !ok || bq.Operator != cqr.AND || len(bq.Children) != 2 {
+		t.Fatalf("expected the target conditions and index tests to be combined with and, got %v", q.Query)
+	}
+	conditions := bq.Children[0].(cqr.BooleanQuery)
+	if len(conditions.Children) != 2 || conditions.Children[0].(cqr.Keyword).QueryString != "invasive" {
+		t.Errorf("expected the most frequent terms of the target conditions, got %v", conditions)
+	}
+	if q.Metadata.Description != "To assess the diagnostic accuracy of galactomannan detection." {
+		t.Errorf("expected the objective to be preserved, got %q", q.Metadata.Description)
+	}
+}
+
+func TestQuickUMLSProtocolQuerySource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		candidates := []map[string]interface{}{
+			{"term": "Invasive Aspergillosis", "similarity": 1.0},
+			{"term": "aspergillosis", "similarity": 0.5},
+		}
+		if strings.Contains(body["text"], "ELISA") {
+			candidates = []map[string]interface{}{{"term": "ELISA", "similarity": 0.9}}
+		}
+		_ = json.NewEncoder(w).Encode(candidates)
+	}))
+	defer server.Close()
+
+	dir := writeFiles(t, map[string]string{"CD007394.xml": galactomannanProtocol})
+	queries, err := query.NewQuickUMLSProtocolQuerySource(server.URL, nil, 0.8).Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bq := queries[0].Query.(cqr.BooleanQuery)
+	conditions := bq.Children[0].(cqr.BooleanQuery)
+	if len(conditions.Children) != 1 || conditions.Children[0].(cqr.Keyword).QueryString != `"invasive aspergillosis"` {
+		t.Errorf("expected matched concepts above the threshold to be quoted phrases, got %v", conditions)
+	}
+	if tests := bq.Children[1].(cqr.BooleanQuery); tests.Children[0].(cqr.Keyword).QueryString != "elisa" {
+		t.Errorf("expected the concepts of the index tests, got %v", tests)
+	}
+}
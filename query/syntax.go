@@ -0,0 +1,80 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	tpipeline "github.com/hscells/transmute/pipeline"
+	"regexp"
+	"strings"
+)
+
+// Syntaxes that queries can be written in.
+const (
+	SyntaxMedline = "medline"
+	SyntaxPubMed  = "pubmed"
+	SyntaxCQR     = "cqr"
+	SyntaxKeyword = "keyword"
+)
+
+var (
+	ovidLine   = regexp.MustCompile(`(?i)(^exp |/$|\.(ti|ab|tw|sh|pt|mp|kw|fs|ti,ab|tw,kw)\.?$|^or/|^and/|^\d+ (and|or|not) \d+)`)
+	lineNumber = regexp.MustCompile(`^\s*\d+\.?\s+`)
+)
+
+// DetectSyntax guesses whether a query is written in Ovid Medline or PubMed syntax. A query is considered to be a
+// Medline query if any of the lines look like an Ovid line (e.g. `exp Aspergillosis/` or `pcr.ti,ab.`).
+func DetectSyntax(query string) string {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(lineNumber.ReplaceAllString(line, ""))
+		if len(line) > 0 && ovidLine.MatchString(line) {
+			return SyntaxMedline
+		}
+	}
+	return SyntaxPubMed
+}
+
+// ParseQuery parses a query written in the specified syntax into the common query representation. Keyword queries
+// are searched in the specified fields, or in the title and abstract if no fields are specified.
+func ParseQuery(syntax, query string, keywordFields ...string) (cqr.CommonQueryRepresentation, error) {
+	query = strings.Replace(query, `“`, `"`, -1)
+	query = strings.Replace(query, `”`, `"`, -1)
+
+	var p tpipeline.TransmutePipeline
+	switch strings.ToLower(syntax) {
+	case SyntaxMedline:
+		// Line numbers are implied by the position of the line, so they can be safely removed.
+		lines := strings.Split(strings.TrimSpace(query), "\n")
+		for i, line := range lines {
+			lines[i] = lineNumber.ReplaceAllString(line, "")
+		}
+		query = strings.Join(lines, "\n")
+		p = MedlineTransmutePipeline
+	case SyntaxPubMed:
+		p = PubMedTransmutePipeline
+	case SyntaxCQR:
+		p = CQRTransmutePipeline
+	case SyntaxKeyword, "":
+		if len(keywordFields) == 0 {
+			keywordFields = []string{fields.TitleAbstract}
+		}
+		return cqr.NewKeyword(strings.TrimSpace(query), keywordFields...), nil
+	default:
+		return nil, fmt.Errorf("unknown query syntax %s", syntax)
+	}
+
+	if len(strings.TrimSpace(query)) == 0 {
+		return nil, errors.New("cannot parse an empty query")
+	}
+
+	bq, err := p.Execute(query)
+	if err != nil {
+		return nil, err
+	}
+	repr, err := bq.Representation()
+	if err != nil {
+		return nil, err
+	}
+	return repr.(cqr.CommonQueryRepresentation), nil
+}
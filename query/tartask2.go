@@ -27,21 +27,27 @@ func (TARTask2QueriesSource) LoadSingle(file string) (pipeline.Query, error) {
 	s := bufio.NewScanner(bytes.NewBuffer(source))
 	n := 0
 	lines := 0
-	inQuery := false
+	inQuery, inPids := false, false
 	var (
 		topic, title, query string
+		pids                []string
 		q                   cqr.CommonQueryRepresentation
 	)
 	for s.Scan() {
 		line := s.Text()
-		if n == 3 {
-			break
-		}
 		if (len(line) > 0 && line[0] == '\n') || len(line) == 0 {
 			n++
+			// The query ends at the first blank line after it.
+			if n >= 3 {
+				inQuery = false
+			}
 			continue
 		}
-		if !inQuery {
+		if inPids {
+			if pid := strings.TrimSpace(line); len(pid) > 0 {
+				pids = append(pids, pid)
+			}
+		} else if !inQuery {
 			t := strings.Split(line, ":")
 			if len(t) > 1 {
 				switch t[0] {
@@ -50,7 +56,9 @@ func (TARTask2QueriesSource) LoadSingle(file string) (pipeline.Query, error) {
 				case "Title":
 					title = strings.TrimSpace(strings.Join(t[1:], " "))
 				case "Query":
-					inQuery = true
+					inQuery = n < 3
+				case "Pids":
+					inPids = true
 				}
 			}
 		} else {
@@ -58,17 +66,24 @@ func (TARTask2QueriesSource) LoadSingle(file string) (pipeline.Query, error) {
 			lines++
 		}
 	}
-	fmt.Println(topic)
 	query = strings.Replace(query, `“`, `"`, -1)
 	query = strings.Replace(query, `”`, `"`, -1)
+	m := pipeline.Metadata{
+		Title:    title,
+		Original: query,
+		PIDs:     pids,
+	}
 	if lines < 3 {
-		fmt.Println("pubmed")
-		q, _ = transmute.CompilePubmed2Cqr(query)
+		m.Syntax = SyntaxPubMed
+		q, err = transmute.CompilePubmed2Cqr(query)
 	} else {
-		fmt.Println("medline")
-		q, _ = transmute.CompileMedline2Cqr(query)
+		m.Syntax = SyntaxMedline
+		q, err = transmute.CompileMedline2Cqr(query)
+	}
+	if err != nil {
+		return pipeline.Query{}, fmt.Errorf("topic %s: %s: %v", topic, file, err)
 	}
-	return pipeline.NewQuery(title, topic, q), nil
+	return pipeline.NewQuery(title, topic, q).WithMetadata(m), nil
 }
 
 func (t TARTask2QueriesSource) Load(directory string) ([]pipeline.Query, error) {
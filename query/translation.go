@@ -0,0 +1,336 @@
+package query
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/combinator"
+	gpipeline "github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/transmute"
+	"github.com/hscells/transmute/backend"
+	"sort"
+	"strings"
+)
+
+// Translation writes queries in a syntax and, if the syntax can be read, parses them back.
+type Translation struct {
+	Syntax  string
+	Compile func(q cqr.CommonQueryRepresentation) (string, error)
+	// Parse is nil for syntaxes that are only written (e.g. Elasticsearch), whose translations can only be checked
+	// for errors.
+	Parse func(s string) (cqr.CommonQueryRepresentation, error)
+}
+
+// SyntaxElasticsearch is the Elasticsearch query DSL.
+const SyntaxElasticsearch = "elasticsearch"
+
+var (
+	// PubMedTranslation translates queries to and from PubMed syntax.
+	PubMedTranslation = Translation{
+		Syntax:  SyntaxPubMed,
+		Compile: transmute.CompileCqr2PubMed,
+		Parse:   syntaxParser(SyntaxPubMed),
+	}
+	// MedlineTranslation translates queries to and from Ovid Medline syntax.
+	MedlineTranslation = Translation{
+		Syntax:  SyntaxMedline,
+		Compile: transmute.CompileCqr2Medline,
+		Parse:   syntaxParser(SyntaxMedline),
+	}
+	// CQRTranslation translates queries to and from the JSON representation of cqr.
+	CQRTranslation = Translation{
+		Syntax: SyntaxCQR,
+		Compile: func(q cqr.CommonQueryRepresentation) (string, error) {
+			return backend.NewCQRQuery(q).String()
+		},
+		Parse: syntaxParser(SyntaxCQR),
+	}
+	// ElasticsearchTranslation translates queries to the Elasticsearch query DSL used by the Elasticsearch statistics
+	// source.
+	ElasticsearchTranslation = Translation{
+		Syntax: SyntaxElasticsearch,
+		Compile: func(q cqr.CommonQueryRepresentation) (string, error) {
+			return stats.CompileElasticsearch(q, "")
+		},
+	}
+
+	// Translations are the translations that can be checked, keyed by syntax.
+	Translations = map[string]Translation{
+		SyntaxPubMed:        PubMedTranslation,
+		SyntaxMedline:       MedlineTranslation,
+		SyntaxCQR:           CQRTranslation,
+		SyntaxElasticsearch: ElasticsearchTranslation,
+	}
+)
+
+func syntaxParser(syntax string) func(s string) (cqr.CommonQueryRepresentation, error) {
+	return func(s string) (cqr.CommonQueryRepresentation, error) {
+		return ParseQuery(syntax, s)
+	}
+}
+
+// DifferenceKind is the way a clause of a query changes when it is translated.
+type DifferenceKind string
+
+const (
+	// ClauseDifference is a keyword that became a Boolean clause, or vice versa.
+	ClauseDifference DifferenceKind = "clause"
+	// OperatorDifference is a Boolean clause with a different operator.
+	OperatorDifference DifferenceKind = "operator"
+	// QueryStringDifference is a keyword with different text (e.g. truncation that is lost or added).
+	QueryStringDifference DifferenceKind = "query_string"
+	// FieldsDifference is a keyword that searches different fields.
+	FieldsDifference DifferenceKind = "fields"
+	// OptionDifference is a clause with a different option (e.g. MeSH explosion or truncation).
+	OptionDifference DifferenceKind = "option"
+	// MissingDifference is a clause that is not in the translated query.
+	MissingDifference DifferenceKind = "missing"
+	// AddedDifference is a clause that is only in the translated query.
+	AddedDifference DifferenceKind = "added"
+)
+
+// Difference is a structural difference between a query and its translation.
+type Difference struct {
+	// Path is the location of the clause in the normalised query (see combinator.NormaliseClauses), or in the
+	// normalised translation for added clauses, as the index of each child from the root (e.g. /0/2).
+	Path       string         `json:"path"`
+	Kind       DifferenceKind `json:"kind"`
+	Original   string         `json:"original,omitempty"`
+	Translated string         `json:"translated,omitempty"`
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s: %s -> %s", d.Path, d.Kind, d.Original, d.Translated)
+}
+
+// CompareQueries finds the structural differences between a query and its translation. The clauses of both queries
+// are normalised first, so differences that do not change the documents retrieved (e.g. the order of clauses,
+// redundant parentheses, or aliases of fields) are not reported. Options are compared as they are set, so an option
+// that is lost in translation (e.g. MeSH explosion) is reported even when it was set to its default value.
+func CompareQueries(original, translated cqr.CommonQueryRepresentation) []Difference {
+	return compareClauses(combinator.NormaliseClauses(original), combinator.NormaliseClauses(translated), "")
+}
+
+func compareClauses(a, b cqr.CommonQueryRepresentation, path string) []Difference {
+	p := path
+	if len(p) == 0 {
+		p = "/"
+	}
+	switch x := a.(type) {
+	case cqr.Keyword:
+		y, ok := b.(cqr.Keyword)
+		if !ok {
+			return []Difference{{Path: p, Kind: ClauseDifference, Original: combinator.PubMedString(a), Translated: combinator.PubMedString(b)}}
+		}
+		var d []Difference
+		if x.QueryString != y.QueryString {
+			d = append(d, Difference{Path: p, Kind: QueryStringDifference, Original: x.QueryString, Translated: y.QueryString})
+		}
+		if strings.Join(x.Fields, ",") != strings.Join(y.Fields, ",") {
+			d = append(d, Difference{Path: p, Kind: FieldsDifference, Original: strings.Join(x.Fields, ","), Translated: strings.Join(y.Fields, ",")})
+		}
+		return append(d, compareOptions(x.Options, y.Options, p)...)
+	case cqr.BooleanQuery:
+		y, ok := b.(cqr.BooleanQuery)
+		if !ok {
+			return []Difference{{Path: p, Kind: ClauseDifference, Original: combinator.PubMedString(a), Translated: combinator.PubMedString(b)}}
+		}
+		var d []Difference
+		if x.Operator != y.Operator {
+			d = append(d, Difference{Path: p, Kind: OperatorDifference, Original: x.Operator, Translated: y.Operator})
+		}
+		d = append(d, compareOptions(x.Options, y.Options, p)...)
+		return append(d, compareChildren(x.Children, y.Children, path)...)
+	}
+	return nil
+}
+
+// compareChildren pairs the children of two clauses. Logically identical children are paired first, and only differ
+// by options set to their default value; the remaining children are compared in order if there are as many of each,
+// and are otherwise missing from or added to the translation.
+func compareChildren(a, b []cqr.CommonQueryRepresentation, path string) []Difference {
+	unmatched := make(map[string][]int)
+	for j, child := range b {
+		k := combinator.Canonical(child)
+		unmatched[k] = append(unmatched[k], j)
+	}
+	var d []Difference
+	var missing, added []int
+	for i, child := range a {
+		k := combinator.Canonical(child)
+		if js := unmatched[k]; len(js) > 0 {
+			unmatched[k] = js[1:]
+			d = append(d, compareClauses(child, b[js[0]], fmt.Sprintf("%s/%d", path, i))...)
+			continue
+		}
+		missing = append(missing, i)
+	}
+	for _, js := range unmatched {
+		added = append(added, js...)
+	}
+	sort.Ints(added)
+
+	if len(missing) == len(added) {
+		for n, i := range missing {
+			d = append(d, compareClauses(a[i], b[added[n]], fmt.Sprintf("%s/%d", path, i))...)
+		}
+		return d
+	}
+	for _, i := range missing {
+		d = append(d, Difference{Path: fmt.Sprintf("%s/%d", path, i), Kind: MissingDifference, Original: combinator.PubMedString(a[i])})
+	}
+	for _, j := range added {
+		d = append(d, Difference{Path: fmt.Sprintf("%s/%d", path, j), Kind: AddedDifference, Translated: combinator.PubMedString(b[j])})
+	}
+	return d
+}
+
+// compareOptions finds the options that are different, in order of their keys.
+func compareOptions(a, b map[string]interface{}, path string) []Difference {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var d []Difference
+	for _, k := range sorted {
+		x, y := optionString(a, k), optionString(b, k)
+		if x != y {
+			d = append(d, Difference{Path: path, Kind: OptionDifference, Original: x, Translated: y})
+		}
+	}
+	return d
+}
+
+func optionString(options map[string]interface{}, key string) string {
+	if v, ok := options[key]; ok {
+		return fmt.Sprintf("%s=%v", key, v)
+	}
+	return ""
+}
+
+// RetrievalComparison is the number of documents retrieved by a query, by its translation, and by both.
+type RetrievalComparison struct {
+	Original   int `json:"original"`
+	Translated int `json:"translated"`
+	Both       int `json:"both"`
+}
+
+// Identical is whether the query and its translation retrieve the same documents.
+func (r RetrievalComparison) Identical() bool {
+	return r.Original == r.Both && r.Translated == r.Both
+}
+
+// TranslationCheck is the result of translating a query into a syntax and back.
+type TranslationCheck struct {
+	Topic       string               `json:"topic"`
+	Syntax      string               `json:"syntax"`
+	Translated  string               `json:"translated,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	Differences []Difference         `json:"differences,omitempty"`
+	Retrieval   *RetrievalComparison `json:"retrieval,omitempty"`
+}
+
+// Ok is whether the query was translated without errors or differences.
+func (c TranslationCheck) Ok() bool {
+	return len(c.Error) == 0 && len(c.Differences) == 0 && (c.Retrieval == nil || c.Retrieval.Identical())
+}
+
+// TranslationChecker round-trips queries through translations to find where they change.
+type TranslationChecker struct {
+	translations []Translation
+	source       stats.StatisticsSource
+}
+
+// TranslationSyntaxes sets the translations queries are checked with (by default, PubMed, Medline, CQR and
+// Elasticsearch).
+func TranslationSyntaxes(translations ...Translation) func(*TranslationChecker) {
+	return func(c *TranslationChecker) {
+		c.translations = translations
+	}
+}
+
+// TranslationRetrieval compares the documents a query and its translation retrieve from a statistics source.
+func TranslationRetrieval(source stats.StatisticsSource) func(*TranslationChecker) {
+	return func(c *TranslationChecker) {
+		c.source = source
+	}
+}
+
+// NewTranslationChecker creates a checker of the translation of queries.
+func NewTranslationChecker(options ...func(*TranslationChecker)) TranslationChecker {
+	c := TranslationChecker{
+		translations: []Translation{PubMedTranslation, MedlineTranslation, CQRTranslation, ElasticsearchTranslation},
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	return c
+}
+
+// Check translates a query into each syntax and back. Errors compiling or parsing the query are part of the check
+// of that syntax; an error is only returned if the statistics source cannot retrieve the documents of the query.
+func (c TranslationChecker) Check(q gpipeline.Query) ([]TranslationCheck, error) {
+	var original map[uint32]bool
+	checks := make([]TranslationCheck, len(c.translations))
+	for i, t := range c.translations {
+		checks[i] = TranslationCheck{Topic: q.Topic, Syntax: t.Syntax}
+		s, err := t.Compile(q.Query)
+		if err != nil {
+			checks[i].Error = err.Error()
+			continue
+		}
+		checks[i].Translated = s
+		if t.Parse == nil {
+			continue
+		}
+		translated, err := t.Parse(s)
+		if err != nil {
+			checks[i].Error = err.Error()
+			continue
+		}
+		checks[i].Differences = CompareQueries(q.Query, translated)
+
+		if c.source == nil {
+			continue
+		}
+		if original == nil {
+			original, err = documentSet(q, c.source)
+			if err != nil {
+				return nil, err
+			}
+		}
+		docs, err := documentSet(q.WithQuery(translated), c.source)
+		if err != nil {
+			return nil, fmt.Errorf("%s translation: %v", t.Syntax, err)
+		}
+		r := RetrievalComparison{Original: len(original), Translated: len(docs)}
+		for doc := range docs {
+			if original[doc] {
+				r.Both++
+			}
+		}
+		checks[i].Retrieval = &r
+	}
+	return checks, nil
+}
+
+func documentSet(q gpipeline.Query, ss stats.StatisticsSource) (map[uint32]bool, error) {
+	ids, err := stats.GetDocumentIDs(q, ss)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		docs[id] = true
+	}
+	return docs, nil
+}
@@ -0,0 +1,122 @@
+package query_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/transmute/fields"
+	"testing"
+)
+
+func TestCompareQueries(t *testing.T) {
+	original := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("Aspergillosis", fields.MeshHeadings).SetOption(cqr.ExplodedString, true),
+		cqr.NewKeyword("pcr", "tiab").SetOption("truncated", false),
+	})
+
+	// Differences in the order of clauses and aliases of fields are not reported.
+	reordered := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("pcr", fields.TitleAbstract).SetOption("truncated", false),
+		cqr.NewKeyword("Aspergillosis", "mh").SetOption(cqr.ExplodedString, true),
+	})
+	if d := query.CompareQueries(original, reordered); len(d) > 0 {
+		t.Errorf("expected no differences, got %v", d)
+	}
+
+	// Options are compared as they are set, even when they do not change the documents retrieved.
+	translated := cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("Aspergillosis", fields.MeshHeadings),
+		cqr.NewKeyword("pcr", fields.Title),
+	})
+	d := query.CompareQueries(original, translated)
+	if len(d) != 3 {
+		t.Fatalf("expected the lost explosion, fields and truncation to be reported, got %v", d)
+	}
+	for _, diff := range d {
+		if diff.Kind == query.OptionDifference && diff.Original == "exploded=true" && diff.Translated == "" {
+			return
+		}
+	}
+	t.Errorf("expected the lost explosion to be reported, got %v", d)
+}
+
+// lossyTranslation round-trips queries through CQR, losing the explosion of MeSH headings and searching keywords in
+// the title only, like a syntax that cannot express them.
+var lossyTranslation = query.Translation{
+	Syntax:  "lossy",
+	Compile: query.CQRTranslation.Compile,
+	Parse: func(s string) (cqr.CommonQueryRepresentation, error) {
+		q, err := query.CQRTranslation.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		var lose func(q cqr.CommonQueryRepresentation) cqr.CommonQueryRepresentation
+		lose = func(q cqr.CommonQueryRepresentation) cqr.CommonQueryRepresentation {
+			switch q := q.(type) {
+			case cqr.Keyword:
+				k := cqr.NewKeyword(q.QueryString, q.Fields...)
+				if len(q.Fields) == 0 || q.Fields[0] != fields.MeshHeadings {
+					k.Fields = []string{fields.Title}
+				}
+				return k
+			case cqr.BooleanQuery:
+				children := make([]cqr.CommonQueryRepresentation, len(q.Children))
+				for i, child := range q.Children {
+					children[i] = lose(child)
+				}
+				return cqr.NewBooleanQuery(q.Operator, children)
+			}
+			return q
+		}
+		return lose(q), nil
+	},
+}
+
+func TestTranslationChecker(t *testing.T) {
+	q, err := query.ParseQuery(query.SyntaxMedline, "1. exp Aspergillosis/\n2. Aspergillus/\n3. pcr.ti,ab.\n4. 1 or 2\n5. 3 and 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := query.NewTranslationChecker(query.TranslationSyntaxes(
+		query.PubMedTranslation, query.MedlineTranslation, query.CQRTranslation, lossyTranslation))
+	checks, err := checker.Check(pipeline.NewQuery("1", "1", q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 4 {
+		t.Fatalf("expected a check of each syntax, got %d", len(checks))
+	}
+	for _, c := range checks[:3] {
+		if len(c.Error) > 0 || len(c.Translated) == 0 {
+			t.Errorf("expected the query to be translated to %s and back, got %+v", c.Syntax, c)
+		}
+		for _, d := range c.Differences {
+			if d.Kind == query.MissingDifference || d.Kind == query.AddedDifference {
+				t.Errorf("expected no clauses to be lost translating to %s, got %v", c.Syntax, c.Differences)
+			}
+		}
+	}
+	if c := checks[2]; !c.Ok() {
+		t.Errorf("expected the query to be unchanged by CQR, got %v", c.Differences)
+	}
+
+	// The lossy translation loses the explosion of one heading and the fields of the keyword.
+	c := checks[3]
+	if c.Ok() {
+		t.Fatal("expected the lossy translation to change the query")
+	}
+	var exploded, searched int
+	for _, d := range c.Differences {
+		switch d.Kind {
+		case query.OptionDifference:
+			exploded++
+		case query.FieldsDifference:
+			searched++
+		default:
+			t.Errorf("unexpected difference %v", d)
+		}
+	}
+	if exploded == 0 || searched != 1 {
+		t.Errorf("expected the lost explosion and fields to be reported, got %v", c.Differences)
+	}
+}
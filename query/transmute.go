@@ -75,7 +75,9 @@ func (ts TransmuteQuerySource) LoadSingle(file string) (gpipeline.Query, error)
 	if err != nil {
 		return gpipeline.Query{}, err
 	}
-	return gpipeline.NewQuery(topic, topic, repr.(cqr.CommonQueryRepresentation)), nil
+	return gpipeline.NewQuery(topic, topic, repr.(cqr.CommonQueryRepresentation)).WithMetadata(gpipeline.Metadata{
+		Original: string(source),
+	}), nil
 }
 
 // Load takes a directory of queries and parses them using a supplied transmute gpipeline.
@@ -87,8 +89,8 @@ func (ts TransmuteQuerySource) Load(directory string) ([]gpipeline.Query, error)
 	}
 
 	// Next, use the transmute gpipeline to parse them.
-	queries := make([]gpipeline.Query, len(files))
-	for i, f := range files {
+	var queries []gpipeline.Query
+	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
@@ -97,10 +99,11 @@ func (ts TransmuteQuerySource) Load(directory string) ([]gpipeline.Query, error)
 			continue
 		}
 
-		queries[i], err = ts.LoadSingle(path.Join(directory, f.Name()))
+		q, err := ts.LoadSingle(path.Join(directory, f.Name()))
 		if err != nil {
 			return nil, err
 		}
+		queries = append(queries, q)
 	}
 
 	// Finally, return the parsed queries.
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"github.com/hscells/groove/pipeline"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Parts of a TREC topic that can be used to construct a query.
+const (
+	TRECTitle       = "title"
+	TRECDescription = "desc"
+	TRECNarrative   = "narr"
+)
+
+var (
+	trecTopic       = regexp.MustCompile(`(?is)<(top|topic)\b([^>]*)>(.*?)</(top|topic)>`)
+	trecNumberAttr  = regexp.MustCompile(`(?i)number\s*=\s*"([^"]*)"`)
+	trecNumber      = regexp.MustCompile(`(?is)<num>\s*(?:Number:)?\s*([^<\s]+)`)
+	trecTag         = regexp.MustCompile(`(?i)<(num|title|desc|description|narr|narrative|summary|query|question)>`)
+	trecClosingTag  = regexp.MustCompile(`(?i)</[a-z]+>`)
+	trecTagPrefixes = regexp.MustCompile(`(?i)^\s*(Topic|Description|Narrative):`)
+)
+
+// TRECQuerySource loads topics in the TREC topic format. Both the classic SGML format (`<top>`, `<num>`, `<title>`,
+// `<desc>`, `<narr>`) and well-formed XML (`<topic number="1"><title>...</title>...</topic>`) are supported. Each file
+// in the directory may contain many topics. The query for each topic is a keyword query constructed from the parts
+// of the topic that have been configured (the title by default).
+type TRECQuerySource struct {
+	parts  []string
+	fields []string
+}
+
+// parseTRECTopics parses all of the topics in a TREC topic file.
+func (t TRECQuerySource) parseTRECTopics(source string) ([]pipeline.Query, error) {
+	var queries []pipeline.Query
+	for _, match := range trecTopic.FindAllStringSubmatch(source, -1) {
+		attributes, body := match[2], match[3]
+		var topic string
+		m := pipeline.Metadata{Syntax: SyntaxKeyword}
+
+		if n := trecNumberAttr.FindStringSubmatch(attributes); n != nil {
+			topic = strings.TrimSpace(n[1])
+		} else if n := trecNumber.FindStringSubmatch(body); n != nil {
+			topic = strings.TrimSpace(n[1])
+		}
+
+		// The text of a tag spans until the next tag, since closing tags are optional in the SGML format.
+		tags := trecTag.FindAllStringSubmatchIndex(body, -1)
+		for i, tag := range tags {
+			end := len(body)
+			if i+1 < len(tags) {
+				end = tags[i+1][0]
+			}
+			text := trecClosingTag.ReplaceAllString(body[tag[1]:end], " ")
+			text = strings.Join(strings.Fields(trecTagPrefixes.ReplaceAllString(text, "")), " ")
+			switch strings.ToLower(body[tag[2]:tag[3]]) {
+			case "title":
+				m.Title = text
+			case "desc", "description":
+				m.Description = text
+			case "narr", "narrative":
+				m.Narrative = text
+			}
+		}
+
+		var text []string
+		for _, part := range t.parts {
+			switch part {
+			case TRECTitle:
+				text = append(text, m.Title)
+			case TRECDescription:
+				text = append(text, m.Description)
+			case TRECNarrative:
+				text = append(text, m.Narrative)
+			}
+		}
+		m.Original = strings.TrimSpace(strings.Join(text, " "))
+
+		q, err := ParseQuery(SyntaxKeyword, m.Original, t.fields...)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, pipeline.NewQuery(m.Title, topic, q).WithMetadata(m))
+	}
+	return queries, nil
+}
+
+// LoadFile loads all of the queries in a TREC topic file.
+func (t TRECQuerySource) LoadFile(file string) ([]pipeline.Query, error) {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return t.parseTRECTopics(string(source))
+}
+
+// Load loads the queries from every TREC topic file in a directory.
+func (t TRECQuerySource) Load(directory string) ([]pipeline.Query, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []pipeline.Query
+	for _, f := range files {
+		if f.IsDir() || len(f.Name()) == 0 {
+			continue
+		}
+		qs, err := t.LoadFile(path.Join(directory, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, qs...)
+	}
+	return queries, nil
+}
+
+// Iterate lazily loads the queries from every TREC topic file in a directory.
+func (t TRECQuerySource) Iterate(directory string) (QueryIterator, error) {
+	return NewFileQueryIterator(directory, t.LoadFile)
+}
+
+// NewTRECQuerySource creates a new TREC topic query source. The parts are the parts of the topic used to create the
+// query (TRECTitle, TRECDescription, TRECNarrative), and the fields are the fields the keyword query is issued to.
+func NewTRECQuerySource(parts []string, fields ...string) TRECQuerySource {
+	if len(parts) == 0 {
+		parts = []string{TRECTitle}
+	}
+	return TRECQuerySource{
+		parts:  parts,
+		fields: fields,
+	}
+}
@@ -12,9 +12,9 @@ import (
 	"github.com/hscells/groove/analysis"
 	"github.com/hscells/groove/combinator"
 	"github.com/hscells/groove/learning"
+	"github.com/hscells/groove/metrics"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/groove/stats"
-	"github.com/hscells/headway"
 	"github.com/hscells/merging"
 	"github.com/hscells/quickumlsrest"
 	"github.com/hscells/transmute"
@@ -23,7 +23,6 @@ import (
 	"github.com/reiver/go-porterstemmer"
 	"gopkg.in/jdkato/prose.v2"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"os"
 	"path"
@@ -498,11 +497,6 @@ func clfVariations(query cqr.CommonQueryRepresentation, topic string, e stats.En
 		return err
 	}
 
-	var hw *headway.Client
-	if len(options.HeadwayServer) > 0 {
-		log.Println("connecting to headway server...")
-		hw = headway.NewClient(options.HeadwayServer, fmt.Sprintf("@harry topic [%s]", topic))
-	}
 	//wg := new(sync.WaitGroup)
 
 	cd, err := os.UserCacheDir()
@@ -654,18 +648,7 @@ func clfVariations(query cqr.CommonQueryRepresentation, topic string, e stats.En
 		if err != nil {
 			return err
 		}
-		if len(options.HeadwayServer) > 0 {
-			err = hw.Send(float64(i), float64(len(filteredCandidates)), fmt.Sprintf("retrieved: %f", n))
-			if err != nil {
-				log.Println(err)
-			}
-		}
-	}
-	if len(options.HeadwayServer) > 0 {
-		err = hw.Send(float64(len(filteredCandidates)), float64(len(filteredCandidates)), fmt.Sprintf("done!"))
-		if err != nil {
-			log.Println(err)
-		}
+		metrics.Progress("clf variations", i+1, len(filteredCandidates))
 	}
 	//wg.Wait()
 	return nil
@@ -716,8 +699,6 @@ type CLFOptions struct {
 	quickumlscache   quickumlsrest.Cache
 
 	Titles string `json:"titles"`
-
-	HeadwayServer string `json:"headway_server"`
 }
 
 func (o CLFOptions) SetVariationOptions(vector cui2vec.Embeddings, mapping cui2vec.Mapping, cache quickumlsrest.Cache) CLFOptions {
@@ -738,7 +719,15 @@ func CLF(query pipeline.Query, e stats.EntrezStatisticsSource, options CLFOption
 	//idealIndexPath := path.Join(cd, "groove_rank_ideal")
 
 	var pmids []int
-	if !options.CLFVariations {
+	if !options.CLFVariations && len(query.Metadata.PIDs) > 0 {
+		for _, pid := range query.Metadata.PIDs {
+			pmid, err := strconv.Atoi(pid)
+			if err != nil {
+				return nil, err
+			}
+			pmids = append(pmids, pmid)
+		}
+	} else if !options.CLFVariations {
 		b, err := ioutil.ReadFile(path.Join(options.PMIDS, query.Topic))
 		if err != nil {
 			return nil, err
@@ -764,9 +753,12 @@ func CLF(query pipeline.Query, e stats.EntrezStatisticsSource, options CLFOption
 			cqr.NewKeyword("accuracy", fields.TitleAbstract),
 		}))
 
-		title, err := ioutil.ReadFile(path.Join(options.Titles, query.Topic))
-		if err != nil {
-			return nil, err
+		title := []byte(query.Metadata.Title)
+		if len(title) == 0 {
+			title, err = ioutil.ReadFile(path.Join(options.Titles, query.Topic))
+			if err != nil {
+				return nil, err
+			}
 		}
 		fmt.Println(string(title))
 
@@ -2,6 +2,7 @@ package rank
 
 import (
 	"fmt"
+	"github.com/hscells/groove/preprocess"
 	"github.com/hscells/guru"
 	"gopkg.in/cheggaaa/pb.v1"
 	"gopkg.in/jdkato/prose.v2"
@@ -29,6 +30,13 @@ type Posting struct {
 }
 
 var (
+	// Analyser is applied to each title and abstract token when indexing documents, and to each query term when
+	// scoring, so that both are analysed consistently. Tokens analysed to the empty string are not indexed.
+	Analyser preprocess.QueryProcessor
+	// AnalyserName identifies the Analyser in the keys of cached postings and query tokens, so that postings and
+	// tokens analysed differently are not reused. It must be changed whenever the Analyser is.
+	AnalyserName string
+
 	H        = fnv.New32a()
 	suffixes = []string{
 		// Noun suffixes.
@@ -49,6 +57,22 @@ func hash(s string) uint32 {
 	return H.Sum32()
 }
 
+// analysedKey qualifies a cache key with the name of the Analyser, if one has been set.
+func analysedKey(key string) string {
+	if len(AnalyserName) == 0 {
+		return key
+	}
+	return key + "_" + AnalyserName
+}
+
+// analyse applies the Analyser to a token, if one has been set.
+func analyse(token string) string {
+	if Analyser == nil {
+		return token
+	}
+	return Analyser(token)
+}
+
 func Index(documents guru.MedlineDocuments) (*Posting, error) {
 	ii := make(map[uint32]map[uint32]map[uint32]Statistics)
 	dl := make(map[string]map[uint32]float64, len(documents))
@@ -96,7 +120,11 @@ func Index(documents guru.MedlineDocuments) (*Posting, error) {
 		tiTf := make(map[uint32]float64)
 		tiPos := make(map[uint32]float64)
 		for i, tok := range ti.Tokens() {
-			t := hash(tok.Text)
+			text := analyse(tok.Text)
+			if len(text) == 0 {
+				continue
+			}
+			t := hash(text)
 			if _, ok := ii[t]; !ok {
 				ii[t] = make(map[uint32]map[uint32]Statistics)
 				ii[t][TI] = make(map[uint32]Statistics)
@@ -118,7 +146,11 @@ func Index(documents guru.MedlineDocuments) (*Posting, error) {
 				return nil, err
 			}
 			for _, tok := range toks.Tokens() {
-				t := hash(tok.Text)
+				text := analyse(tok.Text)
+				if len(text) == 0 {
+					continue
+				}
+				t := hash(text)
 				if _, ok := ii[t]; !ok {
 					ii[t] = make(map[uint32]map[uint32]Statistics)
 					ii[t][TI] = make(map[uint32]Statistics)
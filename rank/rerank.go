@@ -0,0 +1,647 @@
+package rank
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DocumentStatistics are the statistics of the terms in a set of documents, read from their term vectors in a
+// statistics source. They are to the scorers of re-ranked documents what a Posting is to the scorers of documents
+// fetched from Entrez.
+type DocumentStatistics struct {
+	source stats.StatisticsSource
+
+	tf        map[string]map[string]map[string]float64 // document -> field -> term -> frequency
+	length    map[string]map[string]float64            // document -> field -> length
+	avgLength map[string]float64                       // field -> average length
+
+	// N is the number of documents in the collection.
+	N float64
+
+	mu         sync.Mutex
+	df, ttf    map[string]map[string]float64 // field -> analysed term -> frequency
+	analysed   map[string]map[string]string  // field -> term -> analysed term, of the terms counted in df and ttf
+	vocabulary map[string]float64            // field -> number of tokens in the collection
+}
+
+// NewDocumentStatistics reads the term vectors of documents from a statistics source. The terms are analysed with the
+// Analyser, like the terms of queries. n is the number of documents in the collection; if it is zero, it is read from
+// the statistics source.
+func NewDocumentStatistics(ss stats.StatisticsSource, documents []string, n float64) (*DocumentStatistics, error) {
+	if n <= 0 {
+		var err error
+		n, err = ss.CollectionSize()
+		if err != nil {
+			return nil, err
+		}
+	}
+	ds := &DocumentStatistics{
+		source:     ss,
+		tf:         make(map[string]map[string]map[string]float64, len(documents)),
+		length:     make(map[string]map[string]float64, len(documents)),
+		avgLength:  make(map[string]float64),
+		N:          n,
+		df:         make(map[string]map[string]float64),
+		ttf:        make(map[string]map[string]float64),
+		analysed:   make(map[string]map[string]string),
+		vocabulary: make(map[string]float64),
+	}
+
+	var (
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan bool, runtime.NumCPU())
+	for _, document := range documents {
+		sem <- true
+		wg.Add(1)
+		go func(document string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			tv, err := ss.TermVector(document)
+			ds.mu.Lock()
+			defer ds.mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("document %s: %v", document, err)
+				}
+				return
+			}
+			ds.add(document, tv)
+		}(document)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	for field, length := range ds.avgLength {
+		ds.avgLength[field] = length / float64(len(ds.length))
+	}
+	return ds, nil
+}
+
+// add adds the term vector of a document, analysing each term. Terms analysed to the empty string are not counted.
+// The collection frequencies of terms that are analysed to the same term are summed, once for each of the terms.
+func (ds *DocumentStatistics) add(document string, tv stats.TermVector) {
+	tf := make(map[string]map[string]float64)
+	length := make(map[string]float64)
+	for _, term := range tv {
+		t := analyse(term.Term)
+		if len(t) == 0 {
+			continue
+		}
+		if _, ok := tf[term.Field]; !ok {
+			tf[term.Field] = make(map[string]float64)
+		}
+		tf[term.Field][t] += term.TermFrequency
+		length[term.Field] += term.TermFrequency
+
+		if _, ok := ds.analysed[term.Field]; !ok {
+			ds.analysed[term.Field] = make(map[string]string)
+		}
+		if _, ok := ds.analysed[term.Field][term.Term]; ok {
+			continue
+		}
+		ds.analysed[term.Field][term.Term] = t
+		if _, ok := ds.df[term.Field]; !ok {
+			ds.df[term.Field] = make(map[string]float64)
+			ds.ttf[term.Field] = make(map[string]float64)
+		}
+		ds.df[term.Field][t] += term.DocumentFrequency
+		ds.ttf[term.Field][t] += term.TotalTermFrequency
+	}
+	for field, l := range length {
+		ds.avgLength[field] += l
+	}
+	ds.tf[document] = tf
+	ds.length[document] = length
+}
+
+// Documents are the documents the statistics are of.
+func (ds *DocumentStatistics) Documents() []string {
+	documents := make([]string, 0, len(ds.tf))
+	for document := range ds.tf {
+		documents = append(documents, document)
+	}
+	sort.Strings(documents)
+	return documents
+}
+
+// TermFrequency is the number of times a term, analysed with the Analyser, occurs in a field of a document.
+func (ds *DocumentStatistics) TermFrequency(term, field, document string) float64 {
+	return ds.tf[document][field][term]
+}
+
+// Length is the number of terms in a field of a document.
+func (ds *DocumentStatistics) Length(field, document string) float64 {
+	return ds.length[document][field]
+}
+
+// AverageLength is the average number of terms in a field of the documents.
+func (ds *DocumentStatistics) AverageLength(field string) float64 {
+	return ds.avgLength[field]
+}
+
+// DocumentFrequency is the number of documents in the collection containing a term in a field. The frequencies of
+// terms that do not occur in any of the documents are fetched from the statistics source.
+func (ds *DocumentStatistics) DocumentFrequency(term, field string) (float64, error) {
+	df, _, err := ds.frequencies(term, field)
+	return df, err
+}
+
+// TotalTermFrequency is the number of times a term occurs in a field in the collection.
+func (ds *DocumentStatistics) TotalTermFrequency(term, field string) (float64, error) {
+	_, ttf, err := ds.frequencies(term, field)
+	return ttf, err
+}
+
+// VocabularySize is the number of tokens in a field in the collection.
+func (ds *DocumentStatistics) VocabularySize(field string) (float64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if v, ok := ds.vocabulary[field]; ok {
+		return v, nil
+	}
+	v, err := ds.source.VocabularySize(field)
+	if err != nil {
+		return 0, err
+	}
+	ds.vocabulary[field] = v
+	return v, nil
+}
+
+func (ds *DocumentStatistics) frequencies(term, field string) (float64, float64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if df, ok := ds.df[field][term]; ok {
+		return df, ds.ttf[field][term], nil
+	}
+	df, err := ds.source.DocumentFrequency(term, field)
+	if err != nil {
+		return 0, 0, err
+	}
+	ttf, err := ds.source.TotalTermFrequency(term, field)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, ok := ds.df[field]; !ok {
+		ds.df[field] = make(map[string]float64)
+		ds.ttf[field] = make(map[string]float64)
+	}
+	ds.df[field][term] = df
+	ds.ttf[field][term] = ttf
+	return df, ttf, nil
+}
+
+// idf is the inverse document frequency of a term in a field.
+func (ds *DocumentStatistics) idf(term, field string) (float64, error) {
+	df, err := ds.DocumentFrequency(term, field)
+	if err != nil || df == 0 {
+		return 0, err
+	}
+	return math.Log(ds.N / df), nil
+}
+
+// fields are the fields of a document that are scored; all of the fields of the document if none are given.
+func (ds *DocumentStatistics) fields(document string, fields []string) ([]string, error) {
+	tf, ok := ds.tf[document]
+	if !ok {
+		return nil, fmt.Errorf("document %s is not in the document statistics", document)
+	}
+	if len(fields) > 0 {
+		return fields, nil
+	}
+	all := make([]string, 0, len(tf))
+	for field := range tf {
+		all = append(all, field)
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+// StatisticsScorer is a scorer of the documents in a DocumentStatistics. The ReRanker sets the statistics of the
+// documents retrieved by each query before they are scored.
+type StatisticsScorer interface {
+	Scorer
+	SetStatistics(ds *DocumentStatistics)
+}
+
+// SourceBM25Scorer scores documents with Okapi BM25.
+type SourceBM25Scorer struct {
+	ds *DocumentStatistics
+	K1 float64
+	B  float64
+}
+
+// SourceDirichletScorer scores documents by their query likelihood, using Dirichlet smoothing.
+type SourceDirichletScorer struct {
+	ds *DocumentStatistics
+	Mu float64
+}
+
+// SourceTFIDFScorer scores documents by the sum of the tf-idf of the query terms.
+type SourceTFIDFScorer struct {
+	ds *DocumentStatistics
+}
+
+// SourceVectorSpaceScorer scores documents by the cosine similarity of the tf-idf vectors of the query and document.
+type SourceVectorSpaceScorer struct {
+	ds *DocumentStatistics
+}
+
+// LinearScorer scores documents by a weighted sum of the scores of other scorers.
+type LinearScorer struct {
+	Scorers []Scorer
+	Weights []float64
+}
+
+func (s *SourceBM25Scorer) Score(query, pmid string, fields ...string) (float64, error) {
+	fields, err := s.ds.fields(pmid, fields)
+	if err != nil {
+		return 0, err
+	}
+	var score float64
+	for _, field := range fields {
+		k := s.K1 * (1 - s.B + s.B*s.ds.Length(field, pmid)/s.ds.AverageLength(field))
+		for _, term := range QueryTerms(query) {
+			tf := s.ds.TermFrequency(term, field, pmid)
+			if tf == 0 {
+				continue
+			}
+			df, err := s.ds.DocumentFrequency(term, field)
+			if err != nil {
+				return 0, err
+			}
+			idf := math.Log(1 + (s.ds.N-df+0.5)/(df+0.5))
+			score += idf * (tf * (s.K1 + 1)) / (tf + k)
+		}
+	}
+	return score, nil
+}
+
+func (s *SourceBM25Scorer) SetStatistics(ds *DocumentStatistics) {
+	s.ds = ds
+}
+
+func (s *SourceDirichletScorer) Score(query, pmid string, fields ...string) (float64, error) {
+	fields, err := s.ds.fields(pmid, fields)
+	if err != nil {
+		return 0, err
+	}
+	var score float64
+	for _, field := range fields {
+		v, err := s.ds.VocabularySize(field)
+		if err != nil {
+			return 0, err
+		}
+		if v == 0 {
+			continue
+		}
+		dl := s.ds.Length(field, pmid)
+		for _, term := range QueryTerms(query) {
+			ttf, err := s.ds.TotalTermFrequency(term, field)
+			if err != nil {
+				return 0, err
+			}
+			// Terms that are not in the collection cannot be smoothed, and so do not contribute to the score.
+			if ttf == 0 {
+				continue
+			}
+			score += math.Log((s.ds.TermFrequency(term, field, pmid) + s.Mu*(ttf/v)) / (dl + s.Mu))
+		}
+	}
+	return score, nil
+}
+
+func (s *SourceDirichletScorer) SetStatistics(ds *DocumentStatistics) {
+	s.ds = ds
+}
+
+func (s *SourceTFIDFScorer) Score(query, pmid string, fields ...string) (float64, error) {
+	fields, err := s.ds.fields(pmid, fields)
+	if err != nil {
+		return 0, err
+	}
+	var score float64
+	for _, field := range fields {
+		for _, term := range QueryTerms(query) {
+			tf := s.ds.TermFrequency(term, field, pmid)
+			if tf == 0 {
+				continue
+			}
+			idf, err := s.ds.idf(term, field)
+			if err != nil {
+				return 0, err
+			}
+			score += tf * idf
+		}
+	}
+	return score, nil
+}
+
+func (s *SourceTFIDFScorer) SetStatistics(ds *DocumentStatistics) {
+	s.ds = ds
+}
+
+func (s *SourceVectorSpaceScorer) Score(query, pmid string, fields ...string) (float64, error) {
+	fields, err := s.ds.fields(pmid, fields)
+	if err != nil {
+		return 0, err
+	}
+	qtf := make(map[string]float64)
+	for _, term := range QueryTerms(query) {
+		qtf[term]++
+	}
+
+	var dot, qNorm, dNorm float64
+	for _, field := range fields {
+		for term, tf := range s.ds.tf[pmid][field] {
+			idf, err := s.ds.idf(term, field)
+			if err != nil {
+				return 0, err
+			}
+			d := tf * idf
+			dNorm += d * d
+			dot += qtf[term] * idf * d
+		}
+		for term, tf := range qtf {
+			idf, err := s.ds.idf(term, field)
+			if err != nil {
+				return 0, err
+			}
+			qNorm += (tf * idf) * (tf * idf)
+		}
+	}
+	if qNorm == 0 || dNorm == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(qNorm) * math.Sqrt(dNorm)), nil
+}
+
+func (s *SourceVectorSpaceScorer) SetStatistics(ds *DocumentStatistics) {
+	s.ds = ds
+}
+
+func (s LinearScorer) Score(query, pmid string, fields ...string) (float64, error) {
+	var score float64
+	for i, scorer := range s.Scorers {
+		if s.Weights[i] == 0 {
+			continue
+		}
+		v, err := scorer.Score(query, pmid, fields...)
+		if err != nil {
+			return 0, err
+		}
+		score += s.Weights[i] * v
+	}
+	return score, nil
+}
+
+// SetStatistics sets the statistics of each of the scorers that score documents from statistics.
+func (s LinearScorer) SetStatistics(ds *DocumentStatistics) {
+	for _, scorer := range s.Scorers {
+		if scorer, ok := scorer.(StatisticsScorer); ok {
+			scorer.SetStatistics(ds)
+		}
+	}
+}
+
+// QueryTerms tokenises the text of a query into lowercase terms, removing stopwords and applying the Analyser.
+func QueryTerms(query string) []string {
+	var terms []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if preprocess.GeneralStopwords[token] {
+			continue
+		}
+		if token = analyse(token); len(token) > 0 {
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
+
+// QueryText is the text of the keywords of a Boolean query that are not negated, which documents retrieved by the
+// query are scored against.
+func QueryText(q cqr.CommonQueryRepresentation) string {
+	var text []string
+	var walk func(q cqr.CommonQueryRepresentation)
+	walk = func(q cqr.CommonQueryRepresentation) {
+		switch q := q.(type) {
+		case cqr.Keyword:
+			text = append(text, q.QueryString)
+		case cqr.BooleanQuery:
+			for i, child := range q.Children {
+				if i > 0 && strings.EqualFold(q.Operator, cqr.NOT) {
+					break
+				}
+				walk(child)
+			}
+		}
+	}
+	walk(q)
+	return strings.Join(text, " ")
+}
+
+// ReRanker ranks the documents retrieved by Boolean queries from any statistics source, so that the documents most
+// likely to be relevant can be screened first.
+type ReRanker struct {
+	source  stats.StatisticsSource
+	scorer  Scorer
+	fields  []string
+	runName string
+	n       float64
+}
+
+// ReRankFields sets the fields of the documents that are scored (by default, all of them).
+func ReRankFields(fields ...string) func(*ReRanker) {
+	return func(r *ReRanker) {
+		r.fields = fields
+	}
+}
+
+// ReRankRunName sets the name of the runs of ranked documents.
+func ReRankRunName(name string) func(*ReRanker) {
+	return func(r *ReRanker) {
+		r.runName = name
+	}
+}
+
+// ReRankCollectionSize sets the number of documents in the collection (by default, it is read from the statistics
+// source).
+func ReRankCollectionSize(n float64) func(*ReRanker) {
+	return func(r *ReRanker) {
+		r.n = n
+	}
+}
+
+// NewReRanker creates a re-ranker of the documents retrieved from a statistics source. If the scorer is a
+// StatisticsScorer, it is given the statistics of the documents retrieved by each query before they are scored.
+func NewReRanker(ss stats.StatisticsSource, scorer Scorer, options ...func(*ReRanker)) ReRanker {
+	r := ReRanker{
+		source:  ss,
+		scorer:  scorer,
+		runName: "groove_rerank",
+	}
+	for _, option := range options {
+		option(&r)
+	}
+	return r
+}
+
+// ReRank retrieves the documents of a Boolean query and ranks them by their score, highest first. Documents with the
+// same score are ranked in order of their identifiers.
+func (r ReRanker) ReRank(q pipeline.Query) (trecresults.ResultList, error) {
+	ds, err := r.statistics(q)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := r.score(r.scorer, QueryText(q.Query), ds)
+	if err != nil {
+		return nil, err
+	}
+	return r.rank(q.Topic, ds.Documents(), scores), nil
+}
+
+// statistics retrieves the documents of a query and reads their statistics.
+func (r ReRanker) statistics(q pipeline.Query) (*DocumentStatistics, error) {
+	ids, err := stats.GetDocumentIDs(q, r.source)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]string, len(ids))
+	for i, id := range ids {
+		documents[i] = strconv.Itoa(int(id))
+	}
+	return NewDocumentStatistics(r.source, documents, r.n)
+}
+
+// score scores each of the documents, in the order of ds.Documents().
+func (r ReRanker) score(scorer Scorer, text string, ds *DocumentStatistics) ([]float64, error) {
+	if s, ok := scorer.(StatisticsScorer); ok {
+		s.SetStatistics(ds)
+	}
+	documents := ds.Documents()
+	scores := make([]float64, len(documents))
+	for i, document := range documents {
+		score, err := scorer.Score(text, document, r.fields...)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
+
+func (r ReRanker) rank(topic string, documents []string, scores []float64) trecresults.ResultList {
+	order := make([]int, len(documents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	list := make(trecresults.ResultList, len(order))
+	for i, j := range order {
+		list[i] = &trecresults.Result{
+			Topic:     topic,
+			Iteration: "0",
+			DocId:     documents[j],
+			Rank:      int64(i + 1),
+			Score:     scores[j],
+			RunName:   r.runName,
+		}
+	}
+	return list
+}
+
+// linearWeights are the candidate weights of each scorer tried by Learn.
+var linearWeights = []float64{0, 0.001, 0.01, 0.1, 0.25, 0.5, 1, 2, 4, 10, 100}
+
+// Learn learns the weights of a linear combination of scorers with coordinate ascent, maximising the mean average
+// precision of the documents retrieved by training queries and ranked by the combination. Queries without relevance
+// assessments are skipped. The weights of each scorer start at one, and each is set in turn to the candidate weight
+// that most improves the mean average precision, until none of them improve it.
+func (r ReRanker) Learn(queries []pipeline.Query, qrels trecresults.QrelsFile, scorers ...Scorer) (LinearScorer, error) {
+	type topicFeatures struct {
+		topic     string
+		documents []string
+		features  [][]float64 // scorer -> document
+	}
+	var training []topicFeatures
+	for _, q := range queries {
+		if _, ok := qrels.Qrels[q.Topic]; !ok {
+			continue
+		}
+		ds, err := r.statistics(q)
+		if err != nil {
+			return LinearScorer{}, fmt.Errorf("topic %s: %v", q.Topic, err)
+		}
+		t := topicFeatures{topic: q.Topic, documents: ds.Documents(), features: make([][]float64, len(scorers))}
+		text := QueryText(q.Query)
+		for i, scorer := range scorers {
+			t.features[i], err = r.score(scorer, text, ds)
+			if err != nil {
+				return LinearScorer{}, fmt.Errorf("topic %s: %v", q.Topic, err)
+			}
+		}
+		training = append(training, t)
+	}
+	if len(training) == 0 {
+		return LinearScorer{}, fmt.Errorf("none of the queries have relevance assessments")
+	}
+
+	objective := func(weights []float64) float64 {
+		var sum float64
+		for _, t := range training {
+			scores := make([]float64, len(t.documents))
+			for i, features := range t.features {
+				for j, v := range features {
+					scores[j] += weights[i] * v
+				}
+			}
+			list := r.rank(t.topic, t.documents, scores)
+			// Topics without relevant documents have an undefined average precision.
+			if ap := eval.AP.Score(&list, qrels.Qrels[t.topic]); !math.IsNaN(ap) {
+				sum += ap
+			}
+		}
+		return sum / float64(len(training))
+	}
+
+	weights := make([]float64, len(scorers))
+	for i := range weights {
+		weights[i] = 1
+	}
+	best := objective(weights)
+	for improved := true; improved; {
+		improved = false
+		for i := range weights {
+			current := weights[i]
+			for _, w := range linearWeights {
+				weights[i] = w
+				if score := objective(weights); score > best {
+					best, current, improved = score, w, true
+				}
+			}
+			weights[i] = current
+		}
+	}
+	return LinearScorer{Scorers: scorers, Weights: weights}, nil
+}
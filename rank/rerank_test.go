@@ -0,0 +1,183 @@
+package rank_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/preprocess"
+	"github.com/hscells/groove/rank"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"testing"
+)
+
+// rerankSource is a statistics source of a handful of documents, each retrieved by any query.
+type rerankSource struct {
+	docs map[string]map[string]float64
+	df   map[string]float64
+	ttf  map[string]float64
+}
+
+func (s rerankSource) SearchOptions() stats.SearchOptions { return stats.SearchOptions{Size: 10} }
+func (s rerankSource) Parameters() map[string]float64     { return nil }
+func (s rerankSource) TermFrequency(term, field, document string) (float64, error) {
+	return s.docs[document][term], nil
+}
+func (s rerankSource) TermVector(document string) (stats.TermVector, error) {
+	var tv stats.TermVector
+	for term, tf := range s.docs[document] {
+		tv = append(tv, stats.TermVectorTerm{
+			Term:               term,
+			Field:              "text",
+			TermFrequency:      tf,
+			TotalTermFrequency: s.ttf[term],
+			DocumentFrequency:  s.df[term],
+		})
+	}
+	return tv, nil
+}
+func (s rerankSource) DocumentFrequency(term, field string) (float64, error) {
+	return s.df[term], nil
+}
+func (s rerankSource) TotalTermFrequency(term, field string) (float64, error) {
+	return s.ttf[term], nil
+}
+func (s rerankSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	return 0, nil
+}
+func (s rerankSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
+	return float64(len(s.docs)), nil
+}
+func (s rerankSource) VocabularySize(field string) (float64, error) { return 100000, nil }
+func (s rerankSource) Execute(query pipeline.Query, options stats.SearchOptions) (trecresults.ResultList, error) {
+	var results trecresults.ResultList
+	for id := range s.docs {
+		results = append(results, &trecresults.Result{Topic: query.Topic, DocId: id})
+	}
+	return results, nil
+}
+func (s rerankSource) CollectionSize() (float64, error) { return 1000, nil }
+
+// priorScorer scores documents independently of the query.
+type priorScorer map[string]float64
+
+func (s priorScorer) Score(query, pmid string, fields ...string) (float64, error) {
+	return s[pmid], nil
+}
+
+func TestReRanker(t *testing.T) {
+	s := rerankSource{
+		docs: map[string]map[string]float64{
+			"1": {"stroke": 3, "aspirin": 1, "trial": 6},
+			"2": {"stroke": 1, "trial": 19},
+			"3": {"aspirin": 1, "dose": 4},
+			"4": {"trial": 8},
+		},
+		df:  map[string]float64{"stroke": 50, "aspirin": 10, "trial": 200, "dose": 100},
+		ttf: map[string]float64{"stroke": 100, "aspirin": 20, "trial": 1000, "dose": 300},
+	}
+	q := pipeline.NewQuery("1", "1", cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("stroke", "text"),
+			cqr.NewKeyword("aspirin", "text"),
+		}),
+		cqr.NewBooleanQuery(cqr.NOT, []cqr.CommonQueryRepresentation{
+			cqr.NewKeyword("the", "text"),
+			cqr.NewKeyword("trial", "text"),
+		}),
+	}))
+	if text := rank.QueryText(q.Query); text != "stroke aspirin the" {
+		t.Errorf("expected the negated keywords to be excluded from the text of the query, got %q", text)
+	}
+
+	scorers := map[string]rank.Scorer{
+		"bm25":      &rank.SourceBM25Scorer{K1: 1.2, B: 0.75},
+		"dirichlet": &rank.SourceDirichletScorer{Mu: 2000},
+		"tfidf":     &rank.SourceTFIDFScorer{},
+		"vsm":       &rank.SourceVectorSpaceScorer{},
+	}
+	for name, scorer := range scorers {
+		results, err := rank.NewReRanker(s, scorer, rank.ReRankRunName(name)).ReRank(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("%s: expected every retrieved document to be ranked, got %d", name, len(results))
+		}
+		if results[0].DocId != "1" || results[3].DocId != "4" {
+			t.Errorf("%s: expected documents 1 and 4 to be ranked first and last, got %s and %s", name, results[0].DocId, results[3].DocId)
+		}
+		for i, r := range results {
+			if r.Rank != int64(i+1) || r.RunName != name || r.Topic != "1" {
+				t.Errorf("%s: unexpected result %+v", name, r)
+			}
+			if i > 0 && r.Score > results[i-1].Score {
+				t.Errorf("%s: expected the results to be in order of score", name)
+			}
+		}
+	}
+
+	qrels := trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{
+		"1": {
+			"3": &trecresults.Qrel{Topic: "1", DocId: "3", Score: 2},
+			"1": &trecresults.Qrel{Topic: "1", DocId: "1", Score: 0},
+		},
+	}}
+	r := rank.NewReRanker(s, nil)
+	linear, err := r.Learn([]pipeline.Query{q}, qrels, &rank.SourceBM25Scorer{K1: 1.2, B: 0.75}, priorScorer{"3": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linear.Weights) != 2 || linear.Weights[1] == 0 {
+		t.Fatalf("expected the prior to be weighted, got %v", linear.Weights)
+	}
+	results, err := rank.NewReRanker(s, linear).ReRank(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].DocId != "3" {
+		t.Errorf("expected the learned combination to rank the relevant document first, got %s", results[0].DocId)
+	}
+}
+
+func TestDocumentStatisticsAnalyser(t *testing.T) {
+	rank.Analyser = preprocess.PorterStem
+	defer func() { rank.Analyser = nil }()
+
+	s := rerankSource{
+		docs: map[string]map[string]float64{
+			"1": {"strokes": 2, "stroke": 1, "trials": 4},
+			"2": {"stroking": 1},
+		},
+		df:  map[string]float64{"strokes": 20, "stroke": 50, "trials": 200, "stroking": 5},
+		ttf: map[string]float64{"strokes": 40, "stroke": 100, "trials": 1000, "stroking": 5},
+	}
+	ds, err := rank.NewDocumentStatistics(s, []string{"1", "2"}, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.N != 500 {
+		t.Errorf("expected the collection size to be the one given, got %f", ds.N)
+	}
+
+	terms := rank.QueryTerms("Strokes in trials")
+	if len(terms) != 2 || terms[0] != "stroke" || terms[1] != "trial" {
+		t.Fatalf("expected the query terms to be stemmed, got %v", terms)
+	}
+	if tf := ds.TermFrequency(terms[0], "text", "1"); tf != 3 {
+		t.Errorf("expected the frequencies of the terms of the document to be stemmed like the query, got %f", tf)
+	}
+	if tf := ds.TermFrequency(terms[1], "text", "1"); tf != 4 {
+		t.Errorf("expected trials to be stemmed like the query, got %f", tf)
+	}
+	if df, err := ds.DocumentFrequency(terms[0], "text"); err != nil || df != 75 {
+		t.Errorf("expected the document frequencies of the terms stemmed to stroke to be summed once each, got %f (%v)", df, err)
+	}
+
+	results, err := rank.NewReRanker(s, &rank.SourceBM25Scorer{K1: 1.2, B: 0.75}).ReRank(pipeline.NewQuery("1", "1", cqr.NewKeyword("strokes", "text")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Score == 0 || results[1].Score == 0 {
+		t.Errorf("expected both documents to match the stemmed query, got %v", results)
+	}
+}
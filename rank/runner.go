@@ -85,7 +85,7 @@ func index(pmids []int, e stats.EntrezStatisticsSource) (*Posting, error) {
 }
 
 func newPostingFromPMIDS(pmids []int, topic string, indexPath string, e stats.EntrezStatisticsSource) (*Posting, error) {
-	cachePath := path.Join(indexPath, topic)
+	cachePath := path.Join(indexPath, analysedKey(topic))
 
 	var posting *Posting
 
@@ -120,7 +120,7 @@ func newPostingFromPMIDS(pmids []int, topic string, indexPath string, e stats.En
 func newPosting(query, indexPath string, e stats.EntrezStatisticsSource) (*Posting, error) {
 	h := sha256.New()
 	h.Write([]byte(query))
-	id := fmt.Sprintf("%x", h.Sum(nil))
+	id := analysedKey(fmt.Sprintf("%x", h.Sum(nil)))
 
 	cachePath := path.Join(indexPath, id)
 
@@ -177,8 +177,10 @@ func (r Runner) run(done chan bool, scoredc chan ScoredDocuments, errc chan erro
 			errc <- err
 			return
 		}
-		r.scorer.posting(posting)
-		r.scorer.entrez(r.EntrezStatisticsSource)
+		if s, ok := r.scorer.(indexedScorer); ok {
+			s.posting(posting)
+			s.entrez(r.EntrezStatisticsSource)
+		}
 
 		fmt.Println("scoring documents")
 		scored := make([]ScoredDocument, len(posting.DocLens))
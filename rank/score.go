@@ -10,8 +10,14 @@ import (
 	"sync"
 )
 
+// Scorer scores how well a document matches a query, optionally only using some fields of the document.
 type Scorer interface {
 	Score(query, pmid string, fields ...string) (float64, error)
+}
+
+// indexedScorer is a scorer of documents fetched from Entrez, which the Runner indexes before they are scored.
+type indexedScorer interface {
+	Scorer
 	posting(p *Posting)
 	entrez(e stats.EntrezStatisticsSource)
 }
@@ -96,11 +102,11 @@ func (s SumTFScorer) Score(query, pmid string, fields ...string) (float64, error
 	return tf, nil
 }
 
-func (s SumTFScorer) posting(p *Posting) {
+func (s *SumTFScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s SumTFScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *SumTFScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -123,11 +129,11 @@ func (s LnL2Scorer) Score(query, pmid string, fields ...string) (float64, error)
 	return lnl2, nil
 }
 
-func (s LnL2Scorer) posting(p *Posting) {
+func (s *LnL2Scorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s LnL2Scorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *LnL2Scorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -167,11 +173,11 @@ func (s SumIDFScorer) Score(query, pmid string, fields ...string) (float64, erro
 	return idf, nil
 }
 
-func (s SumIDFScorer) posting(p *Posting) {
+func (s *SumIDFScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s SumIDFScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *SumIDFScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -195,11 +201,11 @@ func (s PubDateScorer) Score(query, pmid string, fields ...string) (float64, err
 	return float64(s.p.DocDates[hash(pmid)]), nil
 }
 
-func (s PubDateScorer) posting(p *Posting) {
+func (s *PubDateScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s PubDateScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *PubDateScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -220,11 +226,11 @@ func (s PosScorer) Score(query, pmid string, fields ...string) (float64, error)
 	return sumTf, nil
 }
 
-func (s PosScorer) posting(p *Posting) {
+func (s *PosScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s PosScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *PosScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -264,11 +270,11 @@ func (s VectorSpaceScorer) Score(query, pmid string, fields ...string) (float64,
 
 }
 
-func (s VectorSpaceScorer) posting(p *Posting) {
+func (s *VectorSpaceScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s VectorSpaceScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *VectorSpaceScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -311,11 +317,11 @@ func (s TitleAbstractScorer) Score(query, pmid string, fields ...string) (float6
 	return score, nil
 }
 
-func (s TitleAbstractScorer) posting(p *Posting) {
+func (s *TitleAbstractScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s TitleAbstractScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *TitleAbstractScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -344,11 +350,11 @@ func (s DocLenScorer) Score(query, pmid string, fields ...string) (float64, erro
 	return l, nil
 }
 
-func (s DocLenScorer) posting(p *Posting) {
+func (s *DocLenScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s DocLenScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *DocLenScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -366,11 +372,11 @@ func (s DirichletTermProbScorer) Score(query, pmid string, fields ...string) (fl
 	return sumProb, nil
 }
 
-func (s DirichletTermProbScorer) posting(p *Posting) {
+func (s *DirichletTermProbScorer) posting(p *Posting) {
 	s.p = p
 }
 
-func (s DirichletTermProbScorer) entrez(e stats.EntrezStatisticsSource) {
+func (s *DirichletTermProbScorer) entrez(e stats.EntrezStatisticsSource) {
 	s.s = e
 }
 
@@ -505,7 +511,7 @@ func fastTokenise(p *Posting, query string, fields ...string) ([]string, error)
 	tokensMu.Lock()
 	defer tokensMu.Unlock()
 	query = strings.ReplaceAll(strings.ToLower(query), `"`, "")
-	q := hash(query)
+	q := hash(analysedKey(query))
 	if tokensCache == nil {
 		tokensCache = make(map[uint32][]string)
 	}
@@ -534,6 +540,15 @@ func fastTokenise(p *Posting, query string, fields ...string) ([]string, error)
 				toks = append(toks, term)
 			}
 		}
+		if Analyser != nil {
+			analysed := make([]string, 0, len(toks))
+			for _, tok := range toks {
+				if tok = Analyser(tok); len(tok) > 0 {
+					analysed = append(analysed, tok)
+				}
+			}
+			toks = analysed
+		}
 		tokensCache[q] = toks
 
 	}
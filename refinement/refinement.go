@@ -0,0 +1,278 @@
+// Package refinement supports interactively refining Boolean queries. A query is broken down into its clauses, and
+// for each clause the number of documents retrieved and how well it retrieves a set of seed studies is reported, along
+// with query performance predictions for the query and suggested transformations to it.
+package refinement
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/analysis"
+	"github.com/hscells/groove/combinator"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/learning"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/query"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Request is a query to refine.
+type Request struct {
+	// Query is the query to refine, written in Syntax.
+	Query string `json:"query"`
+	// Syntax is the syntax of the query (pubmed, medline or cqr). If empty, it is detected from the query.
+	Syntax string `json:"syntax,omitempty"`
+	// Topic identifies the query.
+	Topic string `json:"topic,omitempty"`
+	// Seeds are the PMIDs of studies the query should retrieve.
+	Seeds []string `json:"seeds,omitempty"`
+	// Suggest is whether suggested transformations of the query should be made.
+	Suggest bool `json:"suggest,omitempty"`
+}
+
+// Clause is a clause of a query and what it retrieves.
+type Clause struct {
+	// Path is the location of the clause in the query, as the index of each child from the root (e.g. /0/2).
+	Path      string `json:"path"`
+	Operator  string `json:"operator,omitempty"`
+	Query     string `json:"query"`
+	Retrieved int    `json:"retrieved"`
+	// Seeds is how well the clause retrieves the seed studies, if any were given.
+	Seeds    Scores   `json:"seeds,omitempty"`
+	Children []Clause `json:"children,omitempty"`
+}
+
+// Suggestion is a transformation of a query and what the transformed query retrieves.
+type Suggestion struct {
+	Transformation string `json:"transformation"`
+	Query          string `json:"query"`
+	Retrieved      int    `json:"retrieved"`
+	Seeds          Scores `json:"seeds,omitempty"`
+}
+
+// Response is the refinement of a query.
+type Response struct {
+	Topic        string       `json:"topic"`
+	Query        Clause       `json:"query"`
+	Measurements Scores       `json:"measurements,omitempty"`
+	Suggestions  []Suggestion `json:"suggestions,omitempty"`
+}
+
+// Scores are the values of measures, keyed by the name of the measure.
+type Scores map[string]float64
+
+// MarshalJSON encodes the scores, writing values that are not a number or are infinite (which JSON cannot represent)
+// as null.
+func (s Scores) MarshalJSON() ([]byte, error) {
+	m := make(map[string]*float64, len(s))
+	for k, v := range s {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			m[k] = nil
+			continue
+		}
+		v := v
+		m[k] = &v
+	}
+	return json.Marshal(m)
+}
+
+// Refiner refines queries using a statistics source.
+type Refiner struct {
+	ss              stats.StatisticsSource
+	cache           combinator.QueryCacher
+	executor        analysis.MeasurementExecutor
+	measurements    []analysis.Measurement
+	evaluators      []eval.Evaluator
+	transformations []learning.Transformation
+	suggestions     int
+}
+
+// RefinerMeasurements sets the query performance predictors computed for queries.
+func RefinerMeasurements(measurements ...analysis.Measurement) func(*Refiner) {
+	return func(r *Refiner) {
+		r.measurements = measurements
+	}
+}
+
+// RefinerEvaluators sets the evaluation measures used to score clauses against the seed studies (default: recall,
+// precision, and the number of seed studies retrieved).
+func RefinerEvaluators(evaluators ...eval.Evaluator) func(*Refiner) {
+	return func(r *Refiner) {
+		r.evaluators = evaluators
+	}
+}
+
+// RefinerTransformations sets the transformations used to suggest variations of queries.
+func RefinerTransformations(transformations ...learning.Transformation) func(*Refiner) {
+	return func(r *Refiner) {
+		r.transformations = transformations
+	}
+}
+
+// RefinerSuggestions sets the maximum number of suggestions made for a query (default: 10).
+func RefinerSuggestions(n int) func(*Refiner) {
+	return func(r *Refiner) {
+		r.suggestions = n
+	}
+}
+
+// RefinerQueryCache sets the cache of the documents retrieved by clauses (default: in memory).
+func RefinerQueryCache(cache combinator.QueryCacher) func(*Refiner) {
+	return func(r *Refiner) {
+		r.cache = cache
+	}
+}
+
+// RefinerMeasurementExecutor sets how measurements are computed and cached (default: in memory).
+func RefinerMeasurementExecutor(executor analysis.MeasurementExecutor) func(*Refiner) {
+	return func(r *Refiner) {
+		r.executor = executor
+	}
+}
+
+// NewRefiner creates a new refiner for queries executed on a statistics source.
+func NewRefiner(ss stats.StatisticsSource, options ...func(*Refiner)) *Refiner {
+	r := &Refiner{
+		ss:          ss,
+		cache:       combinator.NewMapQueryCache(),
+		executor:    analysis.NewMemoryMeasurementExecutor(),
+		evaluators:  []eval.Evaluator{eval.Recall, eval.Precision, eval.NumRelRet},
+		suggestions: 10,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Refine breaks a query down into clauses and reports what each clause retrieves.
+func (r *Refiner) Refine(req Request) (Response, error) {
+	syntax := req.Syntax
+	if len(syntax) == 0 {
+		syntax = query.DetectSyntax(req.Query)
+	}
+	q, err := query.ParseQuery(syntax, req.Query)
+	if err != nil {
+		return Response{}, err
+	}
+	topic := req.Topic
+	if len(topic) == 0 {
+		topic = strconv.FormatUint(combinator.HashCQR(q), 10)
+	}
+	pq := pipeline.NewQuery(topic, topic, q)
+	qrels := eval.Seeds{topic: req.Seeds}.Qrels().Qrels[topic]
+
+	tree, _, err := combinator.NewLogicalTree(pq, r.ss, r.cache)
+	if err != nil {
+		return Response{}, err
+	}
+	resp := Response{
+		Topic: topic,
+		Query: r.clause(pq, tree.Root, "", qrels),
+	}
+
+	if len(r.measurements) > 0 {
+		scores, err := r.executor.Execute(pq, r.ss, r.measurements...)
+		if err != nil {
+			return Response{}, err
+		}
+		resp.Measurements = make(Scores, len(scores))
+		for i, measurement := range r.measurements {
+			resp.Measurements[measurement.Name()] = scores[i]
+		}
+	}
+
+	if req.Suggest {
+		resp.Suggestions, err = r.suggest(pq, qrels)
+		if err != nil {
+			return Response{}, err
+		}
+	}
+	return resp, nil
+}
+
+// clause reports the documents retrieved by a node of a logical tree and its children.
+func (r *Refiner) clause(pq pipeline.Query, node combinator.LogicalTreeNode, path string, qrels trecresults.Qrels) Clause {
+	docs := node.Documents(r.cache)
+	c := Clause{
+		Path:      path,
+		Query:     combinator.PubMedString(node.Query()),
+		Retrieved: len(docs),
+		Seeds:     r.evaluate(pq, docs, qrels),
+	}
+	if len(path) == 0 {
+		c.Path = "/"
+	}
+	if n, ok := node.(combinator.Combinator); ok {
+		c.Operator = n.String()
+		if q, ok := node.Query().(cqr.BooleanQuery); ok {
+			c.Operator = q.Operator
+		}
+		for i, child := range n.Clauses {
+			c.Children = append(c.Children, r.clause(pq, child, fmt.Sprintf("%s/%d", path, i), qrels))
+		}
+	}
+	return c
+}
+
+// evaluate scores the documents retrieved by a clause against the seed studies.
+func (r *Refiner) evaluate(pq pipeline.Query, docs combinator.Documents, qrels trecresults.Qrels) Scores {
+	if len(qrels) == 0 {
+		return nil
+	}
+	results := docs.Results(pq, pq.Topic)
+	return eval.Evaluate(r.evaluators, &results, trecresults.QrelsFile{Qrels: map[string]trecresults.Qrels{pq.Topic: qrels}}, pq.Topic)
+}
+
+// suggest generates variations of a query and reports what they retrieve. When there are seed studies, suggestions
+// that retrieve more of them, and then fewer documents overall, are ranked first; otherwise, suggestions that retrieve
+// fewer documents are ranked first.
+func (r *Refiner) suggest(pq pipeline.Query, qrels trecresults.Qrels) ([]Suggestion, error) {
+	candidate := learning.NewCandidateQuery(pq.Query, pq.Topic, nil)
+	seen := map[uint64]bool{combinator.HashCQR(pq.Query): true}
+
+	var suggestions []Suggestion
+	for _, transformation := range r.transformations {
+		variations, err := learning.Variations(candidate, r.ss, r.executor, nil, transformation)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variations {
+			h := combinator.HashCQR(v.Query)
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+
+			vq := pipeline.NewQuery(pq.Name, pq.Topic, v.Query)
+			tree, _, err := combinator.NewLogicalTree(vq, r.ss, r.cache)
+			if err != nil {
+				return nil, err
+			}
+			docs := tree.Documents(r.cache)
+			suggestions = append(suggestions, Suggestion{
+				Transformation: transformation.Name(),
+				Query:          combinator.PubMedString(v.Query),
+				Retrieved:      len(docs),
+				Seeds:          r.evaluate(vq, docs, qrels),
+			})
+		}
+	}
+
+	seeds := eval.NumRelRet.Name()
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Seeds[seeds] != b.Seeds[seeds] {
+			return a.Seeds[seeds] > b.Seeds[seeds]
+		}
+		return a.Retrieved < b.Retrieved
+	})
+	if r.suggestions > 0 && len(suggestions) > r.suggestions {
+		suggestions = suggestions[:r.suggestions]
+	}
+	return suggestions, nil
+}
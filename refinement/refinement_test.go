@@ -0,0 +1,142 @@
+package refinement_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/eval"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/refinement"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// keywordSource retrieves a fixed set of documents for each keyword.
+type keywordSource map[string][]string
+
+func (s keywordSource) SearchOptions() stats.SearchOptions { return stats.SearchOptions{} }
+func (s keywordSource) Parameters() map[string]float64     { return nil }
+func (s keywordSource) TermFrequency(term, field, document string) (float64, error) {
+	return 0, nil
+}
+func (s keywordSource) TermVector(document string) (stats.TermVector, error) { return nil, nil }
+func (s keywordSource) DocumentFrequency(term, field string) (float64, error) {
+	return 0, nil
+}
+func (s keywordSource) TotalTermFrequency(term, field string) (float64, error) {
+	return 0, nil
+}
+func (s keywordSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	return 0, nil
+}
+func (s keywordSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
+	return 0, nil
+}
+func (s keywordSource) VocabularySize(field string) (float64, error) { return 0, nil }
+func (s keywordSource) CollectionSize() (float64, error)             { return 0, nil }
+func (s keywordSource) Execute(query pipeline.Query, options stats.SearchOptions) (trecresults.ResultList, error) {
+	kw, ok := query.Query.(cqr.Keyword)
+	if !ok {
+		return nil, nil
+	}
+	var list trecresults.ResultList
+	for i, id := range s[strings.ToLower(kw.QueryString)] {
+		list = append(list, &trecresults.Result{Topic: query.Topic, DocId: id, Rank: int64(i + 1)})
+	}
+	return list, nil
+}
+
+// undefinedMeasurement is a query performance predictor that cannot be computed (e.g. the mean IDF of no keywords).
+type undefinedMeasurement struct{}
+
+func (undefinedMeasurement) Name() string { return "Undefined" }
+func (undefinedMeasurement) Execute(q pipeline.Query, s stats.StatisticsSource) (float64, error) {
+	return math.NaN(), nil
+}
+
+func post(t *testing.T, url string, body string) (int, []byte) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var b bytes.Buffer
+	_, err = b.ReadFrom(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, b.Bytes()
+}
+
+func TestServer(t *testing.T) {
+	ss := keywordSource{
+		"heart":   {"1", "2"},
+		"cardiac": {"3"},
+		"attack":  {"1", "3", "4"},
+	}
+	server := httptest.NewServer(refinement.NewServer(refinement.NewRefiner(ss)))
+	defer server.Close()
+
+	status, b := post(t, server.URL+"/refine", `{"query": "(heart[tiab] OR cardiac[tiab]) AND attack[tiab]", "syntax": "pubmed", "topic": "1", "seeds": ["1", "2"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected the query to be refined, got %d: %s", status, b)
+	}
+	var resp refinement.Response
+	err := json.Unmarshal(b, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := resp.Query
+	if resp.Topic != "1" || root.Path != "/" || strings.ToLower(root.Operator) != cqr.AND || root.Retrieved != 2 {
+		t.Errorf("expected the query to retrieve documents 1 and 3, got %+v", root)
+	}
+	if root.Seeds[eval.NumRelRet.Name()] != 1 {
+		t.Errorf("expected the query to retrieve one seed study, got %v", root.Seeds)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("expected a clause for each child of the query, got %d", len(root.Children))
+	}
+	or := root.Children[0]
+	if or.Path != "/0" || or.Retrieved != 3 || len(or.Children) != 2 || or.Seeds[eval.NumRelRet.Name()] != 2 {
+		t.Errorf("expected the OR clause to retrieve both seed studies, got %+v", or)
+	}
+	if heart := or.Children[0]; heart.Path != "/0/0" || heart.Retrieved != 2 || !strings.Contains(heart.Query, "heart") {
+		t.Errorf("expected the keyword clause to be reported in PubMed syntax, got %+v", heart)
+	}
+
+	status, _ = post(t, server.URL+"/refine", `{"query": "heart[tiab]", "syntax": "ovid"}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("expected a query that cannot be parsed to be unprocessable, got %d", status)
+	}
+	status, _ = post(t, server.URL+"/refine", `{"syntax": "pubmed"}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected a request without a query to be a bad request, got %d", status)
+	}
+	status, _ = post(t, server.URL+"/refine", `{"query": `)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected malformed JSON to be a bad request, got %d", status)
+	}
+
+	// Measurements that are not a number are encoded as null, rather than failing after the response has started.
+	measured := httptest.NewServer(refinement.NewServer(refinement.NewRefiner(ss, refinement.RefinerMeasurements(undefinedMeasurement{}))))
+	defer measured.Close()
+	status, b = post(t, measured.URL+"/refine", `{"query": "heart[tiab]", "syntax": "pubmed"}`)
+	if status != http.StatusOK || !strings.Contains(string(b), `"measurements":{"Undefined":null}`) {
+		t.Errorf("expected the undefined measurement to be null, got %d: %s", status, b)
+	}
+
+	resp2, err := http.Get(server.URL + "/refine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusMethodNotAllowed || resp2.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("expected only POST to be allowed, got %d", resp2.StatusCode)
+	}
+}
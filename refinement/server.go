@@ -0,0 +1,87 @@
+package refinement
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Server serves a refiner over HTTP. Queries are refined by POSTing a JSON Request to /refine, which responds with a
+// JSON Response. Requests are refined one at a time, as the caches of a refiner are not safe for concurrent use.
+type Server struct {
+	refiner *Refiner
+	mux     *http.ServeMux
+	mu      sync.Mutex
+}
+
+// NewServer creates a new HTTP server for a refiner.
+func NewServer(refiner *Refiner) *Server {
+	s := &Server{
+		refiner: refiner,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/refine", s.refine)
+	return s
+}
+
+// ServeHTTP handles requests to the server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves a refiner on an address (e.g. "localhost:8080").
+func ListenAndServe(addr string, refiner *Refiner) error {
+	return http.ListenAndServe(addr, NewServer(refiner))
+}
+
+func (s *Server) refine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	var req Request
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Query) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing query"))
+		return
+	}
+
+	s.mu.Lock()
+	resp, err := s.refiner.Refine(req)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON encodes a value before writing it, so that a value which cannot be encoded is an internal server error
+// rather than a truncated response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(append(b, '\n'))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
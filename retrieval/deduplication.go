@@ -1,75 +1,192 @@
 package retrieval
 
 import (
-	"github.com/hscells/groove/stats"
 	"github.com/hscells/guru"
 	"github.com/hscells/trecresults"
-	"log"
-	"strconv"
-	"time"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
 )
 
-// Deduplicator removes duplicate documents from a result list.
+// Deduplicator removes duplicate documents from a result list. The first (highest ranked) occurrence of a document is
+// kept. Documents are considered duplicates if they are different versions of the same PMID, share a DOI, or have
+// (nearly) the same title.
 type Deduplicator struct {
-	e stats.EntrezStatisticsSource
+	f              DocumentFetcher
+	versions       bool
+	dois           bool
+	titles         bool
+	titleThreshold float64
 }
 
-func min(a, b int) int {
-	if a <= b {
-		return a
-	}
-	return b
-}
+var doiPattern = regexp.MustCompile(`(?i)(10\.\d{4,9}/\S+)\s*\[doi\]`)
 
+// Handle removes duplicate documents from the result list.
 func (d Deduplicator) Handle(list *trecresults.ResultList) error {
-	// Obtain the pmids from the result list.
-	pmids := make([]int, list.Len())
-	for i, doc := range *list {
+	var docs map[string]guru.MedlineDocument
+	if (d.dois || d.titles) && d.f != nil {
 		var err error
-		pmids[i], err = strconv.Atoi(doc.DocId)
+		docs, err = fetchDocuments(d.f, *list)
 		if err != nil {
 			return err
 		}
 	}
 
-	log.Println("fetching documents")
+	var (
+		pmids  = make(map[string]bool)
+		dois   = make(map[string]bool)
+		titles = make(map[string][][]string)
+	)
+	filter(list, func(r *trecresults.Result) bool {
+		pmid := r.DocId
+		if d.versions {
+			pmid = stripVersion(pmid)
+		}
+		if pmids[pmid] {
+			return false
+		}
+		pmids[pmid] = true
 
-	var docs []guru.MedlineDocument
-reset:
-	fetched, err := d.e.Fetch(pmids)
-	if err != nil {
-		log.Println(err)
-		time.Sleep(5 * time.Second)
-		goto reset
-	}
-	docs = append(docs, fetched...)
-	log.Println("begin de-duplication")
-
-	var removal []int
-	for i := 0; i < len(docs); i++ {
-		for j := 0; j < len(docs); j++ {
-			if i == j {
-				continue
+		doc, ok := docs[stripVersion(r.DocId)]
+		if !ok {
+			return true
+		}
+
+		if d.dois {
+			if doi := documentDOI(doc); len(doi) > 0 {
+				if dois[doi] {
+					return false
+				}
+				dois[doi] = true
+			}
+		}
+
+		if d.titles {
+			title := normaliseTitle(doc.TI)
+			if len(title) == 0 {
+				return true
+			}
+			tokens := titleTokens(title)
+			prefix := tokens[:titlePrefixSize(len(tokens), d.titleThreshold)]
+			for _, token := range prefix {
+				for _, other := range titles[token] {
+					if jaccard(tokens, other) >= d.titleThreshold {
+						return false
+					}
+				}
 			}
-			if docs[i].TI == docs[j].TI {
-				removal = append(removal, i)
+			for _, token := range prefix {
+				titles[token] = append(titles[token], tokens)
 			}
 		}
+		return true
+	})
+	return nil
+}
+
+// documentDOI extracts the DOI of a Medline document.
+func documentDOI(doc guru.MedlineDocument) string {
+	for _, id := range append([]string{doc.LID}, doc.AID...) {
+		if m := doiPattern.FindStringSubmatch(id); m != nil {
+			return strings.ToLower(m[1])
+		}
 	}
+	return ""
+}
 
-	a := make(trecresults.ResultList, list.Len())
-	copy(a, *list)
-	for i := 0; i < a.Len(); i++ {
-		copy(a[i:], a[i+1:])
-		a[len(a)-1] = nil // or the zero value of T
-		a = a[:len(a)-1]
+// normaliseTitle lowercases a title and removes punctuation so titles can be compared.
+func normaliseTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// titleTokens is the sorted set of tokens of a normalised title.
+func titleTokens(title string) []string {
+	tokens := strings.Fields(title)
+	sort.Strings(tokens)
+	set := tokens[:0]
+	for i, t := range tokens {
+		if i == 0 || t != tokens[i-1] {
+			set = append(set, t)
+		}
 	}
-	list = &a
-	return nil
+	return set
 }
 
-func NewDeduplicator(e stats.EntrezStatisticsSource) Deduplicator {
-	return Deduplicator{
-		e: e,
+// titlePrefixSize is the number of (sorted) tokens of a title that are used to find the titles it is compared to. Two
+// sets of n and m tokens with a Jaccard similarity of at least the threshold share at least threshold*max(n, m)
+// tokens, so they must share a token among their first n-ceil(threshold*n)+1 and m-ceil(threshold*m)+1 tokens. Titles
+// that differ anywhere (including in their first words) are therefore still compared.
+func titlePrefixSize(n int, threshold float64) int {
+	size := n - int(math.Ceil(threshold*float64(n)-1e-9)) + 1
+	if size < 1 {
+		return 1
+	}
+	if size > n {
+		return n
+	}
+	return size
+}
+
+// jaccard computes the Jaccard similarity of two sets of tokens.
+func jaccard(a, b []string) float64 {
+	set := make(map[string]int)
+	for _, t := range a {
+		set[t] |= 1
+	}
+	for _, t := range b {
+		set[t] |= 2
+	}
+	if len(set) == 0 {
+		return 0
+	}
+	intersection := 0
+	for _, v := range set {
+		if v == 3 {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(set))
+}
+
+// DeduplicateVersions configures whether different versions of the same PMID are duplicates.
+func DeduplicateVersions(versions bool) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.versions = versions
+	}
+}
+
+// DeduplicateDOIs configures whether documents with the same DOI are duplicates.
+func DeduplicateDOIs(dois bool) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.dois = dois
+	}
+}
+
+// DeduplicateTitles configures whether documents with similar titles are duplicates. Titles are similar if the
+// Jaccard similarity of their tokens is at least the threshold (a threshold of 1 only matches identical titles).
+func DeduplicateTitles(threshold float64) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.titles = threshold > 0
+		d.titleThreshold = threshold
+	}
+}
+
+// NewDeduplicator creates a new deduplicator. By default, PMID versions, DOIs, and titles with a similarity of at
+// least 0.9 are deduplicated.
+func NewDeduplicator(f DocumentFetcher, options ...func(*Deduplicator)) Deduplicator {
+	d := Deduplicator{
+		f:              f,
+		versions:       true,
+		dois:           true,
+		titles:         true,
+		titleThreshold: 0.9,
+	}
+	for _, option := range options {
+		option(&d)
 	}
+	return d
 }
@@ -0,0 +1,104 @@
+package retrieval
+
+import (
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"strings"
+	"time"
+)
+
+// Cutoff truncates a result list to the top k results.
+type Cutoff int
+
+// Handle truncates the result list.
+func (k Cutoff) Handle(list *trecresults.ResultList) error {
+	if k >= 0 && len(*list) > int(k) {
+		*list = (*list)[:k]
+	}
+	return nil
+}
+
+// DateFilter removes documents published outside of a date range. Documents that do not have a date are kept.
+type DateFilter struct {
+	f        DocumentFetcher
+	from, to time.Time
+}
+
+// Handle removes documents outside of the date range from the result list.
+func (d DateFilter) Handle(list *trecresults.ResultList) error {
+	docs, err := fetchDocuments(d.f, *list)
+	if err != nil {
+		return err
+	}
+	filter(list, func(r *trecresults.Result) bool {
+		doc, ok := docs[stripVersion(r.DocId)]
+		if !ok {
+			return true
+		}
+		t, ok := stats.MedlinePublicationDate(doc)
+		if !ok {
+			return true
+		}
+		return !t.Before(d.from) && !t.After(d.to)
+	})
+	return nil
+}
+
+// NewDateFilter creates a filter that keeps documents published between two dates (inclusive).
+func NewDateFilter(f DocumentFetcher, from, to time.Time) DateFilter {
+	return DateFilter{
+		f:    f,
+		from: from,
+		to:   to,
+	}
+}
+
+// PublicationTypeFilter removes documents based on their publication types. If any publication types are included,
+// only documents with one of those types are kept. Documents with any excluded publication type are removed.
+type PublicationTypeFilter struct {
+	f       DocumentFetcher
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// Handle removes documents from the result list based on their publication types.
+func (p PublicationTypeFilter) Handle(list *trecresults.ResultList) error {
+	docs, err := fetchDocuments(p.f, *list)
+	if err != nil {
+		return err
+	}
+	filter(list, func(r *trecresults.Result) bool {
+		doc, ok := docs[stripVersion(r.DocId)]
+		if !ok {
+			return len(p.include) == 0
+		}
+		included := len(p.include) == 0
+		for _, pt := range doc.PT {
+			pt = strings.ToLower(pt)
+			if p.exclude[pt] {
+				return false
+			}
+			if p.include[pt] {
+				included = true
+			}
+		}
+		return included
+	})
+	return nil
+}
+
+// NewPublicationTypeFilter creates a filter using publication types (e.g. "Randomized Controlled Trial").
+func NewPublicationTypeFilter(f DocumentFetcher, include, exclude []string) PublicationTypeFilter {
+	p := PublicationTypeFilter{
+		f:       f,
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+	}
+	for _, pt := range include {
+		p.include[strings.ToLower(pt)] = true
+	}
+	for _, pt := range exclude {
+		p.exclude[strings.ToLower(pt)] = true
+	}
+	return p
+}
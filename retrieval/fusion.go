@@ -0,0 +1,43 @@
+package retrieval
+
+import (
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+)
+
+// Fusion combines a result list with the result lists of the same topic from other runs.
+type Fusion struct {
+	method stats.FusionMethod
+	runs   []map[string]trecresults.ResultList
+}
+
+// Handle replaces the result list with the fusion of itself and the other runs.
+func (f Fusion) Handle(list *trecresults.ResultList) error {
+	if len(*list) == 0 {
+		return nil
+	}
+	topic, runName := (*list)[0].Topic, (*list)[0].RunName
+
+	lists := []trecresults.ResultList{*list}
+	for _, run := range f.runs {
+		if l, ok := run[topic]; ok {
+			lists = append(lists, l)
+		}
+	}
+
+	fused := stats.FuseResults(f.method, topic, lists...)
+	for _, r := range fused {
+		r.RunName = runName
+	}
+	*list = fused
+	return nil
+}
+
+// NewFusion creates a handler that fuses result lists with the lists of other runs (keyed by topic) using CombSUM,
+// CombMNZ, or RRF.
+func NewFusion(method stats.FusionMethod, runs ...map[string]trecresults.ResultList) Fusion {
+	return Fusion{
+		method: method,
+		runs:   runs,
+	}
+}
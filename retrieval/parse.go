@@ -0,0 +1,139 @@
+package retrieval
+
+import (
+	"fmt"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseResultsHandler creates a results handler from a specification of the form `name` or `name:arguments`. The
+// following handlers are available:
+//
+//	deduplicate             remove duplicate PMIDs, DOIs, and titles
+//	deduplicate:0.8         as above, with a title similarity threshold of 0.8
+//	cutoff:100              keep only the top 100 results
+//	dates:19900101-20101231 keep only documents published between two dates
+//	pubtype:Review,!Letter  keep only documents with (or without, using !) publication types
+//	combsum:a.run,b.run     fuse the result list with other runs using CombSUM
+//	combmnz:a.run,b.run     fuse the result list with other runs using CombMNZ
+//	rrf:a.run,b.run         fuse the result list with other runs using reciprocal rank fusion
+//
+// Handlers that filter documents retrieve the documents using the document fetcher.
+func ParseResultsHandler(spec string, f DocumentFetcher) (ResultsHandler, error) {
+	name, arg := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+
+	requiresFetcher := func() error {
+		if f == nil {
+			return fmt.Errorf("results handler %s requires documents to be fetched", name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "deduplicate":
+		var options []func(*Deduplicator)
+		if len(arg) > 0 {
+			threshold, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, DeduplicateTitles(threshold))
+		}
+		if f == nil {
+			options = append(options, DeduplicateDOIs(false), DeduplicateTitles(0))
+		}
+		return NewDeduplicator(f, options...), nil
+	case "cutoff":
+		k, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, err
+		}
+		return Cutoff(k), nil
+	case "dates":
+		if err := requiresFetcher(); err != nil {
+			return nil, err
+		}
+		dates := strings.Split(arg, "-")
+		if len(dates) != 2 {
+			return nil, fmt.Errorf("dates must be in the format from-to, got %s", arg)
+		}
+		from, err := time.Parse("20060102", dates[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := time.Parse("20060102", dates[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewDateFilter(f, from, to), nil
+	case "pubtype":
+		if err := requiresFetcher(); err != nil {
+			return nil, err
+		}
+		var include, exclude []string
+		for _, pt := range strings.Split(arg, ",") {
+			pt = strings.TrimSpace(pt)
+			if strings.HasPrefix(pt, "!") {
+				exclude = append(exclude, pt[1:])
+			} else if len(pt) > 0 {
+				include = append(include, pt)
+			}
+		}
+		return NewPublicationTypeFilter(f, include, exclude), nil
+	case "combsum", "combmnz", "rrf":
+		method := map[string]stats.FusionMethod{
+			"combsum": stats.CombSUM,
+			"combmnz": stats.CombMNZ,
+			"rrf":     stats.RRF,
+		}[name]
+		var runs []map[string]trecresults.ResultList
+		for _, file := range strings.Split(arg, ",") {
+			if len(file) == 0 {
+				continue
+			}
+			r, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
+			results, err := trecresults.ResultsFromReader(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, results.Results)
+		}
+		return NewFusion(method, runs...), nil
+	}
+	return nil, fmt.Errorf("unknown results handler %s", name)
+}
+
+// fetcherCacheSize is the number of documents remembered by the fetcher shared between the handlers in a chain.
+const fetcherCacheSize = 10000
+
+// ParseResultsHandlers creates a chain of results handlers from specifications (see ParseResultsHandler). The
+// documents retrieved by the fetcher are shared between the handlers in the chain.
+func ParseResultsHandlers(specs []string, f DocumentFetcher) (Chain, error) {
+	if f != nil {
+		c, err := NewCachedFetcher(f, fetcherCacheSize)
+		if err != nil {
+			return nil, err
+		}
+		f = c
+	}
+	chain := make(Chain, len(specs))
+	for i, spec := range specs {
+		h, err := ParseResultsHandler(spec, f)
+		if err != nil {
+			return nil, err
+		}
+		chain[i] = h
+	}
+	return chain, nil
+}
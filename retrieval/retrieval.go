@@ -1,9 +1,154 @@
 // package retrieval provides handlers which operate on result lists.
 package retrieval
 
-import "github.com/hscells/trecresults"
+import (
+	"github.com/biogo/ncbi/entrez"
+	"github.com/hashicorp/golang-lru"
+	"github.com/hscells/guru"
+	"github.com/hscells/trecresults"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
 
-// ResultsHandler is the interface for operations on result lists.
+// ResultsHandler is the interface for operations on result lists. Handlers modify the list in place.
 type ResultsHandler interface {
 	Handle(list *trecresults.ResultList) error
 }
+
+// Chain is a sequence of results handlers which are applied to a result list in order.
+type Chain []ResultsHandler
+
+// Handle applies each handler in the chain to the result list, stopping at the first error.
+func (c Chain) Handle(list *trecresults.ResultList) error {
+	for _, h := range c {
+		err := h.Handle(list)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewChain creates a results handler that applies several handlers in order.
+func NewChain(handlers ...ResultsHandler) Chain {
+	return Chain(handlers)
+}
+
+// DocumentFetcher retrieves the Medline documents of PMIDs. The Entrez statistics source is a document fetcher.
+type DocumentFetcher interface {
+	Fetch(pmids []int, options ...func(p *entrez.Parameters)) ([]guru.MedlineDocument, error)
+}
+
+// CachedFetcher is a document fetcher that remembers documents it has fetched, so that several handlers in a chain
+// that require documents only fetch each document once. Only the most recently used documents are remembered.
+type CachedFetcher struct {
+	f    DocumentFetcher
+	docs *lru.Cache
+}
+
+// Fetch retrieves the documents that have not already been fetched.
+func (c CachedFetcher) Fetch(pmids []int, options ...func(p *entrez.Parameters)) ([]guru.MedlineDocument, error) {
+	found := make(map[string]guru.MedlineDocument, len(pmids))
+	var missing []int
+	for _, pmid := range pmids {
+		if v, ok := c.docs.Get(strconv.Itoa(pmid)); ok {
+			found[strconv.Itoa(pmid)] = v.(guru.MedlineDocument)
+		} else {
+			missing = append(missing, pmid)
+		}
+	}
+	if len(missing) > 0 {
+		docs, err := c.f.Fetch(missing, options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			found[doc.PMID] = doc
+			c.docs.Add(doc.PMID, doc)
+		}
+	}
+
+	docs := make([]guru.MedlineDocument, 0, len(pmids))
+	for _, pmid := range pmids {
+		if doc, ok := found[strconv.Itoa(pmid)]; ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// NewCachedFetcher creates a document fetcher that caches at most size documents of another fetcher in memory.
+func NewCachedFetcher(f DocumentFetcher, size int) (CachedFetcher, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return CachedFetcher{}, err
+	}
+	return CachedFetcher{
+		f:    f,
+		docs: c,
+	}, nil
+}
+
+// fetchRetries is the number of times fetching documents is attempted before giving up.
+const fetchRetries = 5
+
+// fetchDocuments retrieves the Medline documents of a result list, keyed by PMID. Document ids with a version suffix
+// (e.g. 12345678.2) are fetched using the PMID without the version.
+func fetchDocuments(f DocumentFetcher, list trecresults.ResultList) (map[string]guru.MedlineDocument, error) {
+	seen := make(map[int]bool)
+	var pmids []int
+	for _, r := range list {
+		pmid, err := strconv.Atoi(stripVersion(r.DocId))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[pmid] {
+			seen[pmid] = true
+			pmids = append(pmids, pmid)
+		}
+	}
+
+	var (
+		docs []guru.MedlineDocument
+		err  error
+	)
+	for i := 0; i < fetchRetries; i++ {
+		docs, err = f.Fetch(pmids)
+		if err == nil {
+			break
+		}
+		log.Println(err)
+		time.Sleep(5 * time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]guru.MedlineDocument, len(docs))
+	for _, doc := range docs {
+		m[doc.PMID] = doc
+	}
+	return m, nil
+}
+
+// stripVersion removes the version suffix of a PMID.
+func stripVersion(docID string) string {
+	if i := strings.IndexByte(docID, '.'); i >= 0 {
+		return docID[:i]
+	}
+	return docID
+}
+
+// filter keeps the results of a list that satisfy a predicate, re-ranking the results that are kept.
+func filter(list *trecresults.ResultList, keep func(r *trecresults.Result) bool) {
+	filtered := make(trecresults.ResultList, 0, len(*list))
+	for _, r := range *list {
+		if keep(r) {
+			r.Rank = int64(len(filtered) + 1)
+			filtered = append(filtered, r)
+		}
+	}
+	*list = filtered
+}
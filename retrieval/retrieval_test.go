@@ -0,0 +1,157 @@
+package retrieval_test
+
+import (
+	"github.com/biogo/ncbi/entrez"
+	"github.com/hscells/groove/retrieval"
+	"github.com/hscells/guru"
+	"github.com/hscells/trecresults"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// mapFetcher fetches documents from a map, counting the documents it is asked for.
+type mapFetcher struct {
+	docs    map[string]guru.MedlineDocument
+	fetched *int
+}
+
+func (m mapFetcher) Fetch(pmids []int, options ...func(p *entrez.Parameters)) ([]guru.MedlineDocument, error) {
+	var docs []guru.MedlineDocument
+	for _, pmid := range pmids {
+		*m.fetched++
+		if doc, ok := m.docs[strconv.Itoa(pmid)]; ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func newFetcher(docs ...guru.MedlineDocument) mapFetcher {
+	m := mapFetcher{docs: make(map[string]guru.MedlineDocument), fetched: new(int)}
+	for _, doc := range docs {
+		m.docs[doc.PMID] = doc
+	}
+	return m
+}
+
+func resultList(ids ...string) trecresults.ResultList {
+	list := make(trecresults.ResultList, len(ids))
+	for i, id := range ids {
+		list[i] = &trecresults.Result{Topic: "1", DocId: id, Rank: int64(i + 1)}
+	}
+	return list
+}
+
+func docIDs(list trecresults.ResultList) []string {
+	ids := make([]string, len(list))
+	for i, r := range list {
+		ids[i] = r.DocId
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDateFilter(t *testing.T) {
+	f := newFetcher(
+		// Published in range, but completed long after.
+		guru.MedlineDocument{PMID: "1", DP: "2014 Dec", DCOM: "20170105"},
+		// Published before the range.
+		guru.MedlineDocument{PMID: "2", DP: "2009 Spring"},
+		// Only added to PubMed in range.
+		guru.MedlineDocument{PMID: "3", EDAT: "2012/06/01 06:00"},
+		// No date at all.
+		guru.MedlineDocument{PMID: "4"},
+	)
+	list := resultList("1", "2", "3", "4", "5")
+	err := retrieval.NewDateFilter(f, time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC)).Handle(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docIDs(list); !equalIDs(got, []string{"1", "3", "4", "5"}) {
+		t.Errorf("expected documents to be filtered by their publication date, got %v", got)
+	}
+	for i, r := range list {
+		if r.Rank != int64(i+1) {
+			t.Errorf("expected the filtered list to be re-ranked, got %+v", r)
+		}
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	f := newFetcher(
+		guru.MedlineDocument{PMID: "1", TI: "Aspirin for the prevention of stroke in older adults.", LID: "10.1000/abc [doi]"},
+		guru.MedlineDocument{PMID: "2", TI: "Warfarin for atrial fibrillation."},
+		guru.MedlineDocument{PMID: "3", TI: "A trial of aspirin", AID: []string{"10.1000/ABC [doi]"}},
+		// The same title as 1, except for its first word.
+		guru.MedlineDocument{PMID: "4", TI: "Asprin for the prevention of stroke in older adults"},
+		guru.MedlineDocument{PMID: "5", TI: "Warfarin for atrial fibrillation: a trial."},
+	)
+	list := resultList("1", "2", "1.2", "3", "4", "5")
+	err := retrieval.NewDeduplicator(f, retrieval.DeduplicateTitles(0.8)).Handle(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docIDs(list); !equalIDs(got, []string{"1", "2", "5"}) {
+		t.Errorf("expected versions, DOIs and similar titles to be deduplicated, got %v", got)
+	}
+
+	list = resultList("1", "4")
+	err = retrieval.NewDeduplicator(f, retrieval.DeduplicateTitles(1)).Handle(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docIDs(list); !equalIDs(got, []string{"1", "4"}) {
+		t.Errorf("expected only identical titles to be duplicates with a threshold of 1, got %v", got)
+	}
+}
+
+func TestCachedFetcher(t *testing.T) {
+	f := newFetcher(
+		guru.MedlineDocument{PMID: "1"},
+		guru.MedlineDocument{PMID: "2"},
+		guru.MedlineDocument{PMID: "3"},
+	)
+	c, err := retrieval.NewCachedFetcher(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := c.Fetch([]int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("expected every document to be fetched, even those that do not fit in the cache, got %d", len(docs))
+	}
+	*f.fetched = 0
+	docs, err = c.Fetch([]int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || *f.fetched != 0 {
+		t.Errorf("expected the most recently fetched documents to be cached, fetched %d", *f.fetched)
+	}
+	docs, err = c.Fetch([]int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || *f.fetched != 1 {
+		t.Errorf("expected the least recently used document to be evicted, fetched %d", *f.fetched)
+	}
+
+	if _, err := retrieval.NewCachedFetcher(f, 0); err == nil {
+		t.Errorf("expected an error for a cache without space")
+	}
+}
@@ -0,0 +1,515 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/hscells/cqr"
+	gpipeline "github.com/hscells/groove/pipeline"
+	"github.com/hscells/guru"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/trecresults"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// BleveTitleField is the name of the field titles are indexed into.
+	BleveTitleField = "title"
+	// BleveAbstractField is the name of the field abstracts are indexed into.
+	BleveAbstractField = "abstract"
+	// BleveMeSHField is the name of the field MeSH headings are indexed into.
+	BleveMeSHField = "mesh"
+	// BlevePublicationTypeField is the name of the field publication types are indexed into.
+	BlevePublicationTypeField = "publication_type"
+	// BlevePublicationDateField is the name of the field publication dates are indexed into.
+	BlevePublicationDateField = "publication_date"
+
+	// blevePageSize is the number of hits requested from the index at a time.
+	blevePageSize = 10000
+)
+
+// BleveDocument is the representation of a Medline document inside a Bleve index.
+type BleveDocument struct {
+	PMID            string    `json:"pmid"`
+	Title           string    `json:"title"`
+	Abstract        string    `json:"abstract"`
+	MeSH            []string  `json:"mesh"`
+	PublicationType []string  `json:"publication_type"`
+	PublicationDate time.Time `json:"publication_date"`
+}
+
+// NewBleveDocument converts a Medline document into a document that can be indexed by Bleve. The publication date is
+// read with MedlinePublicationDate.
+func NewBleveDocument(doc guru.MedlineDocument) BleveDocument {
+	d := BleveDocument{
+		PMID:            doc.PMID,
+		Title:           doc.TI,
+		Abstract:        doc.AB,
+		MeSH:            make([]string, len(doc.MH)),
+		PublicationType: doc.PT,
+	}
+	// MeSH headings may contain subheadings and major topic markers (e.g. "Aspergillosis/*diagnosis").
+	for i, mh := range doc.MH {
+		d.MeSH[i] = normaliseMeSHHeading(mh)
+	}
+	if t, ok := MedlinePublicationDate(doc); ok {
+		d.PublicationDate = t
+	}
+	return d
+}
+
+// NewBleveIndexMapping creates the index mapping used by the Bleve statistics source. Titles and abstracts are
+// analysed with the specified analyser, while MeSH headings and publication types are indexed verbatim.
+func NewBleveIndexMapping(analyser string) mapping.IndexMapping {
+	if len(analyser) == 0 {
+		analyser = en.AnalyzerName
+	}
+
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = analyser
+	text.Store = true
+	text.IncludeTermVectors = true
+
+	keyword := bleve.NewTextFieldMapping()
+	keyword.Analyzer = "keyword"
+	keyword.Store = true
+
+	date := bleve.NewDateTimeFieldMapping()
+
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt(BleveTitleField, text)
+	doc.AddFieldMappingsAt(BleveAbstractField, text)
+	doc.AddFieldMappingsAt(BleveMeSHField, keyword)
+	doc.AddFieldMappingsAt(BlevePublicationTypeField, keyword)
+	doc.AddFieldMappingsAt(BlevePublicationDateField, date)
+
+	pmid := bleve.NewDocumentDisabledMapping()
+	doc.AddSubDocumentMapping("pmid", pmid)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = analyser
+	return m
+}
+
+// IndexMedlineDocuments adds Medline documents to a Bleve index in a single batch.
+func IndexMedlineDocuments(index bleve.Index, docs guru.MedlineDocuments) error {
+	batch := index.NewBatch()
+	for _, doc := range docs {
+		err := batch.Index(doc.PMID, NewBleveDocument(doc))
+		if err != nil {
+			return err
+		}
+	}
+	return index.Batch(batch)
+}
+
+// BleveStatisticsSource is a statistics source backed by an embedded Bleve index. It allows a pipeline to be run
+// entirely locally without an external search server or a JVM. Indexes can be created using the cmd/bleve_index
+// command; https://blevesearch.com/
+type BleveStatisticsSource struct {
+	index    bleve.Index
+	path     string
+	analyser string
+
+	options    SearchOptions
+	parameters map[string]float64
+}
+
+// SearchOptions gets the execute options for this source.
+func (b *BleveStatisticsSource) SearchOptions() SearchOptions {
+	return b.options
+}
+
+// Parameters gets the parameters for this source.
+func (b *BleveStatisticsSource) Parameters() map[string]float64 {
+	return b.parameters
+}
+
+// storedFields retrieves the stored fields of a document.
+func (b *BleveStatisticsSource) storedFields(document string) (map[string]interface{}, error) {
+	req := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery([]string{document}), 1, 0, false)
+	req.Fields = []string{BleveTitleField, BleveAbstractField, BleveMeSHField}
+	res, err := b.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Hits) == 0 {
+		return nil, nil
+	}
+	return res.Hits[0].Fields, nil
+}
+
+// analyse tokenises text using the analyser of the index.
+func (b *BleveStatisticsSource) analyse(text string) ([]string, error) {
+	return b.Analyse(text, b.analyser)
+}
+
+// Analyse tokenises text using an analyser registered with the index (e.g. en, which removes stopwords and stems).
+func (b *BleveStatisticsSource) Analyse(text, analyser string) ([]string, error) {
+	tokens, err := b.index.Mapping().AnalyzeText(analyser, []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = string(token.Term)
+	}
+	return terms, nil
+}
+
+// fieldTermFrequencies computes the term frequencies of the analysed fields of a document.
+func (b *BleveStatisticsSource) fieldTermFrequencies(document string) (map[string]map[string]float64, error) {
+	stored, err := b.storedFields(document)
+	if err != nil {
+		return nil, err
+	}
+	tfs := make(map[string]map[string]float64)
+	for _, field := range []string{BleveTitleField, BleveAbstractField} {
+		text, ok := stored[field].(string)
+		if !ok {
+			continue
+		}
+		terms, err := b.analyse(text)
+		if err != nil {
+			return nil, err
+		}
+		tfs[field] = make(map[string]float64)
+		for _, term := range terms {
+			tfs[field][term]++
+		}
+	}
+	return tfs, nil
+}
+
+// TermFrequency is the number of times a term appears in the field of a document. The term is analysed in the same
+// way as the field, so that it matches the indexed tokens (e.g. "running" matches "run" once stemmed); terms that are
+// analysed away (e.g. stopwords) do not appear in any document. Terms of several tokens (e.g. "heart attack") have
+// the frequency of their least frequent token, which is 0 unless every token appears in the field. Bleve does not
+// record positions, so this is an upper bound on the number of times the tokens appear together.
+func (b *BleveStatisticsSource) TermFrequency(term, field, document string) (float64, error) {
+	terms, err := b.analyse(term)
+	if err != nil {
+		return 0, err
+	}
+	if len(terms) == 0 {
+		return 0, nil
+	}
+	tfs, err := b.fieldTermFrequencies(document)
+	if err != nil {
+		return 0, err
+	}
+	var tf float64
+	for _, f := range bleveFields(field) {
+		least := tfs[f][terms[0]]
+		for _, t := range terms[1:] {
+			least = math.Min(least, tfs[f][t])
+		}
+		tf += least
+	}
+	return tf, nil
+}
+
+// TermVector retrieves the term vector for the title and abstract of a document. Bleve does not record total term
+// frequencies, so the document frequency is used in its place.
+func (b *BleveStatisticsSource) TermVector(document string) (TermVector, error) {
+	tfs, err := b.fieldTermFrequencies(document)
+	if err != nil {
+		return nil, err
+	}
+	var tv TermVector
+	for field, terms := range tfs {
+		for term, tf := range terms {
+			df, err := b.DocumentFrequency(term, field)
+			if err != nil {
+				return nil, err
+			}
+			tv = append(tv, TermVectorTerm{
+				DocumentFrequency:  df,
+				TotalTermFrequency: df,
+				TermFrequency:      tf,
+				Field:              field,
+				Term:               term,
+			})
+		}
+	}
+	return tv, nil
+}
+
+// DocumentFrequency is the number of documents that contain the (analysed) term in the field.
+func (b *BleveStatisticsSource) DocumentFrequency(term, field string) (float64, error) {
+	return b.RetrievalSize(cqr.NewKeyword(term, field))
+}
+
+// TotalTermFrequency is approximated by the document frequency, as Bleve does not record total term frequencies.
+func (b *BleveStatisticsSource) TotalTermFrequency(term, field string) (float64, error) {
+	return b.DocumentFrequency(term, field)
+}
+
+// InverseDocumentFrequency is the ratio of of documents in the collection to the number of documents the term appears
+// in, logarithmically smoothed.
+func (b *BleveStatisticsSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	N, err := b.CollectionSize()
+	if err != nil {
+		return 0, err
+	}
+	nt, err := b.DocumentFrequency(term, field)
+	if err != nil {
+		return 0, err
+	}
+	return idf(N, nt), nil
+}
+
+// RetrievalSize is the number of documents the query matches.
+func (b *BleveStatisticsSource) RetrievalSize(q cqr.CommonQueryRepresentation) (float64, error) {
+	bq, err := toBleve(q)
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.index.Search(bleve.NewSearchRequestOptions(bq, 0, 0, false))
+	if err != nil {
+		return 0, err
+	}
+	return float64(res.Total), nil
+}
+
+// VocabularySize is the sum of the document frequencies of every term in the dictionary of a field.
+func (b *BleveStatisticsSource) VocabularySize(field string) (float64, error) {
+	var vocab float64
+	for _, f := range bleveFields(field) {
+		dict, err := b.index.FieldDict(f)
+		if err != nil {
+			return 0, err
+		}
+		entry, err := dict.Next()
+		for err == nil && entry != nil {
+			vocab += float64(entry.Count)
+			entry, err = dict.Next()
+		}
+		if err != nil {
+			_ = dict.Close()
+			return 0, err
+		}
+		err = dict.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return vocab, nil
+}
+
+// Execute runs the query on the index and returns results in trec format. When the size of the search options is
+// zero, every matching document is retrieved.
+func (b *BleveStatisticsSource) Execute(q gpipeline.Query, options SearchOptions) (trecresults.ResultList, error) {
+	bq, err := toBleve(q.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var results trecresults.ResultList
+	for from := 0; options.Size <= 0 || from < options.Size; from += blevePageSize {
+		size := blevePageSize
+		if options.Size > 0 && from+size > options.Size {
+			size = options.Size - from
+		}
+		res, err := b.index.Search(bleve.NewSearchRequestOptions(bq, size, from, false))
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range res.Hits {
+			results = append(results, &trecresults.Result{
+				Topic:     q.Topic,
+				Iteration: "Q0",
+				DocId:     hit.ID,
+				Rank:      int64(len(results)),
+				Score:     hit.Score,
+				RunName:   options.RunName,
+			})
+		}
+		if len(res.Hits) < size || uint64(len(results)) >= res.Total {
+			break
+		}
+	}
+	return results, nil
+}
+
+// CollectionSize is the number of documents in the index.
+func (b *BleveStatisticsSource) CollectionSize() (float64, error) {
+	n, err := b.index.DocCount()
+	if err != nil {
+		return 0, err
+	}
+	return float64(n), nil
+}
+
+// Close closes the underlying index.
+func (b *BleveStatisticsSource) Close() error {
+	return b.index.Close()
+}
+
+// bleveFields maps a query field onto the fields of the index.
+func bleveFields(field string) []string {
+	switch field {
+	case BleveTitleField, fields.Title:
+		return []string{BleveTitleField}
+	case BleveAbstractField, fields.Abstract:
+		return []string{BleveAbstractField}
+	case BleveMeSHField, fields.MeshHeadings, fields.MeSHTerms, fields.MeSHMajorTopic, fields.MeSHSubheading, fields.FloatingMeshHeadings, fields.MajorFocusMeshHeading:
+		return []string{BleveMeSHField}
+	case BlevePublicationTypeField, fields.PublicationType:
+		return []string{BlevePublicationTypeField}
+	case BlevePublicationDateField, fields.PublicationDate:
+		return []string{BlevePublicationDateField}
+	default:
+		return []string{BleveTitleField, BleveAbstractField}
+	}
+}
+
+// normaliseMeSHHeading strips the subheadings and major topic markers from a MeSH heading.
+func normaliseMeSHHeading(heading string) string {
+	heading = strings.Split(heading, "/")[0]
+	return strings.TrimSpace(strings.TrimPrefix(heading, "*"))
+}
+
+// bleveKeyword transforms a keyword into a Bleve query for a single field.
+func bleveKeyword(keyword cqr.Keyword, field string) (query.Query, error) {
+	s := strings.TrimSpace(keyword.QueryString)
+	switch field {
+	case BlevePublicationDateField:
+		r, err := ParseDateRange(s)
+		if err != nil {
+			return nil, err
+		}
+		q := bleve.NewDateRangeQuery(r.Start, r.End)
+		q.SetField(field)
+		return q, nil
+	case BleveMeSHField, BlevePublicationTypeField:
+		q := bleve.NewTermQuery(normaliseMeSHHeading(s))
+		q.SetField(field)
+		return q, nil
+	}
+
+	s = strings.Replace(s, `"`, "", -1)
+	if truncated, ok := keyword.Options["truncated"].(bool); (ok && truncated) || strings.HasSuffix(s, "*") {
+		q := bleve.NewPrefixQuery(strings.ToLower(strings.TrimRight(s, "*$")))
+		q.SetField(field)
+		return q, nil
+	}
+	if strings.Contains(s, " ") {
+		q := bleve.NewMatchPhraseQuery(s)
+		q.SetField(field)
+		return q, nil
+	}
+	q := bleve.NewMatchQuery(s)
+	q.SetField(field)
+	return q, nil
+}
+
+// toBleve transforms a cqr query into a Bleve query. Adjacency operators are treated as conjunctions.
+func toBleve(q cqr.CommonQueryRepresentation) (query.Query, error) {
+	switch x := q.(type) {
+	case cqr.Keyword:
+		var f []string
+		for _, field := range x.Fields {
+			f = append(f, bleveFields(field)...)
+		}
+		if len(f) == 0 {
+			f = bleveFields("")
+		}
+		queries := make([]query.Query, len(f))
+		for i, field := range f {
+			var err error
+			queries[i], err = bleveKeyword(x, field)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(queries) == 1 {
+			return queries[0], nil
+		}
+		return bleve.NewDisjunctionQuery(queries...), nil
+	case cqr.BooleanQuery:
+		children := make([]query.Query, len(x.Children))
+		for i, child := range x.Children {
+			var err error
+			children[i], err = toBleve(child)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(children) == 0 {
+			return bleve.NewMatchNoneQuery(), nil
+		}
+		switch op := strings.ToLower(x.Operator); {
+		case op == cqr.OR:
+			return bleve.NewDisjunctionQuery(children...), nil
+		case op == cqr.NOT:
+			bq := bleve.NewBooleanQuery()
+			bq.AddMust(children[0])
+			if len(children) > 1 {
+				bq.AddMustNot(children[1:]...)
+			}
+			return bq, nil
+		default:
+			return bleve.NewConjunctionQuery(children...), nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("supplied query is not supported: %v", q))
+}
+
+// BleveIndexPath sets the path to the Bleve index.
+func BleveIndexPath(path string) func(*BleveStatisticsSource) {
+	return func(b *BleveStatisticsSource) {
+		b.path = path
+		return
+	}
+}
+
+// BleveAnalyser sets the analyser used to tokenise documents when computing term statistics.
+func BleveAnalyser(analyser string) func(*BleveStatisticsSource) {
+	return func(b *BleveStatisticsSource) {
+		b.analyser = analyser
+		return
+	}
+}
+
+// BleveSearchOptions sets the execute options for the statistic source.
+func BleveSearchOptions(options SearchOptions) func(*BleveStatisticsSource) {
+	return func(b *BleveStatisticsSource) {
+		b.options = options
+		return
+	}
+}
+
+// BleveParameters sets the parameters for the statistic source.
+func BleveParameters(params map[string]float64) func(*BleveStatisticsSource) {
+	return func(b *BleveStatisticsSource) {
+		b.parameters = params
+		return
+	}
+}
+
+// NewBleveStatisticsSource opens an existing Bleve index as a statistics source.
+func NewBleveStatisticsSource(options ...func(*BleveStatisticsSource)) (*BleveStatisticsSource, error) {
+	b := &BleveStatisticsSource{
+		analyser: en.AnalyzerName,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if len(b.path) == 0 {
+		return nil, errors.New("a path to a bleve index must be specified")
+	}
+
+	var err error
+	b.index, err = bleve.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,125 @@
+package stats_test
+
+import (
+	"github.com/blevesearch/bleve"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/guru"
+	"github.com/hscells/transmute/fields"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewBleveDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      guru.MedlineDocument
+		expected time.Time
+	}{
+		{
+			name:     "publication date",
+			doc:      guru.MedlineDocument{DP: "2018 Mar 15", EDAT: "2018/04/01 06:00", DCOM: "20190101"},
+			expected: time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "publication month",
+			doc:      guru.MedlineDocument{DP: "2018 Mar", DCOM: "20190101"},
+			expected: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "publication season",
+			doc:      guru.MedlineDocument{DP: "2018 Spring", DCOM: "20190101"},
+			expected: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "entrez date",
+			doc:      guru.MedlineDocument{EDAT: "2017/05/02 06:00", DCOM: "20190101"},
+			expected: time.Date(2017, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "date completed",
+			doc:      guru.MedlineDocument{DCOM: "20190101"},
+			expected: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		d := stats.NewBleveDocument(test.doc)
+		if !d.PublicationDate.Equal(test.expected) {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, d.PublicationDate)
+		}
+	}
+}
+
+func TestBleveStatisticsSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	idx, err := bleve.New(path, stats.NewBleveIndexMapping(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stats.IndexMedlineDocuments(idx, guru.MedlineDocuments{
+		{PMID: "1", TI: "Running and runners", AB: "The effect of running on the heart.", MH: []string{"Running"}, DP: "2018 Mar 15"},
+		{PMID: "2", TI: "Aspirin for stroke", AB: "Aspirin prevents stroke.", MH: []string{"Aspirin", "Stroke"}, DP: "2012"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := stats.NewBleveStatisticsSource(stats.BleveIndexPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Term frequencies are of the analysed term, so they agree with the indexed tokens.
+	for _, term := range []string{"running", "Running", "run"} {
+		tf, err := b.TermFrequency(term, stats.BleveAbstractField, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tf != 1 {
+			t.Errorf("expected %s to occur once in the abstract, got %f", term, tf)
+		}
+	}
+	// Terms of several tokens occur as often as their least frequent token.
+	for term, want := range map[string]float64{"running heart": 1, "heart attack": 0} {
+		tf, err := b.TermFrequency(term, stats.BleveAbstractField, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tf != want {
+			t.Errorf("expected %s to occur %f times in the abstract, got %f", term, want, tf)
+		}
+	}
+	tf, err := b.TermFrequency("the", stats.BleveAbstractField, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tf != 0 {
+		t.Errorf("expected a stopword not to occur in any document, got %f", tf)
+	}
+
+	n, err := b.RetrievalSize(cqr.NewKeyword("stroke", fields.TitleAbstract))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected one document about stroke, got %f", n)
+	}
+
+	results, err := b.Execute(pipeline.NewQuery("1", "1", cqr.NewBooleanQuery(cqr.AND, []cqr.CommonQueryRepresentation{
+		cqr.NewKeyword("aspirin", fields.MeshHeadings),
+		cqr.NewKeyword("2010:2015", fields.PublicationDate),
+	})), stats.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].DocId != "2" {
+		t.Errorf("expected document 2 to be retrieved by its heading and publication date, got %v", results)
+	}
+}
@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the layouts of full and partial dates, and how long the period each represents is.
+var dateLayouts = []struct {
+	format string
+	years  int
+	months int
+	days   int
+}{
+	{"2006/01/02", 0, 0, 1},
+	{"20060102", 0, 0, 1},
+	{"2006/01", 0, 1, 0},
+	{"200601", 0, 1, 0},
+	{"2006", 1, 0, 0},
+}
+
+// OpenEndDate is the end of a date range without an end date. It is a fixed date far in the future (rather than the
+// current date) so that queries, and the keys of results cached for them, do not change from day to day. 3000 is
+// also the conventional open end of a PubMed date range (e.g. 2010:3000[dp]).
+var OpenEndDate = time.Date(3000, 12, 31, 23, 59, 59, 999999999, time.UTC)
+
+// DateRange is an inclusive range of publication dates.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParsePartialDate parses dates in the formats yyyy/mm/dd, yyyymmdd, yyyy/mm, yyyymm, and yyyy, where the
+// separator may also be a dash. When end is true, the last instant of the partial date is returned so that ranges
+// are inclusive.
+func ParsePartialDate(s string, end bool) (time.Time, error) {
+	s = strings.Replace(strings.TrimSpace(s), "-", "/", -1)
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout.format, s)
+		if err != nil {
+			continue
+		}
+		if end {
+			t = t.AddDate(layout.years, layout.months, layout.days).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unable to parse date %s", s)
+}
+
+// NewDateRange creates a date range from two, possibly partial, dates. A missing end date leaves the range open
+// (see OpenEndDate).
+func NewDateRange(start, end string) (DateRange, error) {
+	s, err := ParsePartialDate(start, false)
+	if err != nil {
+		return DateRange{}, err
+	}
+	e := OpenEndDate
+	if len(strings.TrimSpace(end)) > 0 {
+		e, err = ParsePartialDate(end, true)
+		if err != nil {
+			return DateRange{}, err
+		}
+	}
+	if e.Before(s) {
+		return DateRange{}, fmt.Errorf("date range %s:%s ends before it starts", start, end)
+	}
+	return DateRange{Start: s, End: e}, nil
+}
+
+// ParseDateRange parses a date range in the format start:end, which is how publication date constraints are
+// written in queries.
+func ParseDateRange(s string) (DateRange, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(parts) == 1 {
+		return NewDateRange(parts[0], "")
+	}
+	return NewDateRange(parts[0], parts[1])
+}
+
+// String formats the date range as a publication date constraint, e.g. 1940/01/01:2010/01/14.
+func (r DateRange) String() string {
+	return fmt.Sprintf("%s:%s", r.Start.Format("2006/01/02"), r.End.Format("2006/01/02"))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/hscells/cqr"
 	gpipeline "github.com/hscells/groove/pipeline"
 	"github.com/hscells/transmute/backend"
+	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/lexer"
 	"github.com/hscells/transmute/parser"
 	tpipeline "github.com/hscells/transmute/pipeline"
@@ -33,6 +34,7 @@ type ElasticsearchStatisticsSource struct {
 	Scroll       bool
 	Analyser     string
 	AnalyseField string
+	DateField    string
 
 	wg sync.WaitGroup
 }
@@ -175,7 +177,7 @@ func (es *ElasticsearchStatisticsSource) VocabularySize(field string) (float64,
 // RetrievalSize is the minimum number of documents that contains at least one of the query terms.
 func (es *ElasticsearchStatisticsSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
 	// Transform the query to an Elasticsearch query.
-	q, err := toElasticsearch(query)
+	q, err := toElasticsearch(query, es.DateField)
 	if err != nil {
 		return 0.0, err
 	}
@@ -229,7 +231,7 @@ func (es *ElasticsearchStatisticsSource) TermVector(document string) (TermVector
 // use this for ranked results as the concurrency of this method does not guarantee order.
 func (es *ElasticsearchStatisticsSource) ExecuteFast(query gpipeline.Query, options SearchOptions) ([]uint32, error) {
 	// Transform the query to an Elasticsearch query.
-	q, err := toElasticsearch(query.Query)
+	q, err := toElasticsearch(query.Query, es.DateField)
 	if err != nil {
 		return nil, err
 	}
@@ -319,7 +321,7 @@ func (es *ElasticsearchStatisticsSource) ExecuteFast(query gpipeline.Query, opti
 // Execute runs the query on Elasticsearch and returns results in trec format.
 func (es *ElasticsearchStatisticsSource) Execute(query gpipeline.Query, options SearchOptions) (trecresults.ResultList, error) {
 	// Transform the query to an Elasticsearch query.
-	q, err := toElasticsearch(query.Query)
+	q, err := toElasticsearch(query.Query, es.DateField)
 	if err != nil {
 		return nil, err
 	}
@@ -407,8 +409,13 @@ func (es *ElasticsearchStatisticsSource) Execute(query gpipeline.Query, options
 	return results, nil
 }
 
+// CollectionSize is the number of documents in the index.
 func (es *ElasticsearchStatisticsSource) CollectionSize() (float64, error) {
-	panic("implement me")
+	resp, err := es.client.IndexStats(es.index).Do(context.Background())
+	if err != nil {
+		return 0.0, err
+	}
+	return float64(resp.All.Total.Docs.Count), nil
 }
 
 // Analyse is a specific Elasticsearch method used in the analyse transformation.
@@ -423,8 +430,100 @@ func (es *ElasticsearchStatisticsSource) Analyse(text, analyser string) (tokens
 	return
 }
 
-// toElasticsearch transforms a cqr query into an Elasticsearch query.
-func toElasticsearch(query cqr.CommonQueryRepresentation) (string, error) {
+// CompileElasticsearch compiles a cqr query into the Elasticsearch query used by the Elasticsearch statistics
+// source. Publication date constraints are compiled into range filters on the date field (by default, the
+// publication date).
+func CompileElasticsearch(query cqr.CommonQueryRepresentation, dateField string) (string, error) {
+	return toElasticsearch(query, dateField)
+}
+
+// toElasticsearch transforms a cqr query into an Elasticsearch query. Publication date constraints of the query are
+// compiled into range filters on the date field.
+func toElasticsearch(query cqr.CommonQueryRepresentation, dateField string) (string, error) {
+	if len(dateField) == 0 {
+		dateField = fields.PublicationDate
+	}
+	query, dates, err := extractDateRanges(query)
+	if err != nil {
+		return "", err
+	}
+	if len(dates) == 0 {
+		return compileElasticsearch(query)
+	}
+
+	filters := make([]interface{}, len(dates))
+	for i, r := range dates {
+		filters[i] = map[string]interface{}{
+			"range": map[string]interface{}{
+				dateField: map[string]interface{}{
+					"gte":    r.Start.Format("2006/01/02"),
+					"lte":    r.End.Format("2006/01/02"),
+					"format": "yyyy/MM/dd",
+				},
+			},
+		}
+	}
+	clause := map[string]interface{}{"filter": filters}
+	if query != nil {
+		q, err := compileElasticsearch(query)
+		if err != nil {
+			return "", err
+		}
+		clause["must"] = []interface{}{json.RawMessage(q)}
+	}
+	b, err := json.Marshal(map[string]interface{}{"bool": clause})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// extractDateRanges removes the publication date constraints that restrict an entire query, i.e., those that are the
+// query or a child of a top-level AND clause. The remaining query is nil if only dates remain.
+func extractDateRanges(query cqr.CommonQueryRepresentation) (cqr.CommonQueryRepresentation, []DateRange, error) {
+	isDate := func(q cqr.CommonQueryRepresentation) bool {
+		kw, ok := q.(cqr.Keyword)
+		return ok && len(kw.Fields) == 1 && kw.Fields[0] == fields.PublicationDate
+	}
+	switch q := query.(type) {
+	case cqr.Keyword:
+		if isDate(q) {
+			r, err := ParseDateRange(q.QueryString)
+			return nil, []DateRange{r}, err
+		}
+	case cqr.BooleanQuery:
+		if strings.ToLower(q.Operator) != cqr.AND {
+			return query, nil, nil
+		}
+		var (
+			dates    []DateRange
+			children []cqr.CommonQueryRepresentation
+		)
+		for _, child := range q.Children {
+			if !isDate(child) {
+				children = append(children, child)
+				continue
+			}
+			r, err := ParseDateRange(child.(cqr.Keyword).QueryString)
+			if err != nil {
+				return nil, nil, err
+			}
+			dates = append(dates, r)
+		}
+		switch len(children) {
+		case 0:
+			return nil, dates, nil
+		case 1:
+			return children[0], dates, nil
+		}
+		q.Children = children
+		return q, dates, nil
+	}
+	return query, nil, nil
+}
+
+// compileElasticsearch compiles a cqr query into an Elasticsearch query using transmute.
+func compileElasticsearch(query cqr.CommonQueryRepresentation) (string, error) {
 	var result map[string]interface{}
 	// For a Boolean query, it gets a little tricky.
 	// First we need to get the string representation of the cqr.
@@ -545,6 +644,15 @@ func ElasticsearchAnalysedField(field string) func(*ElasticsearchStatisticsSourc
 	}
 }
 
+// ElasticsearchDateField sets the field publication date constraints are applied to. By default, this is the
+// transmute publication date field.
+func ElasticsearchDateField(field string) func(*ElasticsearchStatisticsSource) {
+	return func(es *ElasticsearchStatisticsSource) {
+		es.DateField = field
+		return
+	}
+}
+
 // ElasticsearchScroll sets the scroll for the statistic source.
 func ElasticsearchScroll(scroll bool) func(*ElasticsearchStatisticsSource) {
 	return func(es *ElasticsearchStatisticsSource) {
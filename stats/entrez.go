@@ -7,6 +7,7 @@ import (
 	"github.com/biogo/ncbi/entrez"
 	"github.com/biogo/ncbi/entrez/search"
 	"github.com/hscells/cqr"
+	"github.com/hscells/groove/metrics"
 	"github.com/hscells/groove/pipeline"
 	"github.com/hscells/guru"
 	"github.com/hscells/transmute"
@@ -96,6 +97,7 @@ func (e EntrezStatisticsSource) Count(term, field string) float64 {
 	var s Search
 count:
 	err := entrez.SearchURL.GetXML(map[string][]string{"field": {field}, "api_key": {e.key}, "term": {term}}, e.tool, e.email, entrez.Limit, &s)
+	metrics.Request("entrez.esearch", err)
 	if err != nil {
 		log.Println(err)
 		goto count
@@ -182,25 +184,22 @@ func (e EntrezStatisticsSource) Search(query string, options ...func(p *entrez.P
 	v["db"] = []string{e.db}
 	v["term"] = []string{query}
 	fillParams(p, v)
-	fmt.Print(".")
 	r, err := entrez.SearchURL.Get(v, e.tool, e.email, entrez.Limit)
+	metrics.Request("entrez.esearch", err)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
-	fmt.Print(".")
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 	var s esearch
-	fmt.Print(".")
 	err = easyjson.Unmarshal(b, &s)
 	if err != nil {
 		fmt.Println(string(b))
 		return nil, err
 	}
-	fmt.Print(".")
 
 	pmids := make([]int, len(s.EsearchResult.Idlist))
 	for i, pmid := range s.EsearchResult.Idlist {
@@ -217,7 +216,8 @@ func (e EntrezStatisticsSource) Search(query string, options ...func(p *entrez.P
 	}
 
 	//pmids = s.EsearchResult.Idlist
-	fmt.Printf("[%d/%s]", retstart+len(pmids), s.EsearchResult.Count)
+	count, _ := strconv.Atoi(s.EsearchResult.Count)
+	metrics.Progress("entrez.esearch", retstart+len(pmids), count)
 	//log.Println(len(pmids) == e.options.Size, len(pmids), e.options.Size)
 	// If the number of pmids equals the execute size, there might be more to come.
 	if e.rank || (e.Limit > 0 && len(pmids) >= e.Limit) {
@@ -253,7 +253,9 @@ func (e EntrezStatisticsSource) Summary(ids []string, value interface{}, options
 	p.RetMax = e.options.Size
 	p.RetMode = "xml"
 	p.APIKey = e.key
-	return entrez.SummaryURL.GetXML(v, e.tool, e.email, entrez.Limit, value)
+	err := entrez.SummaryURL.GetXML(v, e.tool, e.email, entrez.Limit, value)
+	metrics.Request("entrez.esummary", err)
+	return err
 }
 
 // Fetch uses the entrez eutils to fetch the pubmed Article given a set of pubmed identifiers.
@@ -274,6 +276,7 @@ func (e EntrezStatisticsSource) Fetch(pmids []int, options ...func(p *entrez.Par
 
 retry:
 	r, err := entrez.Fetch(e.db, p, e.tool, e.email, nil, pmids...)
+	metrics.Request("entrez.efetch", err)
 	if err != nil {
 		if fails > 0 {
 			log.Printf("error: %v, retrying %d more times for %f seconds", err, fails, time.Duration(((nfails - fails) * 5) * int(time.Second)).Seconds())
@@ -338,6 +341,7 @@ func (e EntrezStatisticsSource) TermFrequency(term, field, document string) (flo
 		return 0, err
 	}
 	r, err := entrez.Fetch(e.db, &entrez.Parameters{RetMode: "xml", APIKey: e.key}, e.tool, e.email, nil, int(d))
+	metrics.Request("entrez.efetch", err)
 	if err != nil {
 		return 0, err
 	}
@@ -433,6 +437,7 @@ func (e EntrezStatisticsSource) TermVector(document string) (TermVector, error)
 
 func (e EntrezStatisticsSource) DocumentFrequency(term, field string) (float64, error) {
 	s, err := entrez.DoSearch(e.db, term, &entrez.Parameters{APIKey: e.key}, nil, e.tool, e.email)
+	metrics.Request("entrez.esearch", err)
 	if err != nil {
 		return 0, err
 	}
@@ -488,6 +493,7 @@ func (e EntrezStatisticsSource) RetrievalSize(query cqr.CommonQueryRepresentatio
 	fails := nfails
 retry:
 	s, err := entrez.DoSearch(e.db, q, &entrez.Parameters{RetType: "xml", APIKey: e.key}, nil, e.tool, e.email)
+	metrics.Request("entrez.esearch", err)
 	if err != nil {
 		if fails > 0 {
 			log.Printf("error: %v, retrying %d more times for %d seconds", err, fails, ((nfails-fails)*5)*int(time.Second))
@@ -558,6 +564,7 @@ func (e EntrezStatisticsSource) CollectionSize() (float64, error) {
 		return e.N, nil
 	}
 	info, err := entrez.DoInfo(e.db, e.tool, e.email)
+	metrics.Request("entrez.einfo", err)
 	if err != nil {
 		return 0, err
 	}
@@ -566,7 +573,12 @@ func (e EntrezStatisticsSource) CollectionSize() (float64, error) {
 
 func (e EntrezStatisticsSource) Translation(term string) ([]string, error) {
 	s, err := entrez.DoSearch("pubmed", term, nil, nil, e.tool, e.email)
-	if err != io.EOF && err != nil {
+	// An EOF error is returned when the search succeeds without any results.
+	if err == io.EOF {
+		err = nil
+	}
+	metrics.Request("entrez.esearch", err)
+	if err != nil {
 		return nil, err
 	}
 	if s == nil || len(s.TranslationStack) == 0 {
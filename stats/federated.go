@@ -0,0 +1,429 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/trecresults"
+	"math"
+	"sort"
+	"sync"
+)
+
+// FederatedMethod is the name of a StatisticsSource method that can be routed to a specific backend.
+type FederatedMethod string
+
+// Methods of a statistics source that can be routed.
+const (
+	FederatedTermFrequency            FederatedMethod = "TermFrequency"
+	FederatedTermVector               FederatedMethod = "TermVector"
+	FederatedDocumentFrequency        FederatedMethod = "DocumentFrequency"
+	FederatedTotalTermFrequency       FederatedMethod = "TotalTermFrequency"
+	FederatedInverseDocumentFrequency FederatedMethod = "InverseDocumentFrequency"
+	FederatedRetrievalSize            FederatedMethod = "RetrievalSize"
+	FederatedVocabularySize           FederatedMethod = "VocabularySize"
+	FederatedExecute                  FederatedMethod = "Execute"
+	FederatedCollectionSize           FederatedMethod = "CollectionSize"
+)
+
+// FusionMethod is a method for combining the result lists of several backends into a single list.
+type FusionMethod int
+
+const (
+	// CombSUM scores documents by the sum of their normalised scores in each list.
+	CombSUM FusionMethod = iota
+	// CombMNZ scores documents by the sum of their normalised scores multiplied by the number of lists that
+	// retrieved them.
+	CombMNZ
+	// RRF scores documents using reciprocal rank fusion.
+	RRF
+)
+
+// rrfK is the constant used to dampen the impact of high ranks in reciprocal rank fusion.
+const rrfK = 60
+
+// FuseResults combines several result lists into a single list ranked by the fused score. Backends score documents
+// on different scales, so for CombSUM and CombMNZ the scores of each list are min-max normalised before they are
+// combined.
+func FuseResults(method FusionMethod, topic string, lists ...trecresults.ResultList) trecresults.ResultList {
+	scores := make(map[string]float64)
+	counts := make(map[string]float64)
+	for _, list := range lists {
+		normalised := normaliseScores(list)
+		for i, result := range list {
+			if method == RRF {
+				scores[result.DocId] += 1.0 / float64(rrfK+i+1)
+			} else {
+				scores[result.DocId] += normalised[i]
+			}
+			counts[result.DocId]++
+		}
+	}
+
+	fused := make(trecresults.ResultList, 0, len(scores))
+	for docID, score := range scores {
+		if method == CombMNZ {
+			score *= counts[docID]
+		}
+		fused = append(fused, &trecresults.Result{
+			Topic:     topic,
+			Iteration: "Q0",
+			DocId:     docID,
+			Score:     score,
+		})
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].DocId < fused[j].DocId
+	})
+	for i := range fused {
+		fused[i].Rank = int64(i + 1)
+	}
+	return fused
+}
+
+// normaliseScores min-max normalises the scores of a result list into [0, 1]. When every result has the same score
+// (e.g. a Boolean source), each is scored one.
+func normaliseScores(list trecresults.ResultList) []float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, result := range list {
+		lo = math.Min(lo, result.Score)
+		hi = math.Max(hi, result.Score)
+	}
+	normalised := make([]float64, len(list))
+	for i, result := range list {
+		if hi > lo {
+			normalised[i] = (result.Score - lo) / (hi - lo)
+		} else {
+			normalised[i] = 1
+		}
+	}
+	return normalised
+}
+
+// RetrievalAgreement records the number of documents each backend retrieved for a single query.
+type RetrievalAgreement struct {
+	Query string
+	Sizes map[string]float64
+}
+
+// Ratio is the ratio of the smallest to the largest retrieval size. A ratio of one indicates the backends agree.
+func (r RetrievalAgreement) Ratio() float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, size := range r.Sizes {
+		lo = math.Min(lo, size)
+		hi = math.Max(hi, size)
+	}
+	if hi <= 0 {
+		return 1
+	}
+	return lo / hi
+}
+
+// AgreementSummary summarises how much the backends agree on retrieval sizes.
+type AgreementSummary struct {
+	// Queries is the number of queries that were issued to every backend.
+	Queries int
+	// Exact is the number of queries for which every backend retrieved the same number of documents.
+	Exact int
+	// MeanRatio is the mean ratio of the smallest to the largest retrieval size.
+	MeanRatio float64
+	// MeanAbsoluteDifference is the mean difference of each backend to the primary backend.
+	MeanAbsoluteDifference map[string]float64
+}
+
+// FederatedStatisticsSource combines several statistics sources. Calls can be routed to a specific source by method
+// or by field (e.g. MeSH statistics from Entrez, and term vectors from Elasticsearch). Calls to Execute that are not
+// routed are issued to every source and the result lists are fused, and calls to RetrievalSize that are not routed
+// are issued to every source so that their agreement can be reported.
+type FederatedStatisticsSource struct {
+	names   []string
+	sources map[string]StatisticsSource
+	methods map[FederatedMethod]string
+	fields  map[string]string
+	fusion  FusionMethod
+
+	options    SearchOptions
+	parameters map[string]float64
+
+	agreements []RetrievalAgreement
+	mu         sync.Mutex
+}
+
+// SearchOptions gets the execute options for this source.
+func (f *FederatedStatisticsSource) SearchOptions() SearchOptions {
+	return f.options
+}
+
+// Parameters gets the parameters for this source.
+func (f *FederatedStatisticsSource) Parameters() map[string]float64 {
+	return f.parameters
+}
+
+// Source returns one of the underlying sources by name.
+func (f *FederatedStatisticsSource) Source(name string) (StatisticsSource, bool) {
+	ss, ok := f.sources[name]
+	return ss, ok
+}
+
+// route determines which source a call should be made to. Field routes take precedence over method routes, and
+// any call which is not routed is made to the first (primary) source.
+func (f *FederatedStatisticsSource) route(method FederatedMethod, field string) (StatisticsSource, bool) {
+	if name, ok := f.fields[field]; ok && len(field) > 0 {
+		return f.sources[name], true
+	}
+	if name, ok := f.methods[method]; ok {
+		return f.sources[name], true
+	}
+	return f.sources[f.names[0]], false
+}
+
+// routeQuery determines which source a query should be issued to. A query is only routed by field when every
+// keyword in the query routes to the same source.
+func (f *FederatedStatisticsSource) routeQuery(method FederatedMethod, query cqr.CommonQueryRepresentation) (StatisticsSource, bool) {
+	var routed string
+	for _, field := range queryFields(query) {
+		name, ok := f.fields[field]
+		if !ok || (len(routed) > 0 && routed != name) {
+			routed = ""
+			break
+		}
+		routed = name
+	}
+	if len(routed) > 0 {
+		return f.sources[routed], true
+	}
+	return f.route(method, "")
+}
+
+// queryFields extracts every field used in a query.
+func queryFields(query cqr.CommonQueryRepresentation) (fields []string) {
+	switch q := query.(type) {
+	case cqr.Keyword:
+		return q.Fields
+	case cqr.BooleanQuery:
+		for _, child := range q.Children {
+			fields = append(fields, queryFields(child)...)
+		}
+	}
+	return
+}
+
+// TermFrequency is the term frequency in the field of a document.
+func (f *FederatedStatisticsSource) TermFrequency(term, field, document string) (float64, error) {
+	ss, _ := f.route(FederatedTermFrequency, field)
+	return ss.TermFrequency(term, field, document)
+}
+
+// TermVector retrieves the term vector for a document.
+func (f *FederatedStatisticsSource) TermVector(document string) (TermVector, error) {
+	ss, _ := f.route(FederatedTermVector, "")
+	return ss.TermVector(document)
+}
+
+// DocumentFrequency is the number of documents containing the term.
+func (f *FederatedStatisticsSource) DocumentFrequency(term, field string) (float64, error) {
+	ss, _ := f.route(FederatedDocumentFrequency, field)
+	return ss.DocumentFrequency(term, field)
+}
+
+// TotalTermFrequency is the number of times the term appears in the collection.
+func (f *FederatedStatisticsSource) TotalTermFrequency(term, field string) (float64, error) {
+	ss, _ := f.route(FederatedTotalTermFrequency, field)
+	return ss.TotalTermFrequency(term, field)
+}
+
+// InverseDocumentFrequency is the ratio of of documents in the collection to the number of documents the term appears
+// in, logarithmically smoothed.
+func (f *FederatedStatisticsSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	ss, _ := f.route(FederatedInverseDocumentFrequency, field)
+	return ss.InverseDocumentFrequency(term, field)
+}
+
+// VocabularySize is the total number of terms in the vocabulary.
+func (f *FederatedStatisticsSource) VocabularySize(field string) (float64, error) {
+	ss, _ := f.route(FederatedVocabularySize, field)
+	return ss.VocabularySize(field)
+}
+
+// CollectionSize is the number of documents in the collection of the routed (or primary) source.
+func (f *FederatedStatisticsSource) CollectionSize() (float64, error) {
+	ss, _ := f.route(FederatedCollectionSize, "")
+	return ss.CollectionSize()
+}
+
+// RetrievalSize is the number of documents retrieved by the query. When the call is not routed, the query is issued
+// to every source, the agreement between the sources is recorded, and the size from the primary source is returned.
+func (f *FederatedStatisticsSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
+	if ss, ok := f.routeQuery(FederatedRetrievalSize, query); ok || len(f.names) == 1 {
+		return ss.RetrievalSize(query)
+	}
+
+	sizes := make(map[string]float64, len(f.names))
+	for _, name := range f.names {
+		size, err := f.sources[name].RetrievalSize(query)
+		if err != nil {
+			return 0, err
+		}
+		sizes[name] = size
+	}
+
+	f.mu.Lock()
+	f.agreements = append(f.agreements, RetrievalAgreement{Query: query.String(), Sizes: sizes})
+	f.mu.Unlock()
+
+	return sizes[f.names[0]], nil
+}
+
+// Execute runs the query. When the call is not routed, the query is executed on every source and the result lists
+// are fused using the configured fusion method.
+func (f *FederatedStatisticsSource) Execute(query pipeline.Query, options SearchOptions) (trecresults.ResultList, error) {
+	if ss, ok := f.routeQuery(FederatedExecute, query.Query); ok || len(f.names) == 1 {
+		return ss.Execute(query, options)
+	}
+
+	lists := make([]trecresults.ResultList, len(f.names))
+	errs := make([]error, len(f.names))
+	var wg sync.WaitGroup
+	for i, name := range f.names {
+		wg.Add(1)
+		go func(j int, ss StatisticsSource) {
+			defer wg.Done()
+			lists[j], errs[j] = ss.Execute(query, options)
+		}(i, f.sources[name])
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.names[i], err)
+		}
+	}
+
+	results := FuseResults(f.fusion, query.Topic, lists...)
+	if options.Size > 0 && len(results) > options.Size {
+		results = results[:options.Size]
+	}
+	for _, result := range results {
+		result.RunName = options.RunName
+	}
+	return results, nil
+}
+
+// Agreements returns the retrieval sizes recorded for every query issued to all sources.
+func (f *FederatedStatisticsSource) Agreements() []RetrievalAgreement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a := make([]RetrievalAgreement, len(f.agreements))
+	copy(a, f.agreements)
+	return a
+}
+
+// AgreementSummary summarises the agreement of retrieval sizes between the sources.
+func (f *FederatedStatisticsSource) AgreementSummary() AgreementSummary {
+	agreements := f.Agreements()
+	s := AgreementSummary{
+		Queries:                len(agreements),
+		MeanAbsoluteDifference: make(map[string]float64),
+	}
+	if len(agreements) == 0 {
+		return s
+	}
+	for _, a := range agreements {
+		r := a.Ratio()
+		if r == 1 {
+			s.Exact++
+		}
+		s.MeanRatio += r
+		for _, name := range f.names[1:] {
+			s.MeanAbsoluteDifference[name] += math.Abs(a.Sizes[name] - a.Sizes[f.names[0]])
+		}
+	}
+	n := float64(len(agreements))
+	s.MeanRatio /= n
+	for name := range s.MeanAbsoluteDifference {
+		s.MeanAbsoluteDifference[name] /= n
+	}
+	return s
+}
+
+// FederatedSource adds a named source to the federation. The first source added is the primary source.
+func FederatedSource(name string, ss StatisticsSource) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		if _, ok := f.sources[name]; !ok {
+			f.names = append(f.names, name)
+		}
+		f.sources[name] = ss
+		return
+	}
+}
+
+// FederatedRouteMethod routes all calls of a method to the named source.
+func FederatedRouteMethod(method FederatedMethod, name string) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		f.methods[method] = name
+		return
+	}
+}
+
+// FederatedRouteField routes all calls for a field to the named source.
+func FederatedRouteField(field, name string) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		f.fields[field] = name
+		return
+	}
+}
+
+// FederatedFusion sets the method used to fuse result lists.
+func FederatedFusion(method FusionMethod) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		f.fusion = method
+		return
+	}
+}
+
+// FederatedSearchOptions sets the execute options for the statistic source.
+func FederatedSearchOptions(options SearchOptions) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		f.options = options
+		return
+	}
+}
+
+// FederatedParameters sets the parameters for the statistic source.
+func FederatedParameters(params map[string]float64) func(*FederatedStatisticsSource) {
+	return func(f *FederatedStatisticsSource) {
+		f.parameters = params
+		return
+	}
+}
+
+// NewFederatedStatisticsSource creates a statistics source that combines several other statistics sources.
+func NewFederatedStatisticsSource(options ...func(*FederatedStatisticsSource)) (*FederatedStatisticsSource, error) {
+	f := &FederatedStatisticsSource{
+		sources: make(map[string]StatisticsSource),
+		methods: make(map[FederatedMethod]string),
+		fields:  make(map[string]string),
+		fusion:  CombSUM,
+	}
+	for _, option := range options {
+		option(f)
+	}
+
+	if len(f.names) == 0 {
+		return nil, errors.New("a federated statistics source requires at least one source")
+	}
+	for method, name := range f.methods {
+		if _, ok := f.sources[name]; !ok {
+			return nil, fmt.Errorf("method %s is routed to unknown source %s", method, name)
+		}
+	}
+	for field, name := range f.fields {
+		if _, ok := f.sources[name]; !ok {
+			return nil, fmt.Errorf("field %s is routed to unknown source %s", field, name)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,163 @@
+package stats_test
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/groove/pipeline"
+	"github.com/hscells/groove/stats"
+	"github.com/hscells/trecresults"
+	"testing"
+)
+
+// constantSource answers every statistic with the same value, and retrieves a fixed list of results.
+type constantSource struct {
+	value   float64
+	results trecresults.ResultList
+}
+
+func (s constantSource) SearchOptions() stats.SearchOptions { return stats.SearchOptions{} }
+func (s constantSource) Parameters() map[string]float64     { return nil }
+func (s constantSource) TermFrequency(term, field, document string) (float64, error) {
+	return s.value, nil
+}
+func (s constantSource) TermVector(document string) (stats.TermVector, error) {
+	return stats.TermVector{{Term: "source", TermFrequency: s.value}}, nil
+}
+func (s constantSource) DocumentFrequency(term, field string) (float64, error) {
+	return s.value, nil
+}
+func (s constantSource) TotalTermFrequency(term, field string) (float64, error) {
+	return s.value, nil
+}
+func (s constantSource) InverseDocumentFrequency(term, field string) (float64, error) {
+	return s.value, nil
+}
+func (s constantSource) RetrievalSize(query cqr.CommonQueryRepresentation) (float64, error) {
+	return s.value, nil
+}
+func (s constantSource) VocabularySize(field string) (float64, error) { return s.value, nil }
+func (s constantSource) Execute(query pipeline.Query, options stats.SearchOptions) (trecresults.ResultList, error) {
+	return s.results, nil
+}
+func (s constantSource) CollectionSize() (float64, error) { return s.value, nil }
+
+func results(scores map[string]float64, order ...string) trecresults.ResultList {
+	list := make(trecresults.ResultList, len(order))
+	for i, id := range order {
+		list[i] = &trecresults.Result{Topic: "1", DocId: id, Rank: int64(i + 1), Score: scores[id]}
+	}
+	return list
+}
+
+func docIDs(list trecresults.ResultList) []string {
+	ids := make([]string, len(list))
+	for i, r := range list {
+		ids[i] = r.DocId
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFuseResults(t *testing.T) {
+	// The first backend scores on a much larger scale than the second, which must not let it dominate the fusion.
+	a := results(map[string]float64{"1": 100, "2": 90, "3": 0}, "1", "2", "3")
+	b := results(map[string]float64{"3": 0.9, "4": 0.8, "2": 0.1}, "3", "4", "2")
+
+	tests := []struct {
+		method   stats.FusionMethod
+		expected []string
+	}{
+		// 1: 1, 2: 0.9 + 0, 3: 0 + 1, 4: 0.875.
+		{stats.CombSUM, []string{"1", "3", "2", "4"}},
+		// 1: 1, 2: 0.9 * 2, 3: 1 * 2, 4: 0.875.
+		{stats.CombMNZ, []string{"3", "2", "1", "4"}},
+		// 1: 1/61, 2: 1/62 + 1/63, 3: 1/63 + 1/61, 4: 1/62.
+		{stats.RRF, []string{"3", "2", "1", "4"}},
+	}
+	for _, test := range tests {
+		fused := stats.FuseResults(test.method, "1", a, b)
+		if got := docIDs(fused); !equalIDs(got, test.expected) {
+			t.Errorf("method %d: expected %v, got %v", test.method, test.expected, got)
+		}
+		for i, r := range fused {
+			if r.Rank != int64(i+1) || r.Topic != "1" {
+				t.Errorf("method %d: unexpected result %+v", test.method, r)
+			}
+		}
+	}
+
+	// Lists where every document has the same score (e.g. Boolean retrieval) contribute equally.
+	c := results(map[string]float64{"1": 5, "2": 5}, "1", "2")
+	d := results(map[string]float64{"2": 1}, "2")
+	if got := docIDs(stats.FuseResults(stats.CombSUM, "1", c, d)); !equalIDs(got, []string{"2", "1"}) {
+		t.Errorf("expected documents retrieved by both lists to be ranked first, got %v", got)
+	}
+}
+
+func TestFederatedStatisticsSource(t *testing.T) {
+	f, err := stats.NewFederatedStatisticsSource(
+		stats.FederatedSource("primary", constantSource{
+			value:   1,
+			results: results(map[string]float64{"1": 10, "2": 5, "3": 0}, "1", "2", "3"),
+		}),
+		stats.FederatedSource("mesh", constantSource{
+			value:   2,
+			results: results(map[string]float64{"2": 1}, "2"),
+		}),
+		stats.FederatedSource("vectors", constantSource{value: 3}),
+		stats.FederatedRouteField("mh", "mesh"),
+		stats.FederatedRouteMethod(stats.FederatedTermVector, "vectors"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if df, _ := f.DocumentFrequency("stroke", "mh"); df != 2 {
+		t.Errorf("expected MeSH statistics to be routed by field, got %f", df)
+	}
+	if df, _ := f.DocumentFrequency("stroke", "ti"); df != 1 {
+		t.Errorf("expected calls that are not routed to be made to the primary source, got %f", df)
+	}
+	if tv, _ := f.TermVector("1"); len(tv) != 1 || tv[0].TermFrequency != 3 {
+		t.Errorf("expected term vectors to be routed by method, got %v", tv)
+	}
+
+	mesh := cqr.NewKeyword("stroke", "mh")
+	if n, _ := f.RetrievalSize(mesh); n != 2 {
+		t.Errorf("expected a query of routed fields to be routed, got %f", n)
+	}
+	mixed := cqr.NewBooleanQuery(cqr.OR, []cqr.CommonQueryRepresentation{mesh, cqr.NewKeyword("stroke", "ti")})
+	if n, _ := f.RetrievalSize(mixed); n != 1 {
+		t.Errorf("expected the size from the primary source for a query that is not routed, got %f", n)
+	}
+	if a := f.Agreements(); len(a) != 1 || a[0].Sizes["vectors"] != 3 || a[0].Ratio() != 1.0/3 {
+		t.Errorf("expected the agreement of every source to be recorded, got %v", a)
+	}
+
+	list, err := f.Execute(pipeline.NewQuery("1", "1", mixed), stats.SearchOptions{RunName: "federated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1: 1, 2: 0.5 + 1, 3: 0.
+	if got := docIDs(list); !equalIDs(got, []string{"2", "1", "3"}) || list[0].RunName != "federated" {
+		t.Errorf("expected the results of every source to be fused, got %v", got)
+	}
+
+	_, err = stats.NewFederatedStatisticsSource(
+		stats.FederatedSource("primary", constantSource{}),
+		stats.FederatedRouteField("mh", "entrez"),
+	)
+	if err == nil {
+		t.Errorf("expected an error for a field routed to an unknown source")
+	}
+}
@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"github.com/hscells/guru"
+	"strings"
+	"time"
+)
+
+// medlinePublicationDateLayouts are the layouts of the publication date (DP) of Medline documents, from most to least
+// precise. Dates such as "2018 Mar-Apr" or "2018 Spring" are parsed by their year.
+var medlinePublicationDateLayouts = []string{"2006 Jan 2", "2006 01 02", "2006 Jan", "2006 01"}
+
+// MedlinePublicationDate is the date a Medline document was published (DP), falling back to the date it was added to
+// PubMed (EDAT). The date completed (DCOM) is only used when a document has neither, as it is often years after the
+// document was published.
+func MedlinePublicationDate(doc guru.MedlineDocument) (time.Time, bool) {
+	dp := strings.TrimSpace(doc.DP)
+	for _, layout := range medlinePublicationDateLayouts {
+		if t, err := time.Parse(layout, dp); err == nil {
+			return t, true
+		}
+	}
+	if len(dp) >= 4 {
+		if t, err := time.Parse("2006", dp[:4]); err == nil {
+			return t, true
+		}
+	}
+	if edat := strings.TrimSpace(doc.EDAT); len(edat) >= 10 {
+		if t, err := time.Parse("2006/01/02", edat[:10]); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.Parse("20060102", doc.DCOM); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
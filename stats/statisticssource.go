@@ -8,6 +8,7 @@ import (
 	"github.com/hscells/trecresults"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // SearchOptions are options that the statistics source will use for retrieval.
@@ -113,6 +114,8 @@ type LanguageModel struct {
 	StatisticsSource   StatisticsSource
 	VocabularySize     float64
 	TotalTermFrequency map[string]float64
+	// Field restricts the terms that are counted to the terms of a field of the documents (see LanguageModelField).
+	Field string
 }
 
 // LanguageModelWeights configures a language model to use the specified weights.
@@ -123,6 +126,14 @@ func LanguageModelWeights(weights []float64) func(*LanguageModel) {
 	}
 }
 
+// LanguageModelField configures a language model to only count the terms of a field of the documents, ignoring case.
+// By default, the terms of every field are counted.
+func LanguageModelField(field string) func(*LanguageModel) {
+	return func(lm *LanguageModel) {
+		lm.Field = field
+	}
+}
+
 // NewLanguageModel creates a new language model from a statistics source using the specified documents and scores for
 // those documents. Optionally, the language model can use weights that can be configured through the functional
 // arguments.
@@ -182,13 +193,13 @@ func (lm *LanguageModel) updateTermCountMap(docID string, weight float64) error
 		return err
 	}
 	for _, term := range tv {
-		// Update term counts.
-		if count, ok := lm.TermCount[term.Term]; ok {
-			lm.TermCount[term.Term] += count + (term.TermFrequency * weight)
-		} else {
-			lm.TermCount[term.Term] = term.TermFrequency * weight
+		if len(lm.Field) > 0 && !strings.EqualFold(term.Field, lm.Field) {
+			continue
 		}
 
+		// Update term counts.
+		lm.TermCount[term.Term] += term.TermFrequency * weight
+
 		// Update term frequencies.
 		if _, ok := lm.TotalTermFrequency[term.Term]; !ok {
 			lm.TotalTermFrequency[term.Term] = term.TotalTermFrequency
@@ -0,0 +1,35 @@
+package stats_test
+
+import (
+	"github.com/hscells/groove/stats"
+	"testing"
+)
+
+// vectorSource has the same term vector for every document.
+type vectorSource struct {
+	stats.StatisticsSource
+	tv stats.TermVector
+}
+
+func (s vectorSource) TermVector(document string) (stats.TermVector, error) { return s.tv, nil }
+func (s vectorSource) VocabularySize(field string) (float64, error)         { return 10, nil }
+
+func TestLanguageModel(t *testing.T) {
+	s := vectorSource{tv: stats.TermVector{
+		{Term: "stroke", Field: "ti", TermFrequency: 2, TotalTermFrequency: 5},
+		{Term: "aspirin", Field: "ab", TermFrequency: 1, TotalTermFrequency: 5},
+	}}
+	lm, err := stats.NewLanguageModel(s, []string{"1", "2", "3"}, []float64{1, 1, 1}, "ti", stats.LanguageModelField("TI"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := lm.TermCount["stroke"]; c != 6 {
+		t.Errorf("expected the term to be counted twice in each document, got %f", c)
+	}
+	if p := lm.DocumentTermProbability("stroke"); p != 1 {
+		t.Errorf("expected the only term of the field to have a probability of one, got %f", p)
+	}
+	if _, ok := lm.TermCount["aspirin"]; ok {
+		t.Errorf("expected the terms of other fields not to be counted")
+	}
+}